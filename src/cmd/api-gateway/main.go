@@ -9,9 +9,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/galafis/go-data-api-microservices/internal/app"
 	"github.com/galafis/go-data-api-microservices/internal/config"
-	"github.com/galafis/go-data-api-microservices/internal/handlers"
 	"github.com/galafis/go-data-api-microservices/internal/middleware"
+	"github.com/galafis/go-data-api-microservices/internal/models"
 	"github.com/galafis/go-data-api-microservices/pkg/logger"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -51,6 +52,12 @@ func main() {
 		logger.Fatal("Failed to load configuration", err)
 	}
 
+	// Initialize application dependencies
+	application, err := app.New(cfg)
+	if err != nil {
+		logger.Fatal("Failed to initialize application", err)
+	}
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -87,51 +94,83 @@ func main() {
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// API v1 routes
+	authMiddleware := application.AuthMiddleware
+	canWrite := authMiddleware.RoleRequired(models.RoleAdmin, models.RoleUser)
 	v1 := router.Group("/api/v1")
 	{
 		// Auth routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register", handlers.Register)
-			auth.POST("/login", handlers.Login)
-			auth.POST("/refresh", handlers.RefreshToken)
-			auth.POST("/logout", middleware.AuthRequired(), handlers.Logout)
+			auth.POST("/register", application.AuthHandler.Register)
+			auth.POST("/login", application.AuthHandler.Login)
+			auth.POST("/refresh", application.AuthHandler.RefreshToken)
+			auth.POST("/logout", authMiddleware.AuthRequired(), application.AuthHandler.Logout)
 		}
 
 		// Data routes
 		data := v1.Group("/data")
-		data.Use(middleware.AuthRequired())
+		data.Use(authMiddleware.AuthRequired())
 		{
-			data.GET("/datasets", handlers.ListDatasets)
-			data.POST("/datasets", handlers.CreateDataset)
-			data.GET("/datasets/:id", handlers.GetDataset)
-			data.PUT("/datasets/:id", handlers.UpdateDataset)
-			data.DELETE("/datasets/:id", handlers.DeleteDataset)
+			data.GET("/datasets", application.DatasetHandler.ListDatasets)
+			data.POST("/datasets", canWrite, application.DatasetHandler.CreateDataset)
+			data.GET("/datasets/:id", application.DatasetHandler.GetDataset)
+			data.PUT("/datasets/:id", canWrite, application.DatasetHandler.UpdateDataset)
+			data.DELETE("/datasets/:id", canWrite, application.DatasetHandler.DeleteDataset)
+			data.POST("/datasets/:id/schema/check", application.DatasetHandler.CheckSchema)
+			data.POST("/datasets/:id/rows", canWrite, application.IngestHandler.IngestRows)
+			data.GET("/datasets/:id/export", application.QueryHandler.ExportDataset)
+			data.GET("/datasets/:id/versions", application.VersionHandler.ListVersions)
+			data.GET("/datasets/:id/versions/:version", application.VersionHandler.GetVersion)
+			data.GET("/datasets/:id/diff", application.VersionHandler.DiffVersions)
+			data.POST("/datasets/:id/rollback", canWrite, application.VersionHandler.Rollback)
 			
-			data.POST("/query", handlers.QueryData)
-			data.POST("/transform", handlers.TransformData)
-			data.POST("/aggregate", handlers.AggregateData)
-			data.POST("/join", handlers.JoinData)
+			data.POST("/schema/infer", application.IngestHandler.InferSchema)
+			data.POST("/query", application.QueryHandler.QueryData)
+			data.POST("/transform", canWrite, application.QueryHandler.TransformData)
+			data.POST("/aggregate", canWrite, application.QueryHandler.AggregateData)
+			data.POST("/join", canWrite, application.QueryHandler.JoinData)
 		}
 
 		// Analytics routes
 		analytics := v1.Group("/analytics")
-		analytics.Use(middleware.AuthRequired())
+		analytics.Use(authMiddleware.AuthRequired())
 		{
-			analytics.GET("/summary", handlers.GetDataSummary)
-			analytics.POST("/statistics", handlers.ComputeStatistics)
-			analytics.POST("/correlation", handlers.ComputeCorrelation)
-			analytics.POST("/timeseries", handlers.AnalyzeTimeSeries)
-			analytics.POST("/forecast", handlers.GenerateForecast)
+			analytics.GET("/summary", application.AnalyticsHandler.GetDataSummary)
+			analytics.POST("/statistics", application.AnalyticsHandler.ComputeStatistics)
+			analytics.POST("/correlation", application.AnalyticsHandler.ComputeCorrelation)
+			analytics.POST("/covariance", application.AnalyticsHandler.ComputeCovariance)
+			analytics.POST("/partial-correlation", application.AnalyticsHandler.ComputePartialCorrelation)
+			analytics.POST("/timeseries", application.AnalyticsHandler.AnalyzeTimeSeries)
+			analytics.POST("/timeseries/decompose", application.AnalyticsHandler.DecomposeTimeSeries)
+			analytics.POST("/timeseries/trend", application.AnalyticsHandler.AnalyzeTrend)
+			analytics.POST("/forecast", application.AnalyticsHandler.GenerateForecast)
+			analytics.POST("/hypothesis-test", application.AnalyticsHandler.RunHypothesisTest)
+			analytics.POST("/visualize/chart", application.AnalyticsHandler.RenderChart)
+			analytics.POST("/visualize/heatmap", application.AnalyticsHandler.RenderHeatmap)
+			analytics.POST("/regression", application.ModelHandler.FitRegression)
+
+			analytics.POST("/anomalies/detect", application.AnomalyHandler.DetectAnomalies)
+			analytics.GET("/anomalies/rules", application.AnomalyHandler.ListRules)
+			analytics.POST("/anomalies/rules", canWrite, application.AnomalyHandler.CreateRule)
+			analytics.GET("/anomalies/rules/:id", application.AnomalyHandler.GetRule)
+			analytics.PUT("/anomalies/rules/:id", canWrite, application.AnomalyHandler.UpdateRule)
+			analytics.DELETE("/anomalies/rules/:id", canWrite, application.AnomalyHandler.DeleteRule)
+			analytics.POST("/anomalies/rules/:id/run", application.AnomalyHandler.RunRule)
+
+			analytics.POST("/models/train", canWrite, application.ModelHandler.TrainModel)
+			analytics.POST("/models/predict", application.ModelHandler.Predict)
+			analytics.GET("/models/:id", application.ModelHandler.GetModel)
+			analytics.GET("/models/:id/metrics", application.ModelHandler.GetModelMetrics)
+			analytics.DELETE("/models/:id", canWrite, application.ModelHandler.DeleteModel)
 		}
 
 		// User routes
 		users := v1.Group("/users")
-		users.Use(middleware.AuthRequired())
+		users.Use(authMiddleware.AuthRequired())
 		{
-			users.GET("/me", handlers.GetCurrentUser)
-			users.PUT("/me", handlers.UpdateCurrentUser)
-			users.DELETE("/me", handlers.DeleteCurrentUser)
+			users.GET("/me", application.UserHandler.GetCurrentUser)
+			users.PUT("/me", application.UserHandler.UpdateCurrentUser)
+			users.DELETE("/me", application.UserHandler.DeleteCurrentUser)
 		}
 	}
 
@@ -164,6 +203,9 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown", err)
 	}
+	if err := application.Close(ctx); err != nil {
+		logger.Errorf("Error closing database connection: %v", err)
+	}
 
 	logger.Info("Server exited properly")
 }
@@ -0,0 +1,277 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// defaultAnomalyThresholds is the score threshold of each method when the
+// detection leaves it out
+var defaultAnomalyThresholds = map[models.AnomalyMethod]float64{
+	models.AnomalyZScore:   3,
+	models.AnomalyIQR:      1.5,
+	models.AnomalyMAD:      3.5,
+	models.AnomalySeasonal: 3,
+}
+
+// defaultAnomalyLimit caps the anomalies listed when params leave it out
+const defaultAnomalyLimit = 1000
+
+// Seasonal detection smooths the season over every season of any series, so
+// that it repeats unchanged, and the trend over anomalyTrendPeriods seasons:
+// smoothers short enough to follow the noise shrink the residual the scores
+// are scaled by.
+const (
+	anomalySeasonalSpan = 2*maxTimeSeriesBuckets + 1
+	anomalyTrendPeriods = 4
+)
+
+// AnomalyDetector is a validated anomaly detection
+type AnomalyDetector struct {
+	method    models.AnomalyMethod
+	threshold float64
+	limit     int
+
+	// field is the position of the checked field, when the detection checks
+	// rows rather than a time series
+	field  int
+	series *TimeSeries
+	// decomposition splits the series of seasonal detection
+	decomposition *Decomposition
+}
+
+// CompileAnomalyDetection validates an anomaly detection against the fields of
+// the dataset it runs on
+func CompileAnomalyDetection(detection *models.AnomalyDetection, fields []models.DataField) (*AnomalyDetector, error) {
+	threshold, ok := defaultAnomalyThresholds[detection.Method]
+	if !ok {
+		return nil, models.NewValidationError("unknown anomaly method %q", detection.Method)
+	}
+	if detection.Threshold != nil {
+		threshold = *detection.Threshold
+	}
+	if !(threshold > 0) || math.IsInf(threshold, 1) {
+		return nil, models.NewValidationError("anomaly threshold must be positive")
+	}
+
+	var params models.AnomalyParams
+	if err := query.DecodeParams(detection.Params, &params); err != nil {
+		return nil, models.NewValidationError("anomalies: %v", err)
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultAnomalyLimit
+	}
+	if params.Limit < 0 {
+		return nil, models.NewValidationError("anomalies: limit must be positive")
+	}
+	if params.Period != 0 && detection.Method != models.AnomalySeasonal {
+		return nil, models.NewValidationError("anomalies: period only applies to the seasonal method")
+	}
+
+	d := &AnomalyDetector{method: detection.Method, threshold: threshold, limit: params.Limit, field: -1}
+	switch {
+	case detection.Field != "" && detection.TimeSeries != nil:
+		return nil, models.NewValidationError("set either field or time_series, not both")
+	case detection.TimeSeries != nil:
+		if detection.Method == models.AnomalySeasonal {
+			decomposition, err := newDecomposition(detection.TimeSeries, models.DecompositionParams{
+				Period:   params.Period,
+				Seasonal: anomalySeasonalSpan,
+				Robust:   true,
+			}, fields)
+			if err != nil {
+				return nil, err
+			}
+			decomposition.params.Trend = nextOdd(anomalyTrendPeriods * decomposition.params.Period)
+			d.decomposition = decomposition
+			d.series = decomposition.series
+			return d, nil
+		}
+		series, err := CompileTimeSeries(detection.TimeSeries, fields)
+		if err != nil {
+			return nil, err
+		}
+		d.series = series
+	case detection.Method == models.AnomalySeasonal:
+		return nil, models.NewValidationError("the seasonal method needs a time_series")
+	case detection.Field != "":
+		d.field = fieldIndex(fields, detection.Field)
+		if d.field < 0 {
+			return nil, models.NewValidationError("unknown field %q", detection.Field)
+		}
+		if !isNumeric(fields[d.field].Type) {
+			return nil, models.NewValidationError("field %q is %s, not numeric", detection.Field, fields[d.field].Type)
+		}
+	default:
+		return nil, models.NewValidationError("either field or time_series is required")
+	}
+	return d, nil
+}
+
+// Compute scores the values of a frame and lists the anomalies among them,
+// rows in frame order for a field and buckets in series order for a time
+// series
+func (d *AnomalyDetector) Compute(frame *query.Frame) (*models.AnomalyResult, error) {
+	result := &models.AnomalyResult{
+		Method:    d.method,
+		Threshold: d.threshold,
+		Anomalies: []models.Anomaly{},
+	}
+
+	if d.series == nil {
+		result.Field = frame.Fields[d.field].Name
+		var rows []int
+		var values []float64
+		for r, value := range frame.Columns[d.field] {
+			if v, ok := query.ToFloat(value); ok && !math.IsNaN(v) {
+				rows = append(rows, r)
+				values = append(values, v)
+			}
+		}
+		scores, expected := d.score(values)
+		for i, r := range rows {
+			if d.flag(result, scores[i]) {
+				result.Anomalies = append(result.Anomalies, models.Anomaly{Row: frameRow(frame, r), Value: values[i], Expected: expected[i], Score: scores[i]})
+			}
+		}
+		result.Checked = len(values)
+		return result, nil
+	}
+
+	result.Field = d.series.req.ValueField
+	series, err := d.series.Series(frame)
+	if err != nil {
+		return nil, err
+	}
+	var decomposed []components
+	if d.decomposition != nil {
+		if decomposed, err = d.decomposition.decompose(series); err != nil {
+			return nil, err
+		}
+	}
+	for i, s := range series {
+		var times []time.Time
+		var values, scores, expected []float64
+		if decomposed != nil {
+			c := decomposed[i]
+			for t, v := range s.Values {
+				if !math.IsNaN(v) {
+					times = append(times, s.Times[t])
+					values = append(values, v)
+					expected = append(expected, c.trend[t]+c.seasonal[t])
+					scores = append(scores, c.residual[t])
+				}
+			}
+			scores = robustZScores(scores)
+		} else {
+			for t, v := range s.Values {
+				if !math.IsNaN(v) {
+					times = append(times, s.Times[t])
+					values = append(values, v)
+				}
+			}
+			scores, expected = d.score(values)
+		}
+
+		for k := range values {
+			if d.flag(result, scores[k]) {
+				result.Anomalies = append(result.Anomalies, models.Anomaly{
+					Timestamp: times[k].Format(time.RFC3339),
+					Group:     s.Group,
+					Value:     values[k],
+					Expected:  expected[k],
+					Score:     scores[k],
+				})
+			}
+		}
+		result.Checked += len(values)
+	}
+	return result, nil
+}
+
+// flag counts a score beyond the threshold and reports whether it still fits
+// in the listed anomalies
+func (d *AnomalyDetector) flag(result *models.AnomalyResult, score float64) bool {
+	if !(math.Abs(score) > d.threshold) {
+		return false
+	}
+	result.Flagged++
+	return len(result.Anomalies) < d.limit
+}
+
+// score scores values with the zscore, iqr or mad method, returning each
+// score with the value it is measured from. Scores are NaN when the values have
+// no spread.
+func (d *AnomalyDetector) score(values []float64) (scores, expected []float64) {
+	scores = make([]float64, len(values))
+	expected = make([]float64, len(values))
+	sorted := Sorted(values)
+
+	switch d.method {
+	case models.AnomalyZScore:
+		mean, sd := Mean(values), StdDev(values)
+		for i, v := range values {
+			expected[i] = mean
+			scores[i] = spreadScore(v-mean, sd)
+		}
+	case models.AnomalyIQR:
+		q1, median, q3 := Quantile(sorted, 0.25), Median(sorted), Quantile(sorted, 0.75)
+		for i, v := range values {
+			expected[i] = median
+			switch {
+			case v > q3:
+				scores[i] = spreadScore(v-q3, q3-q1)
+			case v < q1:
+				scores[i] = spreadScore(v-q1, q3-q1)
+			default:
+				scores[i] = spreadScore(0, q3-q1)
+			}
+		}
+	case models.AnomalyMAD:
+		// 0.6745 is the third quartile of the standard normal, so that the
+		// modified z-score of Iglewicz and Hoaglin matches the z-score of
+		// normal values
+		median, mad := medianAbsoluteDeviation(sorted)
+		for i, v := range values {
+			expected[i] = median
+			scores[i] = spreadScore(0.6745*(v-median), mad)
+		}
+	}
+	return scores, expected
+}
+
+// robustZScores scores values around their median in units of their median
+// absolute deviation scaled to a normal standard deviation
+func robustZScores(values []float64) []float64 {
+	median, mad := medianAbsoluteDeviation(Sorted(values))
+	scores := make([]float64, len(values))
+	for i, v := range values {
+		scores[i] = spreadScore(v-median, 1.4826*mad)
+	}
+	return scores
+}
+
+// medianAbsoluteDeviation returns the median of ascending numbers and the
+// median of their absolute deviations from it
+func medianAbsoluteDeviation(sorted []float64) (median, mad float64) {
+	median = Median(sorted)
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, Median(deviations)
+}
+
+// spreadScore divides a deviation by a spread, or returns NaN when there is
+// no spread
+func spreadScore(deviation, spread float64) float64 {
+	if !(spread > 0) {
+		return math.NaN()
+	}
+	return deviation / spread
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func detectAnomalies(t *testing.T, detection *models.AnomalyDetection, amounts []float64) *models.AnomalyResult {
+	detector, err := CompileAnomalyDetection(detection, salesSeriesFields)
+	require.NoError(t, err)
+	result, err := detector.Compute(dailyFrame(amounts))
+	require.NoError(t, err)
+	return result
+}
+
+func TestAnomalyDetection(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	amounts := make([]float64, 60)
+	for i := range amounts {
+		amounts[i] = 100 + rng.NormFloat64()
+	}
+	amounts[25] = 130
+
+	// Test Case 1: Every method flags the outlying row of a field
+	for _, method := range []models.AnomalyMethod{models.AnomalyZScore, models.AnomalyIQR, models.AnomalyMAD} {
+		method := method
+		t.Run(string(method), func(t *testing.T) {
+			result := detectAnomalies(t, &models.AnomalyDetection{Method: method, Field: "amount"}, amounts)
+
+			assert.Equal(t, "amount", result.Field)
+			assert.Equal(t, defaultAnomalyThresholds[method], result.Threshold)
+			assert.Equal(t, 60, result.Checked)
+			require.Equal(t, 1, result.Flagged)
+			anomaly := result.Anomalies[0]
+			assert.Equal(t, 130.0, anomaly.Value)
+			assert.Equal(t, 130.0, anomaly.Row["amount"])
+			assert.Greater(t, anomaly.Score, result.Threshold)
+		})
+	}
+
+	// Test Case 2: Z-scores are measured from the mean in standard deviations
+	t.Run("Z-Score Values", func(t *testing.T) {
+		threshold := 1.0
+		result := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalyZScore, Field: "amount", Threshold: &threshold}, []float64{1, 2, 3, 4, 10})
+
+		require.Len(t, result.Anomalies, 1)
+		assert.Equal(t, 4.0, result.Anomalies[0].Expected)
+		assert.InDelta(t, 6/StdDev([]float64{1, 2, 3, 4, 10}), result.Anomalies[0].Score, 1e-9)
+	})
+
+	// Test Case 3: Values without spread have no anomalies
+	t.Run("No Spread", func(t *testing.T) {
+		result := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalyMAD, Field: "amount"}, []float64{5, 5, 5, 5, 9})
+
+		assert.Equal(t, 5, result.Checked)
+		assert.Equal(t, 0, result.Flagged)
+		assert.Empty(t, result.Anomalies)
+	})
+
+	// Test Case 4: The limit caps the anomalies listed but not the count
+	t.Run("Limit", func(t *testing.T) {
+		spiked := append([]float64(nil), amounts...)
+		spiked[10], spiked[40] = 70, 140
+		result := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalyMAD, Field: "amount", Params: map[string]interface{}{"limit": 2}}, spiked)
+
+		assert.Equal(t, 3, result.Flagged)
+		require.Len(t, result.Anomalies, 2)
+		assert.Equal(t, 70.0, result.Anomalies[0].Value)
+		assert.Less(t, result.Anomalies[0].Score, 0.0)
+		assert.Equal(t, 130.0, result.Anomalies[1].Value)
+	})
+
+	// Test Case 5: Time series buckets are scored per series
+	t.Run("Time Series", func(t *testing.T) {
+		series := salesSeriesRequest()
+		result := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalyZScore, TimeSeries: &series}, amounts)
+
+		assert.Equal(t, "amount", result.Field)
+		require.Len(t, result.Anomalies, 1)
+		assert.Equal(t, "2024-01-26T00:00:00Z", result.Anomalies[0].Timestamp)
+		assert.Nil(t, result.Anomalies[0].Row)
+	})
+
+	// Test Case 6: Seasonal detection flags a value that is only unusual for its season
+	t.Run("Seasonal", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(3))
+		season := []float64{30, 10, -10, -40, -20, 0, 30}
+		seasonal := make([]float64, 112)
+		for i := range seasonal {
+			seasonal[i] = 100 + season[i%7] + rng.NormFloat64()
+		}
+		// Within the range of the series but far below its season
+		seasonal[21] = 95
+		series := salesSeriesRequest()
+
+		plain := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalyZScore, TimeSeries: &series}, seasonal)
+		assert.Equal(t, 0, plain.Flagged)
+
+		result := detectAnomalies(t, &models.AnomalyDetection{Method: models.AnomalySeasonal, TimeSeries: &series}, seasonal)
+		require.NotEmpty(t, result.Anomalies)
+		anomaly := result.Anomalies[0]
+		assert.Equal(t, "2024-01-22T00:00:00Z", anomaly.Timestamp)
+		assert.Equal(t, 95.0, anomaly.Value)
+		assert.InDelta(t, 130, anomaly.Expected, 2)
+		assert.Less(t, anomaly.Score, -20.0)
+	})
+
+	// Test Case 7: Invalid detections are rejected with validation errors
+	series := salesSeriesRequest()
+	negative := -1.0
+	invalid := map[string]*models.AnomalyDetection{
+		"Unknown Method":      {Method: "grubbs", Field: "amount"},
+		"No Target":           {Method: models.AnomalyZScore},
+		"Field And Series":    {Method: models.AnomalyZScore, Field: "amount", TimeSeries: &series},
+		"Unknown Field":       {Method: models.AnomalyZScore, Field: "price"},
+		"Datetime Field":      {Method: models.AnomalyIQR, Field: "day"},
+		"Seasonal Field":      {Method: models.AnomalySeasonal, Field: "amount"},
+		"Negative Threshold":  {Method: models.AnomalyMAD, Field: "amount", Threshold: &negative},
+		"Period Not Seasonal": {Method: models.AnomalyZScore, Field: "amount", Params: map[string]interface{}{"period": 7}},
+		"Unknown Param":       {Method: models.AnomalyZScore, Field: "amount", Params: map[string]interface{}{"window": 7}},
+	}
+	for name, detection := range invalid {
+		detection := detection
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileAnomalyDetection(detection, salesSeriesFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
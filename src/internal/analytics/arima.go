@@ -0,0 +1,252 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// ARIMA order bounds and defaults
+const (
+	maxARIMAOrder       = 10
+	maxARIMADifferences = 2
+	defaultARIMAOrder   = 1
+)
+
+// arima is an ARIMA(p,d,q) model. The series is differenced d times and the
+// ARMA(p,q) coefficients are estimated by conditional sum of squares, with the
+// mean of the differenced series as constant. Coefficients are searched through
+// their partial autocorrelations, which keeps the model stationary and
+// invertible.
+type arima struct {
+	p, d, q int
+
+	ar, ma    []float64
+	mean      float64
+	sigma     float64
+	history   []float64
+	residuals []float64
+}
+
+// newARIMA returns an unfitted ARIMA model for checked params
+func newARIMA(p *models.ForecastParams) *arima {
+	order := func(v *int) int {
+		if v == nil {
+			return defaultARIMAOrder
+		}
+		return *v
+	}
+	return &arima{p: order(p.P), d: order(p.D), q: order(p.Q)}
+}
+
+// checkARIMAParams validates the orders of ARIMA forecasts
+func checkARIMAParams(p *models.ForecastParams) error {
+	if p.P != nil && (*p.P < 0 || *p.P > maxARIMAOrder) {
+		return fmt.Errorf("p must be between 0 and %d", maxARIMAOrder)
+	}
+	if p.D != nil && (*p.D < 0 || *p.D > maxARIMADifferences) {
+		return fmt.Errorf("d must be between 0 and %d", maxARIMADifferences)
+	}
+	if p.Q != nil && (*p.Q < 0 || *p.Q > maxARIMAOrder) {
+		return fmt.Errorf("q must be between 0 and %d", maxARIMAOrder)
+	}
+	return nil
+}
+
+func (m *arima) minPoints() int {
+	return m.d + m.p + m.q + 3
+}
+
+func (m *arima) fit(times []time.Time, y []float64) error {
+	m.history = append([]float64(nil), y...)
+	w := difference(y, m.d)
+	m.mean = Mean(w)
+	centered := make([]float64, len(w))
+	for i := range w {
+		centered[i] = w[i] - m.mean
+	}
+
+	start := make([]float64, m.p+m.q)
+	best := minimize(func(x []float64) float64 {
+		ar, ma := m.coefficients(x)
+		sse, _ := armaResiduals(centered, ar, ma)
+		return sse
+	}, start, 200*(m.p+m.q+1))
+
+	m.ar, m.ma = m.coefficients(best)
+	sse, residuals := armaResiduals(centered, m.ar, m.ma)
+	m.residuals = residuals
+	dof := len(w) - m.p - m.q - 1
+	if dof < 1 {
+		dof = 1
+	}
+	m.sigma = math.Sqrt(sse / float64(dof))
+	return nil
+}
+
+// coefficients maps unconstrained search values to AR and MA coefficients
+func (m *arima) coefficients(x []float64) ([]float64, []float64) {
+	ar := fromPartial(x[:m.p])
+	ma := fromPartial(x[m.p:])
+	// The MA polynomial is 1 + θ₁B + ..., so its coefficients change sign
+	for i := range ma {
+		ma[i] = -ma[i]
+	}
+	return ar, ma
+}
+
+// fromPartial turns unconstrained values into the coefficients of a stationary
+// autoregression: each value is mapped to a partial autocorrelation in (-1, 1)
+// and the Durbin-Levinson recursion builds the coefficients from them
+func fromPartial(x []float64) []float64 {
+	phi := make([]float64, 0, len(x))
+	for k, v := range x {
+		r := math.Tanh(v)
+		next := make([]float64, k+1)
+		for j := 0; j < k; j++ {
+			next[j] = phi[j] - r*phi[k-1-j]
+		}
+		next[k] = r
+		phi = next
+	}
+	return phi
+}
+
+// armaResiduals returns the one-step residuals of an ARMA model over a centered
+// series, conditioning on the first len(ar) values, and their sum of squares
+func armaResiduals(w, ar, ma []float64) (float64, []float64) {
+	residuals := make([]float64, len(w))
+	var sse float64
+	for t := len(ar); t < len(w); t++ {
+		e := w[t]
+		for i, phi := range ar {
+			e -= phi * w[t-1-i]
+		}
+		for j, theta := range ma {
+			if t-1-j >= 0 {
+				e -= theta * residuals[t-1-j]
+			}
+		}
+		residuals[t] = e
+		sse += e * e
+	}
+	return sse, residuals
+}
+
+// difference applies d lag-one differences to a series
+func difference(y []float64, d int) []float64 {
+	w := append([]float64(nil), y...)
+	for k := 0; k < d; k++ {
+		for i := len(w) - 1; i > 0; i-- {
+			w[i] -= w[i-1]
+		}
+		w = w[1:]
+	}
+	return w
+}
+
+// forecast runs the ARMA recursion forward with future shocks at zero and
+// integrates the result. Standard errors come from the psi weights of the
+// integrated model.
+func (m *arima) forecast(future []time.Time, confidence float64) ([]float64, []float64, []float64) {
+	horizon := len(future)
+	w := difference(m.history, m.d)
+	for i := range w {
+		w[i] -= m.mean
+	}
+	e := append([]float64(nil), m.residuals...)
+
+	// Forecast the centered differences
+	for h := 0; h < horizon; h++ {
+		t := len(w)
+		next := 0.0
+		for i, phi := range m.ar {
+			if t-1-i >= 0 {
+				next += phi * w[t-1-i]
+			}
+		}
+		for j, theta := range m.ma {
+			if t-1-j >= 0 {
+				next += theta * e[t-1-j]
+			}
+		}
+		w = append(w, next)
+		e = append(e, 0)
+	}
+	differences := w[len(w)-horizon:]
+	for i := range differences {
+		differences[i] += m.mean
+	}
+
+	// Undo the differencing, one order at a time
+	mean := append([]float64(nil), differences...)
+	for k := m.d; k > 0; k-- {
+		level := difference(m.history, k-1)
+		last := level[len(level)-1]
+		for h := range mean {
+			last += mean[h]
+			mean[h] = last
+		}
+	}
+
+	// Psi weights of φ(B)(1-B)^d ψ(B) = θ(B)
+	poly := arPolynomial(m.ar, m.d)
+	psi := make([]float64, horizon)
+	stderr := make([]float64, horizon)
+	var sum float64
+	for j := 0; j < horizon; j++ {
+		if j == 0 {
+			psi[0] = 1
+		} else {
+			if j-1 < len(m.ma) {
+				psi[j] = m.ma[j-1]
+			}
+			for i := 1; i < len(poly) && i <= j; i++ {
+				psi[j] += poly[i] * psi[j-i]
+			}
+		}
+		sum += psi[j] * psi[j]
+		stderr[j] = m.sigma * math.Sqrt(sum)
+	}
+
+	lower, upper := normalBounds(mean, stderr, confidence)
+	return mean, lower, upper
+}
+
+// arPolynomial returns the coefficients c of the autoregressive polynomial of
+// the integrated model written as y_t = c₁y_{t-1} + c₂y_{t-2} + ..., with c₀
+// unused
+func arPolynomial(ar []float64, d int) []float64 {
+	// 1 - φ₁B - φ₂B² - ...
+	poly := make([]float64, len(ar)+1)
+	poly[0] = 1
+	for i, phi := range ar {
+		poly[i+1] = -phi
+	}
+	// Multiply by (1 - B) d times
+	for k := 0; k < d; k++ {
+		next := make([]float64, len(poly)+1)
+		for i, c := range poly {
+			next[i] += c
+			next[i+1] -= c
+		}
+		poly = next
+	}
+	for i := 1; i < len(poly); i++ {
+		poly[i] = -poly[i]
+	}
+	return poly
+}
+
+func (m *arima) parameters() map[string]float64 {
+	params := map[string]float64{"p": float64(m.p), "d": float64(m.d), "q": float64(m.q), "sigma": m.sigma, "constant": m.mean}
+	for i, phi := range m.ar {
+		params[fmt.Sprintf("ar%d", i+1)] = phi
+	}
+	for j, theta := range m.ma {
+		params[fmt.Sprintf("ma%d", j+1)] = theta
+	}
+	return params
+}
@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Calendar splits time into the buckets of an interval, following the wall
+// clock of a location. Minute and hour buckets are fixed durations; day and
+// longer buckets start at local midnight, so a day across a daylight saving
+// change lasts 23 or 25 hours. Weeks start on Monday.
+type Calendar struct {
+	interval models.TimeSeriesInterval
+	loc      *time.Location
+}
+
+// NewCalendar returns the calendar of an interval in a location
+func NewCalendar(interval models.TimeSeriesInterval, loc *time.Location) (*Calendar, error) {
+	switch interval {
+	case models.TimeSeriesMinute, models.TimeSeriesHour, models.TimeSeriesDay, models.TimeSeriesWeek,
+		models.TimeSeriesMonth, models.TimeSeriesQuarter, models.TimeSeriesYear:
+	default:
+		return nil, models.NewValidationError("unknown interval %q", interval)
+	}
+	return &Calendar{interval: interval, loc: loc}, nil
+}
+
+// LoadLocation resolves an IANA time zone name, treating an empty name as UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, models.NewValidationError("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// Location returns the location of the calendar
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// Truncate returns the start of the bucket holding t
+func (c *Calendar) Truncate(t time.Time) time.Time {
+	t = t.In(c.loc)
+	switch c.interval {
+	case models.TimeSeriesMinute:
+		return t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case models.TimeSeriesHour:
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	}
+
+	year, month, day := t.Date()
+	switch c.interval {
+	case models.TimeSeriesWeek:
+		day -= (int(t.Weekday()) + 6) % 7
+	case models.TimeSeriesMonth:
+		day = 1
+	case models.TimeSeriesQuarter:
+		month, day = (month-1)/3*3+1, 1
+	case models.TimeSeriesYear:
+		month, day = time.January, 1
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, c.loc)
+}
+
+// Next returns the start of the bucket following the one starting at start
+func (c *Calendar) Next(start time.Time) time.Time {
+	return c.Add(start, 1)
+}
+
+// Add returns the start of the bucket n buckets after the one starting at start
+func (c *Calendar) Add(start time.Time, n int) time.Time {
+	switch c.interval {
+	case models.TimeSeriesMinute:
+		return start.Add(time.Duration(n) * time.Minute)
+	case models.TimeSeriesHour:
+		return start.Add(time.Duration(n) * time.Hour)
+	}
+
+	start = start.In(c.loc)
+	year, month, day := start.Date()
+	switch c.interval {
+	case models.TimeSeriesDay:
+		day += n
+	case models.TimeSeriesWeek:
+		day += 7 * n
+	case models.TimeSeriesMonth:
+		month += time.Month(n)
+	case models.TimeSeriesQuarter:
+		month += time.Month(3 * n)
+	case models.TimeSeriesYear:
+		year += n
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, c.loc)
+}
@@ -0,0 +1,213 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Coefficient is a correlation coefficient with the two-sided p-value of the
+// test that the true correlation is zero. Both are NaN when the correlation is
+// undefined, such as for constant samples or fewer than three pairs.
+type Coefficient struct {
+	R float64
+	P float64
+}
+
+// undefined is the coefficient of samples without a defined correlation
+var undefined = Coefficient{R: math.NaN(), P: math.NaN()}
+
+// Pearson returns the linear correlation of paired samples, tested with a t
+// statistic on n-2 degrees of freedom
+func Pearson(x, y []float64) Coefficient {
+	n := len(x)
+	if n < 3 {
+		return undefined
+	}
+
+	mx, my := Mean(x), Mean(y)
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return undefined
+	}
+
+	r := math.Max(-1, math.Min(1, sxy/math.Sqrt(sxx*syy)))
+	return Coefficient{R: r, P: correlationPValue(r, n)}
+}
+
+// Spearman returns the rank correlation of paired samples, the Pearson
+// correlation of their ranks with ties given their average rank; the p-value
+// uses the same t approximation as Pearson
+func Spearman(x, y []float64) Coefficient {
+	return Pearson(Ranks(x), Ranks(y))
+}
+
+// correlationPValue tests a correlation of n pairs against zero
+func correlationPValue(r float64, n int) float64 {
+	df := float64(n - 2)
+	if math.Abs(r) == 1 {
+		return 0
+	}
+	t := r * math.Sqrt(df/(1-r*r))
+	return TwoSidedTPValue(t, df)
+}
+
+// Ranks returns the 1-based ranks of numbers, tied values sharing the average
+// of the ranks they span
+func Ranks(numbers []float64) []float64 {
+	order := make([]int, len(numbers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return numbers[order[a]] < numbers[order[b]] })
+
+	ranks := make([]float64, len(numbers))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && numbers[order[end]] == numbers[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			ranks[i] = rank
+		}
+		start = end
+	}
+	return ranks
+}
+
+// Kendall returns Kendall's tau-b of paired samples, computed in O(n log n)
+// with Knight's algorithm. The p-value uses the normal approximation of the
+// concordance score with the variance corrected for ties.
+func Kendall(x, y []float64) Coefficient {
+	n := len(x)
+	if n < 3 {
+		return undefined
+	}
+
+	// Order pairs by x, then y
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if x[i] != x[j] {
+			return x[i] < x[j]
+		}
+		return y[i] < y[j]
+	})
+	xs := make([]float64, n)
+	ys := make([]float64, n)
+	for k, i := range order {
+		xs[k], ys[k] = x[i], y[i]
+	}
+
+	// Pairs tied on x, and on both x and y
+	var jointTies float64
+	for start := 0; start < n; {
+		end := start + 1
+		for end < n && xs[end] == xs[start] && ys[end] == ys[start] {
+			end++
+		}
+		jointTies += pairs(end - start)
+		start = end
+	}
+	xTies := tieGroups(xs)
+
+	// Sorting y by merge sort counts the discordant pairs as swaps
+	swaps := mergeCount(ys, make([]float64, n))
+	yTies := tieGroups(ys)
+
+	total := pairs(n)
+	var tiedX, tiedY float64
+	for _, t := range xTies {
+		tiedX += pairs(t)
+	}
+	for _, u := range yTies {
+		tiedY += pairs(u)
+	}
+	if tiedX == total || tiedY == total {
+		return undefined
+	}
+
+	score := total - tiedX - tiedY + jointTies - 2*swaps
+	tau := score / math.Sqrt((total-tiedX)*(total-tiedY))
+
+	// Variance of the score under independence
+	fn := float64(n)
+	v0 := fn * (fn - 1) * (2*fn + 5)
+	var vt, vu, t1, u1, t2, u2 float64
+	for _, t := range xTies {
+		ft := float64(t)
+		vt += ft * (ft - 1) * (2*ft + 5)
+		t1 += ft * (ft - 1)
+		t2 += ft * (ft - 1) * (ft - 2)
+	}
+	for _, u := range yTies {
+		fu := float64(u)
+		vu += fu * (fu - 1) * (2*fu + 5)
+		u1 += fu * (fu - 1)
+		u2 += fu * (fu - 1) * (fu - 2)
+	}
+	variance := (v0-vt-vu)/18 + t1*u1/(2*fn*(fn-1)) + t2*u2/(9*fn*(fn-1)*(fn-2))
+
+	return Coefficient{
+		R: math.Max(-1, math.Min(1, tau)),
+		P: TwoSidedNormalPValue(score / math.Sqrt(variance)),
+	}
+}
+
+// pairs returns the number of unordered pairs among n items
+func pairs(n int) float64 {
+	return float64(n) * float64(n-1) / 2
+}
+
+// tieGroups returns the sizes of the runs of equal values of sorted numbers
+// that hold more than one value
+func tieGroups(sorted []float64) []int {
+	var groups []int
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[end] == sorted[start] {
+			end++
+		}
+		if end-start > 1 {
+			groups = append(groups, end-start)
+		}
+		start = end
+	}
+	return groups
+}
+
+// mergeCount sorts numbers in place and returns the number of inversions, pairs
+// whose order the sort had to reverse; equal values are not inversions
+func mergeCount(numbers, buffer []float64) float64 {
+	if len(numbers) < 2 {
+		return 0
+	}
+	mid := len(numbers) / 2
+	swaps := mergeCount(numbers[:mid], buffer[:mid]) + mergeCount(numbers[mid:], buffer[mid:])
+
+	merged := buffer[:0]
+	i, j := 0, mid
+	for i < mid && j < len(numbers) {
+		if numbers[j] < numbers[i] {
+			merged = append(merged, numbers[j])
+			swaps += float64(mid - i)
+			j++
+		} else {
+			merged = append(merged, numbers[i])
+			i++
+		}
+	}
+	merged = append(merged, numbers[i:mid]...)
+	merged = append(merged, numbers[j:]...)
+	copy(numbers, merged)
+	return swaps
+}
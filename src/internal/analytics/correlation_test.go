@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistributions(t *testing.T) {
+	// Test Case 1: Quantiles match the published tables
+	t.Run("Quantiles", func(t *testing.T) {
+		assert.InDelta(t, 1.959964, NormalQuantile(0.975), 1e-6)
+		assert.InDelta(t, 2.228139, StudentTQuantile(0.975, 10), 1e-6)
+		assert.InDelta(t, -2.228139, StudentTQuantile(0.025, 10), 1e-6)
+	})
+
+	// Test Case 2: Distribution functions match the published tables
+	t.Run("CDF", func(t *testing.T) {
+		assert.InDelta(t, 0.975, NormalCDF(1.959964), 1e-6)
+		assert.InDelta(t, 0.95, ChiSquareCDF(3.841459, 1), 1e-6)
+		assert.InDelta(t, 0.95, ChiSquareCDF(5.991465, 2), 1e-6)
+		assert.InDelta(t, 0.95, FCDF(4.102821, 2, 10), 1e-6)
+		assert.InDelta(t, 0.05, TwoSidedTPValue(2.228139, 10), 1e-6)
+	})
+}
+
+func TestCorrelation(t *testing.T) {
+	// Test Case 1: Pearson
+	t.Run("Pearson", func(t *testing.T) {
+		c := Pearson([]float64{1, 2, 3, 4, 5}, []float64{10, 9, 2.5, 6, 4})
+		assert.InDelta(t, -0.7426106572325057, c.R, 1e-12)
+		assert.InDelta(t, 0.1505558088534455, c.P, 1e-9)
+	})
+
+	// Test Case 2: Spearman ranks ties by their average
+	t.Run("Spearman", func(t *testing.T) {
+		assert.Equal(t, []float64{1, 2, 3, 4.5, 4.5}, Ranks([]float64{5, 6, 7, 8, 8}))
+
+		c := Spearman([]float64{1, 2, 3, 4, 5}, []float64{5, 6, 7, 8, 7})
+		assert.InDelta(t, 0.8207826816681233, c.R, 1e-12)
+		assert.InDelta(t, 0.08858700531354381, c.P, 1e-9)
+	})
+
+	// Test Case 3: Kendall tau-b corrects for ties on both sides
+	t.Run("Kendall", func(t *testing.T) {
+		c := Kendall([]float64{12, 2, 1, 12, 2}, []float64{1, 4, 7, 1, 0})
+		assert.InDelta(t, -0.47140452079103173, c.R, 1e-12)
+		assert.InDelta(t, 0.2827454599327748, c.P, 1e-9)
+
+		c = Kendall([]float64{1, 2, 3, 4}, []float64{4, 3, 2, 1})
+		assert.Equal(t, -1.0, c.R)
+	})
+
+	// Test Case 4: Constant samples have no correlation
+	t.Run("Undefined", func(t *testing.T) {
+		for _, c := range []Coefficient{
+			Pearson([]float64{1, 2, 3}, []float64{4, 4, 4}),
+			Spearman([]float64{1, 2}, []float64{3, 4}),
+			Kendall([]float64{1, 1, 1}, []float64{1, 2, 3}),
+		} {
+			assert.True(t, math.IsNaN(c.R))
+			assert.True(t, math.IsNaN(c.P))
+		}
+	})
+}
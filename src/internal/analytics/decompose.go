@@ -0,0 +1,187 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// defaultPeriods is the season of each interval, in buckets, when params
+// leave it out
+var defaultPeriods = map[models.TimeSeriesInterval]int{
+	models.TimeSeriesMinute:  60,
+	models.TimeSeriesHour:    24,
+	models.TimeSeriesDay:     7,
+	models.TimeSeriesWeek:    52,
+	models.TimeSeriesMonth:   12,
+	models.TimeSeriesQuarter: 4,
+}
+
+// defaultSeasonalSpan is the span of the STL seasonal smoother when params
+// leave it out
+const defaultSeasonalSpan = 7
+
+// Decomposition is a validated seasonal decomposition request
+type Decomposition struct {
+	series *TimeSeries
+	params models.DecompositionParams
+}
+
+// CompileDecomposition validates a decomposition request against the fields of
+// the dataset. Buckets without rows are kept as gaps, so that seasons line up,
+// unless the request fills them.
+func CompileDecomposition(req *models.DecompositionRequest, fields []models.DataField) (*Decomposition, error) {
+	var params models.DecompositionParams
+	if err := query.DecodeParams(req.Params, &params); err != nil {
+		return nil, models.NewValidationError("decomposition: %v", err)
+	}
+	return newDecomposition(&req.TimeSeriesRequest, params, fields)
+}
+
+// newDecomposition validates the series and the decoded params of a
+// decomposition
+func newDecomposition(req *models.TimeSeriesRequest, params models.DecompositionParams, fields []models.DataField) (*Decomposition, error) {
+	d := &Decomposition{params: params}
+	tsReq := *req
+	if tsReq.Fill == "" || tsReq.Fill == models.TimeSeriesFillNone {
+		tsReq.Fill = models.TimeSeriesFillNull
+	}
+	series, err := CompileTimeSeries(&tsReq, fields)
+	if err != nil {
+		return nil, err
+	}
+	d.series = series
+
+	if err := d.checkParams(req.Interval); err != nil {
+		return nil, models.NewValidationError("decomposition: %v", err)
+	}
+	return d, nil
+}
+
+// checkParams validates the params and fills in their defaults
+func (d *Decomposition) checkParams(interval models.TimeSeriesInterval) error {
+	p := &d.params
+	if p.Period == 0 {
+		p.Period = defaultPeriods[interval]
+		if p.Period == 0 {
+			return fmt.Errorf("period is required for %s buckets", interval)
+		}
+	}
+	if p.Period < 2 || p.Period > maxTimeSeriesBuckets/2 {
+		return fmt.Errorf("period must be between 2 and %d", maxTimeSeriesBuckets/2)
+	}
+	if p.Seasonal == 0 {
+		p.Seasonal = defaultSeasonalSpan
+	}
+	if p.Seasonal < 3 || p.Seasonal%2 == 0 {
+		return fmt.Errorf("seasonal must be an odd number of at least 3")
+	}
+	if p.Trend == 0 {
+		p.Trend = nextOdd(int(math.Ceil(1.5 * float64(p.Period) / (1 - 1.5/float64(p.Seasonal)))))
+	}
+	if p.Trend < 3 || p.Trend%2 == 0 {
+		return fmt.Errorf("trend must be an odd number of at least 3")
+	}
+	return nil
+}
+
+// components is the decomposition of one series
+type components struct {
+	Series
+	trend, seasonal, residual []float64
+}
+
+// Compute decomposes each series of a frame with the fields the request was
+// compiled for
+func (d *Decomposition) Compute(frame *query.Frame) (*models.DecompositionResult, error) {
+	series, err := d.series.Series(frame)
+	if err != nil {
+		return nil, err
+	}
+	decomposed, err := d.decompose(series)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.DecompositionResult{
+		TimeSeriesResult: *d.series.result(series),
+		Period:           d.params.Period,
+		Trend:            []models.TimeSeriesPoint{},
+		Seasonal:         []models.TimeSeriesPoint{},
+		Residual:         []models.TimeSeriesPoint{},
+	}
+	for _, c := range decomposed {
+		result.Trend = append(result.Trend, Series{Group: c.Group, Times: c.Times, Values: c.trend}.Points()...)
+		result.Seasonal = append(result.Seasonal, Series{Group: c.Group, Times: c.Times, Values: c.seasonal}.Points()...)
+		result.Residual = append(result.Residual, Series{Group: c.Group, Times: c.Times, Values: c.residual}.Points()...)
+	}
+	return result, nil
+}
+
+// decompose splits each series into its components. Gaps are interpolated
+// linearly for the decomposition and left out of the residual; each series
+// needs two full seasons.
+func (d *Decomposition) decompose(series []Series) ([]components, error) {
+	decomposed := make([]components, len(series))
+	for i, s := range series {
+		if len(s.Values) < 2*d.params.Period {
+			return nil, models.NewValidationError("%s has %d %s buckets; a period of %d needs at least %d",
+				seriesName(s), len(s.Values), d.series.req.Interval, d.params.Period, 2*d.params.Period)
+		}
+		y, ok := interpolate(s.Values)
+		if !ok {
+			return nil, models.NewValidationError("%s has no values", seriesName(s))
+		}
+
+		trend, seasonal, residual := STL(y, d.params.Period, d.params.Seasonal, d.params.Trend, d.params.Robust)
+		for t, v := range s.Values {
+			if math.IsNaN(v) {
+				residual[t] = math.NaN()
+			}
+		}
+		decomposed[i] = components{Series: s, trend: trend, seasonal: seasonal, residual: residual}
+	}
+	return decomposed, nil
+}
+
+// seriesName names a series in error messages
+func seriesName(s Series) string {
+	if s.Group == "" {
+		return "time series"
+	}
+	return fmt.Sprintf("time series %q", s.Group)
+}
+
+// interpolate fills the NaN values of a series linearly between their
+// neighbours, repeating the first and last values at the ends. It reports
+// false when the series has no values.
+func interpolate(values []float64) ([]float64, bool) {
+	out := append([]float64(nil), values...)
+	previous := -1
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		switch {
+		case previous < 0:
+			for j := 0; j < i; j++ {
+				out[j] = v
+			}
+		case i-previous > 1:
+			step := (v - values[previous]) / float64(i-previous)
+			for j := previous + 1; j < i; j++ {
+				out[j] = values[previous] + step*float64(j-previous)
+			}
+		}
+		previous = i
+	}
+	if previous < 0 {
+		return nil, false
+	}
+	for j := previous + 1; j < len(out); j++ {
+		out[j] = values[previous]
+	}
+	return out, true
+}
@@ -0,0 +1,227 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Sorted returns an ascending copy of numbers
+func Sorted(numbers []float64) []float64 {
+	sorted := append([]float64(nil), numbers...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// Sum returns the sum of numbers
+func Sum(numbers []float64) float64 {
+	var sum float64
+	for _, x := range numbers {
+		sum += x
+	}
+	return sum
+}
+
+// Mean returns the arithmetic mean of numbers, or NaN when there are none
+func Mean(numbers []float64) float64 {
+	if len(numbers) == 0 {
+		return math.NaN()
+	}
+	return Sum(numbers) / float64(len(numbers))
+}
+
+// Variance returns the sample variance of numbers, or NaN with fewer than two
+func Variance(numbers []float64) float64 {
+	if len(numbers) < 2 {
+		return math.NaN()
+	}
+	m := Mean(numbers)
+	var squares float64
+	for _, x := range numbers {
+		squares += (x - m) * (x - m)
+	}
+	return squares / float64(len(numbers)-1)
+}
+
+// StdDev returns the sample standard deviation of numbers, or NaN with fewer than two
+func StdDev(numbers []float64) float64 {
+	return math.Sqrt(Variance(numbers))
+}
+
+// Quantile returns the p-quantile (0-1) of ascending numbers, linearly
+// interpolating between the closest ranks, or NaN when there are none
+func Quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Median returns the median of ascending numbers, or NaN when there are none
+func Median(sorted []float64) float64 {
+	return Quantile(sorted, 0.5)
+}
+
+// Bin is one equal-width histogram bin. Bins are closed on the left and open on
+// the right, except the last one, which also holds the maximum.
+type Bin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int64   `json:"count"`
+}
+
+// Histogram splits the range of numbers into the given number of equal-width
+// bins. When all numbers are equal a single bin holds them.
+func Histogram(numbers []float64, bins int) []Bin {
+	if len(numbers) == 0 || bins < 1 {
+		return []Bin{}
+	}
+
+	lo, hi := numbers[0], numbers[0]
+	for _, x := range numbers {
+		lo = math.Min(lo, x)
+		hi = math.Max(hi, x)
+	}
+	if lo == hi {
+		return []Bin{{Lower: lo, Upper: hi, Count: int64(len(numbers))}}
+	}
+
+	width := (hi - lo) / float64(bins)
+	out := make([]Bin, bins)
+	for i := range out {
+		out[i].Lower = lo + float64(i)*width
+		out[i].Upper = lo + float64(i+1)*width
+	}
+	out[bins-1].Upper = hi
+	for _, x := range numbers {
+		i := int((x - lo) / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		out[i].Count++
+	}
+	return out
+}
+
+// BoxPlot summarises numbers with Tukey's box plot: whiskers reach the most
+// extreme values within 1.5 IQR of the quartiles and values beyond are outliers
+type BoxPlot struct {
+	Min          float64   `json:"min"`
+	Q1           float64   `json:"q1"`
+	Median       float64   `json:"median"`
+	Q3           float64   `json:"q3"`
+	Max          float64   `json:"max"`
+	IQR          float64   `json:"iqr"`
+	LowerFence   float64   `json:"lower_fence"`
+	UpperFence   float64   `json:"upper_fence"`
+	LowerWhisker float64   `json:"lower_whisker"`
+	UpperWhisker float64   `json:"upper_whisker"`
+	Outliers     []float64 `json:"outliers"`
+}
+
+// NewBoxPlot computes the box plot of ascending, non-empty numbers
+func NewBoxPlot(sorted []float64) BoxPlot {
+	box := BoxPlot{
+		Min:      sorted[0],
+		Q1:       Quantile(sorted, 0.25),
+		Median:   Quantile(sorted, 0.5),
+		Q3:       Quantile(sorted, 0.75),
+		Max:      sorted[len(sorted)-1],
+		Outliers: []float64{},
+	}
+	box.IQR = box.Q3 - box.Q1
+	box.LowerFence = box.Q1 - 1.5*box.IQR
+	box.UpperFence = box.Q3 + 1.5*box.IQR
+
+	box.LowerWhisker, box.UpperWhisker = box.Q1, box.Q3
+	for _, x := range sorted {
+		if x < box.LowerFence || x > box.UpperFence {
+			box.Outliers = append(box.Outliers, x)
+			continue
+		}
+		box.LowerWhisker = math.Min(box.LowerWhisker, x)
+		box.UpperWhisker = math.Max(box.UpperWhisker, x)
+	}
+	return box
+}
+
+// Moments describes the shape of a distribution. Skewness and kurtosis are the
+// population moment estimates, kurtosis in excess of the normal distribution,
+// and the Jarque-Bera test checks them jointly against normality.
+type Moments struct {
+	Count         int64
+	Mean          float64
+	StdDev        float64
+	Skewness      float64
+	Kurtosis      float64
+	JarqueBera    float64
+	JarqueBeraP   float64
+	StandardError float64
+}
+
+// NewMoments computes the distribution moments of numbers. Values that are
+// undefined for the sample, such as the skewness of constant numbers, are NaN.
+func NewMoments(numbers []float64) Moments {
+	n := float64(len(numbers))
+	m := Moments{
+		Count:    int64(len(numbers)),
+		Mean:     Mean(numbers),
+		StdDev:   StdDev(numbers),
+		Skewness: math.NaN(),
+		Kurtosis: math.NaN(),
+	}
+	m.StandardError = m.StdDev / math.Sqrt(n)
+
+	var m2, m3, m4 float64
+	for _, x := range numbers {
+		d := x - m.Mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	m.JarqueBera, m.JarqueBeraP = math.NaN(), math.NaN()
+	if len(numbers) > 0 && m2 > 0 {
+		m.Skewness = m3 / math.Pow(m2, 1.5)
+		m.Kurtosis = m4/(m2*m2) - 3
+		m.JarqueBera = n / 6 * (m.Skewness*m.Skewness + m.Kurtosis*m.Kurtosis/4)
+		m.JarqueBeraP = 1 - ChiSquareCDF(m.JarqueBera, 2)
+	}
+	return m
+}
+
+// Frequency is how often one distinct value occurs
+type Frequency struct {
+	Value   interface{} `json:"value"`
+	Count   int64       `json:"count"`
+	Percent float64     `json:"percent"`
+}
+
+// Frequencies counts the distinct values, using key to decide which values are
+// equal. The result is ordered by descending count, ties in order of first
+// appearance, and each entry holds the first value seen for its key.
+func Frequencies(values []interface{}, key func(interface{}) string) []Frequency {
+	var out []Frequency
+	positions := make(map[string]int)
+	for _, value := range values {
+		k := key(value)
+		i, ok := positions[k]
+		if !ok {
+			i = len(out)
+			positions[k] = i
+			out = append(out, Frequency{Value: value})
+		}
+		out[i].Count++
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	for i := range out {
+		out[i].Percent = float64(out[i].Count) / float64(len(values)) * 100
+	}
+	if out == nil {
+		out = []Frequency{}
+	}
+	return out
+}
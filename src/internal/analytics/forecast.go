@@ -0,0 +1,342 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// defaultConfidence is the coverage of prediction intervals when params leave it out
+const defaultConfidence = 0.95
+
+// forecaster fits a model to a series and forecasts the buckets that follow it
+type forecaster interface {
+	// minPoints returns the number of points needed to fit the model
+	minPoints() int
+	// fit estimates the model from the values at the given bucket times
+	fit(times []time.Time, y []float64) error
+	// forecast returns the mean forecast at each future bucket with the bounds
+	// of its prediction interval at the given confidence
+	forecast(future []time.Time, confidence float64) (mean, lower, upper []float64)
+	// parameters returns the fitted parameters, reported with the metrics
+	parameters() map[string]float64
+}
+
+// Forecast is a validated forecast request
+type Forecast struct {
+	req      models.ForecastRequest
+	params   models.ForecastParams
+	series   *TimeSeries
+	newModel func() forecaster
+}
+
+// CompileForecast validates a forecast request against the fields of the
+// dataset, including the params of its method
+func CompileForecast(req *models.ForecastRequest, fields []models.DataField) (*Forecast, error) {
+	f := &Forecast{req: *req}
+	if err := query.DecodeParams(req.Params, &f.params); err != nil {
+		return nil, models.NewValidationError("%s: %v", req.Method, err)
+	}
+	p := &f.params
+
+	if req.Horizon < 1 || req.Horizon > maxTimeSeriesBuckets {
+		return nil, models.NewValidationError("horizon must be between 1 and %d", maxTimeSeriesBuckets)
+	}
+	if p.Confidence == 0 {
+		p.Confidence = defaultConfidence
+	}
+	if p.Confidence <= 0 || p.Confidence >= 1 {
+		return nil, models.NewValidationError("%s: confidence must be between 0 and 1", req.Method)
+	}
+	if p.Holdout != nil && *p.Holdout < 0 {
+		return nil, models.NewValidationError("%s: holdout must not be negative", req.Method)
+	}
+
+	// The history is a gap-free series of the value field
+	if p.Aggregation == "" {
+		p.Aggregation = models.TimeSeriesSum
+	}
+	switch p.Fill {
+	case "":
+		p.Fill = models.TimeSeriesFillForward
+	case models.TimeSeriesFillForward, models.TimeSeriesFillZero:
+	default:
+		return nil, models.NewValidationError("%s: fill must be %q or %q", req.Method, models.TimeSeriesFillForward, models.TimeSeriesFillZero)
+	}
+	series, err := CompileTimeSeries(&models.TimeSeriesRequest{
+		DatasetID:   req.DatasetID,
+		TimeField:   req.TimeField,
+		ValueField:  req.ValueField,
+		Aggregation: p.Aggregation,
+		Interval:    req.Interval,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Timezone:    p.Timezone,
+		Fill:        p.Fill,
+	}, fields)
+	if err != nil {
+		return nil, err
+	}
+	f.series = series
+
+	// Method params
+	if err := checkMethodParams(req.Method, p); err != nil {
+		return nil, models.NewValidationError("%s: %v", req.Method, err)
+	}
+	switch req.Method {
+	case models.ForecastLinear:
+		f.newModel = func() forecaster { return &linearModel{} }
+	case models.ForecastExponential:
+		f.newModel = func() forecaster { return newHoltWinters(p) }
+	case models.ForecastARIMA:
+		f.newModel = func() forecaster { return newARIMA(p) }
+	case models.ForecastProphet:
+		calendar := series.Calendar()
+		f.newModel = func() forecaster { return newProphet(p, calendar) }
+	default:
+		return nil, models.NewValidationError("unknown forecast method %q", req.Method)
+	}
+
+	return f, nil
+}
+
+// checkMethodParams rejects model params that do not apply to a method and
+// validates those that do
+func checkMethodParams(method models.ForecastMethod, p *models.ForecastParams) error {
+	exponential := p.Alpha != nil || p.Beta != nil || p.Gamma != nil || p.SeasonLength != 0 || p.Seasonal != "" || p.Trend != nil
+	if exponential && method != models.ForecastExponential {
+		return fmt.Errorf("alpha, beta, gamma, season_length, seasonal and trend only apply to %s", models.ForecastExponential)
+	}
+	arima := p.P != nil || p.D != nil || p.Q != nil
+	if arima && method != models.ForecastARIMA {
+		return fmt.Errorf("p, d and q only apply to %s", models.ForecastARIMA)
+	}
+	prophet := p.Changepoints != nil || p.ChangepointRange != nil || p.ChangepointPriorScale != nil ||
+		p.YearlySeasonality != nil || p.WeeklySeasonality != nil || p.DailySeasonality != nil ||
+		p.SeasonalityPriorScale != nil || len(p.Holidays) > 0 || p.HolidayPriorScale != nil
+	if prophet && method != models.ForecastProphet {
+		return fmt.Errorf("changepoint, seasonality and holiday params only apply to %s", models.ForecastProphet)
+	}
+
+	switch method {
+	case models.ForecastExponential:
+		return checkHoltWintersParams(p)
+	case models.ForecastARIMA:
+		return checkARIMAParams(p)
+	case models.ForecastProphet:
+		return checkProphetParams(p)
+	}
+	return nil
+}
+
+// Compute forecasts the buckets following the history of a frame with the
+// fields the request was compiled for
+func (f *Forecast) Compute(frame *query.Frame) (*models.ForecastResult, error) {
+	times, y, err := f.history(frame)
+	if err != nil {
+		return nil, err
+	}
+	calendar := f.series.Calendar()
+
+	result := &models.ForecastResult{
+		Method:     f.req.Method,
+		Horizon:    f.req.Horizon,
+		Interval:   f.req.Interval,
+		Historical: Series{Times: times, Values: y}.Points(),
+		Forecast:   make([]models.ForecastPoint, f.req.Horizon),
+		Metrics:    make(map[string]float64),
+	}
+
+	// Backtest on the last points of the history
+	if err := f.backtest(times, y, result.Metrics); err != nil {
+		return nil, err
+	}
+
+	// Fit the whole history and forecast
+	model := f.newModel()
+	if err := model.fit(times, y); err != nil {
+		return nil, err
+	}
+	future := make([]time.Time, f.req.Horizon)
+	for h := range future {
+		future[h] = calendar.Add(times[len(times)-1], h+1)
+	}
+	mean, lower, upper := model.forecast(future, f.params.Confidence)
+	for h, t := range future {
+		result.Forecast[h] = models.ForecastPoint{
+			Timestamp:  t.Format(time.RFC3339),
+			Value:      mean[h],
+			LowerBound: lower[h],
+			UpperBound: upper[h],
+		}
+	}
+	for name, value := range model.parameters() {
+		if finite(value) != nil {
+			result.Metrics[name] = value
+		}
+	}
+
+	return result, nil
+}
+
+// history returns the bucketed series to forecast from. Leading buckets without
+// a value are dropped and later ones repeat the previous value.
+func (f *Forecast) history(frame *query.Frame) ([]time.Time, []float64, error) {
+	series, err := f.series.Series(frame)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var times []time.Time
+	var y []float64
+	if len(series) > 0 {
+		for i, v := range series[0].Values {
+			switch {
+			case !math.IsNaN(v):
+			case len(y) == 0:
+				continue
+			default:
+				v = y[len(y)-1]
+			}
+			times = append(times, series[0].Times[i])
+			y = append(y, v)
+		}
+	}
+
+	if needed := f.newModel().minPoints(); len(y) < needed {
+		return nil, nil, models.NewValidationError("history has %d %s buckets with values; %s needs at least %d", len(y), f.req.Interval, f.req.Method, needed)
+	}
+	return times, y, nil
+}
+
+// backtest forecasts the held-out end of the history from the points before it
+// and records the errors. Without an explicit holdout the backtest is skipped
+// when the remaining history is too short.
+func (f *Forecast) backtest(times []time.Time, y []float64, metrics map[string]float64) error {
+	n := len(y)
+	holdout := f.req.Horizon
+	if holdout > n/5 {
+		holdout = n / 5
+	}
+	if f.params.Holdout != nil {
+		holdout = *f.params.Holdout
+	}
+	if holdout == 0 {
+		return nil
+	}
+
+	model := f.newModel()
+	if n-holdout < model.minPoints() {
+		if f.params.Holdout == nil {
+			return nil
+		}
+		return models.NewValidationError("holdout of %d leaves %d points; %s needs at least %d", holdout, n-holdout, f.req.Method, model.minPoints())
+	}
+	if err := model.fit(times[:n-holdout], y[:n-holdout]); err != nil {
+		return err
+	}
+	predicted, _, _ := model.forecast(times[n-holdout:], f.params.Confidence)
+
+	for name, value := range ForecastErrors(y[n-holdout:], predicted) {
+		metrics[name] = value
+	}
+	metrics["holdout"] = float64(holdout)
+	return nil
+}
+
+// ForecastErrors compares predictions with actual values. MAPE, in percent,
+// skips actual values of zero and is left out when all of them are zero.
+func ForecastErrors(actual, predicted []float64) map[string]float64 {
+	var absolute, squared, percentage float64
+	var nonZero int
+	for i := range actual {
+		e := actual[i] - predicted[i]
+		absolute += math.Abs(e)
+		squared += e * e
+		if actual[i] != 0 {
+			percentage += math.Abs(e / actual[i])
+			nonZero++
+		}
+	}
+
+	n := float64(len(actual))
+	metrics := map[string]float64{
+		"mae":  absolute / n,
+		"rmse": math.Sqrt(squared / n),
+	}
+	if nonZero > 0 {
+		metrics["mape"] = percentage / float64(nonZero) * 100
+	}
+	return metrics
+}
+
+// linearModel fits a straight line through the series by least squares, with
+// the classical prediction interval of a new observation
+type linearModel struct {
+	n                 int
+	slope, intercept  float64
+	sigma, meanX, sxx float64
+}
+
+func (m *linearModel) minPoints() int {
+	return 3
+}
+
+func (m *linearModel) fit(times []time.Time, y []float64) error {
+	m.n = len(y)
+	x := make([]float64, m.n)
+	for i := range x {
+		x[i] = float64(i)
+	}
+	m.meanX = Mean(x)
+	meanY := Mean(y)
+
+	var sxy float64
+	for i := range x {
+		sxy += (x[i] - m.meanX) * (y[i] - meanY)
+		m.sxx += (x[i] - m.meanX) * (x[i] - m.meanX)
+	}
+	m.slope = sxy / m.sxx
+	m.intercept = meanY - m.slope*m.meanX
+
+	var sse float64
+	for i := range x {
+		e := y[i] - m.intercept - m.slope*x[i]
+		sse += e * e
+	}
+	m.sigma = math.Sqrt(sse / float64(m.n-2))
+	return nil
+}
+
+func (m *linearModel) forecast(future []time.Time, confidence float64) ([]float64, []float64, []float64) {
+	t := StudentTQuantile((1+confidence)/2, float64(m.n-2))
+	mean := make([]float64, len(future))
+	lower := make([]float64, len(future))
+	upper := make([]float64, len(future))
+	for h := range future {
+		x := float64(m.n + h)
+		mean[h] = m.intercept + m.slope*x
+		margin := t * m.sigma * math.Sqrt(1+1/float64(m.n)+(x-m.meanX)*(x-m.meanX)/m.sxx)
+		lower[h], upper[h] = mean[h]-margin, mean[h]+margin
+	}
+	return mean, lower, upper
+}
+
+func (m *linearModel) parameters() map[string]float64 {
+	return map[string]float64{"slope": m.slope, "intercept": m.intercept, "sigma": m.sigma}
+}
+
+// normalBounds returns the bounds of normal prediction intervals around mean
+// with the given standard errors
+func normalBounds(mean, stderr []float64, confidence float64) ([]float64, []float64) {
+	z := NormalQuantile((1 + confidence) / 2)
+	lower := make([]float64, len(mean))
+	upper := make([]float64, len(mean))
+	for h := range mean {
+		lower[h], upper[h] = mean[h]-z*stderr[h], mean[h]+z*stderr[h]
+	}
+	return lower, upper
+}
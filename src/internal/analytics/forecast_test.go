@@ -0,0 +1,250 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var salesSeriesFields = []models.DataField{
+	{Name: "day", Type: models.DataTypeDateTime},
+	{Name: "amount", Type: models.DataTypeFloat},
+}
+
+// dailyFrame holds one row per day from 2024-01-01 with the given amounts
+func dailyFrame(amounts []float64) *query.Frame {
+	rows := make([]map[string]interface{}, len(amounts))
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for i, amount := range amounts {
+		rows[i] = map[string]interface{}{"day": start.AddDate(0, 0, i).Format(time.RFC3339), "amount": amount}
+	}
+	return query.NewFrame(salesSeriesFields, rows)
+}
+
+func forecastRequest(method models.ForecastMethod, horizon int, params map[string]interface{}) *models.ForecastRequest {
+	return &models.ForecastRequest{
+		TimeField:  "day",
+		ValueField: "amount",
+		Method:     method,
+		Horizon:    horizon,
+		Interval:   models.TimeSeriesDay,
+		Params:     params,
+	}
+}
+
+func runForecast(t *testing.T, req *models.ForecastRequest, amounts []float64) *models.ForecastResult {
+	forecast, err := CompileForecast(req, salesSeriesFields)
+	require.NoError(t, err)
+	result, err := forecast.Compute(dailyFrame(amounts))
+	require.NoError(t, err)
+	require.Len(t, result.Forecast, req.Horizon)
+	return result
+}
+
+func forecastValues(result *models.ForecastResult) []float64 {
+	values := make([]float64, len(result.Forecast))
+	for i, p := range result.Forecast {
+		values[i] = p.Value
+	}
+	return values
+}
+
+func TestForecast(t *testing.T) {
+	// Test Case 1: A linear trend is extended exactly
+	t.Run("Linear", func(t *testing.T) {
+		amounts := make([]float64, 20)
+		for i := range amounts {
+			amounts[i] = 2*float64(i) + 1
+		}
+		result := runForecast(t, forecastRequest(models.ForecastLinear, 3, nil), amounts)
+
+		assert.Len(t, result.Historical, 20)
+		assert.Equal(t, "2024-01-21T00:00:00Z", result.Forecast[0].Timestamp)
+		assert.InDeltaSlice(t, []float64{41, 43, 45}, forecastValues(result), 1e-9)
+		assert.InDelta(t, 41, result.Forecast[0].LowerBound, 1e-6)
+		assert.InDelta(t, 2, result.Metrics["slope"], 1e-9)
+		assert.InDelta(t, 0, result.Metrics["mae"], 1e-9)
+		assert.Equal(t, 3.0, result.Metrics["holdout"])
+	})
+
+	// Test Case 2: Holt-Winters follows trend and additive season
+	t.Run("Holt-Winters", func(t *testing.T) {
+		season := []float64{5, -2, 0, -3}
+		amounts := make([]float64, 40)
+		for i := range amounts {
+			amounts[i] = 50 + 0.5*float64(i) + season[i%4]
+		}
+		result := runForecast(t, forecastRequest(models.ForecastExponential, 4, map[string]interface{}{"season_length": 4}), amounts)
+
+		for h, value := range forecastValues(result) {
+			i := 40 + h
+			assert.InDelta(t, 50+0.5*float64(i)+season[i%4], value, 0.5)
+		}
+		assert.Contains(t, result.Metrics, "gamma")
+		assert.Less(t, result.Metrics["mape"], 1.0)
+	})
+
+	// Test Case 3: Fixed smoothing factors are kept and the interval widens
+	t.Run("Fixed Factors", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		amounts := make([]float64, 30)
+		for i := range amounts {
+			amounts[i] = 100 + rng.NormFloat64()
+		}
+		result := runForecast(t, forecastRequest(models.ForecastExponential, 5, map[string]interface{}{"alpha": 0.2, "trend": false}), amounts)
+
+		assert.Equal(t, 0.2, result.Metrics["alpha"])
+		assert.NotContains(t, result.Metrics, "beta")
+		first, last := result.Forecast[0], result.Forecast[4]
+		assert.Less(t, first.UpperBound-first.LowerBound, last.UpperBound-last.LowerBound)
+	})
+
+	// Test Case 4: ARIMA recovers an autoregressive coefficient
+	t.Run("ARIMA", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(7))
+		amounts := make([]float64, 400)
+		for i := 1; i < len(amounts); i++ {
+			amounts[i] = 0.6*amounts[i-1] + rng.NormFloat64()
+		}
+		result := runForecast(t, forecastRequest(models.ForecastARIMA, 10, map[string]interface{}{"p": 1, "d": 0, "q": 0}), amounts)
+
+		assert.InDelta(t, 0.6, result.Metrics["ar1"], 0.1)
+		assert.InDelta(t, 1, result.Metrics["sigma"], 0.1)
+		// Forecasts decay towards the mean
+		values := forecastValues(result)
+		assert.Less(t, math.Abs(values[9]-result.Metrics["constant"]), math.Abs(values[0]-result.Metrics["constant"])+1e-9)
+	})
+
+	// Test Case 5: A differenced model continues the drift of a trend
+	t.Run("ARIMA Drift", func(t *testing.T) {
+		amounts := make([]float64, 20)
+		for i := range amounts {
+			amounts[i] = 10 + 3*float64(i)
+		}
+		result := runForecast(t, forecastRequest(models.ForecastARIMA, 2, map[string]interface{}{"p": 0, "d": 1, "q": 0}), amounts)
+		assert.InDeltaSlice(t, []float64{70, 73}, forecastValues(result), 1e-9)
+	})
+
+	// Test Case 6: Prophet picks up a weekly season on a trend
+	t.Run("Prophet", func(t *testing.T) {
+		week := []float64{4, 6, 5, 3, 0, -8, -10}
+		value := func(i int) float64 {
+			return 100 + 0.5*float64(i) + week[i%7]
+		}
+		amounts := make([]float64, 84)
+		for i := range amounts {
+			amounts[i] = value(i)
+		}
+		result := runForecast(t, forecastRequest(models.ForecastProphet, 14, nil), amounts)
+
+		for h, v := range forecastValues(result) {
+			assert.InDelta(t, value(84+h), v, 1)
+		}
+		assert.Equal(t, 3.0, result.Metrics["weekly_seasonality"])
+		assert.Equal(t, 0.0, result.Metrics["yearly_seasonality"])
+		assert.Equal(t, 0.0, result.Metrics["daily_seasonality"])
+		assert.InDelta(t, 0.5, result.Metrics["growth"], 0.05)
+		first, last := result.Forecast[0], result.Forecast[13]
+		assert.Less(t, first.UpperBound-first.LowerBound, last.UpperBound-last.LowerBound)
+	})
+
+	// Test Case 7: Prophet follows a trend change
+	t.Run("Prophet Changepoint", func(t *testing.T) {
+		amounts := make([]float64, 100)
+		for i := range amounts {
+			amounts[i] = float64(i)
+			if i > 50 {
+				amounts[i] = 50 + 3*float64(i-50)
+			}
+		}
+		result := runForecast(t, forecastRequest(models.ForecastProphet, 5, map[string]interface{}{"weekly_seasonality": 0}), amounts)
+
+		assert.InDelta(t, 3, result.Metrics["growth"], 0.2)
+		assert.InDelta(t, 50+3*50, result.Forecast[0].Value, 3)
+	})
+
+	// Test Case 8: Prophet estimates holiday effects from the history
+	t.Run("Prophet Holidays", func(t *testing.T) {
+		amounts := make([]float64, 120)
+		for i := range amounts {
+			amounts[i] = 20
+			if i%30 == 14 {
+				amounts[i] = 70
+			}
+		}
+		// The 15th of each month, with the next one inside the horizon
+		holidays := []interface{}{map[string]interface{}{
+			"name":  "payday",
+			"dates": []string{"2024-01-15", "2024-02-14", "2024-03-15", "2024-04-14", "2024-05-14"},
+		}}
+		params := map[string]interface{}{"holidays": holidays, "weekly_seasonality": 0, "changepoints": 0}
+		result := runForecast(t, forecastRequest(models.ForecastProphet, 20, params), amounts)
+
+		assert.InDelta(t, 50, result.Metrics["holiday_payday"], 2)
+		values := forecastValues(result)
+		// 2024-04-30 is the first forecast bucket and 2024-05-14 the 15th
+		assert.Equal(t, "2024-05-14T00:00:00Z", result.Forecast[14].Timestamp)
+		assert.InDelta(t, 70, values[14], 2)
+		assert.InDelta(t, 20, values[13], 2)
+	})
+
+	// Test Case 9: Short histories are rejected
+	t.Run("Short History", func(t *testing.T) {
+		forecast, err := CompileForecast(forecastRequest(models.ForecastExponential, 1, map[string]interface{}{"season_length": 7}), salesSeriesFields)
+		require.NoError(t, err)
+		_, err = forecast.Compute(dailyFrame([]float64{1, 2, 3, 4, 5, 6, 7, 8}))
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 10: Invalid requests are rejected with validation errors
+	invalid := map[string]*models.ForecastRequest{
+		"Unknown Method":          forecastRequest("neural", 1, nil),
+		"Unknown Param":           forecastRequest(models.ForecastLinear, 1, map[string]interface{}{"order": 2}),
+		"Misplaced Param":         forecastRequest(models.ForecastLinear, 1, map[string]interface{}{"alpha": 0.5}),
+		"ARIMA Param Elsewhere":   forecastRequest(models.ForecastExponential, 1, map[string]interface{}{"p": 1}),
+		"Confidence Too High":     forecastRequest(models.ForecastLinear, 1, map[string]interface{}{"confidence": 1.5}),
+		"Null Fill":               forecastRequest(models.ForecastLinear, 1, map[string]interface{}{"fill": "null"}),
+		"Alpha Too High":          forecastRequest(models.ForecastExponential, 1, map[string]interface{}{"alpha": 2}),
+		"Season Of One":           forecastRequest(models.ForecastExponential, 1, map[string]interface{}{"season_length": 1}),
+		"Gamma Without Season":    forecastRequest(models.ForecastExponential, 1, map[string]interface{}{"gamma": 0.1}),
+		"Order Too High":          forecastRequest(models.ForecastARIMA, 1, map[string]interface{}{"p": 11}),
+		"Too Many Differences":    forecastRequest(models.ForecastARIMA, 1, map[string]interface{}{"d": 3}),
+		"Zero Horizon":            forecastRequest(models.ForecastLinear, 0, nil),
+		"Prophet Param Elsewhere": forecastRequest(models.ForecastARIMA, 1, map[string]interface{}{"changepoints": 5}),
+		"Changepoint Range":       forecastRequest(models.ForecastProphet, 1, map[string]interface{}{"changepoint_range": 0}),
+		"Negative Prior Scale":    forecastRequest(models.ForecastProphet, 1, map[string]interface{}{"seasonality_prior_scale": -1}),
+		"Fourier Order Too High":  forecastRequest(models.ForecastProphet, 1, map[string]interface{}{"yearly_seasonality": 51}),
+		"Holiday Without Name": forecastRequest(models.ForecastProphet, 1, map[string]interface{}{
+			"holidays": []interface{}{map[string]interface{}{"dates": []string{"2024-01-01"}}},
+		}),
+		"Holiday Date": forecastRequest(models.ForecastProphet, 1, map[string]interface{}{
+			"holidays": []interface{}{map[string]interface{}{"name": "new_year", "dates": []string{"01/01/2024"}}},
+		}),
+		"Holiday Window": forecastRequest(models.ForecastProphet, 1, map[string]interface{}{
+			"holidays": []interface{}{map[string]interface{}{"name": "new_year", "dates": []string{"2024-01-01"}, "lower_window": 1}},
+		}),
+		"Repeated Holiday": forecastRequest(models.ForecastProphet, 1, map[string]interface{}{
+			"holidays": []interface{}{
+				map[string]interface{}{"name": "new_year", "dates": []string{"2024-01-01"}},
+				map[string]interface{}{"name": "new_year", "dates": []string{"2025-01-01"}},
+			},
+		}),
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileForecast(req, salesSeriesFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
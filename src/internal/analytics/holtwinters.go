@@ -0,0 +1,260 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Holt-Winters seasonality kinds
+const (
+	seasonalAdditive       = "additive"
+	seasonalMultiplicative = "multiplicative"
+)
+
+// maxSeasonLength bounds the season of Holt-Winters models
+const maxSeasonLength = 366
+
+// holtWinters is Holt-Winters exponential smoothing with an optional additive
+// trend and an optional additive or multiplicative season. Smoothing factors
+// that are not given are fitted by minimising the squared one-step errors.
+type holtWinters struct {
+	fixed          [3]*float64
+	season         int
+	multiplicative bool
+	trend          bool
+
+	alpha, beta, gamma float64
+	level, slope       float64
+	seasonals          []float64
+	sigma              float64
+}
+
+// newHoltWinters returns an unfitted Holt-Winters model for checked params
+func newHoltWinters(p *models.ForecastParams) *holtWinters {
+	return &holtWinters{
+		fixed:          [3]*float64{p.Alpha, p.Beta, p.Gamma},
+		season:         p.SeasonLength,
+		multiplicative: p.Seasonal == seasonalMultiplicative,
+		trend:          p.Trend == nil || *p.Trend,
+	}
+}
+
+// checkHoltWintersParams validates the params of exponential forecasts
+func checkHoltWintersParams(p *models.ForecastParams) error {
+	factors := map[string]*float64{"alpha": p.Alpha, "beta": p.Beta, "gamma": p.Gamma}
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		if f := factors[name]; f != nil && (*f < 0 || *f > 1) {
+			return fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if p.SeasonLength < 0 || p.SeasonLength == 1 || p.SeasonLength > maxSeasonLength {
+		return fmt.Errorf("season_length must be 0 or between 2 and %d", maxSeasonLength)
+	}
+	switch p.Seasonal {
+	case "", seasonalAdditive, seasonalMultiplicative:
+	default:
+		return fmt.Errorf("seasonal must be %q or %q", seasonalAdditive, seasonalMultiplicative)
+	}
+	if p.SeasonLength == 0 && (p.Gamma != nil || p.Seasonal != "") {
+		return fmt.Errorf("gamma and seasonal require season_length")
+	}
+	if p.Trend != nil && !*p.Trend && p.Beta != nil {
+		return fmt.Errorf("beta requires the trend")
+	}
+	return nil
+}
+
+func (m *holtWinters) minPoints() int {
+	if m.season > 0 {
+		return 2 * m.season
+	}
+	return 3
+}
+
+func (m *holtWinters) fit(times []time.Time, y []float64) error {
+	if m.multiplicative {
+		for _, v := range y {
+			if v <= 0 {
+				return models.NewValidationError("multiplicative seasonality requires positive values")
+			}
+		}
+	}
+
+	// Optimise the free factors on the logistic scale
+	var free []int
+	var start []float64
+	for i, f := range m.fixed {
+		if f == nil && m.uses(i) {
+			free = append(free, i)
+			start = append(start, logit(0.3))
+		}
+	}
+	factors := func(x []float64) [3]float64 {
+		var out [3]float64
+		for i, f := range m.fixed {
+			if f != nil {
+				out[i] = *f
+			}
+		}
+		for k, i := range free {
+			out[i] = logistic(x[k])
+		}
+		return out
+	}
+	best := minimize(func(x []float64) float64 {
+		sse, _, _, _ := m.smooth(y, factors(x))
+		return sse
+	}, start, 500)
+
+	chosen := factors(best)
+	m.alpha, m.beta, m.gamma = chosen[0], chosen[1], chosen[2]
+	sse, level, slope, seasonals := m.smooth(y, chosen)
+	m.level, m.slope, m.seasonals = level, slope, seasonals
+	m.sigma = math.Sqrt(sse / float64(len(y)))
+	return nil
+}
+
+// uses reports whether the model has the component smoothed by factor i
+// (0 level, 1 trend, 2 season)
+func (m *holtWinters) uses(i int) bool {
+	switch i {
+	case 1:
+		return m.trend
+	case 2:
+		return m.season > 0
+	}
+	return true
+}
+
+// smooth runs the smoothing equations over the series, returning the sum of
+// squared one-step errors and the final level, slope and season
+func (m *holtWinters) smooth(y []float64, factors [3]float64) (float64, float64, float64, []float64) {
+	alpha, beta, gamma := factors[0], factors[1], factors[2]
+	level, slope, seasonals := m.initial(y)
+
+	var sse float64
+	for t, v := range y {
+		s := m.neutral()
+		if m.season > 0 {
+			s = seasonals[t%m.season]
+		}
+		predicted := m.combine(level+slope, s)
+		sse += (v - predicted) * (v - predicted)
+
+		previous := level
+		if m.multiplicative {
+			level = alpha*v/s + (1-alpha)*(level+slope)
+		} else {
+			level = alpha*(v-s) + (1-alpha)*(level+slope)
+		}
+		if m.trend {
+			slope = beta*(level-previous) + (1-beta)*slope
+		}
+		if m.season > 0 {
+			if m.multiplicative {
+				seasonals[t%m.season] = gamma*v/level + (1-gamma)*s
+			} else {
+				seasonals[t%m.season] = gamma*(v-level) + (1-gamma)*s
+			}
+		}
+	}
+
+	// Rotate the season so that it starts at the first forecast step
+	if m.season > 0 {
+		shift := len(y) % m.season
+		seasonals = append(seasonals[shift:], seasonals[:shift]...)
+	}
+	return sse, level, slope, seasonals
+}
+
+// initial estimates the starting level, slope and season from the first two
+// seasons, or the first two points without seasonality
+func (m *holtWinters) initial(y []float64) (float64, float64, []float64) {
+	if m.season == 0 {
+		slope := 0.0
+		if m.trend {
+			slope = y[1] - y[0]
+		}
+		return y[0] - slope, slope, nil
+	}
+
+	first := Mean(y[:m.season])
+	second := Mean(y[m.season : 2*m.season])
+	slope := 0.0
+	if m.trend {
+		slope = (second - first) / float64(m.season)
+	}
+	seasonals := make([]float64, m.season)
+	for i := range seasonals {
+		if m.multiplicative {
+			seasonals[i] = y[i] / first
+		} else {
+			seasonals[i] = y[i] - first
+		}
+	}
+	// The level precedes the first point, half a season before the mean
+	return first - slope*float64(m.season+1)/2, slope, seasonals
+}
+
+// neutral returns the seasonal factor that leaves values unchanged
+func (m *holtWinters) neutral() float64 {
+	if m.multiplicative {
+		return 1
+	}
+	return 0
+}
+
+// combine applies a seasonal factor to a trend value
+func (m *holtWinters) combine(trend, seasonal float64) float64 {
+	if m.multiplicative {
+		return trend * seasonal
+	}
+	return trend + seasonal
+}
+
+// forecast extrapolates the final state. The standard errors follow the
+// additive state space model; for multiplicative seasons they are scaled by
+// the seasonal factor, an approximation.
+func (m *holtWinters) forecast(future []time.Time, confidence float64) ([]float64, []float64, []float64) {
+	mean := make([]float64, len(future))
+	stderr := make([]float64, len(future))
+	variance := 1.0
+	for h := range future {
+		s := m.neutral()
+		if m.season > 0 {
+			s = m.seasonals[h%m.season]
+		}
+		mean[h] = m.combine(m.level+float64(h+1)*m.slope, s)
+
+		if h > 0 {
+			c := m.alpha
+			if m.trend {
+				c += m.alpha * m.beta * float64(h)
+			}
+			if m.season > 0 && h%m.season == 0 {
+				c += m.gamma
+			}
+			variance += c * c
+		}
+		stderr[h] = m.sigma * math.Sqrt(variance)
+		if m.multiplicative {
+			stderr[h] *= s
+		}
+	}
+	lower, upper := normalBounds(mean, stderr, confidence)
+	return mean, lower, upper
+}
+
+func (m *holtWinters) parameters() map[string]float64 {
+	params := map[string]float64{"alpha": m.alpha, "sigma": m.sigma}
+	if m.trend {
+		params["beta"] = m.beta
+	}
+	if m.season > 0 {
+		params["gamma"] = m.gamma
+	}
+	return params
+}
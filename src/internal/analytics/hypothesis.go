@@ -0,0 +1,529 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// Alternative hypotheses
+const (
+	alternativeTwoSided = "two_sided"
+	alternativeLess     = "less"
+	alternativeGreater  = "greater"
+)
+
+// defaultTestAlpha is the significance level when params leave it out
+const defaultTestAlpha = 0.05
+
+// effectSizeTypes names the effect size each test reports
+var effectSizeTypes = map[models.HypothesisTestType]string{
+	models.TestOneSampleT:        "cohens_d",
+	models.TestTwoSampleT:        "cohens_d",
+	models.TestPairedT:           "cohens_d",
+	models.TestChiSquare:         "cramers_v",
+	models.TestMannWhitney:       "rank_biserial",
+	models.TestANOVA:             "eta_squared",
+	models.TestKolmogorovSmirnov: "ks_distance",
+}
+
+// HypothesisTest is a validated hypothesis test request
+type HypothesisTest struct {
+	req     models.HypothesisTestRequest
+	params  models.HypothesisTestParams
+	indices []int
+	types   []models.DataType
+	// group is the position of the group field, or -1
+	group     int
+	groupType models.DataType
+}
+
+// sample is the values of one sample of a test
+type sample struct {
+	name   string
+	values []float64
+}
+
+// CompileHypothesisTest validates a hypothesis test request against the fields
+// of the dataset. Each test takes its own number of fields, which must be
+// distinct and, except for chi_square, numeric.
+func CompileHypothesisTest(req *models.HypothesisTestRequest, fields []models.DataField) (*HypothesisTest, error) {
+	if _, ok := effectSizeTypes[req.Test]; !ok {
+		return nil, models.NewValidationError("unknown hypothesis test %q", req.Test)
+	}
+	ht := &HypothesisTest{req: *req, group: -1}
+	if err := query.DecodeParams(req.Params, &ht.params); err != nil {
+		return nil, models.NewValidationError("%s: %v", req.Test, err)
+	}
+	if err := ht.checkParams(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(req.Fields))
+	for _, name := range req.Fields {
+		if seen[name] {
+			return nil, models.NewValidationError("field %q is listed twice", name)
+		}
+		seen[name] = true
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, models.NewValidationError("unknown field %q", name)
+		}
+		if req.Test != models.TestChiSquare && !isNumeric(fields[index].Type) {
+			return nil, models.NewValidationError("field %q is %s, not numeric", name, fields[index].Type)
+		}
+		ht.indices = append(ht.indices, index)
+		ht.types = append(ht.types, fields[index].Type)
+	}
+	if req.GroupField != "" {
+		ht.group = fieldIndex(fields, req.GroupField)
+		if ht.group < 0 {
+			return nil, models.NewValidationError("unknown group field %q", req.GroupField)
+		}
+		if seen[req.GroupField] {
+			return nil, models.NewValidationError("group field %q is also a tested field", req.GroupField)
+		}
+		ht.groupType = fields[ht.group].Type
+	}
+
+	if err := ht.checkFields(); err != nil {
+		return nil, err
+	}
+	return ht, nil
+}
+
+// checkParams validates the params and fills in their defaults
+func (ht *HypothesisTest) checkParams() error {
+	p := &ht.params
+	test := ht.req.Test
+	if p.Alpha == 0 {
+		p.Alpha = defaultTestAlpha
+	}
+	if p.Alpha <= 0 || p.Alpha >= 1 {
+		return models.NewValidationError("%s: alpha must be between 0 and 1", test)
+	}
+
+	switch p.Alternative {
+	case "":
+		p.Alternative = alternativeTwoSided
+	case alternativeTwoSided:
+	case alternativeLess, alternativeGreater:
+		switch test {
+		case models.TestOneSampleT, models.TestTwoSampleT, models.TestPairedT, models.TestMannWhitney:
+		default:
+			return models.NewValidationError("%s: only the two_sided alternative is supported", test)
+		}
+	default:
+		return models.NewValidationError("%s: unknown alternative %q", test, p.Alternative)
+	}
+
+	if p.Mu != 0 && test != models.TestOneSampleT {
+		return models.NewValidationError("%s: mu only applies to %s", test, models.TestOneSampleT)
+	}
+	if p.EqualVariance && test != models.TestTwoSampleT {
+		return models.NewValidationError("%s: equal_variance only applies to %s", test, models.TestTwoSampleT)
+	}
+	if (p.Mean != nil || p.StdDev != nil) && test != models.TestKolmogorovSmirnov {
+		return models.NewValidationError("%s: mean and std_dev only apply to %s", test, models.TestKolmogorovSmirnov)
+	}
+	if p.StdDev != nil && !(*p.StdDev > 0) {
+		return models.NewValidationError("%s: std_dev must be positive", test)
+	}
+	return nil
+}
+
+// checkFields validates the number of fields of the test and its group field
+func (ht *HypothesisTest) checkFields() error {
+	n := len(ht.indices)
+	grouped := ht.group >= 0
+	test := ht.req.Test
+	switch test {
+	case models.TestOneSampleT:
+		if n != 1 || grouped {
+			return models.NewValidationError("%s takes one field and no group field", test)
+		}
+	case models.TestPairedT, models.TestChiSquare:
+		if n != 2 || grouped {
+			return models.NewValidationError("%s takes two fields and no group field", test)
+		}
+	case models.TestTwoSampleT, models.TestMannWhitney:
+		if grouped && n != 1 || !grouped && n != 2 {
+			return models.NewValidationError("%s takes two fields, or one field and a group field", test)
+		}
+	case models.TestANOVA:
+		if grouped && n != 1 || !grouped && n < 2 {
+			return models.NewValidationError("%s takes two or more fields, or one field and a group field", test)
+		}
+	case models.TestKolmogorovSmirnov:
+		if grouped && n != 1 || n > 2 {
+			return models.NewValidationError("%s takes one or two fields, or one field and a group field", test)
+		}
+		if (ht.params.Mean != nil || ht.params.StdDev != nil) && (n != 1 || grouped) {
+			return models.NewValidationError("%s: mean and std_dev only apply to a single sample", test)
+		}
+	}
+	return nil
+}
+
+// Compute runs the test over a frame with the fields the request was compiled
+// for. Nulls are left out of the samples, and paired tests only use the rows
+// where both fields have a value. Samples too small for the test are rejected.
+func (ht *HypothesisTest) Compute(frame *query.Frame) (*models.HypothesisTestResult, error) {
+	result := &models.HypothesisTestResult{
+		Test:           ht.req.Test,
+		Fields:         ht.req.Fields,
+		GroupField:     ht.req.GroupField,
+		Alternative:    ht.params.Alternative,
+		EffectSizeType: effectSizeTypes[ht.req.Test],
+		Alpha:          ht.params.Alpha,
+		Samples:        []models.TestSample{},
+	}
+	if ht.req.Test == models.TestChiSquare {
+		if err := ht.chiSquare(frame, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	samples, err := ht.samples(frame)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range samples {
+		sorted := Sorted(s.values)
+		result.Samples = append(result.Samples, models.TestSample{
+			Name:   s.name,
+			Count:  len(s.values),
+			Mean:   finitePtr(Mean(s.values)),
+			StdDev: finitePtr(StdDev(s.values)),
+			Median: finitePtr(Median(sorted)),
+		})
+	}
+
+	var statistic, p, effect float64
+	switch ht.req.Test {
+	case models.TestOneSampleT:
+		var df float64
+		statistic, df, p, effect = ht.oneSampleT(samples[0].values, ht.params.Mu)
+		result.DegreesOfFreedom = []float64{df}
+	case models.TestPairedT:
+		differences := make([]float64, len(samples[0].values))
+		for i := range differences {
+			differences[i] = samples[0].values[i] - samples[1].values[i]
+		}
+		var df float64
+		statistic, df, p, effect = ht.oneSampleT(differences, 0)
+		result.DegreesOfFreedom = []float64{df}
+	case models.TestTwoSampleT:
+		var df float64
+		statistic, df, p, effect = ht.twoSampleT(samples[0].values, samples[1].values)
+		result.DegreesOfFreedom = []float64{df}
+	case models.TestMannWhitney:
+		statistic, p, effect = ht.mannWhitney(samples[0].values, samples[1].values)
+	case models.TestANOVA:
+		var df []float64
+		statistic, df, p, effect = anova(samples)
+		result.DegreesOfFreedom = df
+	case models.TestKolmogorovSmirnov:
+		if len(samples) == 1 {
+			statistic, p = ht.ksNormal(samples[0].values)
+		} else {
+			statistic, p = ksTwoSample(samples[0].values, samples[1].values)
+		}
+		effect = statistic
+	}
+	ht.finish(result, statistic, p, effect)
+	return result, nil
+}
+
+// samples collects the samples of the test: one per field, or one per value of
+// the group field in order of first appearance
+func (ht *HypothesisTest) samples(frame *query.Frame) ([]sample, error) {
+	var samples []sample
+	switch {
+	case ht.req.Test == models.TestPairedT:
+		x, y := completePairs(frame.Columns[ht.indices[0]], frame.Columns[ht.indices[1]])
+		samples = []sample{{name: ht.req.Fields[0], values: x}, {name: ht.req.Fields[1], values: y}}
+	case ht.group >= 0:
+		key := keyFunc(ht.groupType)
+		positions := make(map[string]int)
+		values := frame.Columns[ht.indices[0]]
+		for r, groupValue := range frame.Columns[ht.group] {
+			v, ok := query.ToFloat(values[r])
+			if groupValue == nil || !ok {
+				continue
+			}
+			k := key(groupValue)
+			position, ok := positions[k]
+			if !ok {
+				position = len(samples)
+				positions[k] = position
+				samples = append(samples, sample{name: query.FormatValue(groupValue)})
+			}
+			samples[position].values = append(samples[position].values, v)
+		}
+	default:
+		for i, index := range ht.indices {
+			samples = append(samples, sample{name: ht.req.Fields[i], values: Numbers(frame.Columns[index])})
+		}
+	}
+
+	switch ht.req.Test {
+	case models.TestTwoSampleT, models.TestMannWhitney, models.TestKolmogorovSmirnov:
+		if ht.group >= 0 && len(samples) != 2 {
+			return nil, models.NewValidationError("group field %q has %d values; %s compares two groups", ht.req.GroupField, len(samples), ht.req.Test)
+		}
+	case models.TestANOVA:
+		if len(samples) < 2 {
+			return nil, models.NewValidationError("group field %q has %d values; %s compares at least two groups", ht.req.GroupField, len(samples), ht.req.Test)
+		}
+	}
+
+	least := 2
+	if ht.req.Test == models.TestMannWhitney || ht.req.Test == models.TestKolmogorovSmirnov {
+		least = 1
+	}
+	for _, s := range samples {
+		if len(s.values) < least {
+			return nil, models.NewValidationError("sample %q has %d values; %s needs at least %d", s.name, len(s.values), ht.req.Test, least)
+		}
+	}
+	return samples, nil
+}
+
+// finish sets the statistic, p-value and effect size of a result and decides
+// its significance
+func (ht *HypothesisTest) finish(result *models.HypothesisTestResult, statistic, p, effect float64) {
+	result.Statistic = finitePtr(statistic)
+	result.PValue = finitePtr(p)
+	result.EffectSize = finitePtr(effect)
+	result.Significant = p < ht.params.Alpha
+}
+
+// oneSampleT tests the mean of values against mu, with Cohen's d as the
+// effect size
+func (ht *HypothesisTest) oneSampleT(values []float64, mu float64) (t, df, p, d float64) {
+	n := float64(len(values))
+	mean, sd := Mean(values), StdDev(values)
+	t = (mean - mu) / (sd / math.Sqrt(n))
+	df = n - 1
+	return t, df, ht.tPValue(t, df), (mean - mu) / sd
+}
+
+// twoSampleT compares the means of two samples with Welch's test, or
+// Student's when the variances are pooled. Cohen's d uses the pooled standard
+// deviation either way.
+func (ht *HypothesisTest) twoSampleT(x, y []float64) (t, df, p, d float64) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	v1, v2 := Variance(x), Variance(y)
+	difference := Mean(x) - Mean(y)
+	pooled := ((n1-1)*v1 + (n2-1)*v2) / (n1 + n2 - 2)
+
+	if ht.params.EqualVariance {
+		t = difference / math.Sqrt(pooled*(1/n1+1/n2))
+		df = n1 + n2 - 2
+	} else {
+		s1, s2 := v1/n1, v2/n2
+		t = difference / math.Sqrt(s1+s2)
+		df = (s1 + s2) * (s1 + s2) / (s1*s1/(n1-1) + s2*s2/(n2-1))
+	}
+	return t, df, ht.tPValue(t, df), difference / math.Sqrt(pooled)
+}
+
+// tPValue returns the p-value of a t statistic under the alternative
+func (ht *HypothesisTest) tPValue(t, df float64) float64 {
+	switch ht.params.Alternative {
+	case alternativeLess:
+		return StudentTCDF(t, df)
+	case alternativeGreater:
+		return 1 - StudentTCDF(t, df)
+	default:
+		return TwoSidedTPValue(t, df)
+	}
+}
+
+// mannWhitney returns the U statistic of the first sample with the p-value of
+// its normal approximation, corrected for ties and continuity, and the
+// rank-biserial correlation, positive when the first sample tends to be larger
+func (ht *HypothesisTest) mannWhitney(x, y []float64) (u, p, r float64) {
+	n1, n2 := float64(len(x)), float64(len(y))
+	combined := append(append([]float64(nil), x...), y...)
+	ranks := Ranks(combined)
+	var rankSum float64
+	for _, rank := range ranks[:len(x)] {
+		rankSum += rank
+	}
+	u = rankSum - n1*(n1+1)/2
+	r = 2*u/(n1*n2) - 1
+
+	n := n1 + n2
+	var ties float64
+	for _, t := range tieGroups(Sorted(combined)) {
+		tf := float64(t)
+		ties += tf*tf*tf - tf
+	}
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if !(sigma > 0) {
+		return u, math.NaN(), r
+	}
+
+	deviation := u - n1*n2/2
+	switch ht.params.Alternative {
+	case alternativeLess:
+		p = NormalCDF((deviation + 0.5) / sigma)
+	case alternativeGreater:
+		p = 1 - NormalCDF((deviation-0.5)/sigma)
+	default:
+		p = math.Min(1, TwoSidedNormalPValue(math.Max(math.Abs(deviation)-0.5, 0)/sigma))
+	}
+	return u, p, r
+}
+
+// anova compares the means of samples with a one-way analysis of variance,
+// with eta squared as the effect size
+func anova(samples []sample) (f float64, df []float64, p, etaSquared float64) {
+	var all []float64
+	for _, s := range samples {
+		all = append(all, s.values...)
+	}
+	grandMean := Mean(all)
+
+	var between, within float64
+	for _, s := range samples {
+		mean := Mean(s.values)
+		between += float64(len(s.values)) * (mean - grandMean) * (mean - grandMean)
+		for _, v := range s.values {
+			within += (v - mean) * (v - mean)
+		}
+	}
+	k, n := float64(len(samples)), float64(len(all))
+	df = []float64{k - 1, n - k}
+	f = (between / df[0]) / (within / df[1])
+	p = math.NaN()
+	if !math.IsNaN(f) {
+		p = 1 - FCDF(f, df[0], df[1])
+	}
+	return f, df, p, between / (between + within)
+}
+
+// ksNormal compares a sample with a normal distribution, its mean and standard
+// deviation estimated from the sample unless params set them
+func (ht *HypothesisTest) ksNormal(values []float64) (d, p float64) {
+	mean, sd := Mean(values), StdDev(values)
+	if ht.params.Mean != nil {
+		mean = *ht.params.Mean
+	}
+	if ht.params.StdDev != nil {
+		sd = *ht.params.StdDev
+	}
+	if !(sd > 0) {
+		return math.NaN(), math.NaN()
+	}
+
+	sorted := Sorted(values)
+	n := float64(len(sorted))
+	for i, v := range sorted {
+		cdf := NormalCDF((v - mean) / sd)
+		d = math.Max(d, math.Max(float64(i+1)/n-cdf, cdf-float64(i)/n))
+	}
+	return d, kolmogorovPValue(d, n)
+}
+
+// ksTwoSample returns the largest distance between the empirical distributions
+// of two samples with its asymptotic p-value
+func ksTwoSample(x, y []float64) (d, p float64) {
+	a, b := Sorted(x), Sorted(y)
+	n1, n2 := float64(len(a)), float64(len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		v := math.Min(a[i], b[j])
+		for i < len(a) && a[i] == v {
+			i++
+		}
+		for j < len(b) && b[j] == v {
+			j++
+		}
+		d = math.Max(d, math.Abs(float64(i)/n1-float64(j)/n2))
+	}
+	return d, kolmogorovPValue(d, n1*n2/(n1+n2))
+}
+
+// kolmogorovPValue returns the asymptotic p-value of a Kolmogorov-Smirnov
+// distance over an effective sample size n, with the small sample correction
+// of Stephens (1970)
+func kolmogorovPValue(d, n float64) float64 {
+	root := math.Sqrt(n)
+	lambda := (root + 0.12 + 0.11/root) * d
+	if lambda < 0.2 {
+		return 1
+	}
+	// Q(lambda) = 2 sum_{j>=1} (-1)^(j-1) exp(-2 j^2 lambda^2)
+	var sum float64
+	sign := 1.0
+	for j := 1; j <= 100; j++ {
+		term := sign * math.Exp(-2*float64(j*j)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, 2*sum))
+}
+
+// chiSquare tests the independence of two fields over the rows where both have
+// a value, with Cramér's V as the effect size
+func (ht *HypothesisTest) chiSquare(frame *query.Frame, result *models.HypothesisTestResult) error {
+	keyA, keyB := keyFunc(ht.types[0]), keyFunc(ht.types[1])
+	a, b := frame.Columns[ht.indices[0]], frame.Columns[ht.indices[1]]
+	rows, columns := make(map[string]int), make(map[string]int)
+	counts := make(map[[2]int]float64)
+	var n float64
+	for r := range a {
+		if a[r] == nil || b[r] == nil {
+			continue
+		}
+		i, ok := rows[keyA(a[r])]
+		if !ok {
+			i = len(rows)
+			rows[keyA(a[r])] = i
+		}
+		j, ok := columns[keyB(b[r])]
+		if !ok {
+			j = len(columns)
+			columns[keyB(b[r])] = j
+		}
+		counts[[2]int{i, j}]++
+		n++
+	}
+	for f, levels := range []int{len(rows), len(columns)} {
+		if levels < 2 {
+			return models.NewValidationError("field %q has %d distinct values where both fields are set; %s needs at least 2", ht.req.Fields[f], levels, ht.req.Test)
+		}
+	}
+
+	rowTotals := make([]float64, len(rows))
+	columnTotals := make([]float64, len(columns))
+	for cell, count := range counts {
+		rowTotals[cell[0]] += count
+		columnTotals[cell[1]] += count
+	}
+	var chi float64
+	for i, rowTotal := range rowTotals {
+		for j, columnTotal := range columnTotals {
+			expected := rowTotal * columnTotal / n
+			observed := counts[[2]int{i, j}]
+			chi += (observed - expected) * (observed - expected) / expected
+		}
+	}
+	df := float64((len(rows) - 1) * (len(columns) - 1))
+	smaller := math.Min(float64(len(rows)-1), float64(len(columns)-1))
+
+	result.DegreesOfFreedom = []float64{df}
+	for _, name := range ht.req.Fields {
+		result.Samples = append(result.Samples, models.TestSample{Name: name, Count: int(n)})
+	}
+	ht.finish(result, chi, 1-ChiSquareCDF(chi, df), math.Sqrt(chi/(n*smaller)))
+	return nil
+}
@@ -0,0 +1,206 @@
+package analytics
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var trialFields = []models.DataField{
+	{Name: "before", Type: models.DataTypeFloat},
+	{Name: "after", Type: models.DataTypeFloat},
+	{Name: "arm", Type: models.DataTypeString},
+	{Name: "outcome", Type: models.DataTypeString},
+}
+
+// trialFrame holds the before and after values of five patients in each arm
+func trialFrame() *query.Frame {
+	before := []float64{1, 2, 3, 4, 5, 3, 4, 5, 6, 7}
+	after := []float64{2, 2, 4, 5, 7, 3, 5, 5, 7, 8}
+	rows := make([]map[string]interface{}, len(before))
+	for i := range rows {
+		arm := "placebo"
+		if i >= 5 {
+			arm = "treatment"
+		}
+		rows[i] = map[string]interface{}{"before": before[i], "after": after[i], "arm": arm}
+	}
+	rows = append(rows, map[string]interface{}{"before": nil, "after": 4.0, "arm": "placebo"})
+	return query.NewFrame(trialFields, rows)
+}
+
+func runHypothesisTest(t *testing.T, req *models.HypothesisTestRequest, frame *query.Frame) *models.HypothesisTestResult {
+	test, err := CompileHypothesisTest(req, trialFields)
+	require.NoError(t, err)
+	result, err := test.Compute(frame)
+	require.NoError(t, err)
+	return result
+}
+
+func TestHypothesisTest(t *testing.T) {
+	// Test Case 1: One-sample t-test against mu
+	t.Run("One Sample T", func(t *testing.T) {
+		req := &models.HypothesisTestRequest{Test: models.TestOneSampleT, Fields: []string{"before"}, Params: map[string]interface{}{"mu": 2}}
+		frame := query.NewFrame(trialFields, []map[string]interface{}{
+			{"before": 1.0}, {"before": 2.0}, {"before": 3.0}, {"before": 4.0}, {"before": 5.0}, {"before": nil},
+		})
+		result := runHypothesisTest(t, req, frame)
+
+		assert.InDelta(t, 1.414214, *result.Statistic, 1e-6)
+		assert.Equal(t, []float64{4}, result.DegreesOfFreedom)
+		assert.InDelta(t, 0.230200, *result.PValue, 1e-5)
+		assert.InDelta(t, 0.632456, *result.EffectSize, 1e-6)
+		assert.Equal(t, "cohens_d", result.EffectSizeType)
+		assert.False(t, result.Significant)
+		require.Len(t, result.Samples, 1)
+		assert.Equal(t, 5, result.Samples[0].Count)
+	})
+
+	// Test Case 2: Two-sample t-test between the groups of a field
+	t.Run("Two Sample T", func(t *testing.T) {
+		req := &models.HypothesisTestRequest{Test: models.TestTwoSampleT, Fields: []string{"before"}, GroupField: "arm"}
+		result := runHypothesisTest(t, req, trialFrame())
+
+		require.Len(t, result.Samples, 2)
+		assert.Equal(t, "placebo", result.Samples[0].Name)
+		assert.Equal(t, "treatment", result.Samples[1].Name)
+		assert.InDelta(t, -2, *result.Statistic, 1e-9)
+		assert.InDelta(t, 8, result.DegreesOfFreedom[0], 1e-9)
+		assert.InDelta(t, 0.080516, *result.PValue, 1e-5)
+		assert.InDelta(t, -1.264911, *result.EffectSize, 1e-6)
+
+		// A one-sided alternative halves the p-value in its direction
+		req.Params = map[string]interface{}{"alternative": "less", "equal_variance": true}
+		less := runHypothesisTest(t, req, trialFrame())
+		assert.InDelta(t, *result.PValue/2, *less.PValue, 1e-9)
+	})
+
+	// Test Case 3: Paired t-test over the rows where both fields are set
+	t.Run("Paired T", func(t *testing.T) {
+		req := &models.HypothesisTestRequest{Test: models.TestPairedT, Fields: []string{"before", "after"}, Params: map[string]interface{}{"alpha": 0.1}}
+		result := runHypothesisTest(t, req, trialFrame())
+
+		assert.Equal(t, 10, result.Samples[0].Count)
+		assert.InDelta(t, -4, *result.Statistic, 1e-9)
+		assert.Equal(t, []float64{9}, result.DegreesOfFreedom)
+		assert.Less(t, *result.PValue, 0.01)
+		assert.True(t, result.Significant)
+	})
+
+	// Test Case 4: Mann-Whitney U with its normal approximation
+	t.Run("Mann-Whitney", func(t *testing.T) {
+		frame := query.NewFrame(trialFields, []map[string]interface{}{
+			{"before": 1.0, "after": 4.0}, {"before": 2.0, "after": 5.0}, {"before": 3.0, "after": 6.0},
+		})
+		result := runHypothesisTest(t, &models.HypothesisTestRequest{Test: models.TestMannWhitney, Fields: []string{"before", "after"}}, frame)
+
+		assert.Equal(t, 0.0, *result.Statistic)
+		assert.InDelta(t, 0.080856, *result.PValue, 1e-5)
+		assert.Equal(t, -1.0, *result.EffectSize)
+		assert.Nil(t, result.DegreesOfFreedom)
+	})
+
+	// Test Case 5: One-way ANOVA across fields
+	t.Run("ANOVA", func(t *testing.T) {
+		fields := []models.DataField{{Name: "a", Type: models.DataTypeInteger}, {Name: "b", Type: models.DataTypeInteger}, {Name: "c", Type: models.DataTypeInteger}}
+		frame := query.NewFrame(fields, []map[string]interface{}{
+			{"a": 1, "b": 4, "c": 7}, {"a": 2, "b": 5, "c": 8}, {"a": 3, "b": 6, "c": 9},
+		})
+		test, err := CompileHypothesisTest(&models.HypothesisTestRequest{Test: models.TestANOVA, Fields: []string{"a", "b", "c"}}, fields)
+		require.NoError(t, err)
+		result, err := test.Compute(frame)
+		require.NoError(t, err)
+
+		assert.InDelta(t, 27, *result.Statistic, 1e-9)
+		assert.Equal(t, []float64{2, 6}, result.DegreesOfFreedom)
+		assert.InDelta(t, 0.001, *result.PValue, 1e-9)
+		assert.InDelta(t, 0.9, *result.EffectSize, 1e-9)
+	})
+
+	// Test Case 6: Chi-square independence of two categorical fields
+	t.Run("Chi-Square", func(t *testing.T) {
+		var rows []map[string]interface{}
+		cells := map[[2]string]int{{"placebo", "better"}: 10, {"placebo", "same"}: 20, {"treatment", "better"}: 20, {"treatment", "same"}: 10}
+		for _, cell := range [][2]string{{"placebo", "better"}, {"placebo", "same"}, {"treatment", "better"}, {"treatment", "same"}} {
+			for i := 0; i < cells[cell]; i++ {
+				rows = append(rows, map[string]interface{}{"arm": cell[0], "outcome": cell[1]})
+			}
+		}
+		rows = append(rows, map[string]interface{}{"arm": "placebo"})
+		result := runHypothesisTest(t, &models.HypothesisTestRequest{Test: models.TestChiSquare, Fields: []string{"arm", "outcome"}}, query.NewFrame(trialFields, rows))
+
+		assert.InDelta(t, 20.0/3, *result.Statistic, 1e-9)
+		assert.Equal(t, []float64{1}, result.DegreesOfFreedom)
+		assert.InDelta(t, 0.009823, *result.PValue, 1e-5)
+		assert.InDelta(t, 1.0/3, *result.EffectSize, 1e-9)
+		assert.Equal(t, 60, result.Samples[0].Count)
+	})
+
+	// Test Case 7: Kolmogorov-Smirnov against a normal and between samples
+	t.Run("Kolmogorov-Smirnov", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		var rows []map[string]interface{}
+		for i := 0; i < 200; i++ {
+			rows = append(rows, map[string]interface{}{"before": 10 + 2*rng.NormFloat64(), "after": 10 + 4*rng.Float64()})
+		}
+		frame := query.NewFrame(trialFields, rows)
+
+		normal := runHypothesisTest(t, &models.HypothesisTestRequest{Test: models.TestKolmogorovSmirnov, Fields: []string{"before"},
+			Params: map[string]interface{}{"mean": 10, "std_dev": 2}}, frame)
+		assert.Greater(t, *normal.PValue, 0.05)
+
+		shifted := runHypothesisTest(t, &models.HypothesisTestRequest{Test: models.TestKolmogorovSmirnov, Fields: []string{"before"},
+			Params: map[string]interface{}{"mean": 12, "std_dev": 2}}, frame)
+		assert.Less(t, *shifted.PValue, 0.001)
+
+		frame = query.NewFrame(trialFields, []map[string]interface{}{
+			{"before": 1.0, "after": 5.0}, {"before": 2.0, "after": 6.0}, {"before": 3.0, "after": 7.0}, {"before": 4.0, "after": 8.0},
+		})
+		twoSample := runHypothesisTest(t, &models.HypothesisTestRequest{Test: models.TestKolmogorovSmirnov, Fields: []string{"before", "after"}}, frame)
+		assert.Equal(t, 1.0, *twoSample.Statistic)
+		assert.Equal(t, 1.0, *twoSample.EffectSize)
+		assert.Less(t, *twoSample.PValue, 0.05)
+	})
+
+	// Test Case 8: A group field with more than two values is rejected for two-sample tests
+	t.Run("Too Many Groups", func(t *testing.T) {
+		frame := query.NewFrame(trialFields, []map[string]interface{}{
+			{"before": 1.0, "arm": "a"}, {"before": 2.0, "arm": "b"}, {"before": 3.0, "arm": "c"},
+		})
+		test, err := CompileHypothesisTest(&models.HypothesisTestRequest{Test: models.TestMannWhitney, Fields: []string{"before"}, GroupField: "arm"}, trialFields)
+		require.NoError(t, err)
+		_, err = test.Compute(frame)
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 9: Invalid requests are rejected with validation errors
+	invalid := map[string]*models.HypothesisTestRequest{
+		"Unknown Test":         {Test: "z_test", Fields: []string{"before"}},
+		"Unknown Field":        {Test: models.TestOneSampleT, Fields: []string{"weight"}},
+		"String Field":         {Test: models.TestOneSampleT, Fields: []string{"arm"}},
+		"Paired One Field":     {Test: models.TestPairedT, Fields: []string{"before"}},
+		"Two Sample Three":     {Test: models.TestTwoSampleT, Fields: []string{"before", "after", "arm"}},
+		"Grouped Two Fields":   {Test: models.TestANOVA, Fields: []string{"before", "after"}, GroupField: "arm"},
+		"Group Is Field":       {Test: models.TestANOVA, Fields: []string{"before"}, GroupField: "before"},
+		"Duplicate Field":      {Test: models.TestPairedT, Fields: []string{"before", "before"}},
+		"One-Sided Chi-Square": {Test: models.TestChiSquare, Fields: []string{"arm", "outcome"}, Params: map[string]interface{}{"alternative": "greater"}},
+		"Mu Not One Sample":    {Test: models.TestPairedT, Fields: []string{"before", "after"}, Params: map[string]interface{}{"mu": 1}},
+		"KS Mean Two Samples":  {Test: models.TestKolmogorovSmirnov, Fields: []string{"before", "after"}, Params: map[string]interface{}{"mean": 1}},
+		"Alpha Too High":       {Test: models.TestOneSampleT, Fields: []string{"before"}, Params: map[string]interface{}{"alpha": 2}},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileHypothesisTest(req, trialFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package analytics
+
+import "math"
+
+// choleskySolve solves a·x = b for a symmetric positive definite matrix a. It
+// reports false when a is not positive definite, in which case x is nil.
+func choleskySolve(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(a)
+	// a = L·Lᵀ with L lower triangular
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, i+1)
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 || math.IsNaN(sum) {
+					return nil, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+
+	// Forward substitution for L·z = b, then back substitution for Lᵀ·x = z
+	x := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	for i := n - 1; i >= 0; i-- {
+		sum := x[i]
+		for k := i + 1; k < n; k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x, true
+}
+
+// choleskyInverse inverts a symmetric positive definite matrix, reporting
+// false when it is not positive definite
+func choleskyInverse(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	inverse := make([][]float64, n)
+	for j := 0; j < n; j++ {
+		unit := make([]float64, n)
+		unit[j] = 1
+		column, ok := choleskySolve(a, unit)
+		if !ok {
+			return nil, false
+		}
+		inverse[j] = column
+	}
+	// The columns of a symmetric inverse are also its rows
+	return inverse, true
+}
@@ -0,0 +1,391 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// correlationMethods maps each correlation method to its coefficient
+var correlationMethods = map[models.CorrelationMethod]func(x, y []float64) Coefficient{
+	models.CorrelationPearson:  Pearson,
+	models.CorrelationSpearman: Spearman,
+	models.CorrelationKendall:  Kendall,
+}
+
+// Correlation is a validated correlation request
+type Correlation struct {
+	method  models.CorrelationMethod
+	names   []string
+	indices []int
+	missing models.MissingValues
+}
+
+// CompileCorrelation validates a correlation request against the fields of the
+// dataset. The method must be known and the fields distinct and numeric.
+func CompileCorrelation(req *models.CorrelationRequest, fields []models.DataField) (*Correlation, error) {
+	if _, ok := correlationMethods[req.Method]; !ok {
+		return nil, models.NewValidationError("unknown correlation method %q", req.Method)
+	}
+	indices, err := numericFields(fields, req.Fields)
+	if err != nil {
+		return nil, models.NewValidationError("%v", err)
+	}
+	missing, err := checkMissing(req.Missing)
+	if err != nil {
+		return nil, err
+	}
+	return &Correlation{method: req.Method, names: req.Fields, indices: indices, missing: missing}, nil
+}
+
+// checkMissing validates a missing value handling, pairwise by default
+func checkMissing(missing models.MissingValues) (models.MissingValues, error) {
+	switch missing {
+	case "":
+		return models.MissingPairwise, nil
+	case models.MissingPairwise, models.MissingListwise:
+		return missing, nil
+	default:
+		return "", models.NewValidationError("unknown missing value handling %q", missing)
+	}
+}
+
+// numericFields resolves at least two distinct numeric fields to their positions
+func numericFields(fields []models.DataField, names []string) ([]int, error) {
+	if len(names) < 2 {
+		return nil, fmt.Errorf("at least two fields are required")
+	}
+	indices := make([]int, len(names))
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("field %q is listed twice", name)
+		}
+		seen[name] = true
+
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if !isNumeric(fields[index].Type) {
+			return nil, fmt.Errorf("field %q is %s, not numeric", name, fields[index].Type)
+		}
+		indices[i] = index
+	}
+	return indices, nil
+}
+
+// Compute evaluates the correlation matrix over a frame with the fields the
+// request was compiled for. With pairwise missing values each pair of fields
+// is correlated over the rows where both are present, so a null only removes
+// a row from the pairs of its own field; listwise, a null removes its row from
+// every pair. Entry [i][j] relates Fields[i] and Fields[j]; the matrix is
+// symmetric and entries are nil where the correlation is undefined.
+func (c *Correlation) Compute(frame *query.Frame) *models.CorrelationResult {
+	coefficient := correlationMethods[c.method]
+	columns := matrixColumns(frame, c.indices, c.missing)
+
+	n := len(columns)
+	result := &models.CorrelationResult{
+		Method:       c.method,
+		Fields:       c.names,
+		Missing:      c.missing,
+		Correlation:  squareMatrix(n),
+		PValues:      squareMatrix(n),
+		Observations: countMatrix(n),
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			x, y := completePairs(columns[i], columns[j])
+			r := coefficient(x, y)
+			result.Correlation[i][j], result.Correlation[j][i] = finitePtr(r.R), finitePtr(r.R)
+			result.PValues[i][j], result.PValues[j][i] = finitePtr(r.P), finitePtr(r.P)
+			result.Observations[i][j], result.Observations[j][i] = len(x), len(x)
+		}
+	}
+	return result
+}
+
+// Covariance is a validated covariance request
+type Covariance struct {
+	names   []string
+	indices []int
+	missing models.MissingValues
+}
+
+// CompileCovariance validates a covariance request against the fields of the
+// dataset. The fields must be distinct and numeric.
+func CompileCovariance(req *models.CovarianceRequest, fields []models.DataField) (*Covariance, error) {
+	indices, err := numericFields(fields, req.Fields)
+	if err != nil {
+		return nil, models.NewValidationError("%v", err)
+	}
+	missing, err := checkMissing(req.Missing)
+	if err != nil {
+		return nil, err
+	}
+	return &Covariance{names: req.Fields, indices: indices, missing: missing}, nil
+}
+
+// Compute evaluates the sample covariance matrix over a frame with the fields
+// the request was compiled for, handling missing values like correlations.
+// The diagonal holds the variances of the fields.
+func (c *Covariance) Compute(frame *query.Frame) *models.CovarianceResult {
+	columns := matrixColumns(frame, c.indices, c.missing)
+
+	n := len(columns)
+	result := &models.CovarianceResult{
+		Fields:       c.names,
+		Missing:      c.missing,
+		Covariance:   squareMatrix(n),
+		Observations: countMatrix(n),
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			x, y := completePairs(columns[i], columns[j])
+			cov := finitePtr(covariance(x, y))
+			result.Covariance[i][j], result.Covariance[j][i] = cov, cov
+			result.Observations[i][j], result.Observations[j][i] = len(x), len(x)
+		}
+	}
+	return result
+}
+
+// PartialCorrelation is a validated partial correlation request
+type PartialCorrelation struct {
+	method   models.CorrelationMethod
+	names    []string
+	controls []string
+	// indices holds the positions of the fields followed by the controls
+	indices []int
+	missing models.MissingValues
+}
+
+// CompilePartialCorrelation validates a partial correlation request against
+// the fields of the dataset. The fields and controls must be distinct and
+// numeric, and the method pearson or spearman.
+func CompilePartialCorrelation(req *models.PartialCorrelationRequest, fields []models.DataField) (*PartialCorrelation, error) {
+	if req.Method != models.CorrelationPearson && req.Method != models.CorrelationSpearman {
+		return nil, models.NewValidationError("partial correlation supports pearson and spearman, not %q", req.Method)
+	}
+	if len(req.Controls) == 0 {
+		return nil, models.NewValidationError("at least one control field is required")
+	}
+	names := append(append([]string(nil), req.Fields...), req.Controls...)
+	indices, err := numericFields(fields, names)
+	if err != nil {
+		return nil, models.NewValidationError("%v", err)
+	}
+	missing, err := checkMissing(req.Missing)
+	if err != nil {
+		return nil, err
+	}
+	return &PartialCorrelation{method: req.Method, names: req.Fields, controls: req.Controls, indices: indices, missing: missing}, nil
+}
+
+// Compute evaluates the partial correlation matrix over a frame with the
+// fields the request was compiled for. Each pair of fields is correlated
+// after regressing both on the controls, over the rows where the pair and
+// every control are present (pairwise) or where every field is (listwise);
+// spearman ranks those rows first. The p-values test the correlations on
+// n - 2 - k degrees of freedom for k controls.
+func (pc *PartialCorrelation) Compute(frame *query.Frame) *models.CorrelationResult {
+	columns := matrixColumns(frame, pc.indices, pc.missing)
+	controls := columns[len(pc.names):]
+
+	n := len(pc.names)
+	result := &models.CorrelationResult{
+		Method:       pc.method,
+		Fields:       pc.names,
+		Controls:     pc.controls,
+		Missing:      pc.missing,
+		Correlation:  squareMatrix(n),
+		PValues:      squareMatrix(n),
+		Observations: countMatrix(n),
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			rows := completeRows(append([][]interface{}{columns[i], columns[j]}, controls...))
+			values := make([][]float64, len(rows))
+			for c, column := range rows {
+				values[c] = Numbers(column)
+				if pc.method == models.CorrelationSpearman {
+					values[c] = Ranks(values[c])
+				}
+			}
+			count := len(values[0])
+			result.Observations[i][j], result.Observations[j][i] = count, count
+
+			r, p := partialCorrelation(values[0], values[1], values[2:])
+			result.Correlation[i][j], result.Correlation[j][i] = finitePtr(r), finitePtr(r)
+			result.PValues[i][j], result.PValues[j][i] = finitePtr(p), finitePtr(p)
+		}
+	}
+	return result
+}
+
+// partialCorrelation returns the correlation of x and y controlling for the
+// control columns, with its p-value, or NaNs when it is undefined
+func partialCorrelation(x, y []float64, controls [][]float64) (float64, float64) {
+	n, k := len(x), len(controls)
+	if n-k < 3 {
+		return math.NaN(), math.NaN()
+	}
+	z := make([][]float64, n)
+	for r := range z {
+		z[r] = make([]float64, k)
+		for c, control := range controls {
+			z[r][c] = control[r]
+		}
+	}
+	rx, okX := residuals(z, x)
+	ry, okY := residuals(z, y)
+	if !okX || !okY {
+		return math.NaN(), math.NaN()
+	}
+	r := Pearson(rx, ry).R
+	if math.IsNaN(r) {
+		return math.NaN(), math.NaN()
+	}
+	return r, correlationPValue(r, n-k)
+}
+
+// residuals regresses y on the columns of z with an intercept and returns the
+// residuals, reporting false when the columns of z are constant or collinear.
+// Centering every column first takes the place of the intercept.
+func residuals(z [][]float64, y []float64) ([]float64, bool) {
+	k := len(z[0])
+	centered := make([][]float64, len(z))
+	for r := range centered {
+		centered[r] = make([]float64, k)
+	}
+	for c := 0; c < k; c++ {
+		var mean float64
+		for _, row := range z {
+			mean += row[c]
+		}
+		mean /= float64(len(z))
+		for r, row := range z {
+			centered[r][c] = row[c] - mean
+		}
+	}
+	my := Mean(y)
+
+	gram := make([][]float64, k)
+	for j := range gram {
+		gram[j] = make([]float64, k)
+	}
+	moments := make([]float64, k)
+	for r, row := range centered {
+		for j := 0; j < k; j++ {
+			moments[j] += row[j] * (y[r] - my)
+			for l := 0; l < k; l++ {
+				gram[j][l] += row[j] * row[l]
+			}
+		}
+	}
+	beta, ok := choleskySolve(gram, moments)
+	if !ok {
+		return nil, false
+	}
+	out := make([]float64, len(y))
+	for r, row := range centered {
+		out[r] = y[r] - my - dot(row, beta)
+	}
+	return out, true
+}
+
+// covariance returns the sample covariance of paired samples, or NaN for
+// fewer than two pairs
+func covariance(x, y []float64) float64 {
+	n := len(x)
+	if n < 2 {
+		return math.NaN()
+	}
+	mx, my := Mean(x), Mean(y)
+	var sum float64
+	for i := range x {
+		sum += (x[i] - mx) * (y[i] - my)
+	}
+	return sum / float64(n-1)
+}
+
+// matrixColumns returns the columns at the given positions of a frame, keeping
+// only the rows where all of them are numeric when missing values are
+// handled listwise
+func matrixColumns(frame *query.Frame, indices []int, missing models.MissingValues) [][]interface{} {
+	columns := make([][]interface{}, len(indices))
+	for i, index := range indices {
+		columns[i] = frame.Columns[index]
+	}
+	if missing == models.MissingListwise {
+		columns = completeRows(columns)
+	}
+	return columns
+}
+
+// completeRows keeps the rows where every column holds a number
+func completeRows(columns [][]interface{}) [][]interface{} {
+	kept := make([][]interface{}, len(columns))
+	if len(columns) == 0 {
+		return kept
+	}
+	for r := range columns[0] {
+		complete := true
+		for _, column := range columns {
+			if v, ok := query.ToFloat(column[r]); !ok || math.IsNaN(v) {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			for c, column := range columns {
+				kept[c] = append(kept[c], column[r])
+			}
+		}
+	}
+	return kept
+}
+
+// completePairs returns the numbers of two columns at the rows where both are numeric
+func completePairs(a, b []interface{}) ([]float64, []float64) {
+	x := make([]float64, 0, len(a))
+	y := make([]float64, 0, len(a))
+	for r := range a {
+		fa, okA := query.ToFloat(a[r])
+		fb, okB := query.ToFloat(b[r])
+		if okA && okB {
+			x = append(x, fa)
+			y = append(y, fb)
+		}
+	}
+	return x, y
+}
+
+// squareMatrix allocates an n by n matrix of nil entries
+func squareMatrix(n int) [][]*float64 {
+	matrix := make([][]*float64, n)
+	for i := range matrix {
+		matrix[i] = make([]*float64, n)
+	}
+	return matrix
+}
+
+// countMatrix allocates an n by n matrix of zero counts
+func countMatrix(n int) [][]int {
+	matrix := make([][]int, n)
+	for i := range matrix {
+		matrix[i] = make([]int, n)
+	}
+	return matrix
+}
+
+// finitePtr returns a pointer to f, or nil when it is NaN or infinite
+func finitePtr(f float64) *float64 {
+	if finite(f) == nil {
+		return nil
+	}
+	return &f
+}
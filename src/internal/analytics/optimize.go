@@ -0,0 +1,119 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// minimize searches for the point minimising f with the Nelder-Mead simplex
+// method, starting around x0. It needs no derivatives, which suits the small
+// model fits of this package, and stops after maxIterations or once the
+// simplex values agree to within a relative tolerance.
+func minimize(f func(x []float64) float64, x0 []float64, maxIterations int) []float64 {
+	const (
+		reflection  = 1.0
+		expansion   = 2.0
+		contraction = 0.5
+		shrink      = 0.5
+		tolerance   = 1e-10
+	)
+
+	n := len(x0)
+	if n == 0 {
+		return x0
+	}
+
+	// Initial simplex: x0 and one step along each axis
+	points := make([][]float64, n+1)
+	values := make([]float64, n+1)
+	for i := range points {
+		points[i] = append([]float64(nil), x0...)
+		if i > 0 {
+			step := 0.1 * math.Abs(x0[i-1])
+			if step == 0 {
+				step = 0.1
+			}
+			points[i][i-1] += step
+		}
+		values[i] = safe(f(points[i]))
+	}
+
+	order := make([]int, n+1)
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+		best, worst, second := order[0], order[n], order[n-1]
+		if math.Abs(values[worst]-values[best]) <= tolerance*(math.Abs(values[best])+tolerance) {
+			break
+		}
+
+		// Centroid of all points but the worst
+		centroid := make([]float64, n)
+		for _, i := range order[:n] {
+			for k := range centroid {
+				centroid[k] += points[i][k] / float64(n)
+			}
+		}
+		along := func(scale float64) []float64 {
+			x := make([]float64, n)
+			for k := range x {
+				x[k] = centroid[k] + scale*(points[worst][k]-centroid[k])
+			}
+			return x
+		}
+
+		reflected := along(-reflection)
+		reflectedValue := safe(f(reflected))
+		switch {
+		case reflectedValue < values[best]:
+			expanded := along(-expansion)
+			if expandedValue := safe(f(expanded)); expandedValue < reflectedValue {
+				points[worst], values[worst] = expanded, expandedValue
+			} else {
+				points[worst], values[worst] = reflected, reflectedValue
+			}
+		case reflectedValue < values[second]:
+			points[worst], values[worst] = reflected, reflectedValue
+		default:
+			contracted := along(contraction)
+			if contractedValue := safe(f(contracted)); contractedValue < values[worst] {
+				points[worst], values[worst] = contracted, contractedValue
+				continue
+			}
+			for _, i := range order[1:] {
+				for k := range points[i] {
+					points[i][k] = points[best][k] + shrink*(points[i][k]-points[best][k])
+				}
+				values[i] = safe(f(points[i]))
+			}
+		}
+	}
+
+	best := 0
+	for i := range values {
+		if values[i] < values[best] {
+			best = i
+		}
+	}
+	return points[best]
+}
+
+// safe replaces NaN objective values by +Inf so that the search moves away from them
+func safe(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.Inf(1)
+	}
+	return v
+}
+
+// logistic maps the real line onto (0, 1)
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// logit is the inverse of logistic
+func logit(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
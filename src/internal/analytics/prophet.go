@@ -0,0 +1,397 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Prophet defaults and bounds
+const (
+	defaultChangepoints          = 25
+	defaultChangepointRange      = 0.8
+	defaultChangepointPriorScale = 0.05
+	defaultSeasonalityPriorScale = 10
+	defaultHolidayPriorScale     = 10
+	// trendPriorScale is the prior scale of the initial level and growth
+	trendPriorScale = 5
+
+	maxChangepoints   = 100
+	maxFourierOrder   = 50
+	maxHolidayWindow  = 30
+	holidayDateLayout = "2006-01-02"
+)
+
+// prophetSeasonality is a periodic component with its default Fourier order
+// and the history it needs to be included by default
+type prophetSeasonality struct {
+	name         string
+	period       float64 // days
+	defaultOrder int
+	minSpan      float64 // days
+}
+
+var prophetSeasonalities = []prophetSeasonality{
+	{name: "yearly_seasonality", period: 365.25, defaultOrder: 10, minSpan: 730},
+	{name: "weekly_seasonality", period: 7, defaultOrder: 3, minSpan: 14},
+	{name: "daily_seasonality", period: 1, defaultOrder: 4, minSpan: 2},
+}
+
+// holidayEffect is one day of the window of a holiday, with the start of that
+// day for each date of the holiday
+type holidayEffect struct {
+	name   string
+	offset int
+	days   []time.Time
+}
+
+// prophet is an additive decomposition in the manner of Facebook's Prophet:
+//
+//	y(t) = trend(t) + seasonality(t) + holidays(t) + noise
+//
+// The trend is piecewise linear with potential changepoints spread over the
+// history, the seasonalities are Fourier series of the local time and each
+// holiday day has its own effect. The coefficients are the maximum a
+// posteriori estimates under normal priors, a ridge regression on the scaled
+// series. Prediction intervals add to the noise the variance of trend changes
+// occurring in the future as often and as large as in the history.
+type prophet struct {
+	calendar         *Calendar
+	changepoints     int
+	changepointRange float64
+	orders           []*int
+	priorScales      [3]float64 // changepoints, seasonality, holidays
+	holidays         []holidayEffect
+
+	start       time.Time
+	span, scale float64
+	cuts        []float64
+	resolved    []int
+	beta        []float64
+	sigma       float64
+	deltaScale  float64
+	rate        float64
+	buckets     int
+}
+
+// newProphet returns an unfitted prophet model for checked params
+func newProphet(p *models.ForecastParams, calendar *Calendar) *prophet {
+	m := &prophet{
+		calendar:         calendar,
+		changepoints:     defaultChangepoints,
+		changepointRange: defaultChangepointRange,
+		orders:           []*int{p.YearlySeasonality, p.WeeklySeasonality, p.DailySeasonality},
+		priorScales:      [3]float64{defaultChangepointPriorScale, defaultSeasonalityPriorScale, defaultHolidayPriorScale},
+	}
+	if p.Changepoints != nil {
+		m.changepoints = *p.Changepoints
+	}
+	if p.ChangepointRange != nil {
+		m.changepointRange = *p.ChangepointRange
+	}
+	for i, scale := range []*float64{p.ChangepointPriorScale, p.SeasonalityPriorScale, p.HolidayPriorScale} {
+		if scale != nil {
+			m.priorScales[i] = *scale
+		}
+	}
+
+	loc := calendar.Location()
+	for _, holiday := range p.Holidays {
+		for offset := holiday.LowerWindow; offset <= holiday.UpperWindow; offset++ {
+			effect := holidayEffect{name: holiday.Name, offset: offset}
+			for _, date := range holiday.Dates {
+				day, _ := time.ParseInLocation(holidayDateLayout, date, loc)
+				effect.days = append(effect.days, time.Date(day.Year(), day.Month(), day.Day()+offset, 0, 0, 0, 0, loc))
+			}
+			m.holidays = append(m.holidays, effect)
+		}
+	}
+	return m
+}
+
+// checkProphetParams validates the params of prophet forecasts
+func checkProphetParams(p *models.ForecastParams) error {
+	if p.Changepoints != nil && (*p.Changepoints < 0 || *p.Changepoints > maxChangepoints) {
+		return fmt.Errorf("changepoints must be between 0 and %d", maxChangepoints)
+	}
+	if p.ChangepointRange != nil && (*p.ChangepointRange <= 0 || *p.ChangepointRange > 1) {
+		return fmt.Errorf("changepoint_range must be greater than 0 and at most 1")
+	}
+	scales := map[string]*float64{
+		"changepoint_prior_scale": p.ChangepointPriorScale,
+		"seasonality_prior_scale": p.SeasonalityPriorScale,
+		"holiday_prior_scale":     p.HolidayPriorScale,
+	}
+	for _, name := range []string{"changepoint_prior_scale", "seasonality_prior_scale", "holiday_prior_scale"} {
+		if s := scales[name]; s != nil && !(*s > 0) {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+	for i, order := range []*int{p.YearlySeasonality, p.WeeklySeasonality, p.DailySeasonality} {
+		if order != nil && (*order < 0 || *order > maxFourierOrder) {
+			return fmt.Errorf("%s must be between 0 and %d", prophetSeasonalities[i].name, maxFourierOrder)
+		}
+	}
+
+	names := make(map[string]bool, len(p.Holidays))
+	for _, holiday := range p.Holidays {
+		if holiday.Name == "" {
+			return fmt.Errorf("holidays need a name")
+		}
+		if names[holiday.Name] {
+			return fmt.Errorf("holiday %q is listed more than once", holiday.Name)
+		}
+		names[holiday.Name] = true
+		if len(holiday.Dates) == 0 {
+			return fmt.Errorf("holiday %q has no dates", holiday.Name)
+		}
+		for _, date := range holiday.Dates {
+			if _, err := time.Parse(holidayDateLayout, date); err != nil {
+				return fmt.Errorf("holiday %q: date %q must be formatted as %s", holiday.Name, date, holidayDateLayout)
+			}
+		}
+		if holiday.LowerWindow < -maxHolidayWindow || holiday.LowerWindow > 0 {
+			return fmt.Errorf("holiday %q: lower_window must be between -%d and 0", holiday.Name, maxHolidayWindow)
+		}
+		if holiday.UpperWindow < 0 || holiday.UpperWindow > maxHolidayWindow {
+			return fmt.Errorf("holiday %q: upper_window must be between 0 and %d", holiday.Name, maxHolidayWindow)
+		}
+	}
+	return nil
+}
+
+func (m *prophet) minPoints() int {
+	return 3
+}
+
+func (m *prophet) fit(times []time.Time, y []float64) error {
+	n := len(y)
+	m.buckets = n
+	m.start = times[0]
+	m.span = times[n-1].Sub(m.start).Seconds()
+
+	// Scale the series so that the priors do not depend on its units
+	m.scale = 0
+	for _, v := range y {
+		m.scale = math.Max(m.scale, math.Abs(v))
+	}
+	if m.scale == 0 {
+		m.scale = 1
+	}
+	scaled := make([]float64, n)
+	for i, v := range y {
+		scaled[i] = v / m.scale
+	}
+
+	// Changepoints at evenly spaced points of the first part of the history
+	size := int(float64(n) * m.changepointRange)
+	count := m.changepoints
+	if count > size-1 {
+		count = size - 1
+	}
+	m.cuts = nil
+	for j := 1; j <= count; j++ {
+		index := int(math.Round(float64(j) * float64(size-1) / float64(count)))
+		m.cuts = append(m.cuts, m.scaledTime(times[index]))
+	}
+	m.rate = float64(len(m.cuts)) / float64(n)
+
+	// Seasonalities the history supports, unless set explicitly
+	days := m.span / 86400
+	m.resolved = make([]int, len(prophetSeasonalities))
+	for i, s := range prophetSeasonalities {
+		switch {
+		case m.orders[i] != nil:
+			m.resolved[i] = *m.orders[i]
+		case days >= s.minSpan && m.bucketDays() < s.period:
+			m.resolved[i] = s.defaultOrder
+		}
+	}
+
+	x := make([][]float64, n)
+	for i, t := range times {
+		x[i] = m.features(t)
+	}
+	priors := m.priors()
+
+	// The penalties weigh the priors against the noise, so the noise variance
+	// and the coefficients are estimated in turn
+	variance := math.Max(Variance(scaled), 1e-6)
+	if math.IsNaN(variance) {
+		variance = 1
+	}
+	k := len(priors)
+	for iteration := 0; iteration < 5; iteration++ {
+		a := make([][]float64, k)
+		b := make([]float64, k)
+		for r := range a {
+			a[r] = make([]float64, k)
+			a[r][r] = variance / (priors[r] * priors[r])
+		}
+		for i, row := range x {
+			for r := range row {
+				if row[r] == 0 {
+					continue
+				}
+				b[r] += row[r] * scaled[i]
+				for c := range row {
+					a[r][c] += row[r] * row[c]
+				}
+			}
+		}
+		beta, ok := choleskySolve(a, b)
+		if !ok {
+			return fmt.Errorf("failed to fit prophet model")
+		}
+		m.beta = beta
+
+		var sse float64
+		for i, row := range x {
+			e := scaled[i] - dot(row, beta)
+			sse += e * e
+		}
+		variance = math.Max(sse/float64(n), 1e-12)
+	}
+	m.sigma = math.Sqrt(variance)
+
+	// Future trend changes are as large on average as the fitted ones
+	m.deltaScale = 0
+	for j := range m.cuts {
+		m.deltaScale += math.Abs(m.beta[2+j]) / float64(len(m.cuts))
+	}
+	return nil
+}
+
+// bucketDays returns the nominal length of a bucket in days
+func (m *prophet) bucketDays() float64 {
+	switch m.calendar.interval {
+	case models.TimeSeriesMinute:
+		return 1.0 / 1440
+	case models.TimeSeriesHour:
+		return 1.0 / 24
+	case models.TimeSeriesDay:
+		return 1
+	case models.TimeSeriesWeek:
+		return 7
+	case models.TimeSeriesMonth:
+		return 30.4375
+	case models.TimeSeriesQuarter:
+		return 91.3125
+	}
+	return 365.25
+}
+
+// scaledTime maps the history onto [0, 1]
+func (m *prophet) scaledTime(t time.Time) float64 {
+	if m.span == 0 {
+		return 0
+	}
+	return t.Sub(m.start).Seconds() / m.span
+}
+
+// features returns the regressors at a bucket: level, growth, the growth
+// change after each changepoint, the Fourier terms of the seasonalities and an
+// indicator for each holiday day overlapping the bucket
+func (m *prophet) features(t time.Time) []float64 {
+	ts := m.scaledTime(t)
+	row := []float64{1, ts}
+	for _, cut := range m.cuts {
+		row = append(row, math.Max(0, ts-cut))
+	}
+
+	// Seasonalities follow the local wall clock
+	local := t.In(m.calendar.Location())
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	days := float64(wall.Unix())/86400 + float64(wall.Nanosecond())/86400e9
+	for i, s := range prophetSeasonalities {
+		for k := 1; k <= m.resolved[i]; k++ {
+			angle := 2 * math.Pi * float64(k) * days / s.period
+			row = append(row, math.Sin(angle), math.Cos(angle))
+		}
+	}
+
+	end := m.calendar.Next(t)
+	for _, effect := range m.holidays {
+		indicator := 0.0
+		for _, day := range effect.days {
+			if day.Before(end) && day.AddDate(0, 0, 1).After(t) {
+				indicator = 1
+				break
+			}
+		}
+		row = append(row, indicator)
+	}
+	return row
+}
+
+// priors returns the prior scale of each regressor
+func (m *prophet) priors() []float64 {
+	priors := []float64{trendPriorScale, trendPriorScale}
+	for range m.cuts {
+		priors = append(priors, m.priorScales[0])
+	}
+	for _, order := range m.resolved {
+		for k := 0; k < 2*order; k++ {
+			priors = append(priors, m.priorScales[1])
+		}
+	}
+	for range m.holidays {
+		priors = append(priors, m.priorScales[2])
+	}
+	return priors
+}
+
+func (m *prophet) forecast(future []time.Time, confidence float64) ([]float64, []float64, []float64) {
+	mean := make([]float64, len(future))
+	stderr := make([]float64, len(future))
+	ts := make([]float64, len(future))
+	for h, t := range future {
+		ts[h] = m.scaledTime(t)
+		mean[h] = dot(m.features(t), m.beta) * m.scale
+
+		// Each future bucket may start a trend change with a Laplace
+		// distributed size, whose variance is twice the squared scale
+		var trend float64
+		for j := 0; j < h; j++ {
+			trend += (ts[h] - ts[j]) * (ts[h] - ts[j])
+		}
+		trend *= m.rate * 2 * m.deltaScale * m.deltaScale
+		stderr[h] = m.scale * math.Sqrt(m.sigma*m.sigma+trend)
+	}
+	lower, upper := normalBounds(mean, stderr, confidence)
+	return mean, lower, upper
+}
+
+func (m *prophet) parameters() map[string]float64 {
+	growth := m.beta[1]
+	for j := range m.cuts {
+		growth += m.beta[2+j]
+	}
+	params := map[string]float64{
+		"sigma":        m.sigma * m.scale,
+		"changepoints": float64(len(m.cuts)),
+		// Final growth per bucket, averaged over the bucket lengths of the history
+		"growth": growth * m.scale / float64(m.buckets-1),
+	}
+	for i, s := range prophetSeasonalities {
+		params[s.name] = float64(m.resolved[i])
+	}
+	first := len(m.beta) - len(m.holidays)
+	for i, effect := range m.holidays {
+		if effect.offset == 0 {
+			params["holiday_"+effect.name] = m.beta[first+i] * m.scale
+		}
+	}
+	return params
+}
+
+// dot returns the inner product of two vectors of the same length
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,608 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// Regression defaults
+const (
+	defaultRidgeLambda        = 1.0
+	defaultLogisticThreshold  = 0.5
+	defaultLogisticIterations = 100
+	// defaultPredictionLimit caps the number of predictions listed
+	defaultPredictionLimit = 1000
+)
+
+// logisticTolerance is the largest coefficient step of a converged logistic
+// regression
+const logisticTolerance = 1e-8
+
+// Regression is a validated regression spec
+type Regression struct {
+	spec       models.RegressionSpec
+	params     models.RegressionParams
+	lambda     float64
+	target     int
+	targetType models.DataType
+	features   []int
+}
+
+// CompileRegression validates a regression spec against the fields of the
+// dataset. Features must be distinct numeric fields other than the target.
+func CompileRegression(spec *models.RegressionSpec, fields []models.DataField) (*Regression, error) {
+	switch spec.Method {
+	case models.RegressionOLS, models.RegressionRidge, models.RegressionLogistic:
+	default:
+		return nil, models.NewValidationError("unknown regression method %q", spec.Method)
+	}
+	r := &Regression{spec: *spec}
+	if err := query.DecodeParams(spec.Params, &r.params); err != nil {
+		return nil, models.NewValidationError("%s: %v", spec.Method, err)
+	}
+	if err := r.checkParams(); err != nil {
+		return nil, err
+	}
+
+	r.target = fieldIndex(fields, spec.Target)
+	if r.target < 0 {
+		return nil, models.NewValidationError("unknown target field %q", spec.Target)
+	}
+	r.targetType = fields[r.target].Type
+	if spec.Method == models.RegressionLogistic {
+		if r.params.PositiveClass == "" && !isNumeric(r.targetType) && r.targetType != models.DataTypeBoolean {
+			return nil, models.NewValidationError("%s: positive_class is required for the %s target %q", spec.Method, r.targetType, spec.Target)
+		}
+	} else if !isNumeric(r.targetType) {
+		return nil, models.NewValidationError("target %q is %s, not numeric", spec.Target, r.targetType)
+	}
+
+	seen := map[string]bool{spec.Target: true}
+	for _, name := range spec.Features {
+		if seen[name] {
+			return nil, models.NewValidationError("feature %q is listed twice or is the target", name)
+		}
+		seen[name] = true
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, models.NewValidationError("unknown feature field %q", name)
+		}
+		if !isNumeric(fields[index].Type) {
+			return nil, models.NewValidationError("feature %q is %s, not numeric", name, fields[index].Type)
+		}
+		r.features = append(r.features, index)
+	}
+	return r, nil
+}
+
+// checkParams validates the params and fills in their defaults
+func (r *Regression) checkParams() error {
+	p := &r.params
+	method := r.spec.Method
+	if p.Lambda != nil {
+		if method == models.RegressionOLS {
+			return models.NewValidationError("%s: lambda only applies to ridge and logistic", method)
+		}
+		if !(*p.Lambda >= 0) {
+			return models.NewValidationError("%s: lambda must not be negative", method)
+		}
+		r.lambda = *p.Lambda
+	} else if method == models.RegressionRidge {
+		r.lambda = defaultRidgeLambda
+	}
+
+	if method == models.RegressionLogistic {
+		if p.Threshold == 0 {
+			p.Threshold = defaultLogisticThreshold
+		}
+		if p.Threshold <= 0 || p.Threshold >= 1 {
+			return models.NewValidationError("%s: threshold must be between 0 and 1", method)
+		}
+		if p.MaxIterations == 0 {
+			p.MaxIterations = defaultLogisticIterations
+		}
+		if p.MaxIterations < 0 {
+			return models.NewValidationError("%s: max_iterations must be positive", method)
+		}
+	} else if p.PositiveClass != "" || p.Threshold != 0 || p.MaxIterations != 0 {
+		return models.NewValidationError("%s: positive_class, threshold and max_iterations only apply to logistic", method)
+	}
+
+	if p.ValidationSplit < 0 || p.ValidationSplit >= 1 {
+		return models.NewValidationError("%s: validation_split must be at least 0 and below 1", method)
+	}
+	return nil
+}
+
+// Compute fits the regression on the rows of a frame where the target and
+// every feature have a value, in frame order. The last validation_split of
+// those rows are held out of training to measure the validation metrics.
+func (r *Regression) Compute(frame *query.Frame) (*models.RegressionResult, error) {
+	method := r.spec.Method
+	result := &models.RegressionResult{
+		Method:       method,
+		Target:       r.spec.Target,
+		TargetType:   r.targetType,
+		Features:     r.spec.Features,
+		Coefficients: []models.Coefficient{},
+		Converged:    true,
+	}
+	if method == models.RegressionLogistic {
+		result.PositiveClass = r.params.PositiveClass
+		result.Threshold = &r.params.Threshold
+	}
+
+	// Collect the complete rows
+	var xs [][]float64
+	var ys []float64
+	for row := 0; row < frame.Len(); row++ {
+		y, ok := targetValue(result, frame.Columns[r.target][row])
+		if !ok {
+			continue
+		}
+		x, ok := featureValues(frame, r.features, row)
+		if !ok {
+			continue
+		}
+		xs = append(xs, x)
+		ys = append(ys, y)
+	}
+	training := len(ys) - int(math.Round(r.params.ValidationSplit*float64(len(ys))))
+	intercept := !r.params.NoIntercept
+	k := len(r.features)
+	if intercept {
+		k++
+	}
+	if training <= k {
+		return nil, models.NewValidationError("%s needs more than %d complete rows to train on, got %d", method, k, training)
+	}
+
+	// Fit the coefficients
+	design := designMatrix(xs[:training], intercept)
+	penalty := make([]float64, k)
+	for j := range penalty {
+		if !intercept || j > 0 {
+			penalty[j] = r.lambda
+		}
+	}
+	var beta []float64
+	var cov [][]float64
+	var err error
+	if method == models.RegressionLogistic {
+		if !bothClasses(ys[:training]) {
+			return nil, models.NewValidationError("%s needs both classes among the training rows", method)
+		}
+		beta, cov, result.Iterations, result.Converged, err = fitLogistic(design, ys[:training], penalty, r.params.MaxIterations)
+	} else {
+		beta, cov, err = fitLinear(design, ys[:training], penalty)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Test the coefficients against zero
+	names := r.spec.Features
+	if intercept {
+		names = append([]string{"intercept"}, names...)
+	}
+	for j, estimate := range beta {
+		coefficient := models.Coefficient{Name: names[j], Estimate: estimate}
+		if cov != nil && cov[j][j] > 0 {
+			stdError := math.Sqrt(cov[j][j])
+			statistic := estimate / stdError
+			coefficient.StdError = &stdError
+			coefficient.Statistic = finitePtr(statistic)
+			if method == models.RegressionLogistic {
+				coefficient.PValue = finitePtr(TwoSidedNormalPValue(statistic))
+			} else {
+				coefficient.PValue = finitePtr(TwoSidedTPValue(statistic, float64(training-k)))
+			}
+		}
+		if intercept && j == 0 {
+			result.Intercept = &coefficient
+		} else {
+			result.Coefficients = append(result.Coefficients, coefficient)
+		}
+	}
+
+	// Measure the fit
+	result.Metrics = regressionMetrics(result, xs[:training], ys[:training])
+	if rSquared := result.Metrics.RSquared; rSquared != nil && training > len(r.features)+1 {
+		n, p := float64(training), float64(len(r.features))
+		result.Metrics.AdjustedRSquared = finitePtr(1 - (1-*rSquared)*(n-1)/(n-p-1))
+	}
+	if training < len(ys) {
+		metrics := regressionMetrics(result, xs[training:], ys[training:])
+		result.ValidationMetrics = &metrics
+	}
+	return result, nil
+}
+
+// designMatrix returns the feature rows, with a leading column of ones for
+// the intercept
+func designMatrix(xs [][]float64, intercept bool) [][]float64 {
+	if !intercept {
+		return xs
+	}
+	design := make([][]float64, len(xs))
+	for i, x := range xs {
+		design[i] = append([]float64{1}, x...)
+	}
+	return design
+}
+
+// fitLinear solves the penalized least squares problem, returning the
+// covariance of the coefficients when there is no penalty
+func fitLinear(x [][]float64, y []float64, penalty []float64) ([]float64, [][]float64, error) {
+	k := len(penalty)
+	gram := make([][]float64, k)
+	moments := make([]float64, k)
+	for j := range gram {
+		gram[j] = make([]float64, k)
+	}
+	for i, row := range x {
+		for j := 0; j < k; j++ {
+			moments[j] += row[j] * y[i]
+			for l := 0; l <= j; l++ {
+				gram[j][l] += row[j] * row[l]
+			}
+		}
+	}
+	penalized := false
+	for j := 0; j < k; j++ {
+		for l := 0; l < j; l++ {
+			gram[l][j] = gram[j][l]
+		}
+		gram[j][j] += penalty[j]
+		penalized = penalized || penalty[j] > 0
+	}
+
+	beta, ok := choleskySolve(gram, moments)
+	if !ok {
+		return nil, nil, models.NewValidationError("the features are collinear or constant")
+	}
+	if penalized {
+		return beta, nil, nil
+	}
+
+	// The covariance is σ² (XᵀX)⁻¹ with σ² estimated from the residuals
+	inverse, ok := choleskyInverse(gram)
+	if !ok {
+		return beta, nil, nil
+	}
+	var rss float64
+	for i, row := range x {
+		residual := y[i] - dot(row, beta)
+		rss += residual * residual
+	}
+	sigma2 := rss / float64(len(y)-k)
+	for j := range inverse {
+		for l := range inverse[j] {
+			inverse[j][l] *= sigma2
+		}
+	}
+	return beta, inverse, nil
+}
+
+// fitLogistic maximizes the penalized log-likelihood of a logistic regression
+// with Newton's method, returning the covariance of the coefficients when
+// there is no penalty along with the iterations taken and whether they
+// converged. Classes that a feature separates perfectly make the coefficients
+// grow until the iterations run out.
+func fitLogistic(x [][]float64, y []float64, penalty []float64, maxIterations int) ([]float64, [][]float64, int, bool, error) {
+	k := len(penalty)
+	beta := make([]float64, k)
+	penalized := false
+	for _, p := range penalty {
+		penalized = penalized || p > 0
+	}
+
+	// hessian returns the negative Hessian and the gradient of the
+	// log-likelihood at beta
+	hessian := func() ([][]float64, []float64) {
+		h := make([][]float64, k)
+		for j := range h {
+			h[j] = make([]float64, k)
+			h[j][j] = penalty[j]
+		}
+		gradient := make([]float64, k)
+		for j := range gradient {
+			gradient[j] = -penalty[j] * beta[j]
+		}
+		for i, row := range x {
+			mu := logistic(dot(row, beta))
+			w := mu * (1 - mu)
+			for j := 0; j < k; j++ {
+				gradient[j] += row[j] * (y[i] - mu)
+				for l := 0; l <= j; l++ {
+					h[j][l] += w * row[j] * row[l]
+				}
+			}
+		}
+		for j := 0; j < k; j++ {
+			for l := 0; l < j; l++ {
+				h[l][j] = h[j][l]
+			}
+		}
+		return h, gradient
+	}
+
+	iterations, converged := 0, false
+	for iterations < maxIterations {
+		h, gradient := hessian()
+		step, ok := choleskySolve(h, gradient)
+		if !ok {
+			if iterations == 0 {
+				return nil, nil, 0, false, models.NewValidationError("the features are collinear or constant")
+			}
+			break
+		}
+		iterations++
+		largest := 0.0
+		for j := range beta {
+			beta[j] += step[j]
+			largest = math.Max(largest, math.Abs(step[j]))
+		}
+		if largest < logisticTolerance {
+			converged = true
+			break
+		}
+	}
+	if penalized || !converged {
+		return beta, nil, iterations, converged, nil
+	}
+
+	// The covariance is the inverse of the Fisher information at the optimum
+	h, _ := hessian()
+	cov, ok := choleskyInverse(h)
+	if !ok {
+		cov = nil
+	}
+	return beta, cov, iterations, converged, nil
+}
+
+// regressionMetrics measures the predictions of a model against the targets
+// of feature rows
+func regressionMetrics(model *models.RegressionResult, xs [][]float64, ys []float64) models.RegressionMetrics {
+	predicted := make([]float64, len(ys))
+	for i, x := range xs {
+		predicted[i] = linearPredictor(model, x)
+		if model.Method == models.RegressionLogistic {
+			predicted[i] = logistic(predicted[i])
+		}
+	}
+	if model.Method == models.RegressionLogistic {
+		return classificationMetrics(ys, predicted, *model.Threshold)
+	}
+	return errorMetrics(ys, predicted)
+}
+
+// errorMetrics measures predicted values against targets
+func errorMetrics(ys, predicted []float64) models.RegressionMetrics {
+	metrics := models.RegressionMetrics{Count: len(ys)}
+	if len(ys) == 0 {
+		return metrics
+	}
+	mean := Mean(ys)
+	var squares, absolutes, total float64
+	for i, y := range ys {
+		residual := y - predicted[i]
+		squares += residual * residual
+		absolutes += math.Abs(residual)
+		total += (y - mean) * (y - mean)
+	}
+	n := float64(len(ys))
+	if total > 0 {
+		metrics.RSquared = finitePtr(1 - squares/total)
+	}
+	metrics.RMSE = finitePtr(math.Sqrt(squares / n))
+	metrics.MAE = finitePtr(absolutes / n)
+	return metrics
+}
+
+// classificationMetrics measures predicted probabilities of the positive
+// class against 1 or 0 class labels, predicting the positive class from the
+// threshold on
+func classificationMetrics(ys, probabilities []float64, threshold float64) models.RegressionMetrics {
+	metrics := models.RegressionMetrics{Count: len(ys)}
+	if len(ys) == 0 {
+		return metrics
+	}
+	// Probabilities are clipped so that confident mistakes have a finite loss
+	const epsilon = 1e-15
+	var loss float64
+	var truePositives, falsePositives, falseNegatives, correct int
+	for i, y := range ys {
+		p := math.Min(math.Max(probabilities[i], epsilon), 1-epsilon)
+		loss -= y*math.Log(p) + (1-y)*math.Log(1-p)
+
+		positive := probabilities[i] >= threshold
+		switch {
+		case positive && y == 1:
+			truePositives++
+		case positive:
+			falsePositives++
+		case y == 1:
+			falseNegatives++
+		}
+		if positive == (y == 1) {
+			correct++
+		}
+	}
+	n := float64(len(ys))
+	metrics.LogLoss = finitePtr(loss / n)
+	metrics.Accuracy = finitePtr(float64(correct) / n)
+	if truePositives+falsePositives > 0 {
+		metrics.Precision = finitePtr(float64(truePositives) / float64(truePositives+falsePositives))
+	}
+	if truePositives+falseNegatives > 0 {
+		metrics.Recall = finitePtr(float64(truePositives) / float64(truePositives+falseNegatives))
+	}
+	if metrics.Precision != nil && metrics.Recall != nil && *metrics.Precision+*metrics.Recall > 0 {
+		metrics.F1 = finitePtr(2 * *metrics.Precision * *metrics.Recall / (*metrics.Precision + *metrics.Recall))
+	}
+	return metrics
+}
+
+// Predictor predicts the target of rows with a fitted regression
+type Predictor struct {
+	model    *models.RegressionResult
+	features []int
+	// target is the position of the model's target, or -1
+	target int
+	limit  int
+}
+
+// CompilePredictor validates a fitted regression against the fields of the
+// rows to predict, which must include the model's features as numeric fields.
+// The target field is optional; when present the predictions are measured
+// against it.
+func CompilePredictor(model *models.RegressionResult, fields []models.DataField, limit int) (*Predictor, error) {
+	if limit == 0 {
+		limit = defaultPredictionLimit
+	}
+	if limit < 0 {
+		return nil, models.NewValidationError("limit must be positive")
+	}
+	p := &Predictor{model: model, target: fieldIndex(fields, model.Target), limit: limit}
+	for _, name := range model.Features {
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, models.NewValidationError("the rows have no feature field %q", name)
+		}
+		if !isNumeric(fields[index].Type) {
+			return nil, models.NewValidationError("feature %q is %s, not numeric", name, fields[index].Type)
+		}
+		p.features = append(p.features, index)
+	}
+	return p, nil
+}
+
+// Compute predicts the rows of a frame in frame order
+func (p *Predictor) Compute(frame *query.Frame) *models.PredictionResult {
+	logisticModel := p.model.Method == models.RegressionLogistic
+	result := &models.PredictionResult{Predictions: []models.Prediction{}}
+	var ys, predicted []float64
+	for row := 0; row < frame.Len(); row++ {
+		var prediction models.Prediction
+		if x, ok := featureValues(frame, p.features, row); ok {
+			value := linearPredictor(p.model, x)
+			estimate := value
+			if logisticModel {
+				probability := logistic(value)
+				prediction.Probability = finitePtr(probability)
+				value, estimate = 0, probability
+				if probability >= *p.model.Threshold {
+					value = 1
+				}
+			}
+			prediction.Value = finitePtr(value)
+
+			if p.target >= 0 {
+				if y, ok := targetValue(p.model, frame.Columns[p.target][row]); ok {
+					ys = append(ys, y)
+					predicted = append(predicted, estimate)
+				}
+			}
+		}
+
+		result.Count++
+		if len(result.Predictions) < p.limit {
+			prediction.Row = frameRow(frame, row)
+			result.Predictions = append(result.Predictions, prediction)
+		}
+	}
+
+	if len(ys) > 0 {
+		var metrics models.RegressionMetrics
+		if logisticModel {
+			metrics = classificationMetrics(ys, predicted, *p.model.Threshold)
+		} else {
+			metrics = errorMetrics(ys, predicted)
+		}
+		result.Metrics = &metrics
+	}
+	return result
+}
+
+// linearPredictor returns the linear combination of a model's coefficients
+// with feature values
+func linearPredictor(model *models.RegressionResult, x []float64) float64 {
+	var sum float64
+	if model.Intercept != nil {
+		sum = model.Intercept.Estimate
+	}
+	for j, coefficient := range model.Coefficients {
+		sum += coefficient.Estimate * x[j]
+	}
+	return sum
+}
+
+// featureValues returns the values of the feature columns in a row, reporting
+// false when one is missing or not a finite number
+func featureValues(frame *query.Frame, features []int, row int) ([]float64, bool) {
+	x := make([]float64, len(features))
+	for j, column := range features {
+		v, ok := query.ToFloat(frame.Columns[column][row])
+		if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		x[j] = v
+	}
+	return x, true
+}
+
+// targetValue returns the value a model fits for a target value: the number
+// itself, or 1 or 0 for the positive or negative class of logistic regression
+func targetValue(model *models.RegressionResult, value interface{}) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+	if model.Method != models.RegressionLogistic {
+		v, ok := query.ToFloat(value)
+		return v, ok && !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+
+	var positive bool
+	switch {
+	case model.PositiveClass != "":
+		positive = query.FormatValue(value) == model.PositiveClass
+	case model.TargetType == models.DataTypeBoolean:
+		b, ok := query.ToBool(value)
+		if !ok {
+			return 0, false
+		}
+		positive = b
+	default:
+		v, ok := query.ToFloat(value)
+		if !ok || math.IsNaN(v) {
+			return 0, false
+		}
+		positive = v != 0
+	}
+	if positive {
+		return 1, true
+	}
+	return 0, true
+}
+
+// bothClasses reports whether class labels hold both 1 and 0
+func bothClasses(ys []float64) bool {
+	for _, y := range ys {
+		if y != ys[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// frameRow returns a row of a frame as a map
+func frameRow(frame *query.Frame, row int) map[string]interface{} {
+	values := make(map[string]interface{}, len(frame.Fields))
+	for c, field := range frame.Fields {
+		values[field.Name] = frame.Columns[c][row]
+	}
+	return values
+}
@@ -0,0 +1,253 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var housingFields = []models.DataField{
+	{Name: "area", Type: models.DataTypeFloat},
+	{Name: "double_area", Type: models.DataTypeFloat},
+	{Name: "price", Type: models.DataTypeFloat},
+	{Name: "plan", Type: models.DataTypeString},
+	{Name: "sold", Type: models.DataTypeBoolean},
+}
+
+// housingFrame holds prices close to 1 + 2·area, with one row missing its price
+func housingFrame() *query.Frame {
+	prices := []float64{3.1, 4.9, 7, 9.1, 10.9}
+	rows := make([]map[string]interface{}, len(prices))
+	for i, price := range prices {
+		area := float64(i + 1)
+		rows[i] = map[string]interface{}{"area": area, "double_area": 2 * area, "price": price}
+	}
+	rows = append(rows, map[string]interface{}{"area": 6.0, "double_area": 12.0})
+	return query.NewFrame(housingFields, rows)
+}
+
+// salesFrame holds eight listings whose sales overlap in area
+func salesFrame() *query.Frame {
+	sold := []bool{false, false, true, false, true, false, true, true}
+	rows := make([]map[string]interface{}, len(sold))
+	for i, s := range sold {
+		plan := "basic"
+		if s {
+			plan = "premium"
+		}
+		rows[i] = map[string]interface{}{"area": float64(i + 1), "sold": s, "plan": plan}
+	}
+	return query.NewFrame(housingFields, rows)
+}
+
+func fitRegression(t *testing.T, spec *models.RegressionSpec, frame *query.Frame) *models.RegressionResult {
+	regression, err := CompileRegression(spec, housingFields)
+	require.NoError(t, err)
+	result, err := regression.Compute(frame)
+	require.NoError(t, err)
+	return result
+}
+
+func TestRegression(t *testing.T) {
+	// Test Case 1: Ordinary least squares with coefficient tests
+	t.Run("OLS", func(t *testing.T) {
+		result := fitRegression(t, &models.RegressionSpec{Method: models.RegressionOLS, Target: "price", Features: []string{"area"}}, housingFrame())
+
+		require.NotNil(t, result.Intercept)
+		assert.InDelta(t, 1.06, result.Intercept.Estimate, 1e-9)
+		assert.InDelta(t, 0.114891, *result.Intercept.StdError, 1e-6)
+		require.Len(t, result.Coefficients, 1)
+		slope := result.Coefficients[0]
+		assert.Equal(t, "area", slope.Name)
+		assert.InDelta(t, 1.98, slope.Estimate, 1e-9)
+		assert.InDelta(t, 0.034641, *slope.StdError, 1e-6)
+		assert.InDelta(t, 57.157677, *slope.Statistic, 1e-5)
+		assert.Less(t, *slope.PValue, 1e-4)
+
+		assert.Equal(t, 5, result.Metrics.Count)
+		assert.InDelta(t, 1-0.036/39.24, *result.Metrics.RSquared, 1e-9)
+		assert.InDelta(t, 1-(0.036/39.24)*4/3, *result.Metrics.AdjustedRSquared, 1e-9)
+		assert.InDelta(t, 0.084853, *result.Metrics.RMSE, 1e-6)
+		assert.InDelta(t, 0.072, *result.Metrics.MAE, 1e-9)
+		assert.True(t, result.Converged)
+		assert.Nil(t, result.ValidationMetrics)
+	})
+
+	// Test Case 2: Ridge shrinks the slope but not the intercept
+	t.Run("Ridge", func(t *testing.T) {
+		spec := &models.RegressionSpec{Method: models.RegressionRidge, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"lambda": 10}}
+		result := fitRegression(t, spec, housingFrame())
+
+		assert.InDelta(t, 0.99, result.Coefficients[0].Estimate, 1e-9)
+		assert.InDelta(t, 4.03, result.Intercept.Estimate, 1e-9)
+		assert.Nil(t, result.Coefficients[0].StdError)
+
+		// Collinear features only fit with a penalty
+		spec.Features = []string{"area", "double_area"}
+		result = fitRegression(t, spec, housingFrame())
+		assert.Len(t, result.Coefficients, 2)
+
+		regression, err := CompileRegression(&models.RegressionSpec{Method: models.RegressionOLS, Target: "price", Features: spec.Features}, housingFields)
+		require.NoError(t, err)
+		_, err = regression.Compute(housingFrame())
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 3: A model through the origin with held out rows
+	t.Run("No Intercept With Validation", func(t *testing.T) {
+		spec := &models.RegressionSpec{Method: models.RegressionOLS, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"no_intercept": true}}
+		result := fitRegression(t, spec, housingFrame())
+		assert.Nil(t, result.Intercept)
+		assert.InDelta(t, 124.8/55, result.Coefficients[0].Estimate, 1e-9)
+
+		spec.Params = map[string]interface{}{"validation_split": 0.4}
+		result = fitRegression(t, spec, housingFrame())
+		assert.Equal(t, 3, result.Metrics.Count)
+		require.NotNil(t, result.ValidationMetrics)
+		assert.Equal(t, 2, result.ValidationMetrics.Count)
+		assert.InDelta(t, 1.95, result.Coefficients[0].Estimate, 1e-9)
+	})
+
+	// Test Case 4: Logistic regression solves its score equations
+	t.Run("Logistic", func(t *testing.T) {
+		result := fitRegression(t, &models.RegressionSpec{Method: models.RegressionLogistic, Target: "sold", Features: []string{"area"}}, salesFrame())
+
+		assert.True(t, result.Converged)
+		assert.Greater(t, result.Iterations, 1)
+		assert.Greater(t, result.Coefficients[0].Estimate, 0.0)
+		assert.NotNil(t, result.Coefficients[0].PValue)
+		assert.Equal(t, 0.5, *result.Threshold)
+
+		// At the maximum the fitted probabilities add up to the positives,
+		// also when weighted by the feature
+		var sum, weighted float64
+		for i := 1; i <= 8; i++ {
+			p := logistic(linearPredictor(result, []float64{float64(i)}))
+			sum += p
+			weighted += float64(i) * p
+		}
+		assert.InDelta(t, 4, sum, 1e-6)
+		assert.InDelta(t, 3+5+7+8, weighted, 1e-6)
+
+		assert.Equal(t, 8, result.Metrics.Count)
+		assert.InDelta(t, 0.75, *result.Metrics.Accuracy, 1e-9)
+		assert.NotNil(t, result.Metrics.LogLoss)
+
+		// A string target with a positive class fits the same model
+		plan := fitRegression(t, &models.RegressionSpec{Method: models.RegressionLogistic, Target: "plan", Features: []string{"area"},
+			Params: map[string]interface{}{"positive_class": "premium"}}, salesFrame())
+		assert.InDelta(t, result.Coefficients[0].Estimate, plan.Coefficients[0].Estimate, 1e-9)
+	})
+
+	// Test Case 5: Perfectly separated classes do not converge without a penalty
+	t.Run("Separated Classes", func(t *testing.T) {
+		var rows []map[string]interface{}
+		for i := 1; i <= 8; i++ {
+			rows = append(rows, map[string]interface{}{"area": float64(i), "sold": i > 4})
+		}
+		frame := query.NewFrame(housingFields, rows)
+
+		result := fitRegression(t, &models.RegressionSpec{Method: models.RegressionLogistic, Target: "sold", Features: []string{"area"}}, frame)
+		assert.False(t, result.Converged)
+		assert.Nil(t, result.Coefficients[0].StdError)
+
+		penalized := fitRegression(t, &models.RegressionSpec{Method: models.RegressionLogistic, Target: "sold", Features: []string{"area"},
+			Params: map[string]interface{}{"lambda": 1}}, frame)
+		assert.True(t, penalized.Converged)
+		assert.InDelta(t, 1.0, *penalized.Metrics.Accuracy, 1e-9)
+	})
+
+	// Test Case 6: Invalid specs are rejected with validation errors
+	invalid := map[string]*models.RegressionSpec{
+		"Unknown Method":     {Method: "lasso", Target: "price", Features: []string{"area"}},
+		"Unknown Target":     {Method: models.RegressionOLS, Target: "cost", Features: []string{"area"}},
+		"String Target":      {Method: models.RegressionOLS, Target: "plan", Features: []string{"area"}},
+		"No Positive Class":  {Method: models.RegressionLogistic, Target: "plan", Features: []string{"area"}},
+		"Unknown Feature":    {Method: models.RegressionOLS, Target: "price", Features: []string{"rooms"}},
+		"String Feature":     {Method: models.RegressionOLS, Target: "price", Features: []string{"plan"}},
+		"Target As Feature":  {Method: models.RegressionOLS, Target: "price", Features: []string{"price"}},
+		"Duplicate Feature":  {Method: models.RegressionOLS, Target: "price", Features: []string{"area", "area"}},
+		"OLS Lambda":         {Method: models.RegressionOLS, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"lambda": 1}},
+		"Negative Lambda":    {Method: models.RegressionRidge, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"lambda": -1}},
+		"Ridge Threshold":    {Method: models.RegressionRidge, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"threshold": 0.7}},
+		"Threshold Too High": {Method: models.RegressionLogistic, Target: "sold", Features: []string{"area"}, Params: map[string]interface{}{"threshold": 1}},
+		"Validation Split":   {Method: models.RegressionOLS, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"validation_split": 1}},
+		"Unknown Param":      {Method: models.RegressionOLS, Target: "price", Features: []string{"area"}, Params: map[string]interface{}{"alpha": 1}},
+	}
+	for name, spec := range invalid {
+		spec := spec
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileRegression(spec, housingFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestPredictor(t *testing.T) {
+	model := fitRegression(t, &models.RegressionSpec{Method: models.RegressionOLS, Target: "price", Features: []string{"area"}}, housingFrame())
+
+	// Test Case 1: Rows are predicted in order and measured against their targets
+	t.Run("Linear", func(t *testing.T) {
+		predictor, err := CompilePredictor(model, housingFields, 0)
+		require.NoError(t, err)
+		result := predictor.Compute(housingFrame())
+
+		assert.Equal(t, 6, result.Count)
+		require.Len(t, result.Predictions, 6)
+		assert.InDelta(t, 3.04, *result.Predictions[0].Value, 1e-9)
+		assert.InDelta(t, 12.94, *result.Predictions[5].Value, 1e-9)
+		assert.Equal(t, 6.0, result.Predictions[5].Row["area"])
+		require.NotNil(t, result.Metrics)
+		assert.Equal(t, 5, result.Metrics.Count)
+		assert.InDelta(t, *model.Metrics.RMSE, *result.Metrics.RMSE, 1e-9)
+	})
+
+	// Test Case 2: Rows without the target or a feature are predicted when possible
+	t.Run("Missing Values", func(t *testing.T) {
+		fields := []models.DataField{{Name: "area", Type: models.DataTypeInteger}}
+		frame := query.NewFrame(fields, []map[string]interface{}{{"area": 10}, {"area": nil}, {"area": 20}})
+		predictor, err := CompilePredictor(model, fields, 2)
+		require.NoError(t, err)
+		result := predictor.Compute(frame)
+
+		assert.Equal(t, 3, result.Count)
+		require.Len(t, result.Predictions, 2)
+		assert.InDelta(t, 20.86, *result.Predictions[0].Value, 1e-9)
+		assert.Nil(t, result.Predictions[1].Value)
+		assert.Nil(t, result.Metrics)
+	})
+
+	// Test Case 3: Logistic predictions carry the class and its probability
+	t.Run("Logistic", func(t *testing.T) {
+		model := fitRegression(t, &models.RegressionSpec{Method: models.RegressionLogistic, Target: "sold", Features: []string{"area"}}, salesFrame())
+		predictor, err := CompilePredictor(model, housingFields, 0)
+		require.NoError(t, err)
+		result := predictor.Compute(salesFrame())
+
+		first, last := result.Predictions[0], result.Predictions[7]
+		assert.Equal(t, 0.0, *first.Value)
+		assert.Less(t, *first.Probability, 0.5)
+		assert.Equal(t, 1.0, *last.Value)
+		assert.Greater(t, *last.Probability, 0.5)
+		assert.InDelta(t, *model.Metrics.LogLoss, *result.Metrics.LogLoss, 1e-9)
+	})
+
+	// Test Case 4: Rows must have the model's features as numeric fields
+	t.Run("Missing Feature", func(t *testing.T) {
+		for _, fields := range [][]models.DataField{
+			{{Name: "price", Type: models.DataTypeFloat}},
+			{{Name: "area", Type: models.DataTypeString}},
+		} {
+			_, err := CompilePredictor(model, fields, 0)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		}
+	})
+}
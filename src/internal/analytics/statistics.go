@@ -0,0 +1,330 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// Statistics defaults when params leave them out
+const (
+	defaultHistogramBins  = 10
+	defaultFrequencyLimit = 100
+	maxHistogramBins      = 1000
+)
+
+// defaultQuantiles are computed when no quantiles are requested
+var defaultQuantiles = []float64{0.25, 0.5, 0.75}
+
+// Statistics is a validated statistics request
+type Statistics struct {
+	kind    models.StatisticsType
+	names   []string
+	indices []int
+	types   []models.DataType
+	params  models.StatisticsParams
+}
+
+// CompileStatistics validates a statistics request against the fields of the
+// dataset: the type must apply to every requested field and params must suit
+// the type
+func CompileStatistics(req *models.StatisticsRequest, fields []models.DataField) (*Statistics, error) {
+	s := &Statistics{kind: req.Type, names: req.Fields}
+
+	if len(req.Fields) == 0 {
+		return nil, models.NewValidationError("statistics require at least one field")
+	}
+	seen := make(map[string]bool, len(req.Fields))
+	for _, name := range req.Fields {
+		if seen[name] {
+			return nil, models.NewValidationError("field %q is listed twice", name)
+		}
+		seen[name] = true
+
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, models.NewValidationError("unknown field %q", name)
+		}
+		field := fields[index]
+		if err := checkStatisticsField(req.Type, field); err != nil {
+			return nil, models.NewValidationError("%s: %v", req.Type, err)
+		}
+		s.indices = append(s.indices, index)
+		s.types = append(s.types, field.Type)
+	}
+
+	if err := query.DecodeParams(req.Params, &s.params); err != nil {
+		return nil, models.NewValidationError("%s: %v", req.Type, err)
+	}
+	if err := s.checkParams(); err != nil {
+		return nil, models.NewValidationError("%s: %v", req.Type, err)
+	}
+	return s, nil
+}
+
+// checkParams rejects params that do not apply to the statistics type and fills
+// in the defaults of those that do
+func (s *Statistics) checkParams() error {
+	p := &s.params
+	if len(p.Quantiles) > 0 && s.kind != models.StatsQuantile {
+		return fmt.Errorf("quantiles only apply to %s", models.StatsQuantile)
+	}
+	if p.Bins != 0 && s.kind != models.StatsHistogram {
+		return fmt.Errorf("bins only apply to %s", models.StatsHistogram)
+	}
+	if p.Limit != 0 && s.kind != models.StatsFrequency {
+		return fmt.Errorf("limit only applies to %s", models.StatsFrequency)
+	}
+
+	for _, q := range p.Quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("quantile %v is not between 0 and 1", q)
+		}
+	}
+	if p.Bins < 0 || p.Bins > maxHistogramBins {
+		return fmt.Errorf("bins must be between 1 and %d", maxHistogramBins)
+	}
+	if p.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+
+	if len(p.Quantiles) == 0 {
+		p.Quantiles = defaultQuantiles
+	}
+	if p.Bins == 0 {
+		p.Bins = defaultHistogramBins
+	}
+	if p.Limit == 0 {
+		p.Limit = defaultFrequencyLimit
+	}
+	return nil
+}
+
+// checkStatisticsField checks that a statistics type applies to a field
+func checkStatisticsField(kind models.StatisticsType, field models.DataField) error {
+	switch kind {
+	case models.StatsMean, models.StatsMedian, models.StatsStdDev, models.StatsVariance, models.StatsRange,
+		models.StatsQuantile, models.StatsHistogram, models.StatsBoxPlot, models.StatsDistribution:
+		if !isNumeric(field.Type) {
+			return fmt.Errorf("field %q is %s, not numeric", field.Name, field.Type)
+		}
+	case models.StatsMin, models.StatsMax:
+		if !isOrdered(field.Type) {
+			return fmt.Errorf("field %q is %s, which has no order", field.Name, field.Type)
+		}
+	case models.StatsMode, models.StatsFrequency:
+	default:
+		return fmt.Errorf("unknown statistics type")
+	}
+	return nil
+}
+
+// Compute evaluates the statistics over a frame with the fields the request was
+// compiled for. Results are keyed by field name; null values are skipped and
+// values that are undefined for the remaining ones, such as the mean of no
+// values, are nil.
+func (s *Statistics) Compute(frame *query.Frame) *models.StatisticsResult {
+	result := &models.StatisticsResult{
+		Type:    s.kind,
+		Fields:  s.names,
+		Results: make(map[string]any, len(s.names)),
+	}
+	for i, name := range s.names {
+		result.Results[name] = s.compute(frame.Columns[s.indices[i]], s.types[i])
+	}
+	return result
+}
+
+// compute evaluates the statistics type over one column
+func (s *Statistics) compute(column []interface{}, t models.DataType) interface{} {
+	switch s.kind {
+	case models.StatsMin, models.StatsMax:
+		return extreme(column, t, s.kind == models.StatsMax)
+	case models.StatsMode:
+		return mode(column, t)
+	case models.StatsFrequency:
+		return frequency(column, t, s.params.Limit)
+	}
+
+	numbers := Numbers(column)
+	switch s.kind {
+	case models.StatsMean:
+		return finite(Mean(numbers))
+	case models.StatsStdDev:
+		return finite(StdDev(numbers))
+	case models.StatsVariance:
+		return finite(Variance(numbers))
+	case models.StatsHistogram:
+		return Histogram(numbers, s.params.Bins)
+	case models.StatsDistribution:
+		return distribution(numbers)
+	}
+
+	sorted := Sorted(numbers)
+	if len(sorted) == 0 && s.kind != models.StatsQuantile {
+		return nil
+	}
+	switch s.kind {
+	case models.StatsMedian:
+		return Median(sorted)
+	case models.StatsRange:
+		return sorted[len(sorted)-1] - sorted[0]
+	case models.StatsBoxPlot:
+		return NewBoxPlot(sorted)
+	default:
+		quantiles := make(map[string]interface{}, len(s.params.Quantiles))
+		for _, q := range s.params.Quantiles {
+			quantiles[strconv.FormatFloat(q, 'f', -1, 64)] = finite(Quantile(sorted, q))
+		}
+		return quantiles
+	}
+}
+
+// extreme returns the smallest or largest non-null value of a column as stored
+func extreme(column []interface{}, t models.DataType, largest bool) interface{} {
+	var best interface{}
+	for _, value := range column {
+		if value == nil {
+			continue
+		}
+		if best == nil {
+			if _, ok := query.Compare(value, value, t); ok {
+				best = value
+			}
+			continue
+		}
+		if cmp, ok := query.Compare(value, best, t); ok && (largest && cmp > 0 || !largest && cmp < 0) {
+			best = value
+		}
+	}
+	return best
+}
+
+// mode returns every most frequent non-null value of a column, in order of
+// first appearance, with their count
+func mode(column []interface{}, t models.DataType) map[string]interface{} {
+	values := []interface{}{}
+	var count int64
+	for _, f := range Frequencies(nonNull(column), keyFunc(t)) {
+		if f.Count < count {
+			break
+		}
+		values = append(values, f.Value)
+		count = f.Count
+	}
+	return map[string]interface{}{"values": values, "count": count}
+}
+
+// frequency returns the frequency table of the non-null values of a column,
+// listing at most limit values
+func frequency(column []interface{}, t models.DataType, limit int) map[string]interface{} {
+	values := nonNull(column)
+	table := Frequencies(values, keyFunc(t))
+	distinct := len(table)
+	if len(table) > limit {
+		table = table[:limit]
+	}
+	return map[string]interface{}{
+		"count":    len(values),
+		"missing":  len(column) - len(values),
+		"distinct": distinct,
+		"values":   table,
+	}
+}
+
+// distribution describes the shape of the numbers of a column
+func distribution(numbers []float64) map[string]interface{} {
+	m := NewMoments(numbers)
+	sorted := Sorted(numbers)
+	return map[string]interface{}{
+		"count":          m.Count,
+		"mean":           finite(m.Mean),
+		"std_dev":        finite(m.StdDev),
+		"standard_error": finite(m.StandardError),
+		"min":            first(sorted),
+		"max":            last(sorted),
+		"skewness":       finite(m.Skewness),
+		"kurtosis":       finite(m.Kurtosis),
+		"jarque_bera":    finite(m.JarqueBera),
+		"jarque_bera_p":  finite(m.JarqueBeraP),
+		"quantiles":      map[string]interface{}{"0.25": finite(Quantile(sorted, 0.25)), "0.5": finite(Median(sorted)), "0.75": finite(Quantile(sorted, 0.75))},
+		"histogram":      Histogram(numbers, defaultHistogramBins),
+	}
+}
+
+// Numbers returns the numeric values of a column, skipping nulls and values
+// that are not numbers
+func Numbers(column []interface{}) []float64 {
+	numbers := make([]float64, 0, len(column))
+	for _, value := range column {
+		if f, ok := query.ToFloat(value); ok && !math.IsNaN(f) {
+			numbers = append(numbers, f)
+		}
+	}
+	return numbers
+}
+
+// nonNull returns the non-null values of a column
+func nonNull(column []interface{}) []interface{} {
+	values := make([]interface{}, 0, len(column))
+	for _, value := range column {
+		if value != nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// keyFunc returns the function that decides which values of a type are equal
+func keyFunc(t models.DataType) func(interface{}) string {
+	return func(value interface{}) string {
+		return query.GroupKey(value, t)
+	}
+}
+
+// finite returns f, or nil when it is NaN or infinite so that it encodes as JSON null
+func finite(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// first returns the smallest of sorted numbers, or nil when there are none
+func first(sorted []float64) interface{} {
+	if len(sorted) == 0 {
+		return nil
+	}
+	return sorted[0]
+}
+
+// last returns the largest of sorted numbers, or nil when there are none
+func last(sorted []float64) interface{} {
+	if len(sorted) == 0 {
+		return nil
+	}
+	return sorted[len(sorted)-1]
+}
+
+// fieldIndex returns the position of a named field, or -1
+func fieldIndex(fields []models.DataField, name string) int {
+	for i, field := range fields {
+		if field.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// isNumeric reports whether a data type holds numbers
+func isNumeric(t models.DataType) bool {
+	return t == models.DataTypeInteger || t == models.DataTypeFloat
+}
+
+// isOrdered reports whether values of a data type have an order
+func isOrdered(t models.DataType) bool {
+	return isNumeric(t) || t == models.DataTypeString || t == models.DataTypeDateTime
+}
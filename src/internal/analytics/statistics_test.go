@@ -0,0 +1,309 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var measureFields = []models.DataField{
+	{Name: "city", Type: models.DataTypeString},
+	{Name: "temp", Type: models.DataTypeFloat},
+	{Name: "rain", Type: models.DataTypeInteger},
+	{Name: "day", Type: models.DataTypeDateTime},
+	{Name: "tags", Type: models.DataTypeArray},
+}
+
+func measureFrame() *query.Frame {
+	return query.NewFrame(measureFields, []map[string]interface{}{
+		{"city": "oslo", "temp": 1.0, "rain": int64(3), "day": "2024-01-03T00:00:00Z"},
+		{"city": "rome", "temp": 2.0, "rain": int64(1), "day": "2024-01-01T00:00:00Z"},
+		{"city": "oslo", "temp": 3.0, "rain": int64(2), "day": "2024-01-02T00:00:00Z"},
+		{"city": "lima", "temp": 4.0, "rain": nil, "day": nil},
+		{"city": nil, "temp": 100.0, "rain": int64(0), "day": "2024-01-05T00:00:00Z"},
+	})
+}
+
+func computeStatistics(t *testing.T, kind models.StatisticsType, field string, params map[string]interface{}) interface{} {
+	stats, err := CompileStatistics(&models.StatisticsRequest{Type: kind, Fields: []string{field}, Params: params}, measureFields)
+	require.NoError(t, err)
+	result := stats.Compute(measureFrame())
+	assert.Equal(t, []string{field}, result.Fields)
+	return result.Results[field]
+}
+
+func TestStatistics(t *testing.T) {
+	// Test Case 1: Scalar statistics skip nulls
+	t.Run("Scalars", func(t *testing.T) {
+		assert.Equal(t, 22.0, computeStatistics(t, models.StatsMean, "temp", nil))
+		assert.Equal(t, 3.0, computeStatistics(t, models.StatsMedian, "temp", nil))
+		assert.Equal(t, 1.5, computeStatistics(t, models.StatsMedian, "rain", nil))
+		assert.Equal(t, 99.0, computeStatistics(t, models.StatsRange, "temp", nil))
+		assert.InDelta(t, 1.6666666, computeStatistics(t, models.StatsVariance, "rain", nil), 1e-6)
+		assert.InDelta(t, 1.2909944, computeStatistics(t, models.StatsStdDev, "rain", nil), 1e-6)
+		assert.Equal(t, "2024-01-01T00:00:00Z", computeStatistics(t, models.StatsMin, "day", nil))
+		assert.Equal(t, "rome", computeStatistics(t, models.StatsMax, "city", nil))
+	})
+
+	// Test Case 2: Quantiles default to the quartiles and accept params
+	t.Run("Quantile", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{"0.25": 2.0, "0.5": 3.0, "0.75": 4.0}, computeStatistics(t, models.StatsQuantile, "temp", nil))
+		assert.Equal(t, map[string]interface{}{"0.1": 0.30000000000000004}, computeStatistics(t, models.StatsQuantile, "rain", map[string]interface{}{"quantiles": []interface{}{0.1}}))
+	})
+
+	// Test Case 3: Histogram bins cover the range, the maximum in the last bin
+	t.Run("Histogram", func(t *testing.T) {
+		bins := computeStatistics(t, models.StatsHistogram, "rain", map[string]interface{}{"bins": 3})
+		assert.Equal(t, []Bin{{Lower: 0, Upper: 1, Count: 1}, {Lower: 1, Upper: 2, Count: 1}, {Lower: 2, Upper: 3, Count: 2}}, bins)
+	})
+
+	// Test Case 4: Box plots flag values beyond the fences
+	t.Run("Box Plot", func(t *testing.T) {
+		box := computeStatistics(t, models.StatsBoxPlot, "temp", nil).(BoxPlot)
+		assert.Equal(t, []float64{100}, box.Outliers)
+		assert.Equal(t, 1.0, box.LowerWhisker)
+		assert.Equal(t, 4.0, box.UpperWhisker)
+		assert.Equal(t, 2.0, box.IQR)
+	})
+
+	// Test Case 5: Mode and frequency count equal values of any type
+	t.Run("Frequency", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{"values": []interface{}{"oslo"}, "count": int64(2)}, computeStatistics(t, models.StatsMode, "city", nil))
+
+		freq := computeStatistics(t, models.StatsFrequency, "city", map[string]interface{}{"limit": 2}).(map[string]interface{})
+		assert.Equal(t, 4, freq["count"])
+		assert.Equal(t, 1, freq["missing"])
+		assert.Equal(t, 3, freq["distinct"])
+		assert.Equal(t, []Frequency{{Value: "oslo", Count: 2, Percent: 50}, {Value: "rome", Count: 1, Percent: 25}}, freq["values"])
+	})
+
+	// Test Case 6: Distribution describes the shape
+	t.Run("Distribution", func(t *testing.T) {
+		dist := computeStatistics(t, models.StatsDistribution, "temp", nil).(map[string]interface{})
+		assert.Equal(t, int64(5), dist["count"])
+		assert.Greater(t, dist["skewness"], 1.0)
+		assert.Less(t, dist["jarque_bera_p"], 1.0)
+	})
+
+	// Test Case 7: Invalid requests are rejected with validation errors
+	invalid := map[string]models.StatisticsRequest{
+		"No Fields":         {Type: models.StatsMean},
+		"Unknown Type":      {Type: "sum", Fields: []string{"temp"}},
+		"Unknown Field":     {Type: models.StatsMean, Fields: []string{"wind"}},
+		"Repeated Field":    {Type: models.StatsMean, Fields: []string{"temp", "temp"}},
+		"Mean Of Strings":   {Type: models.StatsMean, Fields: []string{"city"}},
+		"Max Of Arrays":     {Type: models.StatsMax, Fields: []string{"tags"}},
+		"Unknown Param":     {Type: models.StatsHistogram, Fields: []string{"temp"}, Params: map[string]interface{}{"width": 2}},
+		"Misplaced Param":   {Type: models.StatsMean, Fields: []string{"temp"}, Params: map[string]interface{}{"bins": 2}},
+		"Quantile Too High": {Type: models.StatsQuantile, Fields: []string{"temp"}, Params: map[string]interface{}{"quantiles": []interface{}{1.5}}},
+		"Bins Wrong Type":   {Type: models.StatsHistogram, Fields: []string{"temp"}, Params: map[string]interface{}{"bins": "ten"}},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileStatistics(&req, measureFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+var matrixFields = []models.DataField{
+	{Name: "x", Type: models.DataTypeFloat},
+	{Name: "y", Type: models.DataTypeFloat},
+	{Name: "z", Type: models.DataTypeInteger},
+	{Name: "w", Type: models.DataTypeInteger},
+	{Name: "label", Type: models.DataTypeString},
+}
+
+// matrixFrame holds x = z + e1 and y = z + e2, where the noises e1 and e2 are
+// orthogonal to z and have a correlation of -0.5. The last row misses z.
+func matrixFrame() *query.Frame {
+	z := []float64{1, 2, 3, 4, 5, 6}
+	e1 := []float64{1, -1, -1, 1, 0, 0}
+	e2 := []float64{0, 0, 1, -1, -1, 1}
+	rows := make([]map[string]interface{}, len(z))
+	for i := range z {
+		rows[i] = map[string]interface{}{"x": z[i] + e1[i], "y": z[i] + e2[i], "z": z[i], "w": 7}
+	}
+	rows = append(rows, map[string]interface{}{"x": 10.0, "y": 0.0, "w": 7})
+	return query.NewFrame(matrixFields, rows)
+}
+
+func TestCorrelationMatrix(t *testing.T) {
+	// Test Case 1: Pairs use the rows where both fields are present
+	t.Run("Pairwise", func(t *testing.T) {
+		correlation, err := CompileCorrelation(&models.CorrelationRequest{Method: models.CorrelationSpearman, Fields: []string{"temp", "rain"}}, measureFields)
+		require.NoError(t, err)
+
+		result := correlation.Compute(measureFrame())
+		require.Len(t, result.Correlation, 2)
+		assert.Equal(t, 1.0, *result.Correlation[0][0])
+		assert.Equal(t, 0.0, *result.PValues[0][0])
+		assert.InDelta(t, -0.8, *result.Correlation[0][1], 1e-12)
+		assert.Equal(t, *result.Correlation[0][1], *result.Correlation[1][0])
+		assert.Equal(t, [][]int{{5, 4}, {4, 4}}, result.Observations)
+	})
+
+	// Test Case 2: Undefined coefficients are nil
+	t.Run("Undefined", func(t *testing.T) {
+		frame := query.NewFrame(measureFields, []map[string]interface{}{
+			{"temp": 1.0, "rain": int64(1)}, {"temp": 2.0, "rain": int64(1)}, {"temp": 3.0, "rain": int64(1)},
+		})
+		correlation, err := CompileCorrelation(&models.CorrelationRequest{Method: models.CorrelationPearson, Fields: []string{"temp", "rain"}}, measureFields)
+		require.NoError(t, err)
+
+		result := correlation.Compute(frame)
+		assert.NotNil(t, result.Correlation[0][0])
+		assert.Nil(t, result.Correlation[0][1])
+		assert.Nil(t, result.PValues[1][1])
+	})
+
+	// Test Case 3: Listwise correlations drop a row with a null from every pair
+	t.Run("Listwise", func(t *testing.T) {
+		req := &models.CorrelationRequest{Method: models.CorrelationPearson, Fields: []string{"x", "y", "z"}}
+		correlation, err := CompileCorrelation(req, matrixFields)
+		require.NoError(t, err)
+		pairwise := correlation.Compute(matrixFrame())
+		assert.Equal(t, models.MissingPairwise, pairwise.Missing)
+		assert.Equal(t, [][]int{{7, 7, 6}, {7, 7, 6}, {6, 6, 6}}, pairwise.Observations)
+
+		req.Missing = models.MissingListwise
+		correlation, err = CompileCorrelation(req, matrixFields)
+		require.NoError(t, err)
+		listwise := correlation.Compute(matrixFrame())
+		assert.Equal(t, [][]int{{6, 6, 6}, {6, 6, 6}, {6, 6, 6}}, listwise.Observations)
+		assert.InDelta(t, Pearson([]float64{2, 1, 2, 5, 5, 6}, []float64{1, 2, 4, 3, 4, 7}).R, *listwise.Correlation[0][1], 1e-12)
+		assert.NotEqual(t, *pairwise.Correlation[0][1], *listwise.Correlation[0][1])
+	})
+
+	// Test Case 4: Invalid requests are rejected with validation errors
+	invalid := map[string]models.CorrelationRequest{
+		"Unknown Method":  {Method: "cosine", Fields: []string{"temp", "rain"}},
+		"One Field":       {Method: models.CorrelationPearson, Fields: []string{"temp"}},
+		"Non Numeric":     {Method: models.CorrelationPearson, Fields: []string{"temp", "city"}},
+		"Repeated Field":  {Method: models.CorrelationPearson, Fields: []string{"temp", "temp"}},
+		"Unknown Missing": {Method: models.CorrelationPearson, Fields: []string{"temp", "rain"}, Missing: "casewise"},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileCorrelation(&req, measureFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestCovariance(t *testing.T) {
+	// Test Case 1: Sample covariances with variances on the diagonal
+	t.Run("Pairwise", func(t *testing.T) {
+		c, err := CompileCovariance(&models.CovarianceRequest{Fields: []string{"z", "x", "w"}}, matrixFields)
+		require.NoError(t, err)
+		result := c.Compute(matrixFrame())
+
+		assert.Equal(t, []string{"z", "x", "w"}, result.Fields)
+		assert.InDelta(t, 3.5, *result.Covariance[0][0], 1e-12)
+		assert.InDelta(t, 3.5, *result.Covariance[0][1], 1e-12)
+		assert.Equal(t, *result.Covariance[0][1], *result.Covariance[1][0])
+		assert.Equal(t, 0.0, *result.Covariance[2][2])
+		assert.Equal(t, 7, result.Observations[1][2])
+		assert.Equal(t, 6, result.Observations[0][2])
+	})
+
+	// Test Case 2: Pairs with fewer than two rows have no covariance
+	t.Run("Too Few Rows", func(t *testing.T) {
+		c, err := CompileCovariance(&models.CovarianceRequest{Fields: []string{"x", "y"}, Missing: models.MissingListwise}, matrixFields)
+		require.NoError(t, err)
+		result := c.Compute(query.NewFrame(matrixFields, []map[string]interface{}{{"x": 1.0, "y": 2.0}, {"x": 3.0}}))
+
+		assert.Nil(t, result.Covariance[0][1])
+		assert.Equal(t, 1, result.Observations[0][0])
+	})
+}
+
+func TestPartialCorrelation(t *testing.T) {
+	// Test Case 1: Controlling for z leaves the correlation of the noises
+	t.Run("Pearson", func(t *testing.T) {
+		req := &models.PartialCorrelationRequest{Fields: []string{"x", "y"}, Controls: []string{"z"}, Method: models.CorrelationPearson}
+		pc, err := CompilePartialCorrelation(req, matrixFields)
+		require.NoError(t, err)
+		result := pc.Compute(matrixFrame())
+
+		assert.Equal(t, []string{"z"}, result.Controls)
+		assert.InDelta(t, -0.5, *result.Correlation[0][1], 1e-12)
+		assert.InDelta(t, 0.391002, *result.PValues[0][1], 1e-6)
+		assert.InDelta(t, 1, *result.Correlation[0][0], 1e-12)
+		assert.Equal(t, 6, result.Observations[0][1])
+
+		// The plain correlation is dominated by z
+		c := Pearson([]float64{2, 1, 2, 5, 5, 6}, []float64{1, 2, 4, 3, 4, 7})
+		assert.Greater(t, c.R, 0.7)
+	})
+
+	// Test Case 2: Spearman partial correlations only depend on the ranks
+	t.Run("Spearman", func(t *testing.T) {
+		frame := matrixFrame()
+		req := &models.PartialCorrelationRequest{Fields: []string{"x", "y"}, Controls: []string{"z"}, Method: models.CorrelationSpearman}
+		pc, err := CompilePartialCorrelation(req, matrixFields)
+		require.NoError(t, err)
+		result := pc.Compute(frame)
+
+		for r, value := range frame.Columns[0] {
+			frame.Columns[0][r] = math.Exp(value.(float64))
+		}
+		transformed := pc.Compute(frame)
+		assert.InDelta(t, *result.Correlation[0][1], *transformed.Correlation[0][1], 1e-12)
+	})
+
+	// Test Case 3: A constant control leaves the correlations undefined
+	t.Run("Constant Control", func(t *testing.T) {
+		req := &models.PartialCorrelationRequest{Fields: []string{"x", "y"}, Controls: []string{"w"}, Method: models.CorrelationPearson}
+		pc, err := CompilePartialCorrelation(req, matrixFields)
+		require.NoError(t, err)
+		result := pc.Compute(matrixFrame())
+
+		assert.Nil(t, result.Correlation[0][1])
+		assert.Nil(t, result.PValues[0][1])
+	})
+
+	// Test Case 4: Invalid requests are rejected with validation errors
+	invalid := map[string]*models.PartialCorrelationRequest{
+		"Kendall":          {Fields: []string{"x", "y"}, Controls: []string{"z"}, Method: models.CorrelationKendall},
+		"No Controls":      {Fields: []string{"x", "y"}, Method: models.CorrelationPearson},
+		"Control Is Field": {Fields: []string{"x", "y"}, Controls: []string{"x"}, Method: models.CorrelationPearson},
+		"String Control":   {Fields: []string{"x", "y"}, Controls: []string{"label"}, Method: models.CorrelationPearson},
+		"Unknown Missing":  {Fields: []string{"x", "y"}, Controls: []string{"z"}, Method: models.CorrelationPearson, Missing: "casewise"},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompilePartialCorrelation(req, matrixFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	dataset := &models.Dataset{ID: uuid.New(), Name: "weather"}
+	summary := Summarize(dataset, measureFrame())
+
+	assert.Equal(t, dataset.ID, summary.DatasetID)
+	assert.Equal(t, int64(5), summary.RowCount)
+	assert.Equal(t, 5, summary.ColumnCount)
+	assert.Equal(t, []string{"temp", "rain"}, summary.NumericColumns)
+	assert.Equal(t, []string{"city"}, summary.CategoricalColumns)
+	assert.Equal(t, []string{"day"}, summary.DateColumns)
+	assert.Equal(t, map[string]int64{"city": 1, "temp": 0, "rain": 1, "day": 1, "tags": 5}, summary.MissingValues)
+	assert.Equal(t, map[string]float64{"count": 4, "mean": 1.5, "std_dev": 1.2909944487358056, "min": 0, "p25": 0.75, "median": 1.5, "p75": 2.25, "max": 3}, summary.NumericStats["rain"])
+	assert.Equal(t, map[string]int64{"oslo": 2, "rome": 1, "lima": 1}, summary.CategoricalStats["city"])
+}
@@ -0,0 +1,197 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// STL robustness: outer loops when robust, and the inner loops of each
+const (
+	stlRobustIterations = 15
+	stlInnerIterations  = 2
+)
+
+// STL decomposes a gap-free series into trend, seasonal and remainder
+// components with the seasonal-trend decomposition of Cleveland et al. (1990),
+// using locally linear loess smoothers. period is the number of buckets in a
+// season and seasonal and trend are the odd spans of the seasonal and trend
+// smoothers. A robust decomposition downweights outliers, leaving them in the
+// remainder.
+func STL(y []float64, period, seasonal, trend int, robust bool) (trendPart, seasonalPart, remainder []float64) {
+	n := len(y)
+	lowPass := nextOdd(period)
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	trendPart = make([]float64, n)
+	seasonalPart = make([]float64, n)
+
+	outer, inner := 1, stlInnerIterations
+	if robust {
+		outer, inner = stlRobustIterations, 1
+	}
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			// Smooth each cycle-subseries of the detrended series, extended by
+			// one season at each end
+			detrended := make([]float64, n)
+			for t := range y {
+				detrended[t] = y[t] - trendPart[t]
+			}
+			cycle := make([]float64, n+2*period)
+			for k := 0; k < period && k < n; k++ {
+				var sub, subWeights []float64
+				for t := k; t < n; t += period {
+					sub = append(sub, detrended[t])
+					subWeights = append(subWeights, weights[t])
+				}
+				for j := -1; j <= len(sub); j++ {
+					cycle[period+k+j*period] = loess(sub, subWeights, seasonal, float64(j))
+				}
+			}
+
+			// Remove the low frequencies left in the cycle
+			low := movingAverage(movingAverage(movingAverage(cycle, period), period), 3)
+			low = smoothAll(low, nil, lowPass)
+			for t := range seasonalPart {
+				seasonalPart[t] = cycle[period+t] - low[t]
+			}
+
+			// Smooth the deseasonalised series into the trend
+			deseasonalised := make([]float64, n)
+			for t := range y {
+				deseasonalised[t] = y[t] - seasonalPart[t]
+			}
+			trendPart = smoothAll(deseasonalised, weights, trend)
+		}
+
+		remainder = make([]float64, n)
+		for t := range y {
+			remainder[t] = y[t] - trendPart[t] - seasonalPart[t]
+		}
+		if robust {
+			weights = bisquareWeights(remainder)
+		}
+	}
+	return trendPart, seasonalPart, remainder
+}
+
+// smoothAll evaluates a loess smoother at every point of y
+func smoothAll(y, weights []float64, span int) []float64 {
+	out := make([]float64, len(y))
+	for t := range y {
+		out[t] = loess(y, weights, span, float64(t))
+	}
+	return out
+}
+
+// loess fits a weighted line to the span points nearest to position x of an
+// equally spaced series, with tricube distance weights times the given
+// weights, and returns its value at x. x may lie outside the series; spans
+// longer than the series widen the neighbourhood beyond its ends.
+func loess(y, weights []float64, span int, x float64) float64 {
+	n := len(y)
+	if n == 1 {
+		return y[0]
+	}
+	window := span
+	if window > n {
+		window = n
+	}
+	left := int(math.Round(x)) - (window-1)/2
+	if left < 0 {
+		left = 0
+	}
+	if left > n-window {
+		left = n - window
+	}
+	right := left + window - 1
+	h := math.Max(x-float64(left), float64(right)-x)
+	if span > n {
+		h += float64(span-n) / 2
+	}
+
+	var sw, swx, swy, swxx, swxy float64
+	for i := left; i <= right; i++ {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		d := math.Abs(float64(i)-x) / h
+		switch {
+		case h == 0 || d <= 0.001:
+		case d >= 0.999:
+			w = 0
+		default:
+			c := 1 - d*d*d
+			w *= c * c * c
+		}
+		xi := float64(i)
+		sw += w
+		swx += w * xi
+		swy += w * y[i]
+		swxx += w * xi * xi
+		swxy += w * xi * y[i]
+	}
+	if sw <= 0 && weights != nil {
+		// Every neighbour is an outlier; smooth without the robustness weights
+		return loess(y, nil, span, x)
+	}
+
+	mean := swx / sw
+	variance := swxx/sw - mean*mean
+	if variance <= 1e-12*h*h {
+		return swy / sw
+	}
+	slope := (swxy/sw - mean*swy/sw) / variance
+	return swy/sw + slope*(x-mean)
+}
+
+// movingAverage returns the means of the windows of the given length, one
+// shorter per extra point of the window
+func movingAverage(y []float64, window int) []float64 {
+	out := make([]float64, len(y)-window+1)
+	var sum float64
+	for i, v := range y {
+		sum += v
+		if i >= window {
+			sum -= y[i-window]
+		}
+		if i >= window-1 {
+			out[i-window+1] = sum / float64(window)
+		}
+	}
+	return out
+}
+
+// bisquareWeights returns the STL robustness weights of a remainder
+func bisquareWeights(remainder []float64) []float64 {
+	abs := make([]float64, len(remainder))
+	for i, r := range remainder {
+		abs[i] = math.Abs(r)
+	}
+	sorted := append([]float64(nil), abs...)
+	sort.Float64s(sorted)
+	h := 6 * Median(sorted)
+
+	weights := make([]float64, len(remainder))
+	for i, a := range abs {
+		switch {
+		case h == 0:
+			weights[i] = 1
+		case a < h:
+			u := a / h
+			weights[i] = (1 - u*u) * (1 - u*u)
+		}
+	}
+	return weights
+}
+
+// nextOdd returns the smallest odd number not below n
+func nextOdd(n int) int {
+	if n%2 == 0 {
+		return n + 1
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// summaryTopValues is the number of most frequent values listed per categorical column
+const summaryTopValues = 10
+
+// Summarize describes the rows of a dataset. Integer and float fields are
+// numeric columns, datetime fields date columns, and string and boolean fields
+// categorical columns; array and object fields only count towards missing
+// values. Numeric stats leave out values that are undefined for the column,
+// such as the standard deviation of a single value, and categorical stats hold
+// the counts of the most frequent values.
+func Summarize(dataset *models.Dataset, frame *query.Frame) *models.DataSummary {
+	summary := &models.DataSummary{
+		DatasetID:          dataset.ID,
+		Name:               dataset.Name,
+		RowCount:           int64(frame.Len()),
+		ColumnCount:        len(frame.Fields),
+		NumericColumns:     []string{},
+		CategoricalColumns: []string{},
+		DateColumns:        []string{},
+		MissingValues:      make(map[string]int64, len(frame.Fields)),
+		NumericStats:       make(map[string]map[string]float64),
+		CategoricalStats:   make(map[string]map[string]int64),
+	}
+
+	for i, field := range frame.Fields {
+		column := frame.Columns[i]
+		var missing int64
+		for _, value := range column {
+			if value == nil {
+				missing++
+			}
+		}
+		summary.MissingValues[field.Name] = missing
+
+		switch field.Type {
+		case models.DataTypeInteger, models.DataTypeFloat:
+			summary.NumericColumns = append(summary.NumericColumns, field.Name)
+			summary.NumericStats[field.Name] = numericSummary(Numbers(column))
+		case models.DataTypeDateTime:
+			summary.DateColumns = append(summary.DateColumns, field.Name)
+		case models.DataTypeString, models.DataTypeBoolean:
+			summary.CategoricalColumns = append(summary.CategoricalColumns, field.Name)
+			summary.CategoricalStats[field.Name] = categoricalSummary(column, field.Type)
+		}
+	}
+
+	return summary
+}
+
+// numericSummary returns the count, mean, spread and quartiles of numbers
+func numericSummary(numbers []float64) map[string]float64 {
+	sorted := Sorted(numbers)
+	stats := map[string]float64{"count": float64(len(numbers))}
+	candidates := map[string]float64{
+		"mean":    Mean(numbers),
+		"std_dev": StdDev(numbers),
+		"min":     Quantile(sorted, 0),
+		"p25":     Quantile(sorted, 0.25),
+		"median":  Median(sorted),
+		"p75":     Quantile(sorted, 0.75),
+		"max":     Quantile(sorted, 1),
+	}
+	for name, value := range candidates {
+		if finite(value) != nil {
+			stats[name] = value
+		}
+	}
+	return stats
+}
+
+// categoricalSummary returns the counts of the most frequent non-null values of
+// a column, keyed by their text
+func categoricalSummary(column []interface{}, t models.DataType) map[string]int64 {
+	table := Frequencies(nonNull(column), keyFunc(t))
+	if len(table) > summaryTopValues {
+		table = table[:summaryTopValues]
+	}
+	counts := make(map[string]int64, len(table))
+	for _, f := range table {
+		counts[query.FormatValue(f.Value)] = f.Count
+	}
+	return counts
+}
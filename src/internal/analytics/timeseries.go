@@ -0,0 +1,347 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// maxTimeSeriesBuckets caps the buckets of one filled series, so that a long
+// range at a short interval cannot exhaust memory
+const maxTimeSeriesBuckets = 100000
+
+// TimeSeries is a validated time series request
+type TimeSeries struct {
+	req        models.TimeSeriesRequest
+	timeIndex  int
+	valueIndex int
+	groupBy    []int
+	calendar   *Calendar
+	start      *time.Time
+	end        *time.Time
+	fill       models.TimeSeriesFill
+}
+
+// Series is the bucketed series of one group. Times holds the start of each
+// bucket in order and Values the aggregated value of the bucket, NaN where it
+// has none.
+type Series struct {
+	Group  string
+	Times  []time.Time
+	Values []float64
+}
+
+// CompileTimeSeries validates a time series request against the fields of the
+// dataset. The time field must be a datetime field and, except for counts, the
+// value field numeric.
+func CompileTimeSeries(req *models.TimeSeriesRequest, fields []models.DataField) (*TimeSeries, error) {
+	ts := &TimeSeries{req: *req, fill: req.Fill}
+
+	ts.timeIndex = fieldIndex(fields, req.TimeField)
+	if ts.timeIndex < 0 {
+		return nil, models.NewValidationError("unknown time field %q", req.TimeField)
+	}
+	if fields[ts.timeIndex].Type != models.DataTypeDateTime {
+		return nil, models.NewValidationError("time field %q is %s, not datetime", req.TimeField, fields[ts.timeIndex].Type)
+	}
+
+	ts.valueIndex = fieldIndex(fields, req.ValueField)
+	if ts.valueIndex < 0 {
+		return nil, models.NewValidationError("unknown value field %q", req.ValueField)
+	}
+	switch req.Aggregation {
+	case models.TimeSeriesCount:
+	case models.TimeSeriesSum, models.TimeSeriesAvg, models.TimeSeriesMin, models.TimeSeriesMax:
+		if !isNumeric(fields[ts.valueIndex].Type) {
+			return nil, models.NewValidationError("value field %q is %s, not numeric", req.ValueField, fields[ts.valueIndex].Type)
+		}
+	default:
+		return nil, models.NewValidationError("unknown aggregation %q", req.Aggregation)
+	}
+
+	seen := make(map[string]bool, len(req.GroupBy))
+	for _, name := range req.GroupBy {
+		index := fieldIndex(fields, name)
+		if index < 0 {
+			return nil, models.NewValidationError("group_by: unknown field %q", name)
+		}
+		if seen[name] {
+			return nil, models.NewValidationError("group_by: field %q is listed twice", name)
+		}
+		seen[name] = true
+		ts.groupBy = append(ts.groupBy, index)
+	}
+
+	switch ts.fill {
+	case "":
+		ts.fill = models.TimeSeriesFillNone
+	case models.TimeSeriesFillNone, models.TimeSeriesFillNull, models.TimeSeriesFillZero, models.TimeSeriesFillForward:
+	default:
+		return nil, models.NewValidationError("unknown fill %q", req.Fill)
+	}
+
+	loc, err := LoadLocation(req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if ts.calendar, err = NewCalendar(req.Interval, loc); err != nil {
+		return nil, err
+	}
+
+	// Times without an offset are read in the request timezone
+	if req.StartTime != "" {
+		start, ok := query.ParseTime(req.StartTime, loc)
+		if !ok {
+			return nil, models.NewValidationError("invalid start_time %q", req.StartTime)
+		}
+		ts.start = &start
+	}
+	if req.EndTime != "" {
+		end, ok := query.ParseTime(req.EndTime, loc)
+		if !ok {
+			return nil, models.NewValidationError("invalid end_time %q", req.EndTime)
+		}
+		ts.end = &end
+	}
+	if ts.start != nil && ts.end != nil && !ts.start.Before(*ts.end) {
+		return nil, models.NewValidationError("start_time must be before end_time")
+	}
+
+	return ts, nil
+}
+
+// Calendar returns the calendar the series is bucketed with
+func (ts *TimeSeries) Calendar() *Calendar {
+	return ts.calendar
+}
+
+// bucket accumulates the values of the rows in one bucket
+type bucket struct {
+	count    int64
+	sum      float64
+	min, max float64
+	numbers  int
+}
+
+// value returns the aggregated value of a bucket, NaN when it has none
+func (b *bucket) value(aggregation models.TimeSeriesAggregation) float64 {
+	if aggregation == models.TimeSeriesCount {
+		return float64(b.count)
+	}
+	if b.numbers == 0 {
+		return math.NaN()
+	}
+	switch aggregation {
+	case models.TimeSeriesSum:
+		return b.sum
+	case models.TimeSeriesAvg:
+		return b.sum / float64(b.numbers)
+	case models.TimeSeriesMin:
+		return b.min
+	default:
+		return b.max
+	}
+}
+
+// Series buckets the rows of a frame with the fields the request was compiled
+// for, returning one series per group in order of first appearance. Rows
+// without a valid time, or outside the time range, are skipped. Counts count
+// the rows with a non-null value. When filling, every series spans the same
+// buckets: from the start time, or the first bucket with rows, to the end time,
+// or the last bucket with rows.
+func (ts *TimeSeries) Series(frame *query.Frame) ([]Series, error) {
+	var labels []string
+	groups := make(map[string]map[int64]*bucket)
+	var first, last time.Time
+
+	loc := ts.calendar.Location()
+	for r := 0; r < frame.Len(); r++ {
+		t, ok := timeValue(frame.Columns[ts.timeIndex][r], loc)
+		if !ok || ts.start != nil && t.Before(*ts.start) || ts.end != nil && !t.Before(*ts.end) {
+			continue
+		}
+
+		label := ts.groupLabel(frame, r)
+		buckets, ok := groups[label]
+		if !ok {
+			buckets = make(map[int64]*bucket)
+			groups[label] = buckets
+			labels = append(labels, label)
+		}
+
+		start := ts.calendar.Truncate(t)
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if last.IsZero() || start.After(last) {
+			last = start
+		}
+		b, ok := buckets[start.UnixNano()]
+		if !ok {
+			b = &bucket{}
+			buckets[start.UnixNano()] = b
+		}
+		ts.add(b, frame.Columns[ts.valueIndex][r])
+	}
+
+	// Without group-by a time range still yields a series
+	if len(labels) == 0 && len(ts.groupBy) == 0 && ts.fill != models.TimeSeriesFillNone && ts.start != nil && ts.end != nil {
+		labels = []string{""}
+		groups[""] = map[int64]*bucket{}
+	}
+
+	var times []time.Time
+	if ts.fill != models.TimeSeriesFillNone {
+		if ts.start != nil {
+			first = ts.calendar.Truncate(*ts.start)
+		}
+		if ts.end != nil {
+			last = ts.calendar.Truncate(ts.end.Add(-time.Nanosecond))
+		}
+		for t := first; len(labels) > 0 && !t.After(last); t = ts.calendar.Next(t) {
+			if len(times) == maxTimeSeriesBuckets {
+				return nil, models.NewValidationError("time series spans more than %d %s buckets", maxTimeSeriesBuckets, ts.req.Interval)
+			}
+			times = append(times, t)
+		}
+	}
+
+	series := make([]Series, len(labels))
+	for i, label := range labels {
+		series[i] = ts.series(label, groups[label], times)
+	}
+	return series, nil
+}
+
+// series orders the buckets of one group, filling the given bucket times when
+// the request fills gaps
+func (ts *TimeSeries) series(label string, buckets map[int64]*bucket, times []time.Time) Series {
+	s := Series{Group: label}
+	if ts.fill == models.TimeSeriesFillNone {
+		for key := range buckets {
+			s.Times = append(s.Times, time.Unix(0, key).In(ts.calendar.Location()))
+		}
+		sortTimes(s.Times)
+		for _, t := range s.Times {
+			s.Values = append(s.Values, buckets[t.UnixNano()].value(ts.req.Aggregation))
+		}
+		return s
+	}
+
+	s.Times = times
+	s.Values = make([]float64, len(times))
+	previous := math.NaN()
+	for i, t := range times {
+		b, ok := buckets[t.UnixNano()]
+		switch {
+		case ok:
+			s.Values[i] = b.value(ts.req.Aggregation)
+		case ts.fill == models.TimeSeriesFillZero:
+			s.Values[i] = 0
+		case ts.fill == models.TimeSeriesFillForward:
+			s.Values[i] = previous
+		default:
+			s.Values[i] = math.NaN()
+		}
+		previous = s.Values[i]
+	}
+	return s
+}
+
+// add accumulates the value of one row into a bucket
+func (ts *TimeSeries) add(b *bucket, value interface{}) {
+	if value == nil {
+		return
+	}
+	b.count++
+	f, ok := query.ToFloat(value)
+	if !ok {
+		return
+	}
+	if b.numbers == 0 || f < b.min {
+		b.min = f
+	}
+	if b.numbers == 0 || f > b.max {
+		b.max = f
+	}
+	b.sum += f
+	b.numbers++
+}
+
+// groupLabel labels the group of a row with its group-by values
+func (ts *TimeSeries) groupLabel(frame *query.Frame, r int) string {
+	if len(ts.groupBy) == 0 {
+		return ""
+	}
+	parts := make([]string, len(ts.groupBy))
+	for i, index := range ts.groupBy {
+		value := frame.Columns[index][r]
+		if value == nil {
+			parts[i] = "null"
+			continue
+		}
+		parts[i] = query.FormatValue(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Compute buckets the rows of a frame into time series points, ordered by group
+// and then time. Timestamps are bucket starts in the request timezone.
+func (ts *TimeSeries) Compute(frame *query.Frame) (*models.TimeSeriesResult, error) {
+	series, err := ts.Series(frame)
+	if err != nil {
+		return nil, err
+	}
+	return ts.result(series), nil
+}
+
+// result describes the request with the points of the given series
+func (ts *TimeSeries) result(series []Series) *models.TimeSeriesResult {
+	result := &models.TimeSeriesResult{
+		TimeField:   ts.req.TimeField,
+		ValueField:  ts.req.ValueField,
+		Aggregation: ts.req.Aggregation,
+		Interval:    ts.req.Interval,
+		Timezone:    ts.calendar.Location().String(),
+		Fill:        ts.fill,
+		Points:      []models.TimeSeriesPoint{},
+	}
+	for _, s := range series {
+		result.Points = append(result.Points, s.Points()...)
+	}
+	return result
+}
+
+// Points converts a series to time series points
+func (s Series) Points() []models.TimeSeriesPoint {
+	points := make([]models.TimeSeriesPoint, len(s.Times))
+	for i, t := range s.Times {
+		points[i] = models.TimeSeriesPoint{
+			Timestamp: t.Format(time.RFC3339),
+			Value:     finitePtr(s.Values[i]),
+			Group:     s.Group,
+		}
+	}
+	return points
+}
+
+// timeValue reads a datetime value; strings without an offset are read in loc
+func timeValue(value interface{}, loc *time.Location) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		return query.ParseTime(v, loc)
+	default:
+		return time.Time{}, false
+	}
+}
+
+// sortTimes sorts times in ascending order
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+}
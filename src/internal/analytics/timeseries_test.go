@@ -0,0 +1,217 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var visitFields = []models.DataField{
+	{Name: "at", Type: models.DataTypeDateTime},
+	{Name: "visits", Type: models.DataTypeInteger},
+	{Name: "site", Type: models.DataTypeString},
+}
+
+func visitFrame() *query.Frame {
+	return query.NewFrame(visitFields, []map[string]interface{}{
+		{"at": "2024-03-01T10:00:00Z", "visits": int64(2), "site": "a"},
+		{"at": "2024-03-01T23:30:00Z", "visits": int64(3), "site": "b"},
+		{"at": "2024-03-04T08:00:00Z", "visits": int64(5), "site": "a"},
+		{"at": "2024-03-04T09:00:00Z", "visits": nil, "site": "a"},
+		{"at": nil, "visits": int64(7), "site": "a"},
+	})
+}
+
+func timeSeriesRequest() *models.TimeSeriesRequest {
+	return &models.TimeSeriesRequest{
+		TimeField:   "at",
+		ValueField:  "visits",
+		Aggregation: models.TimeSeriesSum,
+		Interval:    models.TimeSeriesDay,
+	}
+}
+
+func pointValues(points []models.TimeSeriesPoint) []interface{} {
+	values := make([]interface{}, len(points))
+	for i, p := range points {
+		if p.Value != nil {
+			values[i] = *p.Value
+		}
+	}
+	return values
+}
+
+func TestCalendar(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := time.Date(2024, time.November, 3, 15, 45, 30, 0, newYork)
+
+	// Test Case 1: Buckets start on calendar boundaries
+	expected := map[models.TimeSeriesInterval]string{
+		models.TimeSeriesMinute:  "2024-11-03T15:45:00-05:00",
+		models.TimeSeriesHour:    "2024-11-03T15:00:00-05:00",
+		models.TimeSeriesDay:     "2024-11-03T00:00:00-04:00",
+		models.TimeSeriesWeek:    "2024-10-28T00:00:00-04:00",
+		models.TimeSeriesMonth:   "2024-11-01T00:00:00-04:00",
+		models.TimeSeriesQuarter: "2024-10-01T00:00:00-04:00",
+		models.TimeSeriesYear:    "2024-01-01T00:00:00-05:00",
+	}
+	for interval, start := range expected {
+		interval, start := interval, start
+		t.Run(string(interval), func(t *testing.T) {
+			calendar, err := NewCalendar(interval, newYork)
+			require.NoError(t, err)
+			assert.Equal(t, start, calendar.Truncate(at).Format(time.RFC3339))
+		})
+	}
+
+	// Test Case 2: Days across a daylight saving change are 25 hours long
+	t.Run("Daylight Saving", func(t *testing.T) {
+		calendar, err := NewCalendar(models.TimeSeriesDay, newYork)
+		require.NoError(t, err)
+		start := calendar.Truncate(at)
+		assert.Equal(t, 25*time.Hour, calendar.Next(start).Sub(start))
+	})
+
+	// Test Case 3: Unknown intervals and timezones are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewCalendar("fortnight", time.UTC)
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+
+		_, err = LoadLocation("Mars/Olympus")
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestTimeSeries(t *testing.T) {
+	// Test Case 1: Empty buckets are left out by default
+	t.Run("No Fill", func(t *testing.T) {
+		ts, err := CompileTimeSeries(timeSeriesRequest(), visitFields)
+		require.NoError(t, err)
+		result, err := ts.Compute(visitFrame())
+		require.NoError(t, err)
+
+		assert.Equal(t, "UTC", result.Timezone)
+		assert.Equal(t, models.TimeSeriesFillNone, result.Fill)
+		require.Len(t, result.Points, 2)
+		assert.Equal(t, "2024-03-01T00:00:00Z", result.Points[0].Timestamp)
+		assert.Equal(t, []interface{}{5.0, 5.0}, pointValues(result.Points))
+	})
+
+	// Test Case 2: Every fill mode
+	fills := map[models.TimeSeriesFill][]interface{}{
+		models.TimeSeriesFillNull:    {5.0, nil, nil, 5.0},
+		models.TimeSeriesFillZero:    {5.0, 0.0, 0.0, 5.0},
+		models.TimeSeriesFillForward: {5.0, 5.0, 5.0, 5.0},
+	}
+	for fill, values := range fills {
+		fill, values := fill, values
+		t.Run(string(fill), func(t *testing.T) {
+			req := timeSeriesRequest()
+			req.Fill = fill
+			ts, err := CompileTimeSeries(req, visitFields)
+			require.NoError(t, err)
+			result, err := ts.Compute(visitFrame())
+			require.NoError(t, err)
+			assert.Equal(t, values, pointValues(result.Points))
+		})
+	}
+
+	// Test Case 3: The timezone moves rows between local days
+	t.Run("Timezone", func(t *testing.T) {
+		req := timeSeriesRequest()
+		req.Timezone = "Asia/Tokyo"
+		req.Aggregation = models.TimeSeriesCount
+		ts, err := CompileTimeSeries(req, visitFields)
+		require.NoError(t, err)
+		result, err := ts.Compute(visitFrame())
+		require.NoError(t, err)
+
+		require.Len(t, result.Points, 3)
+		assert.Equal(t, "2024-03-01T00:00:00+09:00", result.Points[0].Timestamp)
+		assert.Equal(t, "2024-03-02T00:00:00+09:00", result.Points[1].Timestamp)
+		assert.Equal(t, []interface{}{1.0, 1.0, 1.0}, pointValues(result.Points))
+	})
+
+	// Test Case 4: Groups share the filled range of the time bounds
+	t.Run("Groups", func(t *testing.T) {
+		req := timeSeriesRequest()
+		req.GroupBy = []string{"site"}
+		req.Fill = models.TimeSeriesFillZero
+		req.StartTime = "2024-03-01"
+		req.EndTime = "2024-03-03"
+		ts, err := CompileTimeSeries(req, visitFields)
+		require.NoError(t, err)
+		series, err := ts.Series(visitFrame())
+		require.NoError(t, err)
+
+		require.Len(t, series, 2)
+		assert.Equal(t, "a", series[0].Group)
+		assert.Equal(t, []float64{2, 0}, series[0].Values)
+		assert.Equal(t, "b", series[1].Group)
+		assert.Equal(t, []float64{3, 0}, series[1].Values)
+	})
+
+	// Test Case 5: Buckets whose rows have no value are null
+	t.Run("Null Values", func(t *testing.T) {
+		req := timeSeriesRequest()
+		req.Interval = models.TimeSeriesHour
+		req.Aggregation = models.TimeSeriesMax
+		req.StartTime = "2024-03-04T09:00:00Z"
+		ts, err := CompileTimeSeries(req, visitFields)
+		require.NoError(t, err)
+		series, err := ts.Series(visitFrame())
+		require.NoError(t, err)
+
+		require.Len(t, series, 1)
+		require.Len(t, series[0].Values, 1)
+		assert.True(t, math.IsNaN(series[0].Values[0]))
+	})
+
+	// Test Case 6: Filling is capped
+	t.Run("Bucket Cap", func(t *testing.T) {
+		req := timeSeriesRequest()
+		req.Interval = models.TimeSeriesMinute
+		req.Fill = models.TimeSeriesFillNull
+		req.StartTime = "2000-01-01T00:00:00Z"
+		req.EndTime = "2024-01-01T00:00:00Z"
+		ts, err := CompileTimeSeries(req, visitFields)
+		require.NoError(t, err)
+		_, err = ts.Series(visitFrame())
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 7: Invalid requests are rejected with validation errors
+	invalid := map[string]func(req *models.TimeSeriesRequest){
+		"Unknown Time Field":  func(req *models.TimeSeriesRequest) { req.TimeField = "when" },
+		"Time Not Datetime":   func(req *models.TimeSeriesRequest) { req.TimeField = "site" },
+		"Unknown Value Field": func(req *models.TimeSeriesRequest) { req.ValueField = "hits" },
+		"Sum Of Strings":      func(req *models.TimeSeriesRequest) { req.ValueField = "site" },
+		"Unknown Aggregation": func(req *models.TimeSeriesRequest) { req.Aggregation = "median" },
+		"Unknown Interval":    func(req *models.TimeSeriesRequest) { req.Interval = "decade" },
+		"Unknown Timezone":    func(req *models.TimeSeriesRequest) { req.Timezone = "Nowhere/City" },
+		"Unknown Fill":        func(req *models.TimeSeriesRequest) { req.Fill = "linear" },
+		"Unknown Group Field": func(req *models.TimeSeriesRequest) { req.GroupBy = []string{"region"} },
+		"Invalid Start":       func(req *models.TimeSeriesRequest) { req.StartTime = "yesterday" },
+		"Reversed Range":      func(req *models.TimeSeriesRequest) { req.StartTime, req.EndTime = "2024-02-01", "2024-01-01" },
+	}
+	for name, mutate := range invalid {
+		mutate := mutate
+		t.Run(name, func(t *testing.T) {
+			req := timeSeriesRequest()
+			mutate(req)
+			_, err := CompileTimeSeries(req, visitFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// Trend defaults and bounds
+const (
+	defaultTrendAlpha = 0.05
+	defaultMinSegment = 2
+	// maxTrendValues caps the values of a series, as the Mann-Kendall test
+	// and Sen's slope compare every pair of them
+	maxTrendValues = 3000
+)
+
+// Trend directions
+const (
+	trendIncreasing = "increasing"
+	trendDecreasing = "decreasing"
+	trendNone       = "none"
+)
+
+// Trend is a validated trend request
+type Trend struct {
+	series *TimeSeries
+	params models.TrendParams
+}
+
+// CompileTrend validates a trend request against the fields of the dataset
+func CompileTrend(req *models.TrendRequest, fields []models.DataField) (*Trend, error) {
+	tr := &Trend{}
+	if err := query.DecodeParams(req.Params, &tr.params); err != nil {
+		return nil, models.NewValidationError("trend: %v", err)
+	}
+	series, err := CompileTimeSeries(&req.TimeSeriesRequest, fields)
+	if err != nil {
+		return nil, err
+	}
+	tr.series = series
+
+	p := &tr.params
+	if p.Alpha == 0 {
+		p.Alpha = defaultTrendAlpha
+	}
+	if p.Alpha <= 0 || p.Alpha >= 1 {
+		return nil, models.NewValidationError("trend: alpha must be between 0 and 1")
+	}
+	if p.Penalty != nil && *p.Penalty < 0 {
+		return nil, models.NewValidationError("trend: penalty must not be negative")
+	}
+	if p.MinSegment == 0 {
+		p.MinSegment = defaultMinSegment
+	}
+	if p.MinSegment < 1 {
+		return nil, models.NewValidationError("trend: min_segment must be positive")
+	}
+	return tr, nil
+}
+
+// Compute tests each series of a frame with the fields the request was compiled
+// for. Buckets without a value are left out of the tests; the slope is per
+// bucket all the same.
+func (tr *Trend) Compute(frame *query.Frame) (*models.TrendResult, error) {
+	series, err := tr.series.Series(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.TrendResult{
+		TimeSeriesResult: *tr.series.result(series),
+		Trends:           []models.TrendTest{},
+	}
+	for _, s := range series {
+		var times []time.Time
+		var positions, values []float64
+		calendar := tr.series.Calendar()
+		position := 0
+		for i, v := range s.Values {
+			// Buckets left out by the fill still count towards the slope
+			if i > 0 {
+				for t := s.Times[i-1]; t.Before(s.Times[i]); t = calendar.Next(t) {
+					position++
+				}
+			}
+			if math.IsNaN(v) {
+				continue
+			}
+			times = append(times, s.Times[i])
+			positions = append(positions, float64(position))
+			values = append(values, v)
+		}
+		if len(values) > maxTrendValues {
+			return nil, models.NewValidationError("%s has %d values; trends are tested on at most %d", seriesName(s), len(values), maxTrendValues)
+		}
+
+		mk := MannKendall(values)
+		test := models.TrendTest{
+			Group:        s.Group,
+			Count:        len(values),
+			Direction:    trendNone,
+			S:            mk.S,
+			Z:            finitePtr(mk.Z),
+			PValue:       finitePtr(mk.P),
+			Tau:          finitePtr(mk.Tau),
+			ChangePoints: []models.ChangePoint{},
+		}
+		if mk.P < tr.params.Alpha {
+			test.Direction = trendIncreasing
+			if mk.S < 0 {
+				test.Direction = trendDecreasing
+			}
+		}
+		slope, intercept := SenSlope(positions, values)
+		test.Slope, test.Intercept = finitePtr(slope), finitePtr(intercept)
+
+		penalty := 2 * math.Log(float64(len(values)))
+		if tr.params.Penalty != nil {
+			penalty = *tr.params.Penalty
+		}
+		cuts := ChangePoints(values, penalty, tr.params.MinSegment)
+		bounds := append(append([]int{0}, cuts...), len(values))
+		for k, cut := range cuts {
+			test.ChangePoints = append(test.ChangePoints, models.ChangePoint{
+				Timestamp:  times[cut].Format(time.RFC3339),
+				MeanBefore: Mean(values[bounds[k]:cut]),
+				MeanAfter:  Mean(values[cut:bounds[k+2]]),
+			})
+		}
+		result.Trends = append(result.Trends, test)
+	}
+	return result, nil
+}
+
+// MannKendallTest is the result of a Mann-Kendall trend test: the score S,
+// Kendall's tau against time, and the continuity-corrected normal statistic Z
+// with its two-sided p-value. Z, P and Tau are NaN with fewer than three values
+// or when all values are equal.
+type MannKendallTest struct {
+	S, Z, P, Tau float64
+}
+
+// MannKendall tests values in time order for a monotonic trend, with the
+// variance of S corrected for ties
+func MannKendall(values []float64) MannKendallTest {
+	n := len(values)
+	var s float64
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case values[j] > values[i]:
+				s++
+			case values[j] < values[i]:
+				s--
+			}
+		}
+	}
+
+	test := MannKendallTest{S: s, Z: math.NaN(), P: math.NaN(), Tau: math.NaN()}
+	nf := float64(n)
+	variance := nf * (nf - 1) * (2*nf + 5)
+	for _, t := range tieGroups(Sorted(values)) {
+		tf := float64(t)
+		variance -= tf * (tf - 1) * (2*tf + 5)
+	}
+	variance /= 18
+	if n < 3 || variance <= 0 {
+		return test
+	}
+
+	switch {
+	case s > 0:
+		test.Z = (s - 1) / math.Sqrt(variance)
+	case s < 0:
+		test.Z = (s + 1) / math.Sqrt(variance)
+	default:
+		test.Z = 0
+	}
+	test.P = TwoSidedNormalPValue(test.Z)
+	test.Tau = s / pairs(n)
+	return test
+}
+
+// SenSlope returns the Theil-Sen estimate of the line through the points: the
+// median of the slopes between pairs of points and the median of the
+// intercepts under that slope. Both are NaN with fewer than two distinct
+// positions.
+func SenSlope(x, y []float64) (slope, intercept float64) {
+	var slopes []float64
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			if x[j] != x[i] {
+				slopes = append(slopes, (y[j]-y[i])/(x[j]-x[i]))
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	sort.Float64s(slopes)
+	slope = Median(slopes)
+
+	intercepts := make([]float64, len(x))
+	for i := range x {
+		intercepts[i] = y[i] - slope*x[i]
+	}
+	sort.Float64s(intercepts)
+	return slope, Median(intercepts)
+}
+
+// ChangePoints finds the shifts in the mean of values with the pruned exact
+// linear time (PELT) search of Killick et al. (2012). Segments cost their
+// squared deviations from their mean in units of the noise variance, estimated
+// robustly from the differences of consecutive values, and each change costs
+// penalty. It returns the index of the first value of each new segment.
+func ChangePoints(values []float64, penalty float64, minSegment int) []int {
+	n := len(values)
+	if n < 2*minSegment {
+		return nil
+	}
+	variance := noiseVariance(values)
+	if !(variance > 0) {
+		return nil
+	}
+
+	sum := make([]float64, n+1)
+	squares := make([]float64, n+1)
+	for i, v := range values {
+		sum[i+1] = sum[i] + v
+		squares[i+1] = squares[i] + v*v
+	}
+	cost := func(from, to int) float64 {
+		s := sum[to] - sum[from]
+		return (squares[to] - squares[from] - s*s/float64(to-from)) / variance
+	}
+
+	best := make([]float64, n+1)
+	previous := make([]int, n+1)
+	best[0] = -penalty
+	candidates := []int{0}
+	for t := minSegment; t <= n; t++ {
+		if c := t - minSegment; c >= minSegment {
+			candidates = append(candidates, c)
+		}
+		best[t] = math.Inf(1)
+		totals := make([]float64, len(candidates))
+		for k, c := range candidates {
+			totals[k] = best[c] + cost(c, t)
+			if totals[k]+penalty < best[t] {
+				best[t], previous[t] = totals[k]+penalty, c
+			}
+		}
+		// Candidates that cannot beat the best split of t never will later
+		kept := candidates[:0]
+		for k, c := range candidates {
+			if totals[k] <= best[t] {
+				kept = append(kept, c)
+			}
+		}
+		candidates = kept
+	}
+
+	var cuts []int
+	for t := previous[n]; t > 0; t = previous[t] {
+		cuts = append([]int{t}, cuts...)
+	}
+	return cuts
+}
+
+// noiseVariance estimates the variance of the noise around a piecewise
+// constant mean from the median absolute difference of consecutive values,
+// falling back on their mean square difference when most are equal
+func noiseVariance(values []float64) float64 {
+	diffs := make([]float64, len(values)-1)
+	for i := range diffs {
+		diffs[i] = math.Abs(values[i+1] - values[i])
+	}
+	sort.Float64s(diffs)
+	// The differences have twice the noise variance; 1.4826 scales the MAD
+	// of a normal sample to its standard deviation
+	sigma := 1.4826 * Median(diffs) / math.Sqrt2
+	if sigma > 0 {
+		return sigma * sigma
+	}
+	var squares float64
+	for _, d := range diffs {
+		squares += d * d
+	}
+	return squares / float64(len(diffs)) / 2
+}
@@ -0,0 +1,246 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func salesSeriesRequest() models.TimeSeriesRequest {
+	return models.TimeSeriesRequest{
+		TimeField:   "day",
+		ValueField:  "amount",
+		Aggregation: models.TimeSeriesSum,
+		Interval:    models.TimeSeriesDay,
+	}
+}
+
+// dailyFrameWithout is dailyFrame without the row of one day
+func dailyFrameWithout(amounts []float64, skip int) *query.Frame {
+	frame := dailyFrame(amounts)
+	var rows []map[string]interface{}
+	for r := 0; r < frame.Len(); r++ {
+		if r != skip {
+			rows = append(rows, map[string]interface{}{"day": frame.Columns[0][r], "amount": frame.Columns[1][r]})
+		}
+	}
+	return query.NewFrame(salesSeriesFields, rows)
+}
+
+func pointFloats(points []models.TimeSeriesPoint) []float64 {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = math.NaN()
+		if p.Value != nil {
+			values[i] = *p.Value
+		}
+	}
+	return values
+}
+
+func TestSTL(t *testing.T) {
+	season := []float64{3, 1, -1, -4, -2, 0, 3}
+	y := make([]float64, 70)
+	for i := range y {
+		y[i] = 10 + 0.2*float64(i) + season[i%7]
+	}
+
+	// Test Case 1: A trend with a fixed season is split exactly
+	t.Run("Components", func(t *testing.T) {
+		trend, seasonal, residual := STL(y, 7, 7, 13, false)
+
+		for i := 14; i < 56; i++ {
+			assert.InDelta(t, 10+0.2*float64(i), trend[i], 0.05)
+			assert.InDelta(t, season[i%7], seasonal[i], 0.05)
+			assert.InDelta(t, 0, residual[i], 0.05)
+		}
+		for i := range y {
+			assert.InDelta(t, y[i], trend[i]+seasonal[i]+residual[i], 1e-9)
+		}
+	})
+
+	// Test Case 2: A robust decomposition leaves an outlier in the residual
+	t.Run("Robust", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(11))
+		spiked := make([]float64, len(y))
+		for i := range y {
+			spiked[i] = y[i] + 0.1*rng.NormFloat64()
+		}
+		spiked[30] += 50
+		trend, seasonal, residual := STL(spiked, 7, 7, 13, true)
+
+		assert.InDelta(t, 50, residual[30], 1)
+		assert.InDelta(t, 10+0.2*30, trend[30], 0.5)
+		assert.InDelta(t, season[30%7], seasonal[30], 0.5)
+	})
+}
+
+func TestDecomposition(t *testing.T) {
+	season := []float64{3, 1, -1, -4, -2, 0, 3}
+	amounts := make([]float64, 35)
+	for i := range amounts {
+		amounts[i] = 10 + season[i%7]
+	}
+	frame := dailyFrameWithout(amounts, 10)
+
+	// Test Case 1: Gaps stay in the points and the residual
+	t.Run("Gap", func(t *testing.T) {
+		d, err := CompileDecomposition(&models.DecompositionRequest{TimeSeriesRequest: salesSeriesRequest()}, salesSeriesFields)
+		require.NoError(t, err)
+		result, err := d.Compute(frame)
+		require.NoError(t, err)
+
+		assert.Equal(t, 7, result.Period)
+		assert.Equal(t, models.TimeSeriesFillNull, result.Fill)
+		require.Len(t, result.Points, 35)
+		require.Len(t, result.Seasonal, 35)
+		assert.Nil(t, result.Points[10].Value)
+		assert.Nil(t, result.Residual[10].Value)
+		assert.NotNil(t, result.Trend[10].Value)
+		assert.Equal(t, result.Points[18].Timestamp, result.Seasonal[18].Timestamp)
+		assert.InDelta(t, season[18%7], *result.Seasonal[18].Value, 0.5)
+	})
+
+	// Test Case 2: Series shorter than two periods are rejected
+	t.Run("Short Series", func(t *testing.T) {
+		req := &models.DecompositionRequest{TimeSeriesRequest: salesSeriesRequest(), Params: map[string]interface{}{"period": 30}}
+		d, err := CompileDecomposition(req, salesSeriesFields)
+		require.NoError(t, err)
+		_, err = d.Compute(frame)
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 3: Invalid requests are rejected with validation errors
+	yearly := salesSeriesRequest()
+	yearly.Interval = models.TimeSeriesYear
+	invalid := map[string]*models.DecompositionRequest{
+		"Unknown Param":  {TimeSeriesRequest: salesSeriesRequest(), Params: map[string]interface{}{"window": 3}},
+		"Period Of One":  {TimeSeriesRequest: salesSeriesRequest(), Params: map[string]interface{}{"period": 1}},
+		"Even Seasonal":  {TimeSeriesRequest: salesSeriesRequest(), Params: map[string]interface{}{"seasonal": 8}},
+		"Even Trend":     {TimeSeriesRequest: salesSeriesRequest(), Params: map[string]interface{}{"trend": 10}},
+		"Yearly Buckets": {TimeSeriesRequest: yearly},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileDecomposition(req, salesSeriesFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestTrend(t *testing.T) {
+	// Test Case 1: Mann-Kendall on a strictly increasing series
+	t.Run("Mann-Kendall", func(t *testing.T) {
+		test := MannKendall([]float64{1, 2, 3, 4, 5})
+
+		assert.Equal(t, 10.0, test.S)
+		assert.InDelta(t, 2.204541, test.Z, 1e-6)
+		assert.InDelta(t, 0.027486, test.P, 1e-6)
+		assert.Equal(t, 1.0, test.Tau)
+
+		// Ties reduce the variance
+		tied := MannKendall([]float64{1, 1, 2, 3})
+		assert.Equal(t, 5.0, tied.S)
+		assert.InDelta(t, 4/math.Sqrt(23.0/3), tied.Z, 1e-9)
+
+		assert.True(t, math.IsNaN(MannKendall([]float64{4, 4, 4}).P))
+	})
+
+	// Test Case 2: Sen's slope is the median pairwise slope
+	t.Run("Sen Slope", func(t *testing.T) {
+		slope, intercept := SenSlope([]float64{0, 1, 2, 3}, []float64{1, 3, 2, 5})
+
+		assert.InDelta(t, 7.0/6, slope, 1e-9)
+		assert.InDelta(t, 1.25, intercept, 1e-9)
+	})
+
+	// Test Case 3: PELT finds shifts in the mean
+	t.Run("Change Points", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(3))
+		values := make([]float64, 90)
+		for i := range values {
+			values[i] = rng.NormFloat64()
+			switch {
+			case i >= 60:
+				values[i] += 4
+			case i >= 30:
+				values[i] += 10
+			}
+		}
+		assert.Equal(t, []int{30, 60}, ChangePoints(values, 2*math.Log(90), 2))
+
+		// A constant series has none
+		assert.Empty(t, ChangePoints([]float64{1, 1, 1, 1, 1}, 1, 1))
+	})
+
+	// Test Case 4: Missing buckets still count towards the slope
+	t.Run("Compute", func(t *testing.T) {
+		amounts := make([]float64, 40)
+		for i := range amounts {
+			amounts[i] = 100 + 2*float64(i)
+		}
+		tr, err := CompileTrend(&models.TrendRequest{TimeSeriesRequest: salesSeriesRequest()}, salesSeriesFields)
+		require.NoError(t, err)
+		result, err := tr.Compute(dailyFrameWithout(amounts, 5))
+		require.NoError(t, err)
+
+		require.Len(t, result.Trends, 1)
+		test := result.Trends[0]
+		assert.Len(t, result.Points, 39)
+		assert.Equal(t, 39, test.Count)
+		assert.Equal(t, "increasing", test.Direction)
+		assert.Less(t, *test.PValue, 0.001)
+		assert.InDelta(t, 2, *test.Slope, 1e-9)
+		assert.InDelta(t, 100, *test.Intercept, 1e-9)
+	})
+
+	// Test Case 5: A level shift is reported as a change point
+	t.Run("Compute Change Point", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(5))
+		amounts := make([]float64, 40)
+		for i := range amounts {
+			amounts[i] = 100 + rng.NormFloat64()
+			if i >= 20 {
+				amounts[i] += 50
+			}
+		}
+		tr, err := CompileTrend(&models.TrendRequest{TimeSeriesRequest: salesSeriesRequest()}, salesSeriesFields)
+		require.NoError(t, err)
+		result, err := tr.Compute(dailyFrame(amounts))
+		require.NoError(t, err)
+
+		test := result.Trends[0]
+		assert.Equal(t, "increasing", test.Direction)
+		require.Len(t, test.ChangePoints, 1)
+		assert.Equal(t, "2024-01-21T00:00:00Z", test.ChangePoints[0].Timestamp)
+		assert.InDelta(t, Mean(amounts[:20]), test.ChangePoints[0].MeanBefore, 1e-9)
+		assert.InDelta(t, Mean(amounts[20:]), test.ChangePoints[0].MeanAfter, 1e-9)
+	})
+
+	// Test Case 6: Invalid requests are rejected with validation errors
+	invalid := map[string]map[string]interface{}{
+		"Alpha Too High":   {"alpha": 1.5},
+		"Negative Penalty": {"penalty": -1},
+		"Negative Segment": {"min_segment": -2},
+		"Unknown Param":    {"method": "pettitt"},
+	}
+	for name, params := range invalid {
+		params := params
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileTrend(&models.TrendRequest{TimeSeriesRequest: salesSeriesRequest(), Params: params}, salesSeriesFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
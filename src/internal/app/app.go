@@ -0,0 +1,97 @@
+// Package app bootstraps the API gateway: it builds the database connection,
+// repositories, services and HTTP handlers from the loaded configuration.
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/galafis/go-data-api-microservices/internal/auth"
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/middleware"
+	"github.com/galafis/go-data-api-microservices/internal/repository"
+	"github.com/galafis/go-data-api-microservices/internal/services"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+)
+
+// App holds the wired dependencies of the API gateway
+type App struct {
+	Config       *config.Config
+	Repositories *repository.Repositories
+	RowStore     *storage.RowStore
+
+	JWTService      auth.JWTService
+	PasswordService auth.PasswordService
+	AuthMiddleware  *middleware.AuthMiddleware
+
+	AuthHandler      *handlers.AuthHandler
+	DatasetHandler   *handlers.DatasetHandler
+	QueryHandler     *handlers.QueryHandler
+	IngestHandler    *handlers.IngestHandler
+	VersionHandler   *handlers.VersionHandler
+	AnalyticsHandler *handlers.AnalyticsHandler
+	AnomalyHandler   *handlers.AnomalyHandler
+	ModelHandler     *handlers.ModelHandler
+	UserHandler      *handlers.UserHandler
+}
+
+// New creates the application dependencies from the given configuration
+func New(cfg *config.Config) (*App, error) {
+	if cfg.Auth.JWTSecret == "" {
+		return nil, errors.New("auth.jwt_secret must be configured")
+	}
+
+	// Connect to the database
+	repositories, err := repository.New(&cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repositories: %w", err)
+	}
+
+	// Query cursors are signed with a key of their own, derived from the JWT
+	// secret unless one is set, so no key signs both kinds of token
+	if cfg.Query.CursorSecret == "" {
+		mac := hmac.New(sha256.New, []byte(cfg.Auth.JWTSecret))
+		mac.Write([]byte("query-cursor"))
+		cfg.Query.CursorSecret = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	// Create services
+	rowStore := storage.NewRowStore(repositories.Postgres, repositories.ExternalDatasets)
+	jwtService := auth.NewJWTService(&cfg.Auth)
+	passwordService := auth.NewPasswordService(cfg.Auth.PasswordHashCost)
+	queryService := services.NewQueryService(repositories.Postgres, repositories.Datasets, rowStore, &cfg.Query)
+	ingestService := services.NewIngestService(repositories.Datasets, rowStore, &cfg.Ingest)
+	versionService := services.NewVersionService(repositories.Datasets, rowStore)
+	analyticsService := services.NewAnalyticsService(repositories.Postgres, repositories.Datasets, rowStore, &cfg.Query)
+
+	// Create handlers
+	return &App{
+		Config:       cfg,
+		Repositories: repositories,
+		RowStore:     rowStore,
+
+		JWTService:      jwtService,
+		PasswordService: passwordService,
+		AuthMiddleware:  middleware.NewAuthMiddleware(jwtService),
+
+		AuthHandler:      handlers.NewAuthHandler(jwtService, passwordService, repositories.Users),
+		DatasetHandler:   handlers.NewDatasetHandler(repositories.Datasets, rowStore),
+		QueryHandler:     handlers.NewQueryHandler(repositories.Datasets, queryService, rowStore),
+		IngestHandler:    handlers.NewIngestHandler(repositories.Datasets, ingestService),
+		VersionHandler:   handlers.NewVersionHandler(repositories.Datasets, versionService),
+		AnalyticsHandler: handlers.NewAnalyticsHandler(repositories.Datasets, analyticsService),
+		AnomalyHandler:   handlers.NewAnomalyHandler(repositories.Datasets, repositories.AnomalyRules, analyticsService),
+		ModelHandler:     handlers.NewModelHandler(repositories.Datasets, repositories.Models, analyticsService),
+		UserHandler:      handlers.NewUserHandler(repositories.Users, passwordService),
+	}, nil
+}
+
+// Close releases the resources held by the application
+func (a *App) Close(ctx context.Context) error {
+	return a.Repositories.Close(ctx)
+}
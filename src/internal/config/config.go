@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -15,6 +16,8 @@ type Config struct {
 	CORS        CORSConfig    `mapstructure:"cors"`
 	Logging     LoggingConfig `mapstructure:"logging"`
 	Services    ServicesConfig `mapstructure:"services"`
+	Query       QueryConfig    `mapstructure:"query"`
+	Ingest      IngestConfig   `mapstructure:"ingest"`
 }
 
 // ServerConfig represents the server configuration
@@ -38,6 +41,16 @@ type DatabaseConfig struct {
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	Mongo           MongoConfig   `mapstructure:"mongo"`
+}
+
+// MongoConfig represents the MongoDB configuration used when the driver is "mongodb"
+type MongoConfig struct {
+	URI      string        `mapstructure:"uri"`
+	Database string        `mapstructure:"database"`
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	Timeout  time.Duration `mapstructure:"timeout"`
 }
 
 // AuthConfig represents the authentication configuration
@@ -66,6 +79,23 @@ type LoggingConfig struct {
 	TimeFormat string `mapstructure:"time_format"`
 }
 
+// QueryConfig represents the query engine configuration
+type QueryConfig struct {
+	DefaultLimit  int    `mapstructure:"default_limit"`
+	MaxLimit      int    `mapstructure:"max_limit"`
+	MaxJoinRows   int    `mapstructure:"max_join_rows"`
+	MaxInputRows  int    `mapstructure:"max_input_rows"`
+	MaxExportRows int    `mapstructure:"max_export_rows"`
+	CursorSecret  string `mapstructure:"cursor_secret"`
+}
+
+// IngestConfig represents the row upload configuration
+type IngestConfig struct {
+	MaxUploadSize int64 `mapstructure:"max_upload_size"`
+	BatchSize     int   `mapstructure:"batch_size"`
+	MaxErrors     int   `mapstructure:"max_errors"`
+}
+
 // ServicesConfig represents the microservices configuration
 type ServicesConfig struct {
 	DataService      ServiceConfig `mapstructure:"data_service"`
@@ -92,7 +122,11 @@ func LoadConfig() (*Config, error) {
 	setDefaults()
 	
 	// Read environment variables
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	if err := bindEnvAliases(); err != nil {
+		return nil, err
+	}
 	
 	// Read configuration file
 	if err := viper.ReadInConfig(); err != nil {
@@ -131,11 +165,14 @@ func setDefaults() {
 	viper.SetDefault("database.max_open_conns", 25)
 	viper.SetDefault("database.max_idle_conns", 5)
 	viper.SetDefault("database.conn_max_lifetime", "5m")
+	viper.SetDefault("database.mongo.uri", "mongodb://localhost:27017")
+	viper.SetDefault("database.mongo.database", "data_api")
+	viper.SetDefault("database.mongo.timeout", "10s")
 	
 	// Auth defaults
 	viper.SetDefault("auth.jwt_secret", "your-secret-key")
 	viper.SetDefault("auth.access_token_expiry", "15m")
-	viper.SetDefault("auth.refresh_token_expiry", "7d")
+	viper.SetDefault("auth.refresh_token_expiry", "168h")
 	viper.SetDefault("auth.password_hash_cost", 10)
 	
 	// CORS defaults
@@ -152,6 +189,18 @@ func setDefaults() {
 	viper.SetDefault("logging.output", "stdout")
 	viper.SetDefault("logging.time_format", time.RFC3339)
 	
+	// Query defaults
+	viper.SetDefault("query.default_limit", 100)
+	viper.SetDefault("query.max_limit", 10000)
+	viper.SetDefault("query.max_join_rows", 1000000)
+	viper.SetDefault("query.max_input_rows", 1000000)
+	viper.SetDefault("query.max_export_rows", 10000000)
+	
+	// Ingest defaults
+	viper.SetDefault("ingest.max_upload_size", 256<<20)
+	viper.SetDefault("ingest.batch_size", 1000)
+	viper.SetDefault("ingest.max_errors", 1000)
+	
 	// Services defaults
 	viper.SetDefault("services.data_service.host", "localhost")
 	viper.SetDefault("services.data_service.port", 50051)
@@ -166,3 +215,35 @@ func setDefaults() {
 	viper.SetDefault("services.analytics_service.tls", false)
 }
 
+// envAliases maps configuration keys to the short environment variable names
+// used by the deployment files, in addition to the automatic DATABASE_HOST style names
+var envAliases = map[string]string{
+	"database.driver":           "DB_DRIVER",
+	"database.host":             "DB_HOST",
+	"database.port":             "DB_PORT",
+	"database.username":         "DB_USERNAME",
+	"database.password":         "DB_PASSWORD",
+	"database.database":         "DB_NAME",
+	"database.ssl_mode":         "DB_SSL_MODE",
+	"database.mongo.uri":        "MONGO_URI",
+	"database.mongo.database":   "MONGO_DATABASE",
+	"database.mongo.username":   "MONGO_USERNAME",
+	"database.mongo.password":   "MONGO_PASSWORD",
+	"auth.jwt_secret":           "JWT_SECRET",
+	"auth.access_token_expiry":  "ACCESS_TOKEN_EXPIRY",
+	"auth.refresh_token_expiry": "REFRESH_TOKEN_EXPIRY",
+	"auth.password_hash_cost":   "PASSWORD_HASH_COST",
+	"logging.level":             "LOG_LEVEL",
+	"logging.format":            "LOG_FORMAT",
+	"logging.output":            "LOG_OUTPUT",
+}
+
+// bindEnvAliases binds the short environment variable names to their configuration keys
+func bindEnvAliases() error {
+	for key, env := range envAliases {
+		if err := viper.BindEnv(key, strings.ToUpper(strings.ReplaceAll(key, ".", "_")), env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+)
+
+// migrationLockID is the advisory lock key held while migrations run, so that
+// several services starting at the same time do not apply them concurrently
+const migrationLockID = 7243957381
+
+// migration represents a single versioned up migration
+type migration struct {
+	version int64
+	name    string
+}
+
+// Migrate applies all pending up migrations found in the given file system.
+// Migration state is kept in the schema_migrations table using the same layout
+// as golang-migrate, so databases migrated with the CLI are picked up as well.
+func (p *PostgresDB) Migrate(migrations fs.FS) error {
+	ctx := context.Background()
+
+	// Advisory locks are per session, so everything runs on a single connection
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	// Get current version
+	var current int64
+	var dirty bool
+	err = conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&current, &dirty)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("database schema is dirty at version %d, fix it manually before migrating", current)
+	}
+
+	// Collect pending migrations
+	pending, err := pendingMigrations(migrations, current)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		content, err := fs.ReadFile(migrations, m.name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", m.name, err)
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin migration transaction: %w", err)
+		}
+		if _, err := tx.Exec(string(content)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_migrations`); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+		}
+
+		logger.Infof("Applied database migration %s", m.name)
+	}
+
+	return nil
+}
+
+// pendingMigrations returns the up migrations newer than the current version, in order
+func pendingMigrations(migrations fs.FS, current int64) ([]migration, error) {
+	names, err := fs.Glob(migrations, "*.up.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var pending []migration
+	for _, name := range names {
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", name, err)
+		}
+		if version > current {
+			pending = append(pending, migration{version: version, name: name})
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].version < pending[j].version
+	})
+
+	return pending, nil
+}
@@ -3,9 +3,12 @@ package database
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -33,6 +36,13 @@ func NewMongoDB(cfg *MongoDBConfig) (*MongoDB, error) {
 
 	// Create client options
 	clientOptions := options.Client().ApplyURI(cfg.URI)
+
+	// Decode nested documents held in interface values (metadata, defaults) as maps
+	// instead of bson.D, so they serialise to JSON objects
+	registry := bson.NewRegistryBuilder()
+	registry.RegisterTypeMapEntry(bsontype.EmbeddedDocument, reflect.TypeOf(bson.M{}))
+	clientOptions.SetRegistry(registry.Build())
+
 	if cfg.Username != "" && cfg.Password != "" {
 		clientOptions.SetAuth(options.Credential{
 			Username: cfg.Username,
@@ -0,0 +1,147 @@
+package formats
+
+import (
+	"bufio"
+	"io"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// ArrowWriter buffers a record batch in memory before writing it
+const (
+	arrowWriterBatchRows   = 64 * 1024
+	arrowWriterBufferBytes = 256 * 1024
+)
+
+// ArrowWriter writes rows to an Arrow IPC stream with one nullable column per
+// field. Integers are written as Int64, floats as Float64, booleans as Bool,
+// datetimes as UTC microsecond timestamps, and strings, arrays and objects as
+// Utf8, arrays and objects tagged with the arrow.json extension type.
+type ArrowWriter struct {
+	buffer  *bufio.Writer
+	writer  *ipc.Writer
+	fields  []models.DataField
+	builder *array.RecordBuilder
+	rows    int
+}
+
+// NewArrowWriter creates a writer of an Arrow IPC stream. The schema message
+// is written with the first record batch, or on Close when there are no rows.
+func NewArrowWriter(w io.Writer, fields []models.DataField) (*ArrowWriter, error) {
+	schema := arrowSchema(fields)
+	buffer := bufio.NewWriterSize(w, arrowWriterBufferBytes)
+	return &ArrowWriter{
+		buffer:  buffer,
+		writer:  ipc.NewWriter(buffer, ipc.WithSchema(schema)),
+		fields:  fields,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}, nil
+}
+
+// Write buffers a row, writing the record batch once it is full
+func (w *ArrowWriter) Write(row map[string]interface{}) error {
+	appendRow(w.builder, w.fields, row)
+	w.rows++
+	if w.rows >= arrowWriterBatchRows {
+		return w.writeBatch()
+	}
+	return nil
+}
+
+// Close writes the last record batch and the end-of-stream marker
+func (w *ArrowWriter) Close() error {
+	defer w.builder.Release()
+	if w.rows > 0 {
+		if err := w.writeBatch(); err != nil {
+			return err
+		}
+	}
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	return w.buffer.Flush()
+}
+
+// writeBatch writes the buffered rows as a record batch
+func (w *ArrowWriter) writeBatch() error {
+	record := w.builder.NewRecord()
+	defer record.Release()
+	w.rows = 0
+	return w.writer.Write(record)
+}
+
+// arrowSchema returns the Arrow schema rows of the fields are written with,
+// as Arrow IPC streams and as Parquet files
+func arrowSchema(fields []models.DataField) *arrow.Schema {
+	columns := make([]arrow.Field, len(fields))
+	for i, field := range fields {
+		columns[i] = arrow.Field{Name: field.Name, Type: arrowType(field.Type), Nullable: true}
+		if field.Type == models.DataTypeArray || field.Type == models.DataTypeObject {
+			columns[i].Metadata = arrow.NewMetadata([]string{"ARROW:extension:name"}, []string{"arrow.json"})
+		}
+	}
+	return arrow.NewSchema(columns, nil)
+}
+
+// arrowType returns the Arrow type a field type is written as
+func arrowType(t models.DataType) arrow.DataType {
+	switch t {
+	case models.DataTypeInteger:
+		return arrow.PrimitiveTypes.Int64
+	case models.DataTypeFloat:
+		return arrow.PrimitiveTypes.Float64
+	case models.DataTypeBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case models.DataTypeDateTime:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// appendRow appends a row to the column builders of a record built with
+// arrowSchema, converting its values with typedValue
+func appendRow(builder *array.RecordBuilder, fields []models.DataField, row map[string]interface{}) {
+	for i, field := range fields {
+		appendValue(builder.Field(i), typedValue(row[field.Name], field.Type))
+	}
+}
+
+// appendValue appends a typed value to a column builder, or a null when the
+// value is nil or not of the column's type
+func appendValue(builder array.Builder, v interface{}) {
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		if n, ok := v.(int64); ok {
+			b.Append(n)
+			return
+		}
+	case *array.Float64Builder:
+		if f, ok := v.(float64); ok {
+			b.Append(f)
+			return
+		}
+	case *array.BooleanBuilder:
+		if value, ok := v.(bool); ok {
+			b.Append(value)
+			return
+		}
+	case *array.TimestampBuilder:
+		if t, ok := v.(time.Time); ok {
+			b.Append(arrow.Timestamp(unixMicros(t)))
+			return
+		}
+	case *array.StringBuilder:
+		if s, ok := v.(string); ok {
+			b.Append(s)
+			return
+		}
+	}
+	builder.AppendNull()
+}
@@ -0,0 +1,155 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// utf8BOM is the byte order mark some tools write at the start of CSV files
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// CSVReader reads rows from a CSV file whose first record names the columns.
+// Empty cells read as nil and the other cells as strings.
+type CSVReader struct {
+	reader  *csv.Reader
+	columns []string
+	row     int
+}
+
+// NewCSVReader reads the header of a CSV file with the given field delimiter
+func NewCSVReader(r io.Reader, delimiter rune) (*CSVReader, error) {
+	reader := csv.NewReader(&bomReader{r: r})
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, models.NewValidationError("CSV file has no header row")
+	}
+	if err != nil {
+		return nil, models.NewValidationError("invalid CSV header: %v", err)
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, models.NewValidationError("CSV header column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, models.NewValidationError("CSV header repeats column %s", name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+
+	return &CSVReader{reader: reader, columns: columns}, nil
+}
+
+// Columns returns the columns named by the header
+func (r *CSVReader) Columns() []string {
+	return r.columns
+}
+
+// Read returns the next record as a row keyed by the header columns
+func (r *CSVReader) Read() (int, map[string]interface{}, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return 0, nil, io.EOF
+	}
+	r.row++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return r.row, nil, &models.RowError{Row: r.row, Message: parseErr.Err.Error()}
+		}
+		return r.row, nil, err
+	}
+	if len(record) != len(r.columns) {
+		return r.row, nil, &models.RowError{
+			Row:     r.row,
+			Message: "record has a different number of fields than the header",
+		}
+	}
+
+	row := make(map[string]interface{}, len(record))
+	for i, cell := range record {
+		if cell == "" {
+			row[r.columns[i]] = nil
+		} else {
+			row[r.columns[i]] = cell
+		}
+	}
+	return r.row, row, nil
+}
+
+// bomReader drops a byte order mark from the start of a stream
+type bomReader struct {
+	r       io.Reader
+	checked bool
+}
+
+func (b *bomReader) Read(p []byte) (int, error) {
+	if b.checked {
+		return b.r.Read(p)
+	}
+	b.checked = true
+
+	head := make([]byte, len(utf8BOM))
+	n, err := io.ReadFull(b.r, head)
+	head = head[:n]
+	if bytes.Equal(head, utf8BOM) {
+		head = nil
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		b.r = bytes.NewReader(head)
+	} else if err != nil {
+		return 0, err
+	} else {
+		b.r = io.MultiReader(bytes.NewReader(head), b.r)
+	}
+	return b.r.Read(p)
+}
+
+// CSVWriter writes rows to a CSV file with a header naming the columns. Nulls
+// are written as empty cells and arrays and objects as JSON text.
+type CSVWriter struct {
+	writer *csv.Writer
+	fields []models.DataField
+	record []string
+}
+
+// NewCSVWriter writes the header of a CSV file
+func NewCSVWriter(w io.Writer, fields []models.DataField) (*CSVWriter, error) {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &CSVWriter{writer: writer, fields: fields, record: make([]string, len(fields))}, nil
+}
+
+// Write adds a record
+func (w *CSVWriter) Write(row map[string]interface{}) error {
+	for i, field := range w.fields {
+		w.record[i] = query.FormatValue(row[field.Name])
+	}
+	return w.writer.Write(w.record)
+}
+
+// Close writes the buffered records
+func (w *CSVWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,171 @@
+// Package formats reads rows from CSV, NDJSON and Parquet files and writes rows
+// to CSV, NDJSON, Parquet and Arrow IPC streams. Readers yield rows one at a
+// time with the raw values of the file, leaving their conversion to field
+// types to the caller; writers take stored rows and buffer at most a row group
+// or record batch.
+package formats
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// RowReader reads the rows of a file one at a time
+type RowReader interface {
+	// Columns lists the columns of the file, or nil when every row names its
+	// own fields
+	Columns() []string
+
+	// Read returns the next row with its position in the file, starting at 1.
+	// It returns io.EOF after the last row, and a *models.RowError for a row
+	// that cannot be decoded, after which reading can go on.
+	Read() (int, map[string]interface{}, error)
+}
+
+// RowWriter writes rows to a file one at a time
+type RowWriter interface {
+	// Write adds a row. Fields the row lacks are written as nulls and fields
+	// outside the writer's columns are ignored.
+	Write(row map[string]interface{}) error
+
+	// Close writes the buffered rows and the end of the file. It does not
+	// close the underlying writer.
+	Close() error
+}
+
+// NewRowWriter creates a writer of rows with the given fields as columns
+func NewRowWriter(w io.Writer, format models.FileFormat, fields []models.DataField) (RowWriter, error) {
+	switch format {
+	case models.FormatCSV:
+		return NewCSVWriter(w, fields)
+	case models.FormatNDJSON:
+		return NewNDJSONWriter(w, fields), nil
+	case models.FormatParquet:
+		return NewParquetWriter(w, fields)
+	case models.FormatArrow:
+		return NewArrowWriter(w, fields)
+	default:
+		return nil, models.NewValidationError("format must be csv, ndjson, parquet or arrow")
+	}
+}
+
+// ContentType returns the media type of files of a format
+func ContentType(format models.FileFormat) string {
+	switch format {
+	case models.FormatCSV:
+		return "text/csv; charset=utf-8"
+	case models.FormatNDJSON:
+		return "application/x-ndjson"
+	case models.FormatParquet:
+		return "application/vnd.apache.parquet"
+	case models.FormatArrow:
+		return "application/vnd.apache.arrow.stream"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension returns the file name extension of files of a format
+func Extension(format models.FileFormat) string {
+	switch format {
+	case models.FormatArrow:
+		return ".arrows"
+	case models.FormatCSV, models.FormatNDJSON, models.FormatParquet:
+		return "." + string(format)
+	default:
+		return ""
+	}
+}
+
+// Writable reports whether rows can be written in a format
+func Writable(format models.FileFormat) bool {
+	switch format {
+	case models.FormatCSV, models.FormatNDJSON, models.FormatParquet, models.FormatArrow:
+		return true
+	default:
+		return false
+	}
+}
+
+// extensions maps file name extensions to formats
+var extensions = map[string]models.FileFormat{
+	".csv":     models.FormatCSV,
+	".ndjson":  models.FormatNDJSON,
+	".jsonl":   models.FormatNDJSON,
+	".parquet": models.FormatParquet,
+	".arrows":  models.FormatArrow,
+}
+
+// contentTypes maps media types to formats
+var contentTypes = map[string]models.FileFormat{
+	"text/csv":                            models.FormatCSV,
+	"application/csv":                     models.FormatCSV,
+	"application/x-ndjson":                models.FormatNDJSON,
+	"application/ndjson":                  models.FormatNDJSON,
+	"application/jsonl":                   models.FormatNDJSON,
+	"application/x-jsonlines":             models.FormatNDJSON,
+	"application/vnd.apache.parquet":      models.FormatParquet,
+	"application/x-parquet":               models.FormatParquet,
+	"application/vnd.apache.arrow.stream": models.FormatArrow,
+}
+
+// Detect infers the format of a file from its name, or else from its content
+// type
+func Detect(contentType, filename string) (models.FileFormat, bool) {
+	if format, ok := extensions[strings.ToLower(path.Ext(filename))]; ok {
+		return format, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	format, ok := contentTypes[strings.ToLower(mediaType)]
+	return format, ok
+}
+
+// typedValue converts a stored value to what a typed column of the field type
+// holds: int64, float64, bool, time.Time, or string for text and the JSON text
+// of arrays and objects. Values that do not convert are written as nulls.
+func typedValue(value interface{}, t models.DataType) interface{} {
+	if value == nil {
+		return nil
+	}
+	switch t {
+	case models.DataTypeString:
+		return query.FormatValue(value)
+	case models.DataTypeArray, models.DataTypeObject:
+		if s, ok := value.(string); ok {
+			return s
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil
+		}
+		return string(encoded)
+	}
+
+	cast, err := query.CastValue(value, t)
+	if err != nil {
+		return nil
+	}
+	if t == models.DataTypeDateTime {
+		tm, ok := query.ToTime(cast)
+		if !ok {
+			return nil
+		}
+		return tm.UTC()
+	}
+	return cast
+}
+
+// unixMicros returns the microseconds since the Unix epoch of a time
+func unixMicros(t time.Time) int64 {
+	return t.Unix()*1e6 + int64(t.Nanosecond()/1e3)
+}
@@ -0,0 +1,430 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/apache/arrow/go/v11/parquet"
+	"github.com/apache/arrow/go/v11/parquet/compress"
+	"github.com/apache/arrow/go/v11/parquet/file"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll drains a reader, collecting its rows and row errors
+func readAll(t *testing.T, reader RowReader) ([]map[string]interface{}, []*models.RowError) {
+	var rows []map[string]interface{}
+	var rowErrs []*models.RowError
+	for {
+		_, row, err := reader.Read()
+		if err == io.EOF {
+			return rows, rowErrs
+		}
+		if rowErr, ok := err.(*models.RowError); ok {
+			rowErrs = append(rowErrs, rowErr)
+			continue
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		contentType string
+		filename    string
+		format      models.FileFormat
+		ok          bool
+	}{
+		{"", "rows.CSV", models.FormatCSV, true},
+		{"application/octet-stream", "rows.jsonl", models.FormatNDJSON, true},
+		{"text/csv", "rows.parquet", models.FormatParquet, true},
+		{"application/x-ndjson; charset=utf-8", "", models.FormatNDJSON, true},
+		{"application/vnd.apache.parquet", "upload", models.FormatParquet, true},
+		{"application/json", "rows.txt", "", false},
+	}
+	for _, c := range cases {
+		format, ok := Detect(c.contentType, c.filename)
+		assert.Equal(t, c.ok, ok, c.filename)
+		assert.Equal(t, c.format, format, c.filename)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	// Test Case 1: Rows are keyed by the header and empty cells are nil
+	t.Run("Rows", func(t *testing.T) {
+		input := "\xef\xbb\xbfid;name;note\n1;alice;\n2;\"bob; jr\";x\n"
+		reader, err := NewCSVReader(strings.NewReader(input), ';')
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "note"}, reader.Columns())
+
+		rows, rowErrs := readAll(t, reader)
+		assert.Empty(t, rowErrs)
+		assert.Equal(t, []map[string]interface{}{
+			{"id": "1", "name": "alice", "note": nil},
+			{"id": "2", "name": "bob; jr", "note": "x"},
+		}, rows)
+	})
+
+	// Test Case 2: Malformed records are reported without stopping the read
+	t.Run("Malformed", func(t *testing.T) {
+		input := "id,name\n1,alice\n2\n3,\"carol\n"
+		reader, err := NewCSVReader(strings.NewReader(input), ',')
+		require.NoError(t, err)
+
+		_, row, err := reader.Read()
+		require.NoError(t, err)
+		assert.Equal(t, "alice", row["name"])
+
+		position, _, err := reader.Read()
+		var rowErr *models.RowError
+		require.ErrorAs(t, err, &rowErr)
+		assert.Equal(t, 2, position)
+		assert.Equal(t, 2, rowErr.Row)
+
+		_, _, err = reader.Read()
+		require.ErrorAs(t, err, &rowErr)
+		assert.Equal(t, 3, rowErr.Row)
+	})
+
+	// Test Case 3: Invalid headers are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := map[string]string{
+			"empty":     "",
+			"unnamed":   "id,,name\n",
+			"duplicate": "id,name,id\n",
+		}
+		for name, input := range invalid {
+			_, err := NewCSVReader(strings.NewReader(input), ',')
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+}
+
+func TestNDJSONReader(t *testing.T) {
+	input := "{\"id\": 1, \"amount\": 12345678901234567890}\n\n[1, 2]\n{\"id\": \n{\"id\": 4, \"tags\": [\"a\"]}"
+	reader := NewNDJSONReader(strings.NewReader(input))
+	assert.Nil(t, reader.Columns())
+
+	// Test Case 1: Numbers keep their precision
+	position, row, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, 1, position)
+	assert.Equal(t, json.Number("12345678901234567890"), row["amount"])
+
+	// Test Case 2: Non-objects and invalid JSON are row errors numbered by line
+	var rowErr *models.RowError
+	_, _, err = reader.Read()
+	require.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, 3, rowErr.Row)
+	assert.Contains(t, rowErr.Message, "not a JSON object")
+
+	_, _, err = reader.Read()
+	require.ErrorAs(t, err, &rowErr)
+	assert.Equal(t, 4, rowErr.Row)
+
+	// Test Case 3: The last line needs no newline
+	position, row, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, 5, position)
+	assert.Equal(t, []interface{}{"a"}, row["tags"])
+
+	_, _, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParquetReader(t *testing.T) {
+	// rows.parquet holds two row groups of ten columns mixing required and
+	// optional columns, plain, dictionary and RLE encodings, v1 and v2 data
+	// pages, Snappy and gzip compression, and converted and logical types
+	data, err := os.ReadFile("testdata/rows.parquet")
+	require.NoError(t, err)
+
+	// Test Case 1: Rows decode with their annotated types
+	t.Run("Rows", func(t *testing.T) {
+		reader, err := NewParquetReader(strings.NewReader(string(data)), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "score", "active", "created", "seen", "price", "day", "uid", "ratio"}, reader.Columns())
+
+		rows, rowErrs := readAll(t, reader)
+		assert.Empty(t, rowErrs)
+		require.Len(t, rows, 5)
+
+		day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, map[string]interface{}{
+			"id":      int64(1),
+			"name":    "alice",
+			"score":   9.5,
+			"active":  true,
+			"created": day,
+			"seen":    day.Add(123456 * time.Microsecond),
+			"price":   19.99,
+			"day":     day,
+			"uid":     "00010203-0405-0607-0809-0a0b0c0d0e0f",
+			"ratio":   0.1,
+		}, rows[0])
+		assert.Equal(t, map[string]interface{}{
+			"id":      int64(2),
+			"name":    nil,
+			"score":   nil,
+			"active":  false,
+			"created": day.AddDate(0, 0, 1),
+			"seen":    nil,
+			"price":   -2.5,
+			"day":     nil,
+			"uid":     nil,
+			"ratio":   2.5,
+		}, rows[1])
+
+		// The second row group
+		assert.Equal(t, int64(4), rows[3]["id"])
+		assert.Equal(t, "bob", rows[3]["name"])
+		assert.Equal(t, "carol", rows[4]["name"])
+		assert.Equal(t, 123.45, rows[4]["price"])
+		assert.Equal(t, day.AddDate(0, 0, 4).Add(time.Microsecond), rows[4]["seen"])
+	})
+
+	// Test Case 2: Truncated and corrupted files are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		corrupt := []byte(string(data))
+		corrupt[len(corrupt)-9] = 0x7f
+
+		invalid := map[string][]byte{
+			"empty":     {},
+			"magic":     []byte("PAR2" + string(data[4:])),
+			"truncated": data[len(data)/2:],
+			"footer":    corrupt,
+		}
+		for name, input := range invalid {
+			_, err := NewParquetReader(strings.NewReader(string(input)), int64(len(input)))
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+
+	// Test Case 3: Delta encodings, v2 data pages and other codecs decode
+	t.Run("Encodings", func(t *testing.T) {
+		encodings := []parquet.WriterProperty{
+			parquet.WithDictionaryDefault(false),
+			parquet.WithDataPageVersion(parquet.DataPageV2),
+			parquet.WithEncodingFor("id", parquet.Encodings.DeltaBinaryPacked),
+			parquet.WithEncodingFor("name", parquet.Encodings.DeltaByteArray),
+			parquet.WithEncodingFor("tags", parquet.Encodings.DeltaLengthByteArray),
+		}
+		codecs := map[string]compress.Compression{
+			"zstd":   compress.Codecs.Zstd,
+			"brotli": compress.Codecs.Brotli,
+			"gzip":   compress.Codecs.Gzip,
+		}
+		for name, codec := range codecs {
+			var buf bytes.Buffer
+			writer, err := newParquetWriter(&buf, writerFields, parquet.NewWriterProperties(append(encodings, parquet.WithCompression(codec))...))
+			require.NoError(t, err, name)
+			for _, row := range writerRows {
+				require.NoError(t, writer.Write(row), name)
+			}
+			require.NoError(t, writer.Close(), name)
+
+			reader, err := NewParquetReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err, name)
+			rows, rowErrs := readAll(t, reader)
+			assert.Empty(t, rowErrs, name)
+			assert.Equal(t, parquetRows, rows, name)
+		}
+	})
+}
+
+// writerFields and writerRows are stored rows as decoded from the row table
+var writerFields = []models.DataField{
+	{Name: "id", Type: models.DataTypeInteger},
+	{Name: "name", Type: models.DataTypeString},
+	{Name: "score", Type: models.DataTypeFloat},
+	{Name: "active", Type: models.DataTypeBoolean},
+	{Name: "joined", Type: models.DataTypeDateTime},
+	{Name: "tags", Type: models.DataTypeArray},
+}
+
+var writerRows = []map[string]interface{}{
+	{"id": int64(1), "name": "alice", "score": 9.5, "active": true, "joined": "2024-03-01T12:00:00.5Z", "tags": []interface{}{"a", 1.0}},
+	{"id": int64(2), "name": "bob, jr", "score": nil, "active": false, "extra": "ignored"},
+	{"id": int64(3), "name": "<carol>", "score": int64(4), "active": nil, "joined": "2024-03-02T00:00:00+01:00", "tags": nil},
+}
+
+// parquetRows are writerRows as read back from a Parquet file
+var parquetRows = []map[string]interface{}{
+	{"id": int64(1), "name": "alice", "score": 9.5, "active": true, "joined": time.Date(2024, 3, 1, 12, 0, 0, 5e8, time.UTC), "tags": `["a",1]`},
+	{"id": int64(2), "name": "bob, jr", "score": nil, "active": false, "joined": nil, "tags": nil},
+	{"id": int64(3), "name": "<carol>", "score": 4.0, "active": nil, "joined": time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC), "tags": nil},
+}
+
+// writeAll writes rows in a format, returning the file
+func writeAll(t *testing.T, format models.FileFormat, fields []models.DataField, rows []map[string]interface{}) []byte {
+	var buf strings.Builder
+	writer, err := NewRowWriter(&buf, format, fields)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, writer.Write(row))
+	}
+	require.NoError(t, writer.Close())
+	return []byte(buf.String())
+}
+
+func TestCSVWriter(t *testing.T) {
+	output := writeAll(t, models.FormatCSV, writerFields, writerRows)
+	assert.Equal(t, "id,name,score,active,joined,tags\n"+
+		"1,alice,9.5,true,2024-03-01T12:00:00.5Z,\"[\"\"a\"\",1]\"\n"+
+		"2,\"bob, jr\",,false,,\n"+
+		"3,<carol>,4,,2024-03-02T00:00:00+01:00,\n", string(output))
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	output := writeAll(t, models.FormatNDJSON, writerFields, writerRows)
+	assert.Equal(t, `{"id":1,"name":"alice","score":9.5,"active":true,"joined":"2024-03-01T12:00:00.5Z","tags":["a",1]}`+"\n"+
+		`{"id":2,"name":"bob, jr","score":null,"active":false,"joined":null,"tags":null}`+"\n"+
+		`{"id":3,"name":"<carol>","score":4,"active":null,"joined":"2024-03-02T00:00:00+01:00","tags":null}`+"\n", string(output))
+}
+
+func TestParquetWriter(t *testing.T) {
+	// Test Case 1: Written files read back with their field types
+	t.Run("RoundTrip", func(t *testing.T) {
+		data := writeAll(t, models.FormatParquet, writerFields, writerRows)
+		reader, err := NewParquetReader(strings.NewReader(string(data)), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "score", "active", "joined", "tags"}, reader.Columns())
+
+		rows, rowErrs := readAll(t, reader)
+		assert.Empty(t, rowErrs)
+		assert.Equal(t, parquetRows, rows)
+	})
+
+	// Test Case 2: Large results span row groups
+	t.Run("RowGroups", func(t *testing.T) {
+		fields := []models.DataField{{Name: "n", Type: models.DataTypeInteger}, {Name: "even", Type: models.DataTypeBoolean}}
+		rows := make([]map[string]interface{}, parquetWriterRowGroup+1)
+		for i := range rows {
+			rows[i] = map[string]interface{}{"n": int64(i), "even": i%2 == 0}
+		}
+		data := writeAll(t, models.FormatParquet, fields, rows)
+
+		parquetFile, err := file.NewParquetReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, 2, parquetFile.NumRowGroups())
+
+		reader, err := NewParquetReader(strings.NewReader(string(data)), int64(len(data)))
+		require.NoError(t, err)
+		read, rowErrs := readAll(t, reader)
+		assert.Empty(t, rowErrs)
+		require.Len(t, read, len(rows))
+		for _, i := range []int{0, parquetWriterRowGroup - 1, parquetWriterRowGroup} {
+			assert.Equal(t, rows[i], read[i], i)
+		}
+	})
+
+	// Test Case 3: An empty result is a file of the schema alone
+	t.Run("Empty", func(t *testing.T) {
+		data := writeAll(t, models.FormatParquet, writerFields, nil)
+		reader, err := NewParquetReader(strings.NewReader(string(data)), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "score", "active", "joined", "tags"}, reader.Columns())
+		rows, _ := readAll(t, reader)
+		assert.Empty(t, rows)
+	})
+}
+
+func TestArrowWriter(t *testing.T) {
+	// readStream reads the schema and record batches of a stream back with
+	// the Arrow IPC reader
+	readStream := func(data []byte) (*arrow.Schema, []arrow.Record) {
+		reader, err := ipc.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		defer reader.Release()
+
+		var records []arrow.Record
+		for reader.Next() {
+			record := reader.Record()
+			record.Retain()
+			records = append(records, record)
+		}
+		require.NoError(t, reader.Err())
+		return reader.Schema(), records
+	}
+
+	// Test Case 1: The schema lists a nullable column per field
+	t.Run("Schema", func(t *testing.T) {
+		schema, _ := readStream(writeAll(t, models.FormatArrow, writerFields, writerRows))
+		types := []arrow.DataType{
+			arrow.PrimitiveTypes.Int64,
+			arrow.BinaryTypes.String,
+			arrow.PrimitiveTypes.Float64,
+			arrow.FixedWidthTypes.Boolean,
+			&arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"},
+			arrow.BinaryTypes.String,
+		}
+		require.Len(t, schema.Fields(), len(writerFields))
+		for i, field := range schema.Fields() {
+			assert.Equal(t, writerFields[i].Name, field.Name)
+			assert.True(t, field.Nullable, field.Name)
+			assert.True(t, arrow.TypeEqual(types[i], field.Type), field.Name)
+		}
+		tags := schema.Field(5).Metadata
+		require.NotEqual(t, -1, tags.FindKey("ARROW:extension:name"))
+		assert.Equal(t, "arrow.json", tags.Values()[tags.FindKey("ARROW:extension:name")])
+	})
+
+	// Test Case 2: The record batch holds the rows column by column
+	t.Run("Rows", func(t *testing.T) {
+		_, records := readStream(writeAll(t, models.FormatArrow, writerFields, writerRows))
+		require.Len(t, records, 1)
+		record := records[0]
+		assert.Equal(t, int64(3), record.NumRows())
+
+		assert.Equal(t, []int64{1, 2, 3}, record.Column(0).(*array.Int64).Int64Values())
+		names := record.Column(1).(*array.String)
+		assert.Equal(t, []string{"alice", "bob, jr", "<carol>"}, []string{names.Value(0), names.Value(1), names.Value(2)})
+		scores := record.Column(2).(*array.Float64)
+		assert.True(t, scores.IsNull(1))
+		assert.Equal(t, 4.0, scores.Value(2))
+		active := record.Column(3).(*array.Boolean)
+		assert.True(t, active.Value(0))
+		assert.False(t, active.Value(1))
+		assert.True(t, active.IsNull(2))
+		joined := record.Column(4).(*array.Timestamp)
+		assert.Equal(t, arrow.Timestamp(1709294400500000), joined.Value(0))
+		assert.True(t, joined.IsNull(1))
+		assert.Equal(t, arrow.Timestamp(1709334000000000), joined.Value(2))
+		tags := record.Column(5).(*array.String)
+		assert.Equal(t, `["a",1]`, tags.Value(0))
+		assert.Equal(t, 2, tags.NullN())
+	})
+
+	// Test Case 3: Large results span record batches
+	t.Run("Batches", func(t *testing.T) {
+		fields := []models.DataField{{Name: "n", Type: models.DataTypeInteger}}
+		rows := make([]map[string]interface{}, arrowWriterBatchRows+1)
+		for i := range rows {
+			rows[i] = map[string]interface{}{"n": int64(i)}
+		}
+		_, records := readStream(writeAll(t, models.FormatArrow, fields, rows))
+		require.Len(t, records, 2)
+		assert.Equal(t, int64(arrowWriterBatchRows), records[0].NumRows())
+		assert.Equal(t, int64(arrowWriterBatchRows), records[1].Column(0).(*array.Int64).Value(0))
+	})
+
+	// Test Case 4: An empty result is a stream of the schema alone
+	t.Run("Empty", func(t *testing.T) {
+		schema, records := readStream(writeAll(t, models.FormatArrow, writerFields, nil))
+		assert.Len(t, schema.Fields(), len(writerFields))
+		assert.Empty(t, records)
+	})
+}
@@ -0,0 +1,113 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// NDJSONReader reads rows from a file holding one JSON object per line. Blank
+// lines are skipped but still counted, so row positions are line numbers.
+// Numbers read as json.Number to keep their precision.
+type NDJSONReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+// NewNDJSONReader returns a reader of the lines of r
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Columns returns nil as every line names its own fields
+func (r *NDJSONReader) Columns() []string {
+	return nil
+}
+
+// Read decodes the next non-blank line
+func (r *NDJSONReader) Read() (int, map[string]interface{}, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		r.line++
+
+		line = bytes.TrimSpace(line)
+		if r.line == 1 {
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		var row map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		err = decoder.Decode(&row)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) || (err == nil && row == nil) {
+			return r.line, nil, &models.RowError{Row: r.line, Message: "line is not a JSON object"}
+		}
+		if err != nil {
+			return r.line, nil, &models.RowError{Row: r.line, Message: "invalid JSON: " + err.Error()}
+		}
+		if decoder.More() {
+			return r.line, nil, &models.RowError{Row: r.line, Message: "line holds more than one JSON value"}
+		}
+		return r.line, row, nil
+	}
+}
+
+// NDJSONWriter writes rows as one JSON object per line, with the fields in
+// column order
+type NDJSONWriter struct {
+	writer *bufio.Writer
+	fields []models.DataField
+	buf    bytes.Buffer
+	enc    *json.Encoder
+}
+
+// NewNDJSONWriter creates a writer of rows with the given fields
+func NewNDJSONWriter(w io.Writer, fields []models.DataField) *NDJSONWriter {
+	writer := &NDJSONWriter{writer: bufio.NewWriterSize(w, 64*1024), fields: fields}
+	writer.enc = json.NewEncoder(&writer.buf)
+	writer.enc.SetEscapeHTML(false)
+	return writer
+}
+
+// Write adds a line
+func (w *NDJSONWriter) Write(row map[string]interface{}) error {
+	w.buf.Reset()
+	w.buf.WriteByte('{')
+	for i, field := range w.fields {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		// The encoder ends every value with a newline, which is dropped
+		if err := w.enc.Encode(field.Name); err != nil {
+			return err
+		}
+		w.buf.Truncate(w.buf.Len() - 1)
+		w.buf.WriteByte(':')
+		if err := w.enc.Encode(row[field.Name]); err != nil {
+			return err
+		}
+		w.buf.Truncate(w.buf.Len() - 1)
+	}
+	w.buf.WriteString("}\n")
+	_, err := w.writer.Write(w.buf.Bytes())
+	return err
+}
+
+// Close writes the buffered lines
+func (w *NDJSONWriter) Close() error {
+	return w.writer.Flush()
+}
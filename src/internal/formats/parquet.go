@@ -0,0 +1,244 @@
+package formats
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet"
+	"github.com/apache/arrow/go/v11/parquet/file"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+	"github.com/apache/arrow/go/v11/parquet/schema"
+	"github.com/google/uuid"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// ParquetReader decodes a record batch of rows at a time, reading column
+// chunks through buffers of a bounded size
+const (
+	parquetReaderBatchRows   = 64 * 1024
+	parquetReaderBufferBytes = 1 << 20
+)
+
+// ParquetReader reads rows from a Parquet file whose schema is flat: every
+// column is a required or optional primitive. It decodes the file with the
+// Arrow Parquet reader, which supports the encodings and compression codecs
+// of the format, a record batch at a time.
+type ParquetReader struct {
+	columns []string
+	uuids   []bool
+	records pqarrow.RecordReader
+	record  arrow.Record
+	index   int
+	row     int
+}
+
+// NewParquetReader reads the metadata of a Parquet file of the given size
+func NewParquetReader(r io.ReaderAt, size int64) (reader *ParquetReader, err error) {
+	defer recoverParquet(&err)
+
+	props := parquet.NewReaderProperties(memory.DefaultAllocator)
+	props.BufferedStreamEnabled = true
+	props.BufferSize = parquetReaderBufferBytes
+	parquetFile, err := file.NewParquetReader(io.NewSectionReader(r, 0, size), file.WithReadProps(props))
+	if err != nil {
+		return nil, invalidParquet("%v", err)
+	}
+
+	columns, uuids, err := parquetSchema(parquetFile.MetaData().Schema)
+	if err != nil {
+		return nil, err
+	}
+	reader = &ParquetReader{columns: columns, uuids: uuids}
+	if len(columns) == 0 {
+		return reader, nil
+	}
+
+	fileReader, err := pqarrow.NewFileReader(parquetFile, pqarrow.ArrowReadProperties{BatchSize: parquetReaderBatchRows}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, invalidParquet("%v", err)
+	}
+	if reader.records, err = fileReader.GetRecordReader(context.Background(), nil, nil); err != nil {
+		return nil, invalidParquet("%v", err)
+	}
+	return reader, nil
+}
+
+// parquetSchema lists the columns of a flat schema and which of them hold
+// UUIDs
+func parquetSchema(fileSchema *schema.Schema) ([]string, []bool, error) {
+	root := fileSchema.Root()
+	columns := make([]string, root.NumFields())
+	uuids := make([]bool, root.NumFields())
+	seen := make(map[string]bool, root.NumFields())
+	for i := range columns {
+		node := root.Field(i)
+		name := node.Name()
+		if node.Type() == schema.Group {
+			return nil, nil, models.NewValidationError("Parquet column %s is nested, which is not supported", name)
+		}
+		if node.RepetitionType() == parquet.Repetitions.Repeated {
+			return nil, nil, models.NewValidationError("Parquet column %s is repeated, which is not supported", name)
+		}
+		if name == "" || seen[name] {
+			return nil, nil, invalidParquet("column %d has a missing or repeated name", i+1)
+		}
+		seen[name] = true
+
+		columns[i] = name
+		_, uuids[i] = node.LogicalType().(schema.UUIDLogicalType)
+	}
+	return columns, uuids, nil
+}
+
+// Columns returns the names of the columns
+func (p *ParquetReader) Columns() []string {
+	return p.columns
+}
+
+// Read returns the next row, decoding the next record batch when the current
+// one is exhausted
+func (p *ParquetReader) Read() (n int, row map[string]interface{}, err error) {
+	defer recoverParquet(&err)
+
+	for p.record == nil || p.index >= int(p.record.NumRows()) {
+		if p.records == nil {
+			return 0, nil, io.EOF
+		}
+		record, err := p.records.Read()
+		if err != nil || record == nil {
+			p.records.Release()
+			p.records, p.record = nil, nil
+			if err == nil || err == io.EOF {
+				return 0, nil, io.EOF
+			}
+			return 0, nil, invalidParquet("%v", err)
+		}
+		p.record, p.index = record, 0
+	}
+
+	row = make(map[string]interface{}, len(p.columns))
+	for i, name := range p.columns {
+		column := p.record.Column(i)
+		value, ok := arrowValue(column, p.index, p.uuids[i])
+		if !ok {
+			return 0, nil, models.NewValidationError("Parquet column %s has type %s, which is not supported", name, column.DataType().Name())
+		}
+		row[name] = value
+	}
+	p.index++
+	p.row++
+	return p.row, row, nil
+}
+
+// arrowValue converts the value of a decoded column at index i to what rows
+// hold: int64, float64, bool, string, times for dates and timestamps, floats
+// for decimals and nil for nulls. It reports false for types rows cannot hold.
+func arrowValue(column arrow.Array, i int, isUUID bool) (interface{}, bool) {
+	if column.IsNull(i) {
+		return nil, true
+	}
+	switch c := column.(type) {
+	case *array.Boolean:
+		return c.Value(i), true
+	case *array.Int8:
+		return int64(c.Value(i)), true
+	case *array.Int16:
+		return int64(c.Value(i)), true
+	case *array.Int32:
+		return int64(c.Value(i)), true
+	case *array.Int64:
+		return c.Value(i), true
+	case *array.Uint8:
+		return int64(c.Value(i)), true
+	case *array.Uint16:
+		return int64(c.Value(i)), true
+	case *array.Uint32:
+		return int64(c.Value(i)), true
+	case *array.Uint64:
+		if v := c.Value(i); v <= math.MaxInt64 {
+			return int64(v), true
+		}
+		return float64(c.Value(i)), true
+	case *array.Time32:
+		return int64(c.Value(i)), true
+	case *array.Time64:
+		return int64(c.Value(i)), true
+	case *array.Float32:
+		// Format with float32 precision so 0.1 does not widen to 0.100000001
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(c.Value(i)), 'g', -1, 32), 64)
+		return f, true
+	case *array.Float64:
+		return c.Value(i), true
+	case *array.Decimal128:
+		return decimalValue(c.Value(i).BigInt(), c.DataType().(*arrow.Decimal128Type).Scale), true
+	case *array.Date32:
+		return time.Unix(int64(c.Value(i))*86400, 0).UTC(), true
+	case *array.Date64:
+		return time.Unix(0, int64(c.Value(i))*int64(time.Millisecond)).UTC(), true
+	case *array.Timestamp:
+		return timestampValue(int64(c.Value(i)), c.DataType().(*arrow.TimestampType).Unit), true
+	case *array.String:
+		return c.Value(i), true
+	case *array.LargeString:
+		return c.Value(i), true
+	case *array.Binary:
+		return string(c.Value(i)), true
+	case *array.FixedSizeBinary:
+		b := c.Value(i)
+		if isUUID && len(b) == 16 {
+			id, _ := uuid.FromBytes(b)
+			return id.String(), true
+		}
+		return string(b), true
+	case *array.Dictionary:
+		return arrowValue(c.Dictionary(), c.GetValueIndex(i), isUUID)
+	}
+	return nil, false
+}
+
+// decimalValue scales an unscaled decimal integer
+func decimalValue(unscaled *big.Int, scale int32) float64 {
+	f, _ := new(big.Float).Quo(
+		new(big.Float).SetInt(unscaled),
+		new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)),
+	).Float64()
+	return f
+}
+
+// timestampValue converts a count of units since the Unix epoch
+func timestampValue(n int64, unit arrow.TimeUnit) time.Time {
+	var per time.Duration
+	switch unit {
+	case arrow.Second:
+		per = time.Second
+	case arrow.Millisecond:
+		per = time.Millisecond
+	case arrow.Microsecond:
+		per = time.Microsecond
+	default:
+		per = time.Nanosecond
+	}
+	perSecond := int64(time.Second / per)
+	return time.Unix(n/perSecond, n%perSecond*int64(per)).UTC()
+}
+
+// recoverParquet reports a panic of the Parquet decoder, which it raises on
+// some malformed files, as an invalid file
+func recoverParquet(err *error) {
+	if r := recover(); r != nil {
+		*err = invalidParquet("%v", r)
+	}
+}
+
+// invalidParquet reports a malformed Parquet file
+func invalidParquet(format string, args ...interface{}) error {
+	return models.NewValidationError("invalid Parquet file: "+format, args...)
+}
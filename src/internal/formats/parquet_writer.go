@@ -0,0 +1,95 @@
+package formats
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet"
+	"github.com/apache/arrow/go/v11/parquet/compress"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// ParquetWriter buffers a row group in memory before writing it
+const (
+	parquetWriterRowGroup    = 64 * 1024
+	parquetWriterBufferBytes = 256 * 1024
+	parquetWriterCreatedBy   = "go-data-api-microservices"
+)
+
+// ParquetWriter writes rows to a Parquet file with one optional column per
+// field. Integers are written as INT64, floats as DOUBLE, booleans as
+// BOOLEAN, datetimes as INT64 UTC microsecond timestamps, and strings, arrays
+// and objects as UTF-8 byte arrays, arrays and objects tagged with the
+// arrow.json extension type in the stored Arrow schema. Columns are Snappy
+// compressed; rows are buffered until a row group is full.
+type ParquetWriter struct {
+	buffer  *bufio.Writer
+	writer  *pqarrow.FileWriter
+	fields  []models.DataField
+	builder *array.RecordBuilder
+	rows    int
+}
+
+// NewParquetWriter creates a writer of a Parquet file
+func NewParquetWriter(w io.Writer, fields []models.DataField) (*ParquetWriter, error) {
+	return newParquetWriter(w, fields, parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithCreatedBy(parquetWriterCreatedBy),
+	))
+}
+
+// newParquetWriter creates a writer of a Parquet file with the given column
+// encodings and codecs
+func newParquetWriter(w io.Writer, fields []models.DataField, props *parquet.WriterProperties) (*ParquetWriter, error) {
+	schema := arrowSchema(fields)
+
+	// The file writer closes its sink when it implements io.Closer, which the
+	// buffer does not
+	buffer := bufio.NewWriterSize(w, parquetWriterBufferBytes)
+	writer, err := pqarrow.NewFileWriter(schema, buffer, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		return nil, err
+	}
+	return &ParquetWriter{
+		buffer:  buffer,
+		writer:  writer,
+		fields:  fields,
+		builder: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}, nil
+}
+
+// Write buffers a row, writing the row group once it is full
+func (w *ParquetWriter) Write(row map[string]interface{}) error {
+	appendRow(w.builder, w.fields, row)
+	w.rows++
+	if w.rows >= parquetWriterRowGroup {
+		return w.writeRowGroup()
+	}
+	return nil
+}
+
+// Close writes the last row group and the file metadata
+func (w *ParquetWriter) Close() error {
+	defer w.builder.Release()
+	if w.rows > 0 {
+		if err := w.writeRowGroup(); err != nil {
+			return err
+		}
+	}
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
+	return w.buffer.Flush()
+}
+
+// writeRowGroup writes the buffered rows as a row group
+func (w *ParquetWriter) writeRowGroup() error {
+	record := w.builder.NewRecord()
+	defer record.Release()
+	w.rows = 0
+	return w.writer.Write(record)
+}
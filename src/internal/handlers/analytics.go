@@ -7,6 +7,7 @@ import (
 	"github.com/galafis/go-data-api-microservices/internal/models"
 	"github.com/galafis/go-data-api-microservices/pkg/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // AnalyticsHandler handles analytics operations
@@ -20,8 +21,15 @@ type AnalyticsService interface {
 	GetDataSummary(datasetID string) (*models.DataSummary, error)
 	ComputeStatistics(req *models.StatisticsRequest) (*models.StatisticsResult, error)
 	ComputeCorrelation(req *models.CorrelationRequest) (*models.CorrelationResult, error)
+	ComputeCovariance(req *models.CovarianceRequest) (*models.CovarianceResult, error)
+	ComputePartialCorrelation(req *models.PartialCorrelationRequest) (*models.CorrelationResult, error)
 	AnalyzeTimeSeries(req *models.TimeSeriesRequest) (*models.TimeSeriesResult, error)
+	DecomposeTimeSeries(req *models.DecompositionRequest) (*models.DecompositionResult, error)
+	AnalyzeTrend(req *models.TrendRequest) (*models.TrendResult, error)
 	GenerateForecast(req *models.ForecastRequest) (*models.ForecastResult, error)
+	RunHypothesisTest(req *models.HypothesisTestRequest) (*models.HypothesisTestResult, error)
+	RenderChart(req *models.ChartRequest) (*models.Image, error)
+	RenderHeatmap(req *models.HeatmapRequest) (*models.Image, error)
 }
 
 // NewAnalyticsHandler creates a new analytics handler
@@ -57,6 +65,14 @@ func (h *AnalyticsHandler) GetDataSummary(c *gin.Context) {
 	// Get data summary
 	summary, err := h.analyticsService.GetDataSummary(datasetID)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error getting data summary: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting data summary"})
 		return
@@ -107,6 +123,14 @@ func (h *AnalyticsHandler) ComputeStatistics(c *gin.Context) {
 	start := time.Now()
 	result, err := h.analyticsService.ComputeStatistics(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error computing statistics: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing statistics"})
 		return
@@ -158,6 +182,14 @@ func (h *AnalyticsHandler) ComputeCorrelation(c *gin.Context) {
 	start := time.Now()
 	result, err := h.analyticsService.ComputeCorrelation(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error computing correlation: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing correlation"})
 		return
@@ -171,6 +203,124 @@ func (h *AnalyticsHandler) ComputeCorrelation(c *gin.Context) {
 	})
 }
 
+// ComputeCovariance handles computing covariance
+// @Summary Compute covariance
+// @Description Compute the covariance matrix of fields in a dataset
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CovarianceRequest true "Covariance request"
+// @Success 200 {object} models.CovarianceResult "Covariance computed successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/covariance [post]
+func (h *AnalyticsHandler) ComputeCovariance(c *gin.Context) {
+	// Parse request
+	var req models.CovarianceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Compute covariance
+	start := time.Now()
+	result, err := h.analyticsService.ComputeCovariance(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error computing covariance: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing covariance"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
+// ComputePartialCorrelation handles computing partial correlation
+// @Summary Compute partial correlation
+// @Description Compute the correlation between fields in a dataset controlling for other fields
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.PartialCorrelationRequest true "Partial correlation request"
+// @Success 200 {object} models.CorrelationResult "Partial correlation computed successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/partial-correlation [post]
+func (h *AnalyticsHandler) ComputePartialCorrelation(c *gin.Context) {
+	// Parse request
+	var req models.PartialCorrelationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Compute partial correlation
+	start := time.Now()
+	result, err := h.analyticsService.ComputePartialCorrelation(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error computing partial correlation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing partial correlation"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
 // AnalyzeTimeSeries handles analyzing time series
 // @Summary Analyze time series
 // @Description Analyze time series data in a dataset
@@ -209,6 +359,14 @@ func (h *AnalyticsHandler) AnalyzeTimeSeries(c *gin.Context) {
 	start := time.Now()
 	result, err := h.analyticsService.AnalyzeTimeSeries(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error analyzing time series: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error analyzing time series"})
 		return
@@ -222,6 +380,124 @@ func (h *AnalyticsHandler) AnalyzeTimeSeries(c *gin.Context) {
 	})
 }
 
+// DecomposeTimeSeries handles seasonal decomposition
+// @Summary Decompose time series
+// @Description Split a time series from a dataset into trend, seasonal and residual components
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DecompositionRequest true "Decomposition request"
+// @Success 200 {object} models.DecompositionResult "Time series decomposed successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/timeseries/decompose [post]
+func (h *AnalyticsHandler) DecomposeTimeSeries(c *gin.Context) {
+	// Parse request
+	var req models.DecompositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Decompose time series
+	start := time.Now()
+	result, err := h.analyticsService.DecomposeTimeSeries(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error decomposing time series: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decomposing time series"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
+// AnalyzeTrend handles trend analysis
+// @Summary Analyze trend
+// @Description Test a time series from a dataset for a monotonic trend and detect change points
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TrendRequest true "Trend request"
+// @Success 200 {object} models.TrendResult "Trend analyzed successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/timeseries/trend [post]
+func (h *AnalyticsHandler) AnalyzeTrend(c *gin.Context) {
+	// Parse request
+	var req models.TrendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Analyze trend
+	start := time.Now()
+	result, err := h.analyticsService.AnalyzeTrend(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error analyzing trend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error analyzing trend"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
 // GenerateForecast handles generating forecasts
 // @Summary Generate forecast
 // @Description Generate forecast from time series data in a dataset
@@ -260,6 +536,14 @@ func (h *AnalyticsHandler) GenerateForecast(c *gin.Context) {
 	start := time.Now()
 	result, err := h.analyticsService.GenerateForecast(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error generating forecast: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating forecast"})
 		return
@@ -273,28 +557,183 @@ func (h *AnalyticsHandler) GenerateForecast(c *gin.Context) {
 	})
 }
 
-// GetDataSummary is a placeholder handler for getting a data summary
-func GetDataSummary(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Get data summary endpoint"})
-}
+// RunHypothesisTest handles hypothesis testing
+// @Summary Run hypothesis test
+// @Description Run a t, chi-square, Mann-Whitney, ANOVA or Kolmogorov-Smirnov test on fields of a dataset
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.HypothesisTestRequest true "Hypothesis test request"
+// @Success 200 {object} models.HypothesisTestResult "Hypothesis test run successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/hypothesis-test [post]
+func (h *AnalyticsHandler) RunHypothesisTest(c *gin.Context) {
+	// Parse request
+	var req models.HypothesisTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-// ComputeStatistics is a placeholder handler for computing statistics
-func ComputeStatistics(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Compute statistics endpoint"})
-}
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Run hypothesis test
+	start := time.Now()
+	result, err := h.analyticsService.RunHypothesisTest(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error running hypothesis test: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error running hypothesis test"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
 
-// ComputeCorrelation is a placeholder handler for computing correlation
-func ComputeCorrelation(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Compute correlation endpoint"})
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
 }
 
-// AnalyzeTimeSeries is a placeholder handler for analyzing time series
-func AnalyzeTimeSeries(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Analyze time series endpoint"})
+// RenderChart handles rendering a chart
+// @Summary Render a chart
+// @Description Render a time series as a line chart, or histogram or box plot statistics, as an SVG or PNG image. Without a format in the request, the Accept header picks it.
+// @Tags analytics
+// @Accept json
+// @Produce image/svg+xml,image/png
+// @Security BearerAuth
+// @Param request body models.ChartRequest true "Chart request"
+// @Success 200 {file} file "Chart rendered successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/visualize/chart [post]
+func (h *AnalyticsHandler) RenderChart(c *gin.Context) {
+	// Parse request
+	var req models.ChartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	negotiateImageFormat(c, &req.ImageOptions)
+
+	// Check if dataset exists
+	var datasetID uuid.UUID
+	switch {
+	case req.TimeSeries != nil:
+		datasetID = req.TimeSeries.DatasetID
+	case req.Statistics != nil:
+		datasetID = req.Statistics.DatasetID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either time_series or statistics is required"})
+		return
+	}
+	if _, ok := findDataset(c, h.datasetRepository, datasetID); !ok {
+		return
+	}
+
+	// Render chart
+	image, err := h.analyticsService.RenderChart(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error rendering chart: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering chart"})
+		return
+	}
+
+	c.Data(http.StatusOK, image.ContentType, image.Data)
 }
 
-// GenerateForecast is a placeholder handler for generating forecasts
-func GenerateForecast(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Generate forecast endpoint"})
+// RenderHeatmap handles rendering a heatmap
+// @Summary Render a heatmap
+// @Description Render a correlation or partial correlation matrix as an SVG or PNG heatmap. Without a format in the request, the Accept header picks it.
+// @Tags analytics
+// @Accept json
+// @Produce image/svg+xml,image/png
+// @Security BearerAuth
+// @Param request body models.HeatmapRequest true "Heatmap request"
+// @Success 200 {file} file "Heatmap rendered successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/visualize/heatmap [post]
+func (h *AnalyticsHandler) RenderHeatmap(c *gin.Context) {
+	// Parse request
+	var req models.HeatmapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	negotiateImageFormat(c, &req.ImageOptions)
+
+	// Check if dataset exists
+	var datasetID uuid.UUID
+	switch {
+	case req.Correlation != nil:
+		datasetID = req.Correlation.DatasetID
+	case req.PartialCorrelation != nil:
+		datasetID = req.PartialCorrelation.DatasetID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either correlation or partial_correlation is required"})
+		return
+	}
+	if _, ok := findDataset(c, h.datasetRepository, datasetID); !ok {
+		return
+	}
+
+	// Render heatmap
+	image, err := h.analyticsService.RenderHeatmap(&req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error rendering heatmap: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering heatmap"})
+		return
+	}
+
+	c.Data(http.StatusOK, image.ContentType, image.Data)
 }
 
+// negotiateImageFormat picks the image format from the Accept header when the
+// request does not name one
+func negotiateImageFormat(c *gin.Context, opts *models.ImageOptions) {
+	if opts.Format == "" && c.NegotiateFormat("image/svg+xml", "image/png") == "image/png" {
+		opts.Format = models.ImagePNG
+	}
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AnomalyHandler handles anomaly detection and anomaly rules
+type AnomalyHandler struct {
+	datasetRepository DatasetRepository
+	ruleRepository    AnomalyRuleRepository
+	anomalyService    AnomalyService
+}
+
+// AnomalyRuleRepository defines the interface for anomaly rule operations
+type AnomalyRuleRepository interface {
+	FindByID(id uuid.UUID) (*models.AnomalyRule, error)
+	FindAll(page, pageSize int, filters map[string]interface{}) ([]models.AnomalyRule, int64, error)
+	Create(rule *models.AnomalyRule) error
+	Update(rule *models.AnomalyRule) error
+	Delete(id uuid.UUID) error
+}
+
+// AnomalyService defines the interface for anomaly detection operations
+type AnomalyService interface {
+	DetectAnomalies(datasetID uuid.UUID, detection *models.AnomalyDetection) (*models.AnomalyResult, error)
+	ValidateAnomalyDetection(datasetID uuid.UUID, detection *models.AnomalyDetection) error
+}
+
+// NewAnomalyHandler creates a new anomaly handler
+func NewAnomalyHandler(datasetRepository DatasetRepository, ruleRepository AnomalyRuleRepository, anomalyService AnomalyService) *AnomalyHandler {
+	return &AnomalyHandler{
+		datasetRepository: datasetRepository,
+		ruleRepository:    ruleRepository,
+		anomalyService:    anomalyService,
+	}
+}
+
+// DetectAnomalies handles anomaly detection
+// @Summary Detect anomalies
+// @Description Score the values of a dataset field or time series and list the anomalies
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AnomalyRequest true "Anomaly detection request"
+// @Success 200 {object} models.AnomalyResult "Anomalies detected successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/detect [post]
+func (h *AnomalyHandler) DetectAnomalies(c *gin.Context) {
+	// Parse request
+	var req models.AnomalyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.detect(c, req.DatasetID, &req.AnomalyDetection, nil)
+}
+
+// ListRules handles listing anomaly rules
+// @Summary List anomaly rules
+// @Description List anomaly rules with pagination
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param page_size query int false "Page size (default: 10)"
+// @Param dataset_id query string false "Filter by dataset ID"
+// @Success 200 {object} models.AnomalyRuleListResponse "Anomaly rules retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid dataset ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules [get]
+func (h *AnomalyHandler) ListRules(c *gin.Context) {
+	// Parse pagination parameters
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	// Parse filters
+	filters := make(map[string]interface{})
+	if datasetID := c.Query("dataset_id"); datasetID != "" {
+		id, err := uuid.Parse(datasetID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+			return
+		}
+		filters["dataset_id"] = id
+	}
+
+	// Get rules
+	rules, total, err := h.ruleRepository.FindAll(page, pageSize, filters)
+	if err != nil {
+		logger.Errorf("Error finding anomaly rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AnomalyRuleListResponse{
+		Rules:    rules,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetRule handles getting an anomaly rule by ID
+// @Summary Get an anomaly rule
+// @Description Get an anomaly rule by ID
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 200 {object} models.AnomalyRule "Anomaly rule retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid rule ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Anomaly rule not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules/{id} [get]
+func (h *AnomalyHandler) GetRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateRule handles creating an anomaly rule
+// @Summary Create an anomaly rule
+// @Description Store an anomaly detection so that it can be run again
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateAnomalyRuleRequest true "Anomaly rule creation request"
+// @Success 201 {object} models.AnomalyRule "Anomaly rule created successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules [post]
+func (h *AnomalyHandler) CreateRule(c *gin.Context) {
+	// Parse request
+	var req models.CreateAnomalyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Validate the detection against the dataset
+	if !h.validate(c, req.DatasetID, &req.Detection) {
+		return
+	}
+
+	// Create rule
+	now := time.Now()
+	rule := &models.AnomalyRule{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Description: req.Description,
+		DatasetID:   req.DatasetID,
+		Detection:   req.Detection,
+		CreatedBy:   userID.(uuid.UUID),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.ruleRepository.Create(rule); err != nil {
+		logger.Errorf("Error creating anomaly rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule handles updating an anomaly rule
+// @Summary Update an anomaly rule
+// @Description Update the name, description or detection of an anomaly rule
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param request body models.UpdateAnomalyRuleRequest true "Anomaly rule update request"
+// @Success 200 {object} models.AnomalyRule "Anomaly rule updated successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Anomaly rule not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules/{id} [put]
+func (h *AnomalyHandler) UpdateRule(c *gin.Context) {
+	// Parse request
+	var req models.UpdateAnomalyRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Get rule
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	// Check if user is the owner
+	if rule.CreatedBy != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to update this anomaly rule"})
+		return
+	}
+
+	// Update rule
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Description != "" {
+		rule.Description = req.Description
+	}
+	if req.Detection != nil {
+		if !h.validate(c, rule.DatasetID, req.Detection) {
+			return
+		}
+		rule.Detection = *req.Detection
+	}
+	rule.UpdatedAt = time.Now()
+
+	if err := h.ruleRepository.Update(rule); err != nil {
+		logger.Errorf("Error updating anomaly rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule handles deleting an anomaly rule
+// @Summary Delete an anomaly rule
+// @Description Delete an anomaly rule by ID
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 204 "Anomaly rule deleted successfully"
+// @Failure 400 {object} ErrorResponse "Invalid rule ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Anomaly rule not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules/{id} [delete]
+func (h *AnomalyHandler) DeleteRule(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Get rule
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	// Check if user is the owner
+	if rule.CreatedBy != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to delete this anomaly rule"})
+		return
+	}
+
+	// Delete rule
+	if err := h.ruleRepository.Delete(rule.ID); err != nil {
+		logger.Errorf("Error deleting anomaly rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunRule handles running an anomaly rule
+// @Summary Run an anomaly rule
+// @Description Run the detection of an anomaly rule against its dataset, or another dataset with the same fields
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Param dataset_id query string false "Dataset to run the rule against (default: the rule's dataset)"
+// @Success 200 {object} models.AnomalyResult "Anomaly rule run successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Anomaly rule or dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/anomalies/rules/{id}/run [post]
+func (h *AnomalyHandler) RunRule(c *gin.Context) {
+	// Get rule
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	// Pick the dataset to run against
+	datasetID := rule.DatasetID
+	if value := c.Query("dataset_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+			return
+		}
+		datasetID = id
+	}
+
+	h.detect(c, datasetID, &rule.Detection, &rule.ID)
+}
+
+// detect runs a detection on a dataset and writes its result
+func (h *AnomalyHandler) detect(c *gin.Context, datasetID uuid.UUID, detection *models.AnomalyDetection, ruleID *uuid.UUID) {
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, datasetID); !ok {
+		return
+	}
+
+	// Detect anomalies
+	start := time.Now()
+	result, err := h.anomalyService.DetectAnomalies(datasetID, detection)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error detecting anomalies: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error detecting anomalies"})
+		return
+	}
+	result.RuleID = ruleID
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
+// validate checks a detection against a dataset, writing the error response
+// when it cannot run there
+func (h *AnomalyHandler) validate(c *gin.Context, datasetID uuid.UUID, detection *models.AnomalyDetection) bool {
+	if _, ok := findDataset(c, h.datasetRepository, datasetID); !ok {
+		return false
+	}
+	if err := h.anomalyService.ValidateAnomalyDetection(datasetID, detection); err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return false
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		logger.Errorf("Error validating anomaly detection: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+	return true
+}
+
+// findRule loads the rule named by the id path parameter, writing the error
+// response when there is none
+func (h *AnomalyHandler) findRule(c *gin.Context) (*models.AnomalyRule, bool) {
+	// Parse rule ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return nil, false
+	}
+
+	rule, err := h.ruleRepository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding anomaly rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anomaly rule not found"})
+		return nil, false
+	}
+	return rule, true
+}
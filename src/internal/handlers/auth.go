@@ -370,24 +370,3 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
-
-// Register is a placeholder handler for user registration
-func Register(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Register endpoint"})
-}
-
-// Login is a placeholder handler for user login
-func Login(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Login endpoint"})
-}
-
-// RefreshToken is a placeholder handler for token refresh
-func RefreshToken(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Refresh token endpoint"})
-}
-
-// Logout is a placeholder handler for user logout
-func Logout(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Logout endpoint"})
-}
-
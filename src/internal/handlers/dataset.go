@@ -14,6 +14,7 @@ import (
 // DatasetHandler handles dataset operations
 type DatasetHandler struct {
 	datasetRepository DatasetRepository
+	rowStore          RowStore
 }
 
 // DatasetRepository defines the interface for dataset operations
@@ -25,11 +26,39 @@ type DatasetRepository interface {
 	Delete(id uuid.UUID) error
 }
 
+// RowStore defines the interface for dataset row storage operations
+type RowStore interface {
+	SyncIndexes(dataset *models.Dataset) error
+	Insert(dataset *models.Dataset, rows []map[string]interface{}) error
+	RecordVersion(dataset *models.Dataset, change models.VersionChange) error
+	UpdateMetadata(dataset *models.Dataset) error
+	CheckSchema(dataset *models.Dataset, schema *models.DataSchema, plan *models.SchemaMigration) (*models.SchemaCompatibility, error)
+	EvolveSchema(dataset *models.Dataset, schema models.DataSchema, plan *models.SchemaMigration) (*models.SchemaCompatibility, error)
+	Drop(datasetID uuid.UUID) error
+}
+
 // NewDatasetHandler creates a new dataset handler
-func NewDatasetHandler(datasetRepository DatasetRepository) *DatasetHandler {
+func NewDatasetHandler(datasetRepository DatasetRepository, rowStore RowStore) *DatasetHandler {
 	return &DatasetHandler{
 		datasetRepository: datasetRepository,
+		rowStore:          rowStore,
+	}
+}
+
+// findDataset loads a dataset, writing the error response when it cannot be
+// found
+func findDataset(c *gin.Context, repository DatasetRepository, id uuid.UUID) (*models.Dataset, bool) {
+	dataset, err := repository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return nil, false
 	}
+	return dataset, true
 }
 
 // ListDatasets handles listing datasets
@@ -162,7 +191,7 @@ func (h *DatasetHandler) GetDataset(c *gin.Context) {
 
 // CreateDataset handles creating a new dataset
 // @Summary Create a dataset
-// @Description Create a new dataset
+// @Description Create a new dataset. A schema for an existing file can be proposed by /data/schema/infer.
 // @Tags data
 // @Accept json
 // @Produce json
@@ -204,8 +233,35 @@ func (h *DatasetHandler) CreateDataset(c *gin.Context) {
 		UpdatedAt:   now,
 	}
 
+	// Prepare row storage, which also validates the schema
+	if err := h.rowStore.SyncIndexes(dataset); err != nil {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error preparing dataset storage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
 	if err := h.datasetRepository.Create(dataset); err != nil {
 		logger.Errorf("Error creating dataset: %v", err)
+		if err := h.rowStore.Drop(dataset.ID); err != nil {
+			logger.Errorf("Error cleaning up dataset storage: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Record the first version
+	if err := h.rowStore.RecordVersion(dataset, models.VersionCreate); err != nil {
+		logger.Errorf("Error recording dataset version: %v", err)
+		if err := h.datasetRepository.Delete(dataset.ID); err != nil {
+			logger.Errorf("Error removing incomplete dataset: %v", err)
+		}
+		if err := h.rowStore.Drop(dataset.ID); err != nil {
+			logger.Errorf("Error cleaning up dataset storage: %v", err)
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
@@ -232,7 +288,7 @@ func (h *DatasetHandler) CreateDataset(c *gin.Context) {
 
 // UpdateDataset handles updating a dataset
 // @Summary Update a dataset
-// @Description Update an existing dataset
+// @Description Update an existing dataset. A new schema is checked against the stored rows: breaking changes such as dropped fields or narrowed types are rejected unless the request carries a migration plan covering them, which is then applied to the rows.
 // @Tags data
 // @Accept json
 // @Produce json
@@ -294,9 +350,6 @@ func (h *DatasetHandler) UpdateDataset(c *gin.Context) {
 	if req.Description != "" {
 		dataset.Description = req.Description
 	}
-	if req.Schema != nil {
-		dataset.Schema = *req.Schema
-	}
 	if req.Source != "" {
 		dataset.Source = req.Source
 	}
@@ -311,10 +364,32 @@ func (h *DatasetHandler) UpdateDataset(c *gin.Context) {
 	}
 	dataset.UpdatedAt = time.Now()
 
-	if err := h.datasetRepository.Update(dataset); err != nil {
-		logger.Errorf("Error updating dataset: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+	if req.Schema != nil {
+		// Move the stored rows to the new schema, which saves the dataset
+		if _, err := h.rowStore.EvolveSchema(dataset, *req.Schema, req.Migration); err != nil {
+			if isNotFoundError(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if isValidationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Errorf("Error updating dataset schema: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	} else {
+		// Save the metadata with its version, keeping the stored schema
+		if err := h.rowStore.UpdateMetadata(dataset); err != nil {
+			if isNotFoundError(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Errorf("Error updating dataset: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
 	}
 
 	// Convert to response
@@ -337,6 +412,63 @@ func (h *DatasetHandler) UpdateDataset(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// CheckSchema handles checking a schema change against a dataset
+// @Summary Check a dataset schema change
+// @Description List the changes between the schema of a dataset and a new one, and whether the stored rows are compatible with them or with the given migration plan. Nothing is changed.
+// @Tags data
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param request body models.SchemaCheckRequest true "Schema check request"
+// @Success 200 {object} models.SchemaCompatibility "Schema change checked successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/schema/check [post]
+func (h *DatasetHandler) CheckSchema(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Parse request
+	var req models.SchemaCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get dataset
+	dataset, err := h.datasetRepository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Check schema
+	report, err := h.rowStore.CheckSchema(dataset, &req.Schema, req.Migration)
+	if err != nil {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error checking dataset schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // DeleteDataset handles deleting a dataset
 // @Summary Delete a dataset
 // @Description Delete a dataset by ID
@@ -393,31 +525,12 @@ func (h *DatasetHandler) DeleteDataset(c *gin.Context) {
 		return
 	}
 
-	c.Status(http.StatusNoContent)
-}
-
-// ListDatasets is a placeholder handler for listing datasets
-func ListDatasets(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "List datasets endpoint"})
-}
-
-// GetDataset is a placeholder handler for getting a dataset
-func GetDataset(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Get dataset endpoint"})
-}
-
-// CreateDataset is a placeholder handler for creating a dataset
-func CreateDataset(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Create dataset endpoint"})
-}
-
-// UpdateDataset is a placeholder handler for updating a dataset
-func UpdateDataset(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Update dataset endpoint"})
-}
+	// Delete dataset rows
+	if err := h.rowStore.Drop(id); err != nil {
+		logger.Errorf("Error deleting dataset rows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
 
-// DeleteDataset is a placeholder handler for deleting a dataset
-func DeleteDataset(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Delete dataset endpoint"})
+	c.Status(http.StatusNoContent)
 }
-
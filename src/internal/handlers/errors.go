@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// isValidationError reports whether err was caused by an invalid request rather than a server failure
+func isValidationError(err error) bool {
+	var validationErr *models.ValidationError
+	return errors.As(err, &validationErr)
+}
+
+// isNotFoundError reports whether err was caused by a record the request refers to not existing
+func isNotFoundError(err error) bool {
+	var notFoundErr *models.NotFoundError
+	return errors.As(err, &notFoundErr)
+}
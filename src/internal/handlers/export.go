@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/formats"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RowIterator iterates over rows streamed from storage
+type RowIterator interface {
+	// Fields lists the fields of the rows
+	Fields() []models.DataField
+
+	// Next returns the next row, or io.EOF after the last row
+	Next() (map[string]interface{}, error)
+
+	// Close releases the rows
+	Close() error
+}
+
+// ExportDataset handles exporting the rows of a dataset
+// @Summary Export dataset rows
+// @Description Stream every row of a dataset as a CSV, NDJSON, Parquet or Arrow IPC file. The format is taken from the format parameter, else from the Accept header, and defaults to CSV. Rows are written as they are read, so a failure after the first row truncates the file.
+// @Tags data
+// @Produce text/csv,application/x-ndjson,application/vnd.apache.parquet,application/vnd.apache.arrow.stream
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param format query string false "File format: csv, ndjson, parquet or arrow"
+// @Success 200 {file} file "Dataset rows"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/export [get]
+func (h *QueryHandler) ExportDataset(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Negotiate the file format
+	format := models.FileFormat(c.Query("format"))
+	if format == "" {
+		format = acceptedFormat(c)
+	}
+	if format == "" || format == models.FormatJSON {
+		format = models.FormatCSV
+	}
+	if !formats.Writable(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson, parquet or arrow"})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Open rows
+	rows, err := h.queryService.ExportDataset(id)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error exporting dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting dataset"})
+		return
+	}
+
+	streamRows(c, rows, format, dataset.ID.String()+formats.Extension(format))
+}
+
+// streamRows writes rows to the response as a file of the given format, offered
+// for download under filename when it is set. Every write is flushed, so the
+// response is sent with chunked transfer encoding as the rows are read.
+func streamRows(c *gin.Context, rows RowIterator, format models.FileFormat, filename string) {
+	defer rows.Close()
+
+	// Read the first row before writing the status, so a failing query still
+	// gets an error response
+	row, err := rows.Next()
+	if err != nil && err != io.EOF {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error reading rows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading rows"})
+		return
+	}
+
+	c.Header("Content-Type", formats.ContentType(format))
+	if filename != "" {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	c.Status(http.StatusOK)
+
+	writer, werr := formats.NewRowWriter(flushWriter{c.Writer}, format, rows.Fields())
+	if werr != nil {
+		logger.Errorf("Error writing rows: %v", werr)
+		return
+	}
+	for ; err != io.EOF; row, err = rows.Next() {
+		if err != nil {
+			logger.Errorf("Error reading rows: %v", err)
+			return
+		}
+		if err := writer.Write(row); err != nil {
+			logger.Errorf("Error writing rows: %v", err)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		logger.Errorf("Error writing rows: %v", err)
+	}
+}
+
+// acceptedFormat returns the format of the first media type in the Accept
+// header that names JSON or a file format, or "" when none does
+func acceptedFormat(c *gin.Context) models.FileFormat {
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		if format, ok := formats.Detect(accepted, ""); ok {
+			return format
+		}
+		if mediaType, _, err := mime.ParseMediaType(accepted); err == nil && mediaType == gin.MIMEJSON {
+			return models.FormatJSON
+		}
+	}
+	return ""
+}
+
+// flushWriter flushes the response after every write
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/formats"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// IngestHandler handles uploads of dataset rows
+type IngestHandler struct {
+	datasetRepository DatasetRepository
+	ingestService     IngestService
+}
+
+// IngestService defines the interface for row upload operations
+type IngestService interface {
+	IngestRows(datasetID uuid.UUID, body io.Reader, opts *models.IngestOptions) (*models.IngestResult, error)
+	InferSchema(body io.Reader, opts *models.InferOptions) (*models.SchemaInference, error)
+}
+
+// NewIngestHandler creates a new ingest handler
+func NewIngestHandler(datasetRepository DatasetRepository, ingestService IngestService) *IngestHandler {
+	return &IngestHandler{
+		datasetRepository: datasetRepository,
+		ingestService:     ingestService,
+	}
+}
+
+// IngestRows handles uploading rows to a dataset
+// @Summary Upload dataset rows
+// @Description Append or upsert the rows of a CSV, NDJSON or Parquet file, sent as the "file" part of a multipart form or as the request body. Every row is validated against the dataset schema; invalid rows are listed in the report while the valid ones are stored. Rows are stored in batches, so when an upload fails after some were stored the error response is the report of those, with the failure in its error field.
+// @Tags data
+// @Accept multipart/form-data,text/csv,application/x-ndjson,application/vnd.apache.parquet
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param format query string false "File format: csv, ndjson or parquet (default: detected from the file name or content type)"
+// @Param mode query string false "Write mode: insert or upsert (default: insert)"
+// @Param delimiter query string false "CSV field delimiter (default: comma)"
+// @Param dry_run query bool false "Validate the rows without storing them"
+// @Param file formData file false "File of rows"
+// @Success 200 {object} models.IngestResult "Rows uploaded successfully"
+// @Failure 400 {object} models.IngestResult "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} models.IngestResult "Internal server error"
+// @Router /data/datasets/{id}/rows [post]
+func (h *IngestHandler) IngestRows(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Parse options
+	var opts models.IngestOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, err := h.datasetRepository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if dataset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dataset not found"})
+		return
+	}
+
+	// Check if user is the owner
+	if dataset.CreatedBy != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to add rows to this dataset"})
+		return
+	}
+
+	// Open the upload
+	body, contentType, filename, err := uploadedFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Format == "" {
+		format, ok := formats.Detect(contentType, filename)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot detect the file format; set format to csv, ndjson or parquet"})
+			return
+		}
+		opts.Format = format
+	}
+
+	// Ingest rows
+	result, err := h.ingestService.IngestRows(id, body, &opts)
+	if err != nil {
+		status, message := http.StatusBadRequest, err.Error()
+		if isNotFoundError(err) {
+			status = http.StatusNotFound
+		} else if !isValidationError(err) {
+			logger.Errorf("Error ingesting rows: %v", err)
+			status, message = http.StatusInternalServerError, "Error ingesting rows"
+		}
+
+		// Rows of the batches written before the failure stay stored
+		if result != nil {
+			result.Error = message
+			c.JSON(status, result)
+			return
+		}
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	// Return result
+	c.JSON(http.StatusOK, result)
+}
+
+// InferSchema handles proposing a dataset schema for a file
+// @Summary Infer a dataset schema
+// @Description Sample the first rows of a CSV, NDJSON or Parquet file, sent as the "file" part of a multipart form or as the request body, and propose a dataset schema: the type of every column, including datetime formats and integers versus floats, its nullability, and the candidate primary keys. Nothing is stored; the schema can be edited before creating a dataset with it.
+// @Tags data
+// @Accept multipart/form-data,text/csv,application/x-ndjson,application/vnd.apache.parquet
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "File format: csv, ndjson or parquet (default: detected from the file name or content type)"
+// @Param delimiter query string false "CSV field delimiter (default: comma)"
+// @Param sample_size query int false "Rows sampled from the start of the file (default: 1000, maximum: 100000)"
+// @Param file formData file false "File of rows"
+// @Success 200 {object} models.SchemaInference "Schema inferred successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/schema/infer [post]
+func (h *IngestHandler) InferSchema(c *gin.Context) {
+	// Parse options
+	var opts models.InferOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Open the upload
+	body, contentType, filename, err := uploadedFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Format == "" {
+		format, ok := formats.Detect(contentType, filename)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot detect the file format; set format to csv, ndjson or parquet"})
+			return
+		}
+		opts.Format = format
+	}
+
+	// Infer schema
+	result, err := h.ingestService.InferSchema(body, &opts)
+	if err != nil {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error inferring schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inferring schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// uploadedFile returns the "file" part of a multipart request, or else the
+// request body, with its content type and file name. Parts are streamed
+// rather than buffered by gin's form parsing.
+func uploadedFile(c *gin.Context) (io.Reader, string, string, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.Request.Body, c.ContentType(), "", nil
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, "", "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", "", models.NewValidationError("multipart request has no file part")
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+		if part.FormName() == "file" {
+			return part, part.Header.Get("Content-Type"), part.FileName(), nil
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ModelHandler handles regressions and trained models
+type ModelHandler struct {
+	datasetRepository DatasetRepository
+	modelRepository   ModelRepository
+	modelService      ModelService
+}
+
+// ModelRepository defines the interface for trained model operations
+type ModelRepository interface {
+	FindByID(id uuid.UUID) (*models.TrainedModel, error)
+	Create(model *models.TrainedModel) error
+	Delete(id uuid.UUID) error
+}
+
+// ModelService defines the interface for regression operations
+type ModelService interface {
+	FitRegression(datasetID uuid.UUID, spec *models.RegressionSpec) (*models.RegressionResult, error)
+	Predict(model *models.TrainedModel, req *models.PredictRequest) (*models.PredictionResult, error)
+}
+
+// NewModelHandler creates a new model handler
+func NewModelHandler(datasetRepository DatasetRepository, modelRepository ModelRepository, modelService ModelService) *ModelHandler {
+	return &ModelHandler{
+		datasetRepository: datasetRepository,
+		modelRepository:   modelRepository,
+		modelService:      modelService,
+	}
+}
+
+// FitRegression handles fitting a regression
+// @Summary Fit regression
+// @Description Fit an OLS, ridge or logistic regression on fields of a dataset without storing it
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RegressionRequest true "Regression request"
+// @Success 200 {object} models.RegressionResult "Regression fitted successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/regression [post]
+func (h *ModelHandler) FitRegression(c *gin.Context) {
+	// Parse request
+	var req models.RegressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, req.DatasetID); !ok {
+		return
+	}
+
+	// Fit regression
+	start := time.Now()
+	result, ok := h.fit(c, req.DatasetID, &req.RegressionSpec)
+	if !ok {
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
+// TrainModel handles training a model
+// @Summary Train a model
+// @Description Fit a regression on fields of a dataset and store it with its coefficients and metrics
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TrainModelRequest true "Model training request"
+// @Success 201 {object} models.TrainedModel "Model trained successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/models/train [post]
+func (h *ModelHandler) TrainModel(c *gin.Context) {
+	// Parse request
+	var req models.TrainModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, req.DatasetID); !ok {
+		return
+	}
+
+	// Fit regression
+	result, ok := h.fit(c, req.DatasetID, &req.RegressionSpec)
+	if !ok {
+		return
+	}
+
+	// Store model
+	model := &models.TrainedModel{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Description: req.Description,
+		DatasetID:   req.DatasetID,
+		Spec:        req.RegressionSpec,
+		Model:       *result,
+		CreatedBy:   userID.(uuid.UUID),
+		CreatedAt:   time.Now(),
+	}
+	if err := h.modelRepository.Create(model); err != nil {
+		logger.Errorf("Error creating model: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model)
+}
+
+// Predict handles predicting with a model
+// @Summary Predict with a model
+// @Description Predict the target of the rows of a dataset, or of inline rows, with a trained model
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.PredictRequest true "Prediction request"
+// @Success 200 {object} models.PredictionResult "Predictions made successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Model or dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/models/predict [post]
+func (h *ModelHandler) Predict(c *gin.Context) {
+	// Parse request
+	var req models.PredictRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get model
+	model, ok := h.findModel(c, req.ModelID)
+	if !ok {
+		return
+	}
+
+	// Check if dataset exists
+	if req.DatasetID != nil {
+		if _, ok := findDataset(c, h.datasetRepository, *req.DatasetID); !ok {
+			return
+		}
+	}
+
+	// Predict
+	start := time.Now()
+	result, err := h.modelService.Predict(model, &req)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error predicting: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error predicting"})
+		return
+	}
+	executionTime := time.Since(start).Seconds()
+
+	// Return result
+	c.JSON(http.StatusOK, gin.H{
+		"result":         result,
+		"execution_time": executionTime,
+	})
+}
+
+// GetModel handles getting a model by ID
+// @Summary Get a model
+// @Description Get a trained model with its coefficients and metrics by ID
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Model ID"
+// @Success 200 {object} models.TrainedModel "Model retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid model ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Model not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/models/{id} [get]
+func (h *ModelHandler) GetModel(c *gin.Context) {
+	model, ok := h.findModelParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model)
+}
+
+// GetModelMetrics handles getting the metrics of a model
+// @Summary Get model metrics
+// @Description Get the training and validation metrics of a trained model
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Model ID"
+// @Success 200 {object} models.ModelMetrics "Model metrics retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid model ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Model not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/models/{id}/metrics [get]
+func (h *ModelHandler) GetModelMetrics(c *gin.Context) {
+	model, ok := h.findModelParam(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ModelMetrics{
+		ModelID:           model.ID,
+		Method:            model.Model.Method,
+		Metrics:           model.Model.Metrics,
+		ValidationMetrics: model.Model.ValidationMetrics,
+	})
+}
+
+// DeleteModel handles deleting a model
+// @Summary Delete a model
+// @Description Delete a trained model by ID
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Model ID"
+// @Success 204 "Model deleted successfully"
+// @Failure 400 {object} ErrorResponse "Invalid model ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Model not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /analytics/models/{id} [delete]
+func (h *ModelHandler) DeleteModel(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Get model
+	model, ok := h.findModelParam(c)
+	if !ok {
+		return
+	}
+
+	// Check if user is the owner
+	if model.CreatedBy != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to delete this model"})
+		return
+	}
+
+	// Delete model
+	if err := h.modelRepository.Delete(model.ID); err != nil {
+		logger.Errorf("Error deleting model: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// fit fits a regression on a dataset, writing the error response when it
+// fails
+func (h *ModelHandler) fit(c *gin.Context, datasetID uuid.UUID, spec *models.RegressionSpec) (*models.RegressionResult, bool) {
+	result, err := h.modelService.FitRegression(datasetID, spec)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		logger.Errorf("Error fitting regression: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fitting regression"})
+		return nil, false
+	}
+	return result, true
+}
+
+// findModelParam loads the model named by the id path parameter, writing the
+// error response when there is none
+func (h *ModelHandler) findModelParam(c *gin.Context) (*models.TrainedModel, bool) {
+	// Parse model ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid model ID"})
+		return nil, false
+	}
+	return h.findModel(c, id)
+}
+
+// findModel loads a model, writing the error response when there is none
+func (h *ModelHandler) findModel(c *gin.Context, id uuid.UUID) (*models.TrainedModel, bool) {
+	model, err := h.modelRepository.FindByID(id)
+	if err != nil {
+		logger.Errorf("Error finding model: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return nil, false
+	}
+	if model == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return nil, false
+	}
+	return model, true
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/galafis/go-data-api-microservices/internal/formats"
 	"github.com/galafis/go-data-api-microservices/internal/models"
 	"github.com/galafis/go-data-api-microservices/pkg/logger"
 	"github.com/gin-gonic/gin"
@@ -14,30 +15,34 @@ import (
 type QueryHandler struct {
 	datasetRepository DatasetRepository
 	queryService      QueryService
+	rowStore          RowStore
 }
 
 // QueryService defines the interface for query operations
 type QueryService interface {
 	ExecuteQuery(query *models.QueryRequest) ([]map[string]interface{}, int64, string, float64, error)
 	ExecuteTransform(transform *models.TransformRequest) (*models.Dataset, error)
-	ExecuteAggregate(aggregate *models.AggregateRequest) ([]map[string]interface{}, error)
+	ExecuteAggregate(aggregate *models.AggregateRequest) (*models.Dataset, error)
 	ExecuteJoin(join *models.JoinRequest) (*models.Dataset, error)
+	StreamQuery(query *models.QueryRequest) (RowIterator, error)
+	ExportDataset(datasetID uuid.UUID) (RowIterator, error)
 }
 
 // NewQueryHandler creates a new query handler
-func NewQueryHandler(datasetRepository DatasetRepository, queryService QueryService) *QueryHandler {
+func NewQueryHandler(datasetRepository DatasetRepository, queryService QueryService, rowStore RowStore) *QueryHandler {
 	return &QueryHandler{
 		datasetRepository: datasetRepository,
 		queryService:      queryService,
+		rowStore:          rowStore,
 	}
 }
 
 // QueryData handles querying data
 // @Summary Query data
-// @Description Query data from a dataset
+// @Description Query data from a dataset. The paged JSON response is returned unless the format field or the Accept header asks for CSV, NDJSON, Parquet or Arrow IPC, in which case every matching row up to the limit is streamed as a file. Set as_of_version or as_of to query the dataset as it was at an earlier version.
 // @Tags data
 // @Accept json
-// @Produce json
+// @Produce json,text/csv,application/x-ndjson,application/vnd.apache.parquet,application/vnd.apache.arrow.stream
 // @Security BearerAuth
 // @Param request body models.QueryRequest true "Query request"
 // @Success 200 {object} models.QueryResponse "Query executed successfully"
@@ -54,6 +59,15 @@ func (h *QueryHandler) QueryData(c *gin.Context) {
 		return
 	}
 
+	// Negotiate the response format
+	if req.Format == "" {
+		req.Format = acceptedFormat(c)
+	}
+	if req.Format != "" && req.Format != models.FormatJSON && !formats.Writable(req.Format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv, ndjson, parquet or arrow"})
+		return
+	}
+
 	// Check if dataset exists
 	dataset, err := h.datasetRepository.FindByID(req.DatasetID)
 	if err != nil {
@@ -66,9 +80,37 @@ func (h *QueryHandler) QueryData(c *gin.Context) {
 		return
 	}
 
+	// Stream the matching rows as a file
+	if req.Format != "" && req.Format != models.FormatJSON {
+		rows, err := h.queryService.StreamQuery(&req)
+		if err != nil {
+			if isNotFoundError(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if isValidationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Errorf("Error executing query: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing query"})
+			return
+		}
+		streamRows(c, rows, req.Format, "")
+		return
+	}
+
 	// Execute query
 	data, total, rawSQL, executionTime, err := h.queryService.ExecuteQuery(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error executing query: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing query"})
 		return
@@ -80,6 +122,8 @@ func (h *QueryHandler) QueryData(c *gin.Context) {
 		Total:         total,
 		Limit:         req.Limit,
 		Offset:        req.Offset,
+		NextCursor:    req.Cursor,
+		AsOfVersion:   req.AsOfVersion,
 		ExecutionTime: executionTime,
 	}
 
@@ -102,6 +146,7 @@ func (h *QueryHandler) QueryData(c *gin.Context) {
 // @Success 200 {object} models.QueryResponse "Transform executed successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Dataset not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /data/transform [post]
@@ -129,6 +174,14 @@ func (h *QueryHandler) TransformData(c *gin.Context) {
 	start := time.Now()
 	result, err := h.queryService.ExecuteTransform(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error executing transform: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing transform"})
 		return
@@ -165,7 +218,11 @@ func (h *QueryHandler) TransformData(c *gin.Context) {
 			UpdatedAt: now,
 		}
 
-		if err := h.datasetRepository.Create(newDataset); err != nil {
+		if err := h.saveDataset(newDataset, result.Data.([]map[string]interface{})); err != nil {
+			if isValidationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Errorf("Error saving transformed dataset: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving transformed dataset"})
 			return
@@ -176,6 +233,7 @@ func (h *QueryHandler) TransformData(c *gin.Context) {
 			"message":       "Transform executed and saved successfully",
 			"dataset_id":    newDataset.ID,
 			"dataset_name":  newDataset.Name,
+			"schema":        newDataset.Schema,
 			"row_count":     newDataset.RowCount,
 			"execution_time": executionTime,
 		})
@@ -195,6 +253,7 @@ func (h *QueryHandler) TransformData(c *gin.Context) {
 	// Return data directly
 	c.JSON(http.StatusOK, gin.H{
 		"data":          data,
+		"schema":        result.Schema,
 		"total":         len(data),
 		"execution_time": executionTime,
 	})
@@ -211,6 +270,7 @@ func (h *QueryHandler) TransformData(c *gin.Context) {
 // @Success 200 {object} models.QueryResponse "Aggregate executed successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Dataset not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /data/aggregate [post]
@@ -238,11 +298,20 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 	start := time.Now()
 	result, err := h.queryService.ExecuteAggregate(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error executing aggregate: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing aggregate"})
 		return
 	}
 	executionTime := time.Since(start).Seconds()
+	data := result.Data.([]map[string]interface{})
 
 	// Save result as new dataset if requested
 	if req.SaveAs != "" {
@@ -253,52 +322,18 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 			return
 		}
 
-		// Create schema for aggregated data
-		fields := make([]models.DataField, 0)
-		
-		// Add group by fields
-		for _, field := range req.GroupBy {
-			fields = append(fields, models.DataField{
-				Name:     field,
-				Type:     models.DataTypeString, // Assuming string for simplicity
-				Required: true,
-				Nullable: false,
-			})
-		}
-		
-		// Add aggregation fields
-		for _, agg := range req.Aggregations {
-			var dataType models.DataType
-			switch agg.Type {
-			case models.AggregationCount:
-				dataType = models.DataTypeInteger
-			case models.AggregationSum, models.AggregationAvg, models.AggregationMin, models.AggregationMax:
-				dataType = models.DataTypeFloat
-			default:
-				dataType = models.DataTypeFloat
-			}
-			
-			fields = append(fields, models.DataField{
-				Name:     agg.OutputName,
-				Type:     dataType,
-				Required: true,
-				Nullable: false,
-			})
-		}
-		
 		// Create new dataset
 		now := time.Now()
 		newDataset := &models.Dataset{
 			ID:          uuid.New(),
 			Name:        req.SaveAs,
 			Description: "Aggregated from " + dataset.Name,
-			Schema: models.DataSchema{
-				Fields: fields,
-			},
-			Source:   "aggregate",
-			Format:   dataset.Format,
-			RowCount: int64(len(result)),
-			Tags:     dataset.Tags,
+			Schema:      result.Schema,
+			Source:      "aggregate",
+			Format:      dataset.Format,
+			Size:        result.Size,
+			RowCount:    result.RowCount,
+			Tags:        dataset.Tags,
 			Metadata: map[string]interface{}{
 				"source_dataset": dataset.ID.String(),
 				"group_by":       req.GroupBy,
@@ -309,7 +344,11 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 			UpdatedAt: now,
 		}
 
-		if err := h.datasetRepository.Create(newDataset); err != nil {
+		if err := h.saveDataset(newDataset, data); err != nil {
+			if isValidationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Errorf("Error saving aggregated dataset: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving aggregated dataset"})
 			return
@@ -320,6 +359,7 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 			"message":       "Aggregate executed and saved successfully",
 			"dataset_id":    newDataset.ID,
 			"dataset_name":  newDataset.Name,
+			"schema":        newDataset.Schema,
 			"row_count":     newDataset.RowCount,
 			"execution_time": executionTime,
 		})
@@ -328,8 +368,9 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 
 	// Return data directly
 	c.JSON(http.StatusOK, gin.H{
-		"data":          result,
-		"total":         len(result),
+		"data":          data,
+		"schema":        result.Schema,
+		"total":         len(data),
 		"execution_time": executionTime,
 	})
 }
@@ -345,6 +386,7 @@ func (h *QueryHandler) AggregateData(c *gin.Context) {
 // @Success 200 {object} models.QueryResponse "Join executed successfully"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Dataset not found"
 // @Failure 500 {object} ErrorResponse "Internal server error"
 // @Router /data/join [post]
@@ -384,6 +426,14 @@ func (h *QueryHandler) JoinData(c *gin.Context) {
 	start := time.Now()
 	result, err := h.queryService.ExecuteJoin(&req)
 	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Errorf("Error executing join: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error executing join"})
 		return
@@ -416,13 +466,18 @@ func (h *QueryHandler) JoinData(c *gin.Context) {
 				"right_dataset": rightDataset.ID.String(),
 				"join_type":     req.JoinType,
 				"conditions":    req.Conditions,
+				"join_strategy": result.Metadata["join_strategy"],
 			},
 			CreatedBy: userID.(uuid.UUID),
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
 
-		if err := h.datasetRepository.Create(newDataset); err != nil {
+		if err := h.saveDataset(newDataset, result.Data.([]map[string]interface{})); err != nil {
+			if isValidationError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			logger.Errorf("Error saving joined dataset: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving joined dataset"})
 			return
@@ -433,6 +488,7 @@ func (h *QueryHandler) JoinData(c *gin.Context) {
 			"message":       "Join executed and saved successfully",
 			"dataset_id":    newDataset.ID,
 			"dataset_name":  newDataset.Name,
+			"schema":        newDataset.Schema,
 			"row_count":     newDataset.RowCount,
 			"execution_time": executionTime,
 		})
@@ -452,28 +508,43 @@ func (h *QueryHandler) JoinData(c *gin.Context) {
 	// Return data directly
 	c.JSON(http.StatusOK, gin.H{
 		"data":          data,
+		"schema":        result.Schema,
 		"total":         len(data),
 		"execution_time": executionTime,
 	})
 }
 
-// QueryData is a placeholder handler for querying data
-func QueryData(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Query data endpoint"})
-}
+// saveDataset creates a dataset holding the result of an operation and stores its
+// rows. The dataset is removed again if its rows cannot be stored.
+func (h *QueryHandler) saveDataset(dataset *models.Dataset, rows []map[string]interface{}) error {
+	if err := h.rowStore.SyncIndexes(dataset); err != nil {
+		return err
+	}
 
-// TransformData is a placeholder handler for transforming data
-func TransformData(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Transform data endpoint"})
-}
+	// The row store counts the rows as it stores them
+	dataset.RowCount = 0
+	dataset.Size = 0
 
-// AggregateData is a placeholder handler for aggregating data
-func AggregateData(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Aggregate data endpoint"})
-}
+	if err := h.datasetRepository.Create(dataset); err != nil {
+		if err := h.rowStore.Drop(dataset.ID); err != nil {
+			logger.Errorf("Error cleaning up dataset storage: %v", err)
+		}
+		return err
+	}
 
-// JoinData is a placeholder handler for joining data
-func JoinData(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Join data endpoint"})
-}
+	err := h.rowStore.RecordVersion(dataset, models.VersionCreate)
+	if err == nil {
+		err = h.rowStore.Insert(dataset, rows)
+	}
+	if err != nil {
+		if err := h.datasetRepository.Delete(dataset.ID); err != nil {
+			logger.Errorf("Error removing incomplete dataset: %v", err)
+		}
+		if err := h.rowStore.Drop(dataset.ID); err != nil {
+			logger.Errorf("Error cleaning up dataset storage: %v", err)
+		}
+		return err
+	}
 
+	return nil
+}
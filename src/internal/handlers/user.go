@@ -217,19 +217,3 @@ func (h *UserHandler) DeleteCurrentUser(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
-
-// GetCurrentUser is a placeholder handler for getting the current user
-func GetCurrentUser(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Get current user endpoint"})
-}
-
-// UpdateCurrentUser is a placeholder handler for updating the current user
-func UpdateCurrentUser(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Update current user endpoint"})
-}
-
-// DeleteCurrentUser is a placeholder handler for deleting the current user
-func DeleteCurrentUser(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Delete current user endpoint"})
-}
-
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// VersionHandler handles dataset version operations
+type VersionHandler struct {
+	datasetRepository DatasetRepository
+	versionService    VersionService
+}
+
+// VersionService defines the interface for dataset version operations
+type VersionService interface {
+	ListVersions(datasetID uuid.UUID) ([]models.DatasetVersion, error)
+	GetVersion(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error)
+	DiffVersions(datasetID uuid.UUID, opts *models.VersionDiffOptions) (*models.VersionDiff, error)
+	Rollback(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error)
+}
+
+// NewVersionHandler creates a new version handler
+func NewVersionHandler(datasetRepository DatasetRepository, versionService VersionService) *VersionHandler {
+	return &VersionHandler{
+		datasetRepository: datasetRepository,
+		versionService:    versionService,
+	}
+}
+
+// ListVersions handles listing the versions of a dataset
+// @Summary List dataset versions
+// @Description List the versions of a dataset, latest first. Every change to the schema, metadata or rows of a dataset creates a version.
+// @Tags data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Success 200 {array} models.DatasetVersion "Versions retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid dataset ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/versions [get]
+func (h *VersionHandler) ListVersions(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, id); !ok {
+		return
+	}
+
+	// List versions
+	versions, err := h.versionService.ListVersions(id)
+	if err != nil {
+		logger.Errorf("Error listing versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetVersion handles getting a version of a dataset
+// @Summary Get a dataset version
+// @Description Get the schema, metadata and row statistics a dataset had at a version
+// @Tags data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} models.DatasetVersion "Version retrieved successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset or version not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/versions/{version} [get]
+func (h *VersionHandler) GetVersion(c *gin.Context) {
+	// Parse dataset ID and version
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+	number, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil || number <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, id); !ok {
+		return
+	}
+
+	// Get version
+	version, err := h.versionService.GetVersion(id, number)
+	if err != nil {
+		logger.Errorf("Error finding version: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if version == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// DiffVersions handles comparing two versions of a dataset
+// @Summary Compare dataset versions
+// @Description List the schema fields and rows that differ between two versions of a dataset. Rows are matched by their primary key; the counts cover every changed row while the list stops at the limit.
+// @Tags data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param from query int true "Version to compare from"
+// @Param to query int false "Version to compare to (default: latest)"
+// @Param limit query int false "Maximum number of changed rows listed (default: 100, maximum: 1000)"
+// @Success 200 {object} models.VersionDiff "Versions compared successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/diff [get]
+func (h *VersionHandler) DiffVersions(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Parse options
+	var opts models.VersionDiffOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if dataset exists
+	if _, ok := findDataset(c, h.datasetRepository, id); !ok {
+		return
+	}
+
+	// Compare versions
+	diff, err := h.versionService.DiffVersions(id, &opts)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error comparing versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error comparing versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// Rollback handles restoring a dataset to an earlier version
+// @Summary Roll back a dataset
+// @Description Restore the schema, metadata and rows a dataset had at an earlier version. The rollback is recorded as a new version, so it can itself be undone.
+// @Tags data
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Dataset ID"
+// @Param request body models.RollbackRequest true "Rollback request"
+// @Success 200 {object} models.DatasetVersion "Dataset rolled back successfully"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Dataset not found"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Router /data/datasets/{id}/rollback [post]
+func (h *VersionHandler) Rollback(c *gin.Context) {
+	// Parse dataset ID
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dataset ID"})
+		return
+	}
+
+	// Parse request
+	var req models.RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Check if dataset exists
+	dataset, ok := findDataset(c, h.datasetRepository, id)
+	if !ok {
+		return
+	}
+
+	// Check if user is the owner
+	if dataset.CreatedBy != userID.(uuid.UUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to roll back this dataset"})
+		return
+	}
+
+	// Roll back
+	version, err := h.versionService.Rollback(id, req.Version)
+	if err != nil {
+		if isNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Errorf("Error rolling back dataset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rolling back dataset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
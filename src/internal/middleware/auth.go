@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -8,16 +9,17 @@ import (
 	"github.com/galafis/go-data-api-microservices/internal/models"
 	"github.com/galafis/go-data-api-microservices/pkg/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 )
 
 // AuthMiddleware represents the authentication middleware
 type AuthMiddleware struct {
-	jwtService *auth.JWTService
+	jwtService auth.JWTService
 }
 
 // NewAuthMiddleware creates a new authentication middleware
-func NewAuthMiddleware(jwtService *auth.JWTService) *AuthMiddleware {
+func NewAuthMiddleware(jwtService auth.JWTService) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtService: jwtService,
 	}
@@ -44,6 +46,11 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		// Extract and validate the token
 		tokenString := parts[1]
 		claims, err := m.jwtService.ValidateToken(tokenString)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has expired"})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			c.Abort()
@@ -112,22 +119,3 @@ func (m *AuthMiddleware) RoleRequired(roles ...models.Role) gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// AuthRequired is a shorthand function for the auth middleware
-func AuthRequired() gin.HandlerFunc {
-	// This is a placeholder that should be replaced with a proper implementation
-	// that gets the JWT service from the application context
-	jwtService := auth.NewJWTService(nil) // This should be properly initialized
-	middleware := NewAuthMiddleware(jwtService)
-	return middleware.AuthRequired()
-}
-
-// RoleRequired is a shorthand function for the role middleware
-func RoleRequired(roles ...models.Role) gin.HandlerFunc {
-	// This is a placeholder that should be replaced with a proper implementation
-	// that gets the JWT service from the application context
-	jwtService := auth.NewJWTService(nil) // This should be properly initialized
-	middleware := NewAuthMiddleware(jwtService)
-	return middleware.RoleRequired(roles...)
-}
-
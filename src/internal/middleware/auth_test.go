@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/auth"
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTSecret = "test-secret"
+
+func newTestJWTService(secret string, accessExpiry time.Duration) auth.JWTService {
+	return auth.NewJWTService(&config.AuthConfig{
+		JWTSecret:          secret,
+		AccessTokenExpiry:  accessExpiry,
+		RefreshTokenExpiry: time.Hour,
+	})
+}
+
+func newTestRouter(m *AuthMiddleware, handlers ...gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	chain := append([]gin.HandlerFunc{m.AuthRequired()}, handlers...)
+	chain = append(chain, func(c *gin.Context) {
+		userID, _ := c.Get("user_id")
+		role, _ := c.Get("role")
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": role})
+	})
+	r.GET("/protected", chain...)
+	return r
+}
+
+func performRequest(r *gin.Engine, authHeader string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddleware_AuthRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := newTestJWTService(testJWTSecret, 15*time.Minute)
+	r := newTestRouter(NewAuthMiddleware(jwtService))
+
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Role: models.RoleUser}
+
+	// Test Case 1: Valid access token
+	t.Run("Valid Access Token", func(t *testing.T) {
+		token, err := jwtService.GenerateAccessToken(user)
+		assert.NoError(t, err)
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), user.ID.String())
+	})
+
+	// Test Case 2: Missing authorization header
+	t.Run("Missing Header", func(t *testing.T) {
+		w := performRequest(r, "")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "authorization header is required")
+	})
+
+	// Test Case 3: Header without the Bearer scheme
+	t.Run("Invalid Header Format", func(t *testing.T) {
+		token, _ := jwtService.GenerateAccessToken(user)
+
+		w := performRequest(r, "Token "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid authorization header format")
+	})
+
+	// Test Case 4: Expired access token
+	t.Run("Expired Token", func(t *testing.T) {
+		expiredService := newTestJWTService(testJWTSecret, -time.Minute)
+		token, err := expiredService.GenerateAccessToken(user)
+		assert.NoError(t, err)
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "token has expired")
+	})
+
+	// Test Case 5: Refresh token used as an access token
+	t.Run("Wrong Token Type", func(t *testing.T) {
+		token, err := jwtService.GenerateRefreshToken(user)
+		assert.NoError(t, err)
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid token type")
+	})
+
+	// Test Case 6: Malformed token
+	t.Run("Malformed Token", func(t *testing.T) {
+		w := performRequest(r, "Bearer not.a.token")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid or expired token")
+	})
+
+	// Test Case 7: Token signed with another secret
+	t.Run("Wrong Signature", func(t *testing.T) {
+		otherService := newTestJWTService("other-secret", 15*time.Minute)
+		token, _ := otherService.GenerateAccessToken(user)
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid or expired token")
+	})
+}
+
+func TestAuthMiddleware_RoleRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := newTestJWTService(testJWTSecret, 15*time.Minute)
+	m := NewAuthMiddleware(jwtService)
+	r := newTestRouter(m, m.RoleRequired(models.RoleAdmin, models.RoleUser))
+
+	// Test Case 1: User with an allowed role
+	t.Run("Allowed Role", func(t *testing.T) {
+		token, _ := jwtService.GenerateAccessToken(&models.User{ID: uuid.New(), Role: models.RoleAdmin})
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test Case 2: User without an allowed role
+	t.Run("Forbidden Role", func(t *testing.T) {
+		token, _ := jwtService.GenerateAccessToken(&models.User{ID: uuid.New(), Role: models.RoleViewer})
+
+		w := performRequest(r, "Bearer "+token)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "insufficient permissions")
+	})
+}
@@ -45,6 +45,38 @@ type CorrelationRequest struct {
 	Fields    []string         `json:"fields" binding:"required,min=2"`
 	Method    CorrelationMethod `json:"method" binding:"required"`
 	Filters   []FilterCondition `json:"filters,omitempty"`
+	Missing   MissingValues     `json:"missing,omitempty"`
+}
+
+// MissingValues represents how rows with missing values enter a matrix of
+// field pairs: pairwise (the default) computes each pair over the rows where
+// both fields have a value, listwise computes every pair over the rows where
+// all the fields, and any control fields, have one
+type MissingValues string
+
+const (
+	MissingPairwise MissingValues = "pairwise"
+	MissingListwise MissingValues = "listwise"
+)
+
+// CovarianceRequest represents a request for a covariance matrix
+type CovarianceRequest struct {
+	DatasetID uuid.UUID         `json:"dataset_id" binding:"required"`
+	Fields    []string          `json:"fields" binding:"required,min=2"`
+	Filters   []FilterCondition `json:"filters,omitempty"`
+	Missing   MissingValues     `json:"missing,omitempty"`
+}
+
+// PartialCorrelationRequest represents a request for the correlations of
+// fields controlling for other fields. Pearson and Spearman correlations are
+// supported.
+type PartialCorrelationRequest struct {
+	DatasetID uuid.UUID         `json:"dataset_id" binding:"required"`
+	Fields    []string          `json:"fields" binding:"required,min=2"`
+	Controls  []string          `json:"controls" binding:"required,min=1"`
+	Method    CorrelationMethod `json:"method" binding:"required"`
+	Filters   []FilterCondition `json:"filters,omitempty"`
+	Missing   MissingValues     `json:"missing,omitempty"`
 }
 
 // TimeSeriesAggregation represents a time series aggregation
@@ -71,7 +103,22 @@ const (
 	TimeSeriesYear   TimeSeriesInterval = "year"
 )
 
-// TimeSeriesRequest represents a request for time series analysis
+// TimeSeriesFill decides what empty time series buckets hold
+type TimeSeriesFill string
+
+const (
+	TimeSeriesFillNone    TimeSeriesFill = "none"
+	TimeSeriesFillNull    TimeSeriesFill = "null"
+	TimeSeriesFillZero    TimeSeriesFill = "zero"
+	TimeSeriesFillForward TimeSeriesFill = "forward"
+)
+
+// TimeSeriesRequest represents a request for time series analysis. Buckets
+// follow the calendar of Timezone (an IANA name, UTC by default), so day and
+// longer intervals start at local midnight. StartTime is inclusive and EndTime
+// exclusive. Fill decides what buckets without rows hold: "none" (default)
+// leaves them out, "null" and "zero" add them with that value and "forward"
+// repeats the previous value of the series.
 type TimeSeriesRequest struct {
 	DatasetID   uuid.UUID           `json:"dataset_id" binding:"required"`
 	TimeField   string              `json:"time_field" binding:"required"`
@@ -82,6 +129,23 @@ type TimeSeriesRequest struct {
 	EndTime     string              `json:"end_time,omitempty"`
 	GroupBy     []string            `json:"group_by,omitempty"`
 	Filters     []FilterCondition   `json:"filters,omitempty"`
+	Timezone    string              `json:"timezone,omitempty"`
+	Fill        TimeSeriesFill      `json:"fill,omitempty"`
+}
+
+// DecompositionRequest represents a request for the seasonal decomposition of
+// a time series. Buckets without rows are decomposed as gaps unless Fill says
+// otherwise; Params holds DecompositionParams.
+type DecompositionRequest struct {
+	TimeSeriesRequest
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// TrendRequest represents a request for trend tests and change-point detection
+// on a time series; Params holds TrendParams.
+type TrendRequest struct {
+	TimeSeriesRequest
+	Params map[string]any `json:"params,omitempty"`
 }
 
 // ForecastMethod represents a forecasting method
@@ -129,19 +193,37 @@ type StatisticsResult struct {
 	Results map[string]any    `json:"results"`
 }
 
-// CorrelationResult represents the result of a correlation analysis
+// CorrelationResult represents the result of a correlation analysis. Entries are
+// null where the correlation is undefined, such as for a constant field.
+// Observations counts the rows each pair was computed over, and Controls lists
+// the fields a partial correlation controls for.
 type CorrelationResult struct {
 	Method     CorrelationMethod `json:"method"`
 	Fields     []string          `json:"fields"`
-	Correlation [][]float64      `json:"correlation"`
-	PValues    [][]float64       `json:"p_values,omitempty"`
+	Controls   []string          `json:"controls,omitempty"`
+	Missing    MissingValues     `json:"missing"`
+	Correlation [][]*float64     `json:"correlation"`
+	PValues    [][]*float64      `json:"p_values,omitempty"`
+	Observations [][]int         `json:"observations"`
 }
 
-// TimeSeriesPoint represents a point in a time series
+// CovarianceResult represents a covariance matrix in the layout of
+// CorrelationResult. Entries are sample covariances, null for pairs with fewer
+// than two rows.
+type CovarianceResult struct {
+	Fields       []string      `json:"fields"`
+	Missing      MissingValues `json:"missing"`
+	Covariance   [][]*float64  `json:"covariance"`
+	Observations [][]int       `json:"observations"`
+}
+
+// TimeSeriesPoint represents a point in a time series. Timestamp is the start of
+// the bucket, Value is null for buckets without a value and Group labels the
+// series of a group-by request with its group values.
 type TimeSeriesPoint struct {
-	Timestamp string  `json:"timestamp"`
-	Value     float64 `json:"value"`
-	Group     string  `json:"group,omitempty"`
+	Timestamp string   `json:"timestamp"`
+	Value     *float64 `json:"value"`
+	Group     string   `json:"group,omitempty"`
 }
 
 // TimeSeriesResult represents the result of a time series analysis
@@ -150,15 +232,18 @@ type TimeSeriesResult struct {
 	ValueField  string           `json:"value_field"`
 	Aggregation TimeSeriesAggregation `json:"aggregation"`
 	Interval    TimeSeriesInterval `json:"interval"`
+	Timezone    string           `json:"timezone"`
+	Fill        TimeSeriesFill   `json:"fill"`
 	Points      []TimeSeriesPoint `json:"points"`
 }
 
-// ForecastPoint represents a point in a forecast
+// ForecastPoint represents a point in a forecast. LowerBound and UpperBound
+// delimit the prediction interval at the requested confidence.
 type ForecastPoint struct {
 	Timestamp string  `json:"timestamp"`
 	Value     float64 `json:"value"`
-	LowerBound float64 `json:"lower_bound,omitempty"`
-	UpperBound float64 `json:"upper_bound,omitempty"`
+	LowerBound float64 `json:"lower_bound"`
+	UpperBound float64 `json:"upper_bound"`
 }
 
 // ForecastResult represents the result of a forecast
@@ -171,3 +256,46 @@ type ForecastResult struct {
 	Metrics     map[string]float64 `json:"metrics,omitempty"`
 }
 
+// DecompositionResult represents the seasonal decomposition of a time series.
+// Points holds the observed series and Trend, Seasonal and Residual the
+// components at the same timestamps; the residual is null where the observed
+// value is.
+type DecompositionResult struct {
+	TimeSeriesResult
+	Period   int               `json:"period"`
+	Trend    []TimeSeriesPoint `json:"trend"`
+	Seasonal []TimeSeriesPoint `json:"seasonal"`
+	Residual []TimeSeriesPoint `json:"residual"`
+}
+
+// TrendResult represents the trend analysis of a time series, with one test
+// per series of the points
+type TrendResult struct {
+	TimeSeriesResult
+	Trends []TrendTest `json:"trends"`
+}
+
+// TrendTest is the Mann-Kendall trend test of one series with Sen's slope,
+// per bucket, and the change points found in its level. Direction is
+// "increasing" or "decreasing" when the p-value is below the significance
+// level and "none" otherwise.
+type TrendTest struct {
+	Group        string        `json:"group,omitempty"`
+	Count        int           `json:"count"`
+	Direction    string        `json:"direction"`
+	S            float64       `json:"s"`
+	Z            *float64      `json:"z"`
+	PValue       *float64      `json:"p_value"`
+	Tau          *float64      `json:"tau"`
+	Slope        *float64      `json:"slope"`
+	Intercept    *float64      `json:"intercept"`
+	ChangePoints []ChangePoint `json:"change_points"`
+}
+
+// ChangePoint is the first bucket of a new level of a series, with the mean
+// of the segments before and after it
+type ChangePoint struct {
+	Timestamp  string  `json:"timestamp"`
+	MeanBefore float64 `json:"mean_before"`
+	MeanAfter  float64 `json:"mean_after"`
+}
@@ -0,0 +1,193 @@
+package models
+
+// The types below document the Params accepted by the analytics requests.
+// Params are decoded strictly, so unknown keys and values of the wrong type are
+// rejected.
+
+// StatisticsParams tunes the statistics types that take options; every field
+// is optional.
+//
+//	{"type": "quantile", "fields": ["price"], "params": {"quantiles": [0.1, 0.9]}}
+//	{"type": "histogram", "fields": ["price"], "params": {"bins": 20}}
+//	{"type": "frequency", "fields": ["region"], "params": {"limit": 5}}
+type StatisticsParams struct {
+	// Quantiles lists the quantiles to compute, each between 0 and 1
+	// (default 0.25, 0.5 and 0.75)
+	Quantiles []float64 `json:"quantiles,omitempty"`
+	// Bins is the number of histogram bins (default 10)
+	Bins int `json:"bins,omitempty"`
+	// Limit caps the number of values a frequency table lists, most frequent
+	// first (default 100)
+	Limit int `json:"limit,omitempty"`
+}
+
+// ForecastParams tunes how the history is built, the prediction intervals and
+// the model of each forecast method; every field is optional.
+//
+// The history is the value field aggregated into interval buckets. Buckets
+// without rows are filled with Fill, "forward" (default) or "zero", and
+// buckets whose rows have no value repeat the previous value.
+//
+// The last Holdout points (by default the horizon, at most a fifth of the
+// history) are forecast from the points before them to compute the MAE, RMSE
+// and MAPE metrics; 0 disables the backtest.
+//
+//	{"method": "exponential", "params": {"season_length": 7, "confidence": 0.8}}
+//	{"method": "arima", "params": {"p": 2, "d": 1, "q": 1}}
+//	{"method": "prophet", "params": {"weekly_seasonality": 3, "holidays": [
+//		{"name": "christmas", "dates": ["2023-12-25", "2024-12-25"], "lower_window": -1}]}}
+type ForecastParams struct {
+	// Aggregation combines the values in a bucket (default sum)
+	Aggregation TimeSeriesAggregation `json:"aggregation,omitempty"`
+	// Timezone is the IANA time zone of the buckets (default UTC)
+	Timezone string         `json:"timezone,omitempty"`
+	Fill     TimeSeriesFill `json:"fill,omitempty"`
+	Holdout  *int           `json:"holdout,omitempty"`
+	// Confidence is the coverage of the prediction intervals, between 0 and 1
+	// exclusive (default 0.95)
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// Alpha, Beta and Gamma are the Holt-Winters smoothing factors of the
+	// level, trend and season, between 0 and 1; those left out are fitted.
+	// SeasonLength is the number of buckets in a season, 0 (default) for no
+	// seasonality, and Seasonal is "additive" (default) or "multiplicative".
+	// Trend set to false drops the trend component.
+	Alpha        *float64 `json:"alpha,omitempty"`
+	Beta         *float64 `json:"beta,omitempty"`
+	Gamma        *float64 `json:"gamma,omitempty"`
+	SeasonLength int      `json:"season_length,omitempty"`
+	Seasonal     string   `json:"seasonal,omitempty"`
+	Trend        *bool    `json:"trend,omitempty"`
+
+	// P, D and Q are the ARIMA orders of the autoregressive, differencing
+	// and moving average parts (default 1, 1 and 1)
+	P *int `json:"p,omitempty"`
+	D *int `json:"d,omitempty"`
+	Q *int `json:"q,omitempty"`
+
+	// Changepoints is the number of potential trend changes of prophet
+	// forecasts (default 25), spread over the first ChangepointRange of the
+	// history (default 0.8). ChangepointPriorScale sets how freely the trend
+	// bends (default 0.05).
+	Changepoints          *int     `json:"changepoints,omitempty"`
+	ChangepointRange      *float64 `json:"changepoint_range,omitempty"`
+	ChangepointPriorScale *float64 `json:"changepoint_prior_scale,omitempty"`
+	// YearlySeasonality, WeeklySeasonality and DailySeasonality are the
+	// Fourier orders of the prophet seasonalities, 0 to leave one out. By
+	// default yearly (order 10) needs two years of history, weekly (order 3)
+	// two weeks of buckets shorter than a week and daily (order 4) two days
+	// of buckets shorter than a day.
+	YearlySeasonality     *int     `json:"yearly_seasonality,omitempty"`
+	WeeklySeasonality     *int     `json:"weekly_seasonality,omitempty"`
+	DailySeasonality      *int     `json:"daily_seasonality,omitempty"`
+	SeasonalityPriorScale *float64 `json:"seasonality_prior_scale,omitempty"`
+	// Holidays are days with their own effect in prophet forecasts, in the
+	// past and the future
+	Holidays          []ForecastHoliday `json:"holidays,omitempty"`
+	HolidayPriorScale *float64          `json:"holiday_prior_scale,omitempty"`
+}
+
+// ForecastHoliday is a recurring event of a prophet forecast. Dates are days
+// formatted as 2006-01-02 in the forecast time zone; the event also covers
+// the -LowerWindow days before and the UpperWindow days after each date, each
+// with an effect of its own.
+type ForecastHoliday struct {
+	Name        string   `json:"name"`
+	Dates       []string `json:"dates"`
+	LowerWindow int      `json:"lower_window,omitempty"`
+	UpperWindow int      `json:"upper_window,omitempty"`
+}
+
+// DecompositionParams tunes the STL decomposition; every field is optional.
+//
+//	{"interval": "hour", "params": {"period": 24, "robust": true}}
+type DecompositionParams struct {
+	// Period is the number of buckets in a season (default 60 for minutes,
+	// 24 for hours, 7 for days, 52 for weeks, 12 for months and 4 for
+	// quarters; required for years)
+	Period int `json:"period,omitempty"`
+	// Seasonal and Trend are the odd spans, in buckets, of the seasonal and
+	// trend smoothers (default 7 and the smallest odd number above
+	// 1.5 period / (1 - 1.5 / seasonal))
+	Seasonal int `json:"seasonal,omitempty"`
+	Trend    int `json:"trend,omitempty"`
+	// Robust downweights outliers so that they stay in the residual
+	Robust bool `json:"robust,omitempty"`
+}
+
+// TrendParams tunes the trend tests and change-point detection; every field
+// is optional.
+//
+//	{"interval": "day", "params": {"alpha": 0.01, "min_segment": 7}}
+type TrendParams struct {
+	// Alpha is the significance level of the trend test (default 0.05)
+	Alpha float64 `json:"alpha,omitempty"`
+	// Penalty is the cost of a change point in units of the noise variance
+	// (default 2 ln n for n values); higher penalties find fewer
+	Penalty *float64 `json:"penalty,omitempty"`
+	// MinSegment is the least number of values between change points
+	// (default 2)
+	MinSegment int `json:"min_segment,omitempty"`
+}
+
+// AnomalyParams tunes anomaly detection; every field is optional.
+//
+//	{"method": "seasonal", "time_series": {...}, "params": {"period": 24}}
+type AnomalyParams struct {
+	// Period is the number of buckets in a season of seasonal detection,
+	// with the defaults of DecompositionParams
+	Period int `json:"period,omitempty"`
+	// Limit caps the number of anomalies listed (default 1000)
+	Limit int `json:"limit,omitempty"`
+}
+
+// HypothesisTestParams tunes a hypothesis test; every field is optional.
+//
+//	{"test": "t_test_one_sample", "fields": ["price"], "params": {"mu": 10, "alternative": "greater"}}
+//	{"test": "t_test_two_sample", "fields": ["price"], "group_field": "region", "params": {"equal_variance": true}}
+//	{"test": "kolmogorov_smirnov", "fields": ["price"], "params": {"mean": 10, "std_dev": 2}}
+type HypothesisTestParams struct {
+	// Alternative is the alternative hypothesis of t-tests and Mann-Whitney,
+	// "two_sided" (default), "less" or "greater" (the first sample, or the
+	// mean against Mu, being less or greater); the other tests are two-sided
+	Alternative string `json:"alternative,omitempty"`
+	// Alpha is the significance level the p-value is compared with (default
+	// 0.05)
+	Alpha float64 `json:"alpha,omitempty"`
+	// Mu is the mean of the null hypothesis of the one-sample t-test
+	// (default 0)
+	Mu float64 `json:"mu,omitempty"`
+	// EqualVariance pools the variances of a two-sample t-test (Student's
+	// test) instead of using Welch's test
+	EqualVariance bool `json:"equal_variance,omitempty"`
+	// Mean and StdDev define the normal distribution a single sample is
+	// compared with by kolmogorov_smirnov. Those left out are estimated from
+	// the sample, which makes the test conservative.
+	Mean   *float64 `json:"mean,omitempty"`
+	StdDev *float64 `json:"std_dev,omitempty"`
+}
+
+// RegressionParams tunes a regression; every field is optional.
+//
+//	{"method": "ridge", "target": "price", "features": ["area", "rooms"], "params": {"lambda": 10}}
+//	{"method": "logistic", "target": "plan", "features": ["spend"], "params": {"positive_class": "premium", "validation_split": 0.2}}
+type RegressionParams struct {
+	// Lambda is the L2 penalty on the feature coefficients (default 1 for
+	// ridge and 0 for logistic, and not supported by ols); the intercept is
+	// never penalized
+	Lambda *float64 `json:"lambda,omitempty"`
+	// NoIntercept fits the model through the origin
+	NoIntercept bool `json:"no_intercept,omitempty"`
+	// PositiveClass is the target value whose probability logistic
+	// regression models, compared as text
+	PositiveClass string `json:"positive_class,omitempty"`
+	// Threshold is the probability from which logistic regression predicts
+	// the positive class (default 0.5)
+	Threshold float64 `json:"threshold,omitempty"`
+	// MaxIterations caps the Newton iterations of logistic regression
+	// (default 100)
+	MaxIterations int `json:"max_iterations,omitempty"`
+	// ValidationSplit is the fraction of rows, the last ones, held out of
+	// training to measure the validation metrics on (default 0)
+	ValidationSplit float64 `json:"validation_split,omitempty"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnomalyMethod represents an anomaly detection method
+type AnomalyMethod string
+
+const (
+	AnomalyZScore   AnomalyMethod = "zscore"
+	AnomalyIQR      AnomalyMethod = "iqr"
+	AnomalyMAD      AnomalyMethod = "mad"
+	AnomalySeasonal AnomalyMethod = "seasonal"
+)
+
+// AnomalyDetection describes what an anomaly detection checks: either the
+// values of Field in every matching row, or the buckets of TimeSeries. A time
+// series always runs on the dataset being checked, whatever its dataset_id.
+// The seasonal method needs a time series.
+//
+// Values whose score exceeds Threshold in absolute value are anomalies. Scores
+// are z-scores for zscore (default threshold 3), distances beyond the quartiles
+// in interquartile ranges for iqr (default 1.5), modified z-scores around the
+// median for mad (default 3.5) and robust z-scores of the STL residual for
+// seasonal (default 3). Values without spread have no anomalies.
+type AnomalyDetection struct {
+	Method     AnomalyMethod      `json:"method" binding:"required"`
+	Field      string             `json:"field,omitempty"`
+	TimeSeries *TimeSeriesRequest `json:"time_series,omitempty"`
+	Filters    []FilterCondition  `json:"filters,omitempty"`
+	Threshold  *float64           `json:"threshold,omitempty"`
+	Params     map[string]any     `json:"params,omitempty"`
+}
+
+// AnomalyRequest represents a request to detect anomalies in a dataset
+type AnomalyRequest struct {
+	DatasetID uuid.UUID `json:"dataset_id" binding:"required"`
+	AnomalyDetection
+}
+
+// Anomaly is a flagged value. Row holds the row of a field detection;
+// Timestamp and Group locate the bucket of a time series detection. Expected
+// is the value the method measures the score from: the mean, the median or,
+// for seasonal detection, the trend plus the season.
+type Anomaly struct {
+	Row       map[string]any `json:"row,omitempty"`
+	Timestamp string         `json:"timestamp,omitempty"`
+	Group     string         `json:"group,omitempty"`
+	Value     float64        `json:"value"`
+	Expected  float64        `json:"expected"`
+	Score     float64        `json:"score"`
+}
+
+// AnomalyResult represents the result of an anomaly detection. Checked counts
+// the values scored and Flagged the anomalies among them, of which Anomalies
+// lists the first ones up to the limit.
+type AnomalyResult struct {
+	DatasetID uuid.UUID     `json:"dataset_id"`
+	RuleID    *uuid.UUID    `json:"rule_id,omitempty"`
+	Method    AnomalyMethod `json:"method"`
+	Field     string        `json:"field"`
+	Threshold float64       `json:"threshold"`
+	Checked   int           `json:"checked"`
+	Flagged   int           `json:"flagged"`
+	Anomalies []Anomaly     `json:"anomalies"`
+}
+
+// AnomalyRule is a stored anomaly detection that can be run again, against its
+// dataset or another one with the same fields
+type AnomalyRule struct {
+	ID          uuid.UUID        `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	DatasetID   uuid.UUID        `json:"dataset_id"`
+	Detection   AnomalyDetection `json:"detection"`
+	CreatedBy   uuid.UUID        `json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CreateAnomalyRuleRequest represents a request to create an anomaly rule
+type CreateAnomalyRuleRequest struct {
+	Name        string           `json:"name" binding:"required"`
+	Description string           `json:"description,omitempty"`
+	DatasetID   uuid.UUID        `json:"dataset_id" binding:"required"`
+	Detection   AnomalyDetection `json:"detection" binding:"required"`
+}
+
+// UpdateAnomalyRuleRequest represents a request to update an anomaly rule; a
+// new detection replaces the previous one as a whole
+type UpdateAnomalyRuleRequest struct {
+	Name        string            `json:"name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Detection   *AnomalyDetection `json:"detection,omitempty"`
+}
+
+// AnomalyRuleListResponse represents a page of anomaly rules
+type AnomalyRuleListResponse struct {
+	Rules    []AnomalyRule `json:"rules"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
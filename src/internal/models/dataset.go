@@ -19,7 +19,8 @@ const (
 	DataTypeObject   DataType = "object"
 )
 
-// DataField represents a field in a dataset schema
+// DataField represents a field in a dataset schema. Aliases lists former names
+// of a renamed field, which queries and uploads may still use.
 type DataField struct {
 	Name        string   `json:"name" bson:"name"`
 	Type        DataType `json:"type" bson:"type"`
@@ -28,6 +29,7 @@ type DataField struct {
 	Nullable    bool     `json:"nullable" bson:"nullable"`
 	Unique      bool     `json:"unique,omitempty" bson:"unique,omitempty"`
 	Default     any      `json:"default,omitempty" bson:"default,omitempty"`
+	Aliases     []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
 	Metadata    any      `json:"metadata,omitempty" bson:"metadata,omitempty"`
 }
 
@@ -70,11 +72,14 @@ type CreateDatasetRequest struct {
 	Metadata    map[string]any       `json:"metadata,omitempty"`
 }
 
-// UpdateDatasetRequest represents a request to update an existing dataset
+// UpdateDatasetRequest represents a request to update an existing dataset. A
+// new schema must be compatible with the stored rows, or come with the
+// Migration that makes it so.
 type UpdateDatasetRequest struct {
 	Name        string               `json:"name,omitempty"`
 	Description string               `json:"description,omitempty"`
 	Schema      *DataSchema          `json:"schema,omitempty"`
+	Migration   *SchemaMigration     `json:"migration,omitempty"`
 	Source      string               `json:"source,omitempty"`
 	Format      string               `json:"format,omitempty"`
 	Tags        []string             `json:"tags,omitempty"`
@@ -0,0 +1,35 @@
+package models
+
+import "fmt"
+
+// ValidationError reports a request that is well-formed but invalid for the
+// dataset it targets, such as an unknown field or an incompatible schema
+type ValidationError struct {
+	Message string
+}
+
+// NewValidationError creates a new validation error with a formatted message
+func NewValidationError(format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// NotFoundError reports a record a request refers to that does not exist, such
+// as a missing dataset
+type NotFoundError struct {
+	Message string
+}
+
+// NewNotFoundError creates a new not-found error with a formatted message
+func NewNotFoundError(format string, args ...interface{}) *NotFoundError {
+	return &NotFoundError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,72 @@
+package models
+
+// SchemaChangeKind represents a kind of change between two dataset schemas
+type SchemaChangeKind string
+
+const (
+	SchemaFieldAdded        SchemaChangeKind = "field_added"         // Field declared by the new schema only
+	SchemaFieldDropped      SchemaChangeKind = "field_dropped"       // Field declared by the old schema only
+	SchemaFieldRenamed      SchemaChangeKind = "field_renamed"       // Field whose old name is one of its aliases
+	SchemaTypeWidened       SchemaChangeKind = "type_widened"        // Type every stored value converts to losslessly
+	SchemaTypeNarrowed      SchemaChangeKind = "type_narrowed"       // Type stored values may not convert to
+	SchemaFieldTightened    SchemaChangeKind = "field_tightened"     // Field that becomes required, not nullable or unique
+	SchemaPrimaryKeyChanged SchemaChangeKind = "primary_key_changed" // Primary key on another field
+)
+
+// SchemaChange describes one change between two schemas. Breaking changes are
+// those stored rows may not satisfy; Migration names how the migration plan
+// handles one ("cast", "default" or "drop", or "verify" for constraints the
+// stored rows are checked against), and is empty when the plan does not cover
+// it.
+type SchemaChange struct {
+	Kind      SchemaChangeKind `json:"kind"`
+	Field     string           `json:"field"`
+	From      string           `json:"from,omitempty"`
+	To        string           `json:"to,omitempty"`
+	Breaking  bool             `json:"breaking"`
+	Migration string           `json:"migration,omitempty"`
+	Message   string           `json:"message"`
+}
+
+// SchemaCompatibility reports whether a dataset can move to a new schema.
+// Compatible is set when no change is breaking, and Applicable when the
+// migration plan covers every breaking change.
+type SchemaCompatibility struct {
+	Compatible bool           `json:"compatible"`
+	Applicable bool           `json:"applicable"`
+	Changes    []SchemaChange `json:"changes"`
+}
+
+// CastFailure represents what a cast does with a stored value that does not
+// convert to the new type: reject the migration (the default), store null or
+// store the field default
+type CastFailure string
+
+const (
+	CastReject  CastFailure = "reject"
+	CastNull    CastFailure = "null"
+	CastDefault CastFailure = "default"
+)
+
+// CastRule allows the stored values of a field to be cast to a narrower type
+type CastRule struct {
+	OnError CastFailure `json:"on_error,omitempty"`
+}
+
+// SchemaMigration is the plan that lets a schema change break stored rows.
+// Casts maps fields, by their new name, to the rule casting their values to a
+// narrower type. Defaults lists the fields whose missing or null values are
+// filled from their Default when they become required or not nullable. Drop
+// lists the fields, by their old name, whose values are discarded.
+type SchemaMigration struct {
+	Casts    map[string]CastRule `json:"casts,omitempty"`
+	Defaults []string            `json:"defaults,omitempty"`
+	Drop     []string            `json:"drop,omitempty"`
+}
+
+// SchemaCheckRequest represents a request to check a schema change without
+// applying it
+type SchemaCheckRequest struct {
+	Schema    DataSchema       `json:"schema" binding:"required"`
+	Migration *SchemaMigration `json:"migration,omitempty"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "github.com/google/uuid"
+
+// HypothesisTestType represents a statistical hypothesis test
+type HypothesisTestType string
+
+const (
+	TestOneSampleT        HypothesisTestType = "t_test_one_sample"
+	TestTwoSampleT        HypothesisTestType = "t_test_two_sample"
+	TestPairedT           HypothesisTestType = "t_test_paired"
+	TestChiSquare         HypothesisTestType = "chi_square"
+	TestMannWhitney       HypothesisTestType = "mann_whitney"
+	TestANOVA             HypothesisTestType = "anova"
+	TestKolmogorovSmirnov HypothesisTestType = "kolmogorov_smirnov"
+)
+
+// HypothesisTestRequest represents a request for a hypothesis test. The
+// samples of a test come either from Fields, one sample per field, or from the
+// single field of Fields split into one sample per value of GroupField:
+//
+//   - t_test_one_sample tests the mean of one field
+//   - t_test_two_sample, mann_whitney and kolmogorov_smirnov compare two samples
+//     (kolmogorov_smirnov also tests one field against a normal distribution)
+//   - t_test_paired compares two fields row by row
+//   - anova compares the means of two or more samples
+//   - chi_square tests the independence of two fields of any type
+type HypothesisTestRequest struct {
+	DatasetID  uuid.UUID          `json:"dataset_id" binding:"required"`
+	Test       HypothesisTestType `json:"test" binding:"required"`
+	Fields     []string           `json:"fields" binding:"required,min=1"`
+	GroupField string             `json:"group_field,omitempty"`
+	Filters    []FilterCondition  `json:"filters,omitempty"`
+	Params     map[string]any     `json:"params,omitempty"`
+}
+
+// TestSample describes one sample of a hypothesis test
+type TestSample struct {
+	Name   string   `json:"name"`
+	Count  int      `json:"count"`
+	Mean   *float64 `json:"mean,omitempty"`
+	StdDev *float64 `json:"std_dev,omitempty"`
+	Median *float64 `json:"median,omitempty"`
+}
+
+// HypothesisTestResult represents the result of a hypothesis test. Statistic
+// is t, chi-square, U, F or D depending on the test, and EffectSizeType names
+// the measure of EffectSize: cohens_d, cramers_v, rank_biserial, eta_squared or
+// ks_distance. Values that are undefined for the samples, such as the t
+// statistic of samples without variance, are left out.
+type HypothesisTestResult struct {
+	Test             HypothesisTestType `json:"test"`
+	Fields           []string           `json:"fields"`
+	GroupField       string             `json:"group_field,omitempty"`
+	Alternative      string             `json:"alternative"`
+	Statistic        *float64           `json:"statistic,omitempty"`
+	DegreesOfFreedom []float64          `json:"degrees_of_freedom,omitempty"`
+	PValue           *float64           `json:"p_value,omitempty"`
+	EffectSize       *float64           `json:"effect_size,omitempty"`
+	EffectSizeType   string             `json:"effect_size_type"`
+	Alpha            float64            `json:"alpha"`
+	Significant      bool               `json:"significant"`
+	Samples          []TestSample       `json:"samples"`
+}
@@ -0,0 +1,41 @@
+package models
+
+// InferOptions represents the query parameters of a schema inference upload.
+// The format is detected from the file name or content type when it is not
+// set, Delimiter separates CSV fields (a comma by default) and SampleSize
+// bounds the rows read from the start of the file.
+type InferOptions struct {
+	Format     FileFormat `form:"format"`
+	Delimiter  string     `form:"delimiter"`
+	SampleSize int        `form:"sample_size"`
+}
+
+// ColumnProfile describes the values found in a column of the sampled rows.
+// DateTimeFormats lists the Go reference layouts the datetime values were
+// written in; Missing counts rows that did not have the column at all.
+type ColumnProfile struct {
+	Name            string   `json:"name"`
+	Type            DataType `json:"type"`
+	DateTimeFormats []string `json:"datetime_formats,omitempty"`
+	Values          int64    `json:"values"`
+	Nulls           int64    `json:"nulls"`
+	Missing         int64    `json:"missing"`
+	Distinct        int64    `json:"distinct"`
+	Unique          bool     `json:"unique"`
+}
+
+// SchemaInference represents the schema proposed for an uploaded file, to be
+// edited or used as is when creating a dataset. CandidateKeys lists the
+// integer and string columns that are set and unique in every sampled row, the
+// first of which is proposed as primary key; since a sample cannot show that
+// later rows keep a column unique, no other field is proposed as unique.
+// Truncated is set when the file has more rows than were sampled.
+type SchemaInference struct {
+	Format        FileFormat      `json:"format"`
+	RowsSampled   int64           `json:"rows_sampled"`
+	RowsSkipped   int64           `json:"rows_skipped"`
+	Truncated     bool            `json:"truncated"`
+	Schema        DataSchema      `json:"schema"`
+	Columns       []ColumnProfile `json:"columns"`
+	CandidateKeys []string        `json:"candidate_keys"`
+}
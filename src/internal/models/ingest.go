@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// FileFormat represents the format of a file of rows. Rows can be uploaded as
+// CSV, NDJSON or Parquet and exported in any format but JSON, which names the
+// paged JSON response of a query.
+type FileFormat string
+
+const (
+	FormatJSON    FileFormat = "json"
+	FormatCSV     FileFormat = "csv"
+	FormatNDJSON  FileFormat = "ndjson"
+	FormatParquet FileFormat = "parquet"
+	FormatArrow   FileFormat = "arrow"
+)
+
+// IngestMode represents how uploaded rows are written: insert rejects rows
+// whose primary key is already stored, upsert replaces the stored rows
+type IngestMode string
+
+const (
+	IngestInsert IngestMode = "insert"
+	IngestUpsert IngestMode = "upsert"
+)
+
+// IngestOptions represents the query parameters of a row upload. The format is
+// detected from the file name or content type when it is not set, Delimiter
+// separates CSV fields (a comma by default) and DryRun validates the rows
+// without storing them.
+type IngestOptions struct {
+	Format    FileFormat `form:"format"`
+	Mode      IngestMode `form:"mode"`
+	Delimiter string     `form:"delimiter"`
+	DryRun    bool       `form:"dry_run"`
+}
+
+// RowError reports why a row of an upload was rejected. Row is the position of
+// the row in the upload starting at 1: the record after the header of a CSV
+// file, the line of an NDJSON file or the row of a Parquet file.
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *RowError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("row %d: field %q: %s", e.Row, e.Field, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// IngestResult represents the outcome of a row upload. Accepted rows are
+// stored unless the upload was a dry run; Errors lists the rejected rows, up to
+// a limit past which ErrorsTruncated is set. Version is the dataset version the
+// accepted rows were stored as. An upload failing after some of its rows were
+// stored reports them with the failure set in Error.
+type IngestResult struct {
+	DatasetID       uuid.UUID  `json:"dataset_id"`
+	Format          FileFormat `json:"format"`
+	Mode            IngestMode `json:"mode"`
+	DryRun          bool       `json:"dry_run"`
+	RowsReceived    int64      `json:"rows_received"`
+	RowsAccepted    int64      `json:"rows_accepted"`
+	RowsRejected    int64      `json:"rows_rejected"`
+	Errors          []RowError `json:"errors"`
+	ErrorsTruncated bool       `json:"errors_truncated,omitempty"`
+	RowCount        int64      `json:"row_count"`
+	Version         int64      `json:"version,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
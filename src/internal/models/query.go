@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // FilterOperator represents a filter operator
 type FilterOperator string
@@ -15,7 +19,7 @@ const (
 	FilterIN     FilterOperator = "in"     // In array
 	FilterNIN    FilterOperator = "nin"    // Not in array
 	FilterLIKE   FilterOperator = "like"   // Like (string pattern)
-	FilterREGEX  FilterOperator = "regex"  // Regular expression
+	FilterREGEX  FilterOperator = "regex"  // Regular expression (the syntax Go and PostgreSQL share, ASCII classes)
 	FilterEXISTS FilterOperator = "exists" // Field exists
 )
 
@@ -40,15 +44,24 @@ type SortField struct {
 	Direction SortDirection `json:"direction" binding:"required"`
 }
 
-// QueryRequest represents a data query request
+// QueryRequest represents a data query request. Pages are selected with Offset
+// or with the Cursor returned as next_cursor by the previous page, which stays
+// consistent as rows are written. Format selects the response: the paged JSON
+// response by default, or the matching rows streamed as a file of another
+// format. AsOfVersion or AsOf query the dataset as it was at a version, or at
+// the last version created by a point in time.
 type QueryRequest struct {
-	DatasetID  uuid.UUID         `json:"dataset_id" binding:"required"`
-	Fields     []string          `json:"fields,omitempty"`
-	Filters    []FilterCondition `json:"filters,omitempty"`
-	Sort       []SortField       `json:"sort,omitempty"`
-	Limit      int               `json:"limit,omitempty"`
-	Offset     int               `json:"offset,omitempty"`
-	IncludeRaw bool              `json:"include_raw,omitempty"`
+	DatasetID   uuid.UUID         `json:"dataset_id" binding:"required"`
+	Fields      []string          `json:"fields,omitempty"`
+	Filters     []FilterCondition `json:"filters,omitempty"`
+	Sort        []SortField       `json:"sort,omitempty"`
+	Limit       int               `json:"limit,omitempty"`
+	Offset      int               `json:"offset,omitempty"`
+	Cursor      string            `json:"cursor,omitempty"`
+	IncludeRaw  bool              `json:"include_raw,omitempty"`
+	Format      FileFormat        `json:"format,omitempty"`
+	AsOfVersion int64             `json:"as_of_version,omitempty"`
+	AsOf        *time.Time        `json:"as_of,omitempty"`
 }
 
 // TransformType represents a data transformation type
@@ -84,18 +97,25 @@ type TransformRequest struct {
 type AggregationType string
 
 const (
-	AggregationCount AggregationType = "count"
-	AggregationSum   AggregationType = "sum"
-	AggregationAvg   AggregationType = "avg"
-	AggregationMin   AggregationType = "min"
-	AggregationMax   AggregationType = "max"
+	AggregationCount         AggregationType = "count"
+	AggregationSum           AggregationType = "sum"
+	AggregationAvg           AggregationType = "avg"
+	AggregationMin           AggregationType = "min"
+	AggregationMax           AggregationType = "max"
+	AggregationCountDistinct AggregationType = "count_distinct"
+	AggregationMedian        AggregationType = "median"
+	AggregationStdDev        AggregationType = "stddev"
+	AggregationPercentile    AggregationType = "percentile"
 )
 
-// AggregationField represents an aggregation field
+// AggregationField represents an aggregation field. Field may be omitted for
+// count, which then counts rows. Percentile, between 0 and 100, is required by
+// the percentile aggregation.
 type AggregationField struct {
 	Type       AggregationType `json:"type" binding:"required"`
 	Field      string          `json:"field"`
 	OutputName string          `json:"output_name" binding:"required"`
+	Percentile *float64        `json:"percentile,omitempty"`
 }
 
 // AggregateRequest represents a data aggregation request
@@ -142,6 +162,8 @@ type QueryResponse struct {
 	Total      int64            `json:"total"`
 	Limit      int              `json:"limit,omitempty"`
 	Offset     int              `json:"offset,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	AsOfVersion int64           `json:"as_of_version,omitempty"`
 	RawSQL     string           `json:"raw_sql,omitempty"`
 	ExecutionTime float64       `json:"execution_time"`
 }
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RegressionMethod represents a regression method
+type RegressionMethod string
+
+const (
+	RegressionOLS      RegressionMethod = "ols"
+	RegressionRidge    RegressionMethod = "ridge"
+	RegressionLogistic RegressionMethod = "logistic"
+)
+
+// RegressionSpec describes a regression of Target on the numeric Features over
+// the rows matching Filters. ols and ridge fit a numeric target. logistic
+// models the probability of a positive class: true for a boolean target, any
+// nonzero value for a numeric one, and the positive_class param for targets of
+// any type.
+type RegressionSpec struct {
+	Method   RegressionMethod  `json:"method" binding:"required"`
+	Target   string            `json:"target" binding:"required"`
+	Features []string          `json:"features" binding:"required,min=1"`
+	Filters  []FilterCondition `json:"filters,omitempty"`
+	Params   map[string]any    `json:"params,omitempty"`
+}
+
+// RegressionRequest represents a request to fit a regression on a dataset
+type RegressionRequest struct {
+	DatasetID uuid.UUID `json:"dataset_id" binding:"required"`
+	RegressionSpec
+}
+
+// Coefficient is a fitted coefficient. StdError, Statistic and PValue test it
+// against zero, with a t statistic for ols and a z statistic for logistic;
+// they are left out of penalized fits, whose estimates are biased.
+type Coefficient struct {
+	Name      string   `json:"name"`
+	Estimate  float64  `json:"estimate"`
+	StdError  *float64 `json:"std_error,omitempty"`
+	Statistic *float64 `json:"statistic,omitempty"`
+	PValue    *float64 `json:"p_value,omitempty"`
+}
+
+// RegressionMetrics measures how well a model fits a set of rows. ols and
+// ridge report the goodness of fit and errors of the predicted values;
+// logistic reports the log loss of the predicted probabilities and the
+// classification metrics of the predicted classes, with the positive class
+// as the relevant one.
+type RegressionMetrics struct {
+	Count            int      `json:"count"`
+	RSquared         *float64 `json:"r_squared,omitempty"`
+	AdjustedRSquared *float64 `json:"adjusted_r_squared,omitempty"`
+	RMSE             *float64 `json:"rmse,omitempty"`
+	MAE              *float64 `json:"mae,omitempty"`
+	LogLoss          *float64 `json:"log_loss,omitempty"`
+	Accuracy         *float64 `json:"accuracy,omitempty"`
+	Precision        *float64 `json:"precision,omitempty"`
+	Recall           *float64 `json:"recall,omitempty"`
+	F1               *float64 `json:"f1,omitempty"`
+}
+
+// RegressionResult represents a fitted regression. Metrics are measured on
+// the training rows and ValidationMetrics on the rows held out by the
+// validation_split param. PositiveClass and Threshold describe how logistic
+// regression labels rows, and Converged is false when its iterations stopped
+// at the limit.
+type RegressionResult struct {
+	Method            RegressionMethod   `json:"method"`
+	Target            string             `json:"target"`
+	TargetType        DataType           `json:"target_type"`
+	Features          []string           `json:"features"`
+	Intercept         *Coefficient       `json:"intercept,omitempty"`
+	Coefficients      []Coefficient      `json:"coefficients"`
+	PositiveClass     string             `json:"positive_class,omitempty"`
+	Threshold         *float64           `json:"threshold,omitempty"`
+	Iterations        int                `json:"iterations,omitempty"`
+	Converged         bool               `json:"converged"`
+	Metrics           RegressionMetrics  `json:"metrics"`
+	ValidationMetrics *RegressionMetrics `json:"validation_metrics,omitempty"`
+}
+
+// TrainedModel is a stored regression that predicts the target of new rows
+// from their features
+type TrainedModel struct {
+	ID          uuid.UUID        `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	DatasetID   uuid.UUID        `json:"dataset_id"`
+	Spec        RegressionSpec   `json:"spec"`
+	Model       RegressionResult `json:"model"`
+	CreatedBy   uuid.UUID        `json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// TrainModelRequest represents a request to train and store a model
+type TrainModelRequest struct {
+	Name        string    `json:"name" binding:"required"`
+	Description string    `json:"description,omitempty"`
+	DatasetID   uuid.UUID `json:"dataset_id" binding:"required"`
+	RegressionSpec
+}
+
+// PredictRequest represents a request to predict with a trained model, either
+// the rows of a dataset matching Filters or the inline Rows. Values of the
+// model's target in the rows are compared with the predictions.
+type PredictRequest struct {
+	ModelID   uuid.UUID         `json:"model_id" binding:"required"`
+	DatasetID *uuid.UUID        `json:"dataset_id,omitempty"`
+	Filters   []FilterCondition `json:"filters,omitempty"`
+	Rows      []map[string]any  `json:"rows,omitempty"`
+	Limit     int               `json:"limit,omitempty" binding:"omitempty,min=1"`
+}
+
+// Prediction is the prediction for a row. Value is the predicted target of
+// ols and ridge, and 1 or 0 for the positive or negative class of logistic,
+// whose Probability is that of the positive class. Rows missing a feature
+// have no prediction.
+type Prediction struct {
+	Row         map[string]any `json:"row"`
+	Value       *float64       `json:"value"`
+	Probability *float64       `json:"probability,omitempty"`
+}
+
+// PredictionResult represents the predictions of a model. Count is the
+// number of rows predicted, of which Predictions lists the first ones up to
+// the limit. Metrics compare the predictions with the target values of the
+// rows that have one.
+type PredictionResult struct {
+	ModelID     uuid.UUID          `json:"model_id"`
+	DatasetID   *uuid.UUID         `json:"dataset_id,omitempty"`
+	Count       int                `json:"count"`
+	Predictions []Prediction       `json:"predictions"`
+	Metrics     *RegressionMetrics `json:"metrics,omitempty"`
+}
+
+// ModelMetrics represents the metrics of a trained model
+type ModelMetrics struct {
+	ModelID           uuid.UUID          `json:"model_id"`
+	Method            RegressionMethod   `json:"method"`
+	Metrics           RegressionMetrics  `json:"metrics"`
+	ValidationMetrics *RegressionMetrics `json:"validation_metrics,omitempty"`
+}
@@ -0,0 +1,106 @@
+package models
+
+// The types below document the Params accepted by each TransformType. Params are
+// decoded strictly, so unknown keys and values of the wrong type are rejected.
+
+// SelectParams keeps only the listed fields, in the given order.
+//
+//	{"type": "select", "params": {"fields": ["id", "price"]}}
+type SelectParams struct {
+	Fields []string `json:"fields"`
+}
+
+// RenameParams renames fields; keys are current names and values the new names.
+//
+//	{"type": "rename", "params": {"mapping": {"price": "unit_price"}}}
+type RenameParams struct {
+	Mapping map[string]string `json:"mapping"`
+}
+
+// FilterParams keeps the rows matching the conditions. Mode is "and" (default)
+// or "or" and controls how the conditions are combined.
+//
+//	{"type": "filter", "params": {"conditions": [{"field": "price", "operator": "gt", "value": 10}]}}
+type FilterParams struct {
+	Conditions []FilterCondition `json:"conditions"`
+	Mode       string            `json:"mode,omitempty"`
+}
+
+// SortParams orders rows by the given fields. Null values always sort last.
+//
+//	{"type": "sort", "params": {"fields": [{"field": "price", "direction": "desc"}]}}
+type SortParams struct {
+	Fields []SortField `json:"fields"`
+}
+
+// AddColumnParams appends a computed field. Exactly one of Expression and Value
+// must be given. Expressions support field references (bare names or "quoted
+// names"), number, 'string', true, false and null literals, the operators
+// + - * / % (+ concatenates when either side is a string), comparisons
+// = != < <= > >=, the keywords and/or/not, parentheses, and the functions
+// lower, upper, trim, length, concat, abs, round, floor, ceil, sqrt and coalesce.
+// Type is optional and inferred from the computed values when omitted.
+//
+//	{"type": "add_column", "params": {"name": "total", "expression": "price * quantity"}}
+type AddColumnParams struct {
+	Name       string   `json:"name"`
+	Expression string   `json:"expression,omitempty"`
+	Value      any      `json:"value,omitempty"`
+	Type       DataType `json:"type,omitempty"`
+}
+
+// CastParams converts fields to new types; keys are field names and values the
+// target types. OnError is "fail" (default) or "null", which stores null for
+// values that cannot be converted.
+//
+//	{"type": "cast", "params": {"fields": {"quantity": "integer"}, "on_error": "null"}}
+type CastParams struct {
+	Fields  map[string]DataType `json:"fields"`
+	OnError string              `json:"on_error,omitempty"`
+}
+
+// DropParams removes the listed fields.
+//
+//	{"type": "drop", "params": {"fields": ["internal_id"]}}
+type DropParams struct {
+	Fields []string `json:"fields"`
+}
+
+// FillParams replaces null values. Strategy is "value" (default, uses Value),
+// "forward", "backward", "mean", "median" or "mode". Fields defaults to every field.
+//
+//	{"type": "fill", "params": {"fields": ["price"], "strategy": "mean"}}
+type FillParams struct {
+	Fields   []string `json:"fields,omitempty"`
+	Strategy string   `json:"strategy,omitempty"`
+	Value    any      `json:"value,omitempty"`
+}
+
+// ReplaceParams replaces values of the listed fields. Either Replacements, whose
+// From values are matched exactly, or Pattern, a regular expression applied to
+// string values with Replacement as template, must be given.
+//
+//	{"type": "replace", "params": {"fields": ["country"], "replacements": [{"from": "UK", "to": "GB"}]}}
+type ReplaceParams struct {
+	Fields       []string           `json:"fields"`
+	Replacements []ValueReplacement `json:"replacements,omitempty"`
+	Pattern      string             `json:"pattern,omitempty"`
+	Replacement  string             `json:"replacement,omitempty"`
+}
+
+// ValueReplacement maps one value to another
+type ValueReplacement struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// NormalizeParams rescales numeric fields. Method is "minmax" (default, to
+// [Min, Max] which defaults to [0, 1]) or "zscore".
+//
+//	{"type": "normalize", "params": {"fields": ["price"], "method": "zscore"}}
+type NormalizeParams struct {
+	Fields []string `json:"fields"`
+	Method string   `json:"method,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VersionChange represents the change that created a dataset version
+type VersionChange string
+
+const (
+	VersionBaseline VersionChange = "baseline" // State of a dataset when versioning began
+	VersionCreate   VersionChange = "create"   // Dataset created
+	VersionUpdate   VersionChange = "update"   // Schema or metadata updated
+	VersionInsert   VersionChange = "insert"   // Rows inserted
+	VersionUpsert   VersionChange = "upsert"   // Rows inserted or replaced
+	VersionDelete   VersionChange = "delete"   // Rows deleted
+	VersionTruncate VersionChange = "truncate" // Every row deleted
+	VersionRollback VersionChange = "rollback" // Dataset restored to an earlier version
+)
+
+// DatasetVersion is an immutable snapshot of a dataset, created by every change
+// to its schema, metadata or rows. Versions are numbered from 1 per dataset;
+// RestoredVersion names the version a rollback restored.
+type DatasetVersion struct {
+	DatasetID       uuid.UUID      `json:"dataset_id"`
+	Version         int64          `json:"version"`
+	Change          VersionChange  `json:"change"`
+	RestoredVersion int64          `json:"restored_version,omitempty"`
+	Name            string         `json:"name"`
+	Description     string         `json:"description,omitempty"`
+	Schema          DataSchema     `json:"schema"`
+	Source          string         `json:"source,omitempty"`
+	Format          string         `json:"format,omitempty"`
+	Tags            []string       `json:"tags,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+	RowCount        int64          `json:"row_count"`
+	Size            int64          `json:"size"`
+	RowsChanged     int64          `json:"rows_changed"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// FieldChange represents a field whose definition differs between two schemas
+type FieldChange struct {
+	Name   string    `json:"name"`
+	Before DataField `json:"before"`
+	After  DataField `json:"after"`
+}
+
+// SchemaDiff lists the fields added, removed and changed between two schemas,
+// and the primary keys when they differ
+type SchemaDiff struct {
+	Added            []DataField   `json:"added,omitempty"`
+	Removed          []DataField   `json:"removed,omitempty"`
+	Changed          []FieldChange `json:"changed,omitempty"`
+	PrimaryKeyBefore string        `json:"primary_key_before,omitempty"`
+	PrimaryKeyAfter  string        `json:"primary_key_after,omitempty"`
+}
+
+// RowChangeType represents how a row differs between two versions
+type RowChangeType string
+
+const (
+	RowAdded    RowChangeType = "added"
+	RowRemoved  RowChangeType = "removed"
+	RowModified RowChangeType = "modified"
+)
+
+// RowChange represents a row that differs between two versions, identified by
+// its stored key: the primary key value, or a generated key for datasets
+// without a primary key
+type RowChange struct {
+	Key    string         `json:"key"`
+	Change RowChangeType  `json:"change"`
+	Before map[string]any `json:"before,omitempty"`
+	After  map[string]any `json:"after,omitempty"`
+}
+
+// VersionDiff represents the differences between two versions of a dataset.
+// The counts cover every changed row; Rows lists the first of them in row
+// order, up to the requested limit.
+type VersionDiff struct {
+	DatasetID    uuid.UUID   `json:"dataset_id"`
+	From         int64       `json:"from"`
+	To           int64       `json:"to"`
+	Schema       SchemaDiff  `json:"schema"`
+	RowsAdded    int64       `json:"rows_added"`
+	RowsRemoved  int64       `json:"rows_removed"`
+	RowsModified int64       `json:"rows_modified"`
+	Rows         []RowChange `json:"rows"`
+}
+
+// VersionDiffOptions represents the query parameters of a version diff. To
+// defaults to the latest version and Limit bounds the rows listed.
+type VersionDiffOptions struct {
+	From  int64 `form:"from" binding:"required"`
+	To    int64 `form:"to"`
+	Limit int   `form:"limit"`
+}
+
+// RollbackRequest represents a request to restore a dataset to an earlier
+// version. The rollback is recorded as a new version.
+type RollbackRequest struct {
+	Version int64 `json:"version" binding:"required"`
+}
@@ -0,0 +1,41 @@
+package models
+
+// ImageFormat represents the format of a rendered image
+type ImageFormat string
+
+const (
+	ImageSVG ImageFormat = "svg"
+	ImagePNG ImageFormat = "png"
+)
+
+// ImageOptions sets how an image is rendered. Width and height are in pixels,
+// 800 by 500 by default, and the title is drawn above the plot.
+type ImageOptions struct {
+	Format ImageFormat `json:"format,omitempty"`
+	Width  int         `json:"width,omitempty"`
+	Height int         `json:"height,omitempty"`
+	Title  string      `json:"title,omitempty"`
+}
+
+// ChartRequest represents a request to render a chart: a line chart of a time
+// series, with one line per group, or the histograms or box plots of a
+// statistics request of that type
+type ChartRequest struct {
+	TimeSeries *TimeSeriesRequest `json:"time_series,omitempty"`
+	Statistics *StatisticsRequest `json:"statistics,omitempty"`
+	ImageOptions
+}
+
+// HeatmapRequest represents a request to render the matrix of a correlation or
+// of a partial correlation as a heatmap
+type HeatmapRequest struct {
+	Correlation        *CorrelationRequest        `json:"correlation,omitempty"`
+	PartialCorrelation *PartialCorrelationRequest `json:"partial_correlation,omitempty"`
+	ImageOptions
+}
+
+// Image is a rendered image
+type Image struct {
+	ContentType string
+	Data        []byte
+}
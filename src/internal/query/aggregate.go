@@ -0,0 +1,373 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Aggregate is a validated group-by aggregation. Output rows hold the group-by
+// fields followed by one field per aggregation, named by its OutputName; Having
+// and Sort refer to those output names.
+type Aggregate struct {
+	groupBy      []int
+	aggregations []aggregation
+	having       *Filter
+	sortIndices  []int
+	sortFields   []models.SortField
+	limit        int
+	fields       []models.DataField
+}
+
+// aggregation is a compiled aggregation over one source field
+type aggregation struct {
+	kind       models.AggregationType
+	index      int
+	source     models.DataField
+	percentile float64
+}
+
+// CompileAggregate validates an aggregation request against the fields of the
+// source rows and infers the output schema
+func CompileAggregate(req *models.AggregateRequest, fields []models.DataField) (*Aggregate, error) {
+	if len(req.Aggregations) == 0 {
+		return nil, models.NewValidationError("aggregate requires at least one aggregation")
+	}
+	if req.Limit < 0 {
+		return nil, models.NewValidationError("limit must not be negative")
+	}
+	a := &Aggregate{limit: req.Limit}
+
+	// Group-by fields keep their source definition
+	outputs := make(map[string]bool)
+	if len(req.GroupBy) > 0 {
+		indices, err := lookupFields(fields, req.GroupBy)
+		if err != nil {
+			return nil, models.NewValidationError("group_by: %v", err)
+		}
+		a.groupBy = indices
+		for _, index := range indices {
+			field := fields[index]
+			field.Unique = len(indices) == 1
+			a.fields = append(a.fields, field)
+			outputs[field.Name] = true
+		}
+	}
+
+	// Aggregations
+	for i, spec := range req.Aggregations {
+		agg, field, err := compileAggregation(spec, fields)
+		if err != nil {
+			return nil, models.NewValidationError("aggregations[%d] (%s): %v", i, spec.Type, err)
+		}
+		if spec.OutputName == "" {
+			return nil, models.NewValidationError("aggregations[%d] (%s): output_name is required", i, spec.Type)
+		}
+		if outputs[spec.OutputName] {
+			return nil, models.NewValidationError("aggregations[%d] (%s): output name %q is already used", i, spec.Type, spec.OutputName)
+		}
+		outputs[spec.OutputName] = true
+		a.aggregations = append(a.aggregations, agg)
+		a.fields = append(a.fields, field)
+	}
+
+	// Having and sort apply to output rows
+	if len(req.Having) > 0 {
+		having, err := CompileFilter(req.Having, a.fields, FilterModeAnd)
+		if err != nil {
+			return nil, models.NewValidationError("having: %v", err)
+		}
+		a.having = having
+	}
+	if len(req.Sort) > 0 {
+		indices, err := lookupSortFields(a.fields, req.Sort)
+		if err != nil {
+			return nil, models.NewValidationError("sort: %v", err)
+		}
+		a.sortIndices = indices
+		a.sortFields = req.Sort
+	}
+
+	return a, nil
+}
+
+// compileAggregation checks that an aggregation applies to its source field and
+// returns the output field it produces
+func compileAggregation(spec models.AggregationField, fields []models.DataField) (aggregation, models.DataField, error) {
+	agg := aggregation{kind: spec.Type, index: -1}
+	output := models.DataField{Name: spec.OutputName, Required: true, Nullable: true}
+
+	if spec.Field == "" {
+		if spec.Type != models.AggregationCount {
+			return agg, output, fmt.Errorf("field is required")
+		}
+	} else {
+		agg.index = fieldIndex(fields, spec.Field)
+		if agg.index < 0 {
+			return agg, output, fmt.Errorf("unknown field %q", spec.Field)
+		}
+		agg.source = fields[agg.index]
+	}
+	if spec.Percentile != nil && spec.Type != models.AggregationPercentile {
+		return agg, output, fmt.Errorf("percentile is only allowed with the percentile aggregation")
+	}
+
+	switch spec.Type {
+	case models.AggregationCount, models.AggregationCountDistinct:
+		output.Type = models.DataTypeInteger
+		output.Nullable = false
+
+	case models.AggregationSum:
+		if !isNumeric(agg.source.Type) {
+			return agg, output, fmt.Errorf("field %q is %s, not numeric", agg.source.Name, agg.source.Type)
+		}
+		output.Type = agg.source.Type
+
+	case models.AggregationAvg, models.AggregationMedian, models.AggregationStdDev, models.AggregationPercentile:
+		if !isNumeric(agg.source.Type) {
+			return agg, output, fmt.Errorf("field %q is %s, not numeric", agg.source.Name, agg.source.Type)
+		}
+		output.Type = models.DataTypeFloat
+		switch spec.Type {
+		case models.AggregationMedian:
+			agg.percentile = 50
+		case models.AggregationPercentile:
+			if spec.Percentile == nil || *spec.Percentile < 0 || *spec.Percentile > 100 {
+				return agg, output, fmt.Errorf("percentile must be between 0 and 100")
+			}
+			agg.percentile = *spec.Percentile
+		}
+
+	case models.AggregationMin, models.AggregationMax:
+		if !isOrdered(agg.source.Type) {
+			return agg, output, fmt.Errorf("field %q is %s, which has no order", agg.source.Name, agg.source.Type)
+		}
+		output.Type = agg.source.Type
+
+	default:
+		return agg, output, fmt.Errorf("unknown aggregation type %q", spec.Type)
+	}
+
+	return agg, output, nil
+}
+
+// Fields returns the fields of the aggregation output
+func (a *Aggregate) Fields() []models.DataField {
+	return append([]models.DataField(nil), a.fields...)
+}
+
+// Apply aggregates a frame with the fields the aggregation was compiled for.
+// Groups appear in the order their first row appears unless Sort is given; rows
+// with null group-by values form their own group. Without group-by fields a
+// single row is returned, even for an empty frame.
+func (a *Aggregate) Apply(frame *Frame) (*Frame, error) {
+	groups := a.groups(frame)
+
+	out := &Frame{
+		Fields:  a.Fields(),
+		Columns: make([][]interface{}, len(a.fields)),
+		rows:    len(groups),
+	}
+	for i, index := range a.groupBy {
+		column := make([]interface{}, len(groups))
+		for g, members := range groups {
+			column[g] = frame.Columns[index][members[0]]
+		}
+		out.Columns[i] = column
+	}
+	for j, agg := range a.aggregations {
+		column := make([]interface{}, len(groups))
+		for g, members := range groups {
+			var values []interface{}
+			if agg.index >= 0 {
+				values = make([]interface{}, len(members))
+				for k, r := range members {
+					values[k] = frame.Columns[agg.index][r]
+				}
+			}
+			column[g] = agg.compute(members, values)
+		}
+		out.Columns[len(a.groupBy)+j] = column
+	}
+
+	if a.having != nil {
+		out = a.having.Apply(out)
+	}
+	if len(a.sortIndices) > 0 {
+		out = sortFrame(out, a.sortIndices, a.sortFields)
+	}
+	if a.limit > 0 && out.Len() > a.limit {
+		indices := make([]int, a.limit)
+		for i := range indices {
+			indices[i] = i
+		}
+		out = out.take(indices)
+	}
+	return out, nil
+}
+
+// groups partitions the rows of a frame by their group-by values and returns
+// the row positions of each group
+func (a *Aggregate) groups(frame *Frame) [][]int {
+	if len(a.groupBy) == 0 {
+		all := make([]int, frame.Len())
+		for r := range all {
+			all[r] = r
+		}
+		return [][]int{all}
+	}
+
+	var groups [][]int
+	positions := make(map[string]int)
+	parts := make([]string, len(a.groupBy))
+	for r := 0; r < frame.Len(); r++ {
+		for i, index := range a.groupBy {
+			parts[i] = GroupKey(frame.Columns[index][r], frame.Fields[index].Type)
+		}
+		key := strings.Join(parts, "\x1f")
+		g, ok := positions[key]
+		if !ok {
+			g = len(groups)
+			positions[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], r)
+	}
+	return groups
+}
+
+// GroupKey encodes a value so that equal values of a field type share a key
+func GroupKey(value interface{}, t models.DataType) string {
+	if value == nil {
+		return "\x00"
+	}
+	if converted, err := ConvertValue(value, t); err == nil {
+		if tm, ok := converted.(time.Time); ok {
+			return "@" + strconv.FormatInt(tm.UnixNano(), 10)
+		}
+		return "=" + FormatValue(converted)
+	}
+	return "?" + FormatValue(value)
+}
+
+// compute evaluates the aggregation over the values of one group; members are
+// the group's row positions, used to count rows
+func (agg aggregation) compute(members []int, values []interface{}) interface{} {
+	switch agg.kind {
+	case models.AggregationCount:
+		if agg.index < 0 {
+			return int64(len(members))
+		}
+		var count int64
+		for _, value := range values {
+			if value != nil {
+				count++
+			}
+		}
+		return count
+
+	case models.AggregationCountDistinct:
+		seen := make(map[string]bool)
+		for _, value := range values {
+			if value != nil {
+				seen[GroupKey(value, agg.source.Type)] = true
+			}
+		}
+		return int64(len(seen))
+
+	case models.AggregationMin, models.AggregationMax:
+		var best, bestConverted interface{}
+		for _, value := range values {
+			converted, err := ConvertValue(value, agg.source.Type)
+			if err != nil || converted == nil {
+				continue
+			}
+			cmp := 0
+			if bestConverted != nil {
+				cmp = compareNative(converted, bestConverted)
+			}
+			if bestConverted == nil || agg.kind == models.AggregationMin && cmp < 0 || agg.kind == models.AggregationMax && cmp > 0 {
+				best, bestConverted = value, converted
+			}
+		}
+		return best
+	}
+
+	// Numeric aggregations ignore null and non-numeric values
+	if agg.kind == models.AggregationSum && agg.source.Type == models.DataTypeInteger {
+		var sum int64
+		found := false
+		for _, value := range values {
+			if i, ok := ToInt(value); ok {
+				sum += i
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+		return sum
+	}
+
+	numbers := make([]float64, 0, len(values))
+	for _, value := range values {
+		if f, ok := numeric(value); ok {
+			numbers = append(numbers, f)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+
+	switch agg.kind {
+	case models.AggregationSum:
+		var sum float64
+		for _, f := range numbers {
+			sum += f
+		}
+		return sum
+	case models.AggregationAvg:
+		return mean(numbers)
+	case models.AggregationStdDev:
+		if len(numbers) < 2 {
+			return nil
+		}
+		return stdDev(numbers)
+	default:
+		sort.Float64s(numbers)
+		return percentile(numbers, agg.percentile)
+	}
+}
+
+// mean returns the arithmetic mean of numbers
+func mean(numbers []float64) float64 {
+	var sum float64
+	for _, f := range numbers {
+		sum += f
+	}
+	return sum / float64(len(numbers))
+}
+
+// stdDev returns the sample standard deviation of at least two numbers
+func stdDev(numbers []float64) float64 {
+	m := mean(numbers)
+	var squares float64
+	for _, f := range numbers {
+		squares += (f - m) * (f - m)
+	}
+	return math.Sqrt(squares / float64(len(numbers)-1))
+}
+
+// percentile returns the p-th percentile (0-100) of sorted numbers, linearly
+// interpolating between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var salesFields = []models.DataField{
+	{Name: "region", Type: models.DataTypeString},
+	{Name: "units", Type: models.DataTypeInteger},
+	{Name: "price", Type: models.DataTypeFloat},
+	{Name: "sold_at", Type: models.DataTypeDateTime},
+}
+
+func salesFrame() *Frame {
+	return NewFrame(salesFields, []map[string]interface{}{
+		{"region": "north", "units": int64(2), "price": 10.0, "sold_at": "2024-01-02T00:00:00Z"},
+		{"region": "south", "units": int64(5), "price": 4.0, "sold_at": "2024-01-01T00:00:00Z"},
+		{"region": "north", "units": int64(4), "price": 10.0, "sold_at": "2024-01-05T00:00:00Z"},
+		{"region": "north", "units": nil, "price": 7.0, "sold_at": "2024-01-03T00:00:00+02:00"},
+		{"region": nil, "units": int64(1), "price": 1.0, "sold_at": nil},
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	percentile := 25.0
+
+	// Test Case 1: Every aggregation type over grouped rows
+	t.Run("Group By", func(t *testing.T) {
+		plan, err := CompileAggregate(&models.AggregateRequest{
+			GroupBy: []string{"region"},
+			Aggregations: []models.AggregationField{
+				{Type: models.AggregationCount, OutputName: "rows"},
+				{Type: models.AggregationCount, Field: "units", OutputName: "with_units"},
+				{Type: models.AggregationCountDistinct, Field: "price", OutputName: "prices"},
+				{Type: models.AggregationSum, Field: "units", OutputName: "units"},
+				{Type: models.AggregationAvg, Field: "price", OutputName: "avg_price"},
+				{Type: models.AggregationMin, Field: "sold_at", OutputName: "first_sale"},
+				{Type: models.AggregationMax, Field: "price", OutputName: "max_price"},
+				{Type: models.AggregationMedian, Field: "price", OutputName: "median_price"},
+				{Type: models.AggregationStdDev, Field: "units", OutputName: "units_sd"},
+				{Type: models.AggregationPercentile, Field: "price", OutputName: "p25", Percentile: &percentile},
+			},
+		}, salesFields)
+		require.NoError(t, err)
+
+		frame, err := plan.Apply(salesFrame())
+		require.NoError(t, err)
+
+		types := make([]models.DataType, len(frame.Fields))
+		for i, field := range frame.Fields {
+			types[i] = field.Type
+		}
+		assert.Equal(t, []models.DataType{
+			models.DataTypeString, models.DataTypeInteger, models.DataTypeInteger, models.DataTypeInteger,
+			models.DataTypeInteger, models.DataTypeFloat, models.DataTypeDateTime, models.DataTypeFloat,
+			models.DataTypeFloat, models.DataTypeFloat, models.DataTypeFloat,
+		}, types)
+
+		rows := frame.Rows()
+		require.Len(t, rows, 3)
+		assert.Equal(t, map[string]interface{}{
+			"region": "north", "rows": int64(3), "with_units": int64(2), "prices": int64(2), "units": int64(6),
+			"avg_price": 9.0, "first_sale": "2024-01-02T00:00:00Z", "max_price": 10.0, "median_price": 10.0,
+			"units_sd": 1.4142135623730951, "p25": 8.5,
+		}, rows[0])
+		assert.Equal(t, nil, rows[1]["units_sd"])
+		assert.Equal(t, nil, rows[2]["region"])
+	})
+
+	// Test Case 2: Having, sort and limit apply to output names
+	t.Run("Having Sort Limit", func(t *testing.T) {
+		plan, err := CompileAggregate(&models.AggregateRequest{
+			GroupBy:      []string{"region"},
+			Aggregations: []models.AggregationField{{Type: models.AggregationSum, Field: "price", OutputName: "revenue"}},
+			Having:       []models.FilterCondition{{Field: "revenue", Operator: models.FilterGTE, Value: 2}},
+			Sort:         []models.SortField{{Field: "revenue", Direction: models.SortAsc}},
+			Limit:        1,
+		}, salesFields)
+		require.NoError(t, err)
+
+		frame, err := plan.Apply(salesFrame())
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"region": "south", "revenue": 4.0}}, frame.Rows())
+	})
+
+	// Test Case 3: Without group-by an empty frame still yields one row
+	t.Run("Empty Input", func(t *testing.T) {
+		plan, err := CompileAggregate(&models.AggregateRequest{
+			Aggregations: []models.AggregationField{
+				{Type: models.AggregationCount, OutputName: "rows"},
+				{Type: models.AggregationSum, Field: "units", OutputName: "units"},
+			},
+		}, salesFields)
+		require.NoError(t, err)
+
+		frame, err := plan.Apply(NewFrame(salesFields, nil))
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"rows": int64(0), "units": nil}}, frame.Rows())
+	})
+
+	// Test Case 4: Invalid requests are rejected with validation errors
+	invalid := map[string]models.AggregateRequest{
+		"No Aggregations":      {GroupBy: []string{"region"}},
+		"Unknown Group Field":  {GroupBy: []string{"city"}, Aggregations: []models.AggregationField{{Type: models.AggregationCount, OutputName: "n"}}},
+		"Unknown Type":         {Aggregations: []models.AggregationField{{Type: "mode", Field: "units", OutputName: "n"}}},
+		"Sum Of Strings":       {Aggregations: []models.AggregationField{{Type: models.AggregationSum, Field: "region", OutputName: "n"}}},
+		"Missing Field":        {Aggregations: []models.AggregationField{{Type: models.AggregationAvg, OutputName: "n"}}},
+		"Missing Percentile":   {Aggregations: []models.AggregationField{{Type: models.AggregationPercentile, Field: "units", OutputName: "n"}}},
+		"Duplicate Output":     {GroupBy: []string{"region"}, Aggregations: []models.AggregationField{{Type: models.AggregationCount, OutputName: "region"}}},
+		"Having Source Field":  {Aggregations: []models.AggregationField{{Type: models.AggregationCount, OutputName: "n"}}, Having: []models.FilterCondition{{Field: "units", Operator: models.FilterGT, Value: 1}}},
+		"Sort Unknown Output":  {Aggregations: []models.AggregationField{{Type: models.AggregationCount, OutputName: "n"}}, Sort: []models.SortField{{Field: "m", Direction: models.SortAsc}}},
+		"Negative Limit":       {Aggregations: []models.AggregationField{{Type: models.AggregationCount, OutputName: "n"}}, Limit: -1},
+		"Percentile Too Large": {Aggregations: []models.AggregationField{{Type: models.AggregationPercentile, Field: "units", OutputName: "n", Percentile: func() *float64 { p := 101.0; return &p }()}}},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileAggregate(&req, salesFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// ConvertValue converts a request value to the native representation used when
+// comparing values of a field type: float64 for numbers, bool, time.Time, string,
+// and the value itself for arrays and objects
+func ConvertValue(value interface{}, t models.DataType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case models.DataTypeInteger, models.DataTypeFloat:
+		f, ok := ToFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not a number", value)
+		}
+		return f, nil
+	case models.DataTypeBoolean:
+		b, ok := ToBool(value)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not a boolean", value)
+		}
+		return b, nil
+	case models.DataTypeDateTime:
+		tm, ok := ToTime(value)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not a datetime", value)
+		}
+		return tm, nil
+	case models.DataTypeArray, models.DataTypeObject:
+		return value, nil
+	default:
+		switch value.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, fmt.Errorf("value %v is not a string", value)
+		}
+		return FormatValue(value), nil
+	}
+}
+
+// Compare orders two values of a field type. Both values are converted with
+// ConvertValue first; ok is false when either cannot be converted.
+func Compare(a, b interface{}, t models.DataType) (int, bool) {
+	ca, err := ConvertValue(a, t)
+	if err != nil || ca == nil {
+		return 0, false
+	}
+	cb, err := ConvertValue(b, t)
+	if err != nil || cb == nil {
+		return 0, false
+	}
+	return compareNative(ca, cb), true
+}
+
+// compareNative orders two converted values of the same kind
+func compareNative(a, b interface{}) int {
+	switch x := a.(type) {
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case time.Time:
+		y := b.(time.Time)
+		switch {
+		case x.Before(y):
+			return -1
+		case x.After(y):
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	default:
+		return strings.Compare(jsonText(a), jsonText(b))
+	}
+}
+
+// FormatValue renders a scalar value as text; integral floats have no fractional part
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []interface{}, map[string]interface{}:
+		return jsonText(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonText encodes a value as JSON text, used to compare arrays and objects
+func jsonText(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// CastValue converts a stored value to a data type, returning the representation
+// kept in rows: int64, float64, bool, RFC 3339 strings for datetimes, string,
+// []interface{} and map[string]interface{}
+func CastValue(value interface{}, t models.DataType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch t {
+	case models.DataTypeString:
+		return FormatValue(value), nil
+
+	case models.DataTypeInteger:
+		if b, ok := value.(bool); ok {
+			if b {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+		if i, ok := ToInt(value); ok {
+			return i, nil
+		}
+		f, ok := ToFloat(value)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("cannot convert %v to integer", value)
+		}
+		return int64(f), nil
+
+	case models.DataTypeFloat:
+		if b, ok := value.(bool); ok {
+			if b {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		}
+		f, ok := ToFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %v to float", value)
+		}
+		return f, nil
+
+	case models.DataTypeBoolean:
+		if b, ok := ToBool(value); ok {
+			return b, nil
+		}
+		if f, ok := ToFloat(value); ok {
+			return f != 0, nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to boolean", value)
+
+	case models.DataTypeDateTime:
+		if tm, ok := ToTime(value); ok {
+			return tm.Format(time.RFC3339Nano), nil
+		}
+		if f, ok := ToFloat(value); ok {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano), nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to datetime", value)
+
+	case models.DataTypeArray:
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case string:
+			var decoded []interface{}
+			if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+				return decoded, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to array", value)
+
+	case models.DataTypeObject:
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return v, nil
+		case string:
+			var decoded map[string]interface{}
+			if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+				return decoded, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to object", value)
+
+	default:
+		return nil, fmt.Errorf("unknown type %q", t)
+	}
+}
+
+// InferType returns the narrowest data type describing all non-null values:
+// integer when every number is integral, float for other numbers, string when
+// kinds are mixed or no value is set
+func InferType(values []interface{}) models.DataType {
+	var inferred models.DataType
+	for _, value := range values {
+		var t models.DataType
+		switch v := value.(type) {
+		case nil:
+			continue
+		case bool:
+			t = models.DataTypeBoolean
+		case int, int32, int64:
+			t = models.DataTypeInteger
+		case float64:
+			if v == math.Trunc(v) && !math.IsInf(v, 0) {
+				t = models.DataTypeInteger
+			} else {
+				t = models.DataTypeFloat
+			}
+		case float32, json.Number:
+			t = models.DataTypeFloat
+		case time.Time:
+			t = models.DataTypeDateTime
+		case []interface{}:
+			t = models.DataTypeArray
+		case map[string]interface{}:
+			t = models.DataTypeObject
+		default:
+			t = models.DataTypeString
+		}
+
+		switch {
+		case inferred == "" || inferred == t:
+			inferred = t
+		case isNumeric(inferred) && isNumeric(t):
+			inferred = models.DataTypeFloat
+		default:
+			return models.DataTypeString
+		}
+	}
+
+	if inferred == "" {
+		return models.DataTypeString
+	}
+	return inferred
+}
+
+// isNumeric reports whether a data type holds numbers
+func isNumeric(t models.DataType) bool {
+	return t == models.DataTypeInteger || t == models.DataTypeFloat
+}
@@ -0,0 +1,115 @@
+package query
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// Cursor marks the last row of a page: the text of its sort keys, nil for
+// nulls, and its row id. The next page holds the rows that sort after it.
+type Cursor struct {
+	Keys []*string
+	ID   int64
+}
+
+// cursorToken is the signed payload of a cursor. It records the dataset, sort
+// order, filters (as a hash) and version of the query the cursor continues, as
+// the keys mean nothing for another query.
+type cursorToken struct {
+	DatasetID uuid.UUID          `json:"d"`
+	Sort      []models.SortField `json:"s,omitempty"`
+	Filters   string             `json:"f,omitempty"`
+	Version   int64              `json:"v,omitempty"`
+	Keys      []*string          `json:"k,omitempty"`
+	ID        int64              `json:"i"`
+}
+
+// CursorSigner encodes cursors as opaque tokens signed with HMAC-SHA256, so
+// clients can hand them back but not forge them
+type CursorSigner struct {
+	key []byte
+}
+
+// NewCursorSigner creates a cursor signer with a secret key
+func NewCursorSigner(secret string) *CursorSigner {
+	return &CursorSigner{key: []byte(secret)}
+}
+
+// Encode returns the token of a cursor continuing a query
+func (s *CursorSigner) Encode(req *models.QueryRequest, cursor *Cursor) string {
+	payload, _ := json.Marshal(cursorToken{
+		DatasetID: req.DatasetID,
+		Sort:      req.Sort,
+		Filters:   filtersHash(req.Filters),
+		Version:   req.AsOfVersion,
+		Keys:      cursor.Keys,
+		ID:        cursor.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// Decode verifies the cursor token of a query request, returning nil when the
+// request has none
+func (s *CursorSigner) Decode(req *models.QueryRequest) (*Cursor, error) {
+	if req.Cursor == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(req.Cursor, ".")
+	if len(parts) != 2 {
+		return nil, models.NewValidationError("invalid cursor")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, models.NewValidationError("invalid cursor")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.sign(payload)) {
+		return nil, models.NewValidationError("invalid cursor")
+	}
+
+	var token cursorToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, models.NewValidationError("invalid cursor")
+	}
+	if token.DatasetID != req.DatasetID || !sameSort(token.Sort, req.Sort) || token.Filters != filtersHash(req.Filters) ||
+		token.Version != req.AsOfVersion {
+		return nil, models.NewValidationError("cursor was issued for another dataset, sort order, filter set or version")
+	}
+	return &Cursor{Keys: token.Keys, ID: token.ID}, nil
+}
+
+func (s *CursorSigner) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// filtersHash returns a digest of the filters of a query, empty without filters
+func filtersHash(filters []models.FilterCondition) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(filters)
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// sameSort reports whether two sort orders are identical
+func sameSort(a, b []models.SortField) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorSigner(t *testing.T) {
+	signer := NewCursorSigner("secret")
+	key := "2024-03-01 12:00:00+00"
+	req := &models.QueryRequest{
+		DatasetID: uuid.New(),
+		Sort:      []models.SortField{{Field: "created", Direction: models.SortAsc}, {Field: "name", Direction: models.SortDesc}},
+	}
+	token := signer.Encode(req, &Cursor{Keys: []*string{&key, nil}, ID: 7})
+
+	// Test Case 1: Tokens decode to the cursor they were made from
+	t.Run("RoundTrip", func(t *testing.T) {
+		next := *req
+		next.Cursor = token
+		cursor, err := signer.Decode(&next)
+		require.NoError(t, err)
+		assert.Equal(t, &Cursor{Keys: []*string{&key, nil}, ID: 7}, cursor)
+
+		// Requests without a cursor start at the first row
+		cursor, err = signer.Decode(req)
+		assert.NoError(t, err)
+		assert.Nil(t, cursor)
+	})
+
+	// Test Case 2: Forged, altered and misapplied tokens are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		payload := strings.Split(token, ".")[0]
+		forged := NewCursorSigner("other").Encode(req, &Cursor{ID: 1})
+
+		invalid := map[string]models.QueryRequest{
+			"Garbage":        {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: "not a cursor"},
+			"Unsigned":       {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: payload},
+			"Altered":        {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: "e30." + strings.Split(token, ".")[1]},
+			"Other Key":      {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: forged},
+			"Other Dataset":  {DatasetID: uuid.New(), Sort: req.Sort, Cursor: token},
+			"Other Sort":     {DatasetID: req.DatasetID, Sort: req.Sort[:1], Cursor: token},
+			"Other Ordering": {DatasetID: req.DatasetID, Sort: []models.SortField{req.Sort[0], {Field: "name", Direction: models.SortAsc}}, Cursor: token},
+			"Other Version":  {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: token, AsOfVersion: 2},
+			"Other Filters":  {DatasetID: req.DatasetID, Sort: req.Sort, Cursor: token, Filters: []models.FilterCondition{{Field: "name", Operator: models.FilterEQ, Value: "a"}}},
+		}
+		for name, r := range invalid {
+			r := r
+			_, err := signer.Decode(&r)
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+}
@@ -0,0 +1,737 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Expr is a parsed add_column expression. See models.AddColumnParams for the
+// supported syntax. Arithmetic and comparisons involving null yield null, and
+// division by zero yields null.
+type Expr struct {
+	root exprNode
+}
+
+// exprNode is a node of an expression tree
+type exprNode interface {
+	// eval computes the node value for a row whose values are in field order
+	eval(row []interface{}) interface{}
+	// typeOf returns the static type of the node given the row fields
+	typeOf(fields []models.DataField) models.DataType
+	// bind resolves field references against the row fields
+	bind(fields []models.DataField) error
+}
+
+// ParseExpr parses an expression
+func ParseExpr(src string) (*Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	return &Expr{root: root}, nil
+}
+
+// Bind resolves the field references of the expression against row fields and
+// returns the static type of its result
+func (e *Expr) Bind(fields []models.DataField) (models.DataType, error) {
+	if err := e.root.bind(fields); err != nil {
+		return "", err
+	}
+	return e.root.typeOf(fields), nil
+}
+
+// Eval computes the expression for a row whose values are in the order of the
+// fields passed to Bind
+func (e *Expr) Eval(row []interface{}) interface{} {
+	return e.root.eval(row)
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenField
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators lists the operator tokens, longest first
+var operators = []string{"<=", ">=", "!=", "<>", "==", "+", "-", "*", "/", "%", "=", "<", ">", "(", ")", ","}
+
+// tokenize splits an expression into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && src[j] >= '0' && src[j] <= '9' {
+					for i = j; i < len(src) && src[i] >= '0' && src[i] <= '9'; i++ {
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], pos: start})
+
+		case r == '\'' || r == '"':
+			start := i
+			var b strings.Builder
+			closed := false
+			for i++; i < len(src); i++ {
+				if src[i] == byte(r) {
+					if i+1 < len(src) && src[i+1] == byte(r) {
+						b.WriteByte(byte(r))
+						i++
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				b.WriteByte(src[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote at position %d", start)
+			}
+			kind := tokenString
+			if r == '"' {
+				kind = tokenField
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String(), pos: start})
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// Parser
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so
+func (p *exprParser) keyword(word string) bool {
+	if tok := p.peek(); tok.kind == tokenIdent && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// operator consumes the next token if it is one of the given operators
+func (p *exprParser) operator(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.operator("=", "==", "!=", "<>", "<", "<=", ">", ">="); ok {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			op = "="
+		case "<>":
+			op = "!="
+		}
+		return &compareNode{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.operator("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.operator("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if _, ok := p.operator("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmeticNode{op: "-", left: &literalNode{value: int64(0)}, right: operand}, nil
+	}
+	if _, ok := p.operator("+"); ok {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return &literalNode{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", tok, tok.pos)
+		}
+		return &literalNode{value: f}, nil
+
+	case tokenString:
+		return &literalNode{value: tok.text}, nil
+
+	case tokenField:
+		return &fieldNode{name: tok.text}, nil
+
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{}, nil
+		}
+		if _, ok := p.operator("("); !ok {
+			return &fieldNode{name: tok.text}, nil
+		}
+		return p.parseCall(tok)
+
+	case tokenOperator:
+		if tok.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.operator(")"); !ok {
+				return nil, fmt.Errorf("expected \")\" at position %d", p.peek().pos)
+			}
+			return inner, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+// parseCall parses the arguments of a function call after its opening parenthesis
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	fn, ok := exprFunctions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name, name.pos)
+	}
+
+	var args []exprNode
+	if _, ok := p.operator(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.operator(","); ok {
+				continue
+			}
+			if _, ok := p.operator(")"); ok {
+				break
+			}
+			return nil, fmt.Errorf("expected \",\" or \")\" at position %d", p.peek().pos)
+		}
+	}
+
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments for %s: got %d", strings.ToLower(name.text), len(args))
+	}
+	return &callNode{fn: fn, args: args}, nil
+}
+
+// Nodes
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval([]interface{}) interface{} { return n.value }
+func (n *literalNode) bind([]models.DataField) error  { return nil }
+func (n *literalNode) typeOf([]models.DataField) models.DataType {
+	return InferType([]interface{}{n.value})
+}
+
+type fieldNode struct {
+	name  string
+	index int
+}
+
+func (n *fieldNode) eval(row []interface{}) interface{} { return row[n.index] }
+
+func (n *fieldNode) bind(fields []models.DataField) error {
+	n.index = fieldIndex(fields, n.name)
+	if n.index < 0 {
+		return fmt.Errorf("unknown field %q", n.name)
+	}
+	return nil
+}
+
+func (n *fieldNode) typeOf(fields []models.DataField) models.DataType {
+	return fields[n.index].Type
+}
+
+type logicalNode struct {
+	or          bool
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(row []interface{}) interface{} {
+	left := truthy(n.left.eval(row))
+	if n.or && left || !n.or && !left {
+		return left
+	}
+	return truthy(n.right.eval(row))
+}
+
+func (n *logicalNode) bind(fields []models.DataField) error {
+	return bindAll(fields, n.left, n.right)
+}
+
+func (n *logicalNode) typeOf([]models.DataField) models.DataType { return models.DataTypeBoolean }
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(row []interface{}) interface{} { return !truthy(n.operand.eval(row)) }
+func (n *notNode) bind(fields []models.DataField) error {
+	return n.operand.bind(fields)
+}
+func (n *notNode) typeOf([]models.DataField) models.DataType { return models.DataTypeBoolean }
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(row []interface{}) interface{} {
+	left, right := n.left.eval(row), n.right.eval(row)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	var cmp int
+	lf, lok := numeric(left)
+	rf, rok := numeric(right)
+	switch {
+	case lok && rok:
+		cmp = compareNative(lf, rf)
+	default:
+		lb, lok := left.(bool)
+		rb, rok := right.(bool)
+		if lok && rok {
+			cmp = compareNative(lb, rb)
+		} else {
+			cmp = strings.Compare(FormatValue(left), FormatValue(right))
+		}
+	}
+
+	switch n.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (n *compareNode) bind(fields []models.DataField) error {
+	return bindAll(fields, n.left, n.right)
+}
+
+func (n *compareNode) typeOf([]models.DataField) models.DataType { return models.DataTypeBoolean }
+
+type arithmeticNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithmeticNode) eval(row []interface{}) interface{} {
+	left, right := n.left.eval(row), n.right.eval(row)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	if n.op == "+" {
+		_, ls := left.(string)
+		_, rs := right.(string)
+		if ls || rs {
+			return FormatValue(left) + FormatValue(right)
+		}
+	}
+
+	li, lint := left.(int64)
+	ri, rint := right.(int64)
+	if lint && rint {
+		switch n.op {
+		case "+":
+			return li + ri
+		case "-":
+			return li - ri
+		case "*":
+			return li * ri
+		case "%":
+			if ri == 0 {
+				return nil
+			}
+			return li % ri
+		}
+	}
+
+	lf, lok := numeric(left)
+	rf, rok := numeric(right)
+	if !lok || !rok {
+		return nil
+	}
+	switch n.op {
+	case "+":
+		return lf + rf
+	case "-":
+		return lf - rf
+	case "*":
+		return lf * rf
+	case "/":
+		if rf == 0 {
+			return nil
+		}
+		return lf / rf
+	default:
+		if rf == 0 {
+			return nil
+		}
+		return math.Mod(lf, rf)
+	}
+}
+
+func (n *arithmeticNode) bind(fields []models.DataField) error {
+	return bindAll(fields, n.left, n.right)
+}
+
+func (n *arithmeticNode) typeOf(fields []models.DataField) models.DataType {
+	left, right := n.left.typeOf(fields), n.right.typeOf(fields)
+	switch {
+	case n.op == "+" && (left == models.DataTypeString || right == models.DataTypeString):
+		return models.DataTypeString
+	case n.op != "/" && left == models.DataTypeInteger && right == models.DataTypeInteger:
+		return models.DataTypeInteger
+	default:
+		return models.DataTypeFloat
+	}
+}
+
+type callNode struct {
+	fn   *exprFunction
+	args []exprNode
+}
+
+func (n *callNode) eval(row []interface{}) interface{} {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(row)
+	}
+	return n.fn.eval(args)
+}
+
+func (n *callNode) bind(fields []models.DataField) error {
+	return bindAll(fields, n.args...)
+}
+
+func (n *callNode) typeOf(fields []models.DataField) models.DataType {
+	if n.fn.result != "" {
+		return n.fn.result
+	}
+	// coalesce takes the type of its first argument
+	return n.args[0].typeOf(fields)
+}
+
+// bindAll binds every node
+func bindAll(fields []models.DataField, nodes ...exprNode) error {
+	for _, node := range nodes {
+		if err := node.bind(fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truthy interprets a value as a condition; null and non-boolean values are false
+func truthy(value interface{}) bool {
+	b, ok := value.(bool)
+	return ok && b
+}
+
+// numeric converts stored numbers to float64; unlike ToFloat it does not parse strings
+func numeric(value interface{}) (float64, bool) {
+	if _, ok := value.(string); ok {
+		return 0, false
+	}
+	return ToFloat(value)
+}
+
+// Functions
+
+// exprFunction is a function callable from expressions; maxArgs is -1 for
+// variadic functions and result is empty when the type depends on the arguments
+type exprFunction struct {
+	minArgs, maxArgs int
+	result           models.DataType
+	eval             func(args []interface{}) interface{}
+}
+
+var exprFunctions = map[string]*exprFunction{
+	"lower":    {1, 1, models.DataTypeString, stringFunc(strings.ToLower)},
+	"upper":    {1, 1, models.DataTypeString, stringFunc(strings.ToUpper)},
+	"trim":     {1, 1, models.DataTypeString, stringFunc(strings.TrimSpace)},
+	"length":   {1, 1, models.DataTypeInteger, lengthFunc},
+	"concat":   {1, -1, models.DataTypeString, concatFunc},
+	"abs":      {1, 1, models.DataTypeFloat, mathFunc(math.Abs)},
+	"round":    {1, 2, models.DataTypeFloat, roundFunc},
+	"floor":    {1, 1, models.DataTypeFloat, mathFunc(math.Floor)},
+	"ceil":     {1, 1, models.DataTypeFloat, mathFunc(math.Ceil)},
+	"sqrt":     {1, 1, models.DataTypeFloat, sqrtFunc},
+	"coalesce": {1, -1, "", coalesceFunc},
+}
+
+func stringFunc(fn func(string) string) func([]interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		if args[0] == nil {
+			return nil
+		}
+		return fn(FormatValue(args[0]))
+	}
+}
+
+func mathFunc(fn func(float64) float64) func([]interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		f, ok := numeric(args[0])
+		if !ok {
+			return nil
+		}
+		return fn(f)
+	}
+}
+
+func lengthFunc(args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return int64(len(v))
+	case map[string]interface{}:
+		return int64(len(v))
+	default:
+		return int64(utf8.RuneCountInString(FormatValue(v)))
+	}
+}
+
+func concatFunc(args []interface{}) interface{} {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(FormatValue(arg))
+	}
+	return b.String()
+}
+
+func roundFunc(args []interface{}) interface{} {
+	f, ok := numeric(args[0])
+	if !ok {
+		return nil
+	}
+	digits := 0.0
+	if len(args) > 1 {
+		if digits, ok = numeric(args[1]); !ok {
+			return nil
+		}
+	}
+	scale := math.Pow(10, math.Trunc(digits))
+	return math.Round(f*scale) / scale
+}
+
+func sqrtFunc(args []interface{}) interface{} {
+	f, ok := numeric(args[0])
+	if !ok || f < 0 {
+		return nil
+	}
+	return math.Sqrt(f)
+}
+
+func coalesceFunc(args []interface{}) interface{} {
+	for _, arg := range args {
+		if arg != nil {
+			return arg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Filter modes combining the conditions of a Filter
+const (
+	FilterModeAnd = "and"
+	FilterModeOr  = "or"
+)
+
+// Filter is a set of filter conditions evaluated in memory against frame rows.
+// It follows the semantics of the SQL compiler: null values only match eq/ne
+// with a null value, and stored values that cannot be converted to the field
+// type never match.
+type Filter struct {
+	conditions []condition
+	or         bool
+}
+
+// condition is a single compiled filter condition
+type condition struct {
+	index    int
+	field    models.DataField
+	operator models.FilterOperator
+	value    interface{}
+	values   []interface{}
+	pattern  *regexp.Regexp
+	exists   bool
+}
+
+// CompileFilter validates filter conditions against fields and compiles them.
+// Mode is FilterModeAnd (the default when empty) or FilterModeOr.
+func CompileFilter(filters []models.FilterCondition, fields []models.DataField, mode string) (*Filter, error) {
+	f := &Filter{conditions: make([]condition, 0, len(filters))}
+	switch mode {
+	case "", FilterModeAnd:
+	case FilterModeOr:
+		f.or = true
+	default:
+		return nil, models.NewValidationError("invalid filter mode %q", mode)
+	}
+
+	for i, filter := range filters {
+		cond, err := compileCondition(filter, fields)
+		if err != nil {
+			return nil, models.NewValidationError("filter %d: %v", i, err)
+		}
+		f.conditions = append(f.conditions, cond)
+	}
+	return f, nil
+}
+
+// compileCondition resolves the field of a condition and converts its value
+func compileCondition(filter models.FilterCondition, fields []models.DataField) (condition, error) {
+	index := fieldIndex(fields, filter.Field)
+	if index < 0 {
+		return condition{}, fmt.Errorf("unknown field %q", filter.Field)
+	}
+	field := fields[index]
+	cond := condition{index: index, field: field, operator: filter.Operator}
+
+	switch filter.Operator {
+	case models.FilterEQ, models.FilterNE:
+		value, err := ConvertValue(filter.Value, field.Type)
+		if err != nil {
+			return condition{}, fmt.Errorf("%v for field %q", err, field.Name)
+		}
+		cond.value = value
+
+	case models.FilterGT, models.FilterGTE, models.FilterLT, models.FilterLTE:
+		if !isOrdered(field.Type) {
+			return condition{}, fmt.Errorf("operator %s is not supported for %s field %q", filter.Operator, field.Type, field.Name)
+		}
+		if filter.Value == nil {
+			return condition{}, fmt.Errorf("operator %s requires a value", filter.Operator)
+		}
+		value, err := ConvertValue(filter.Value, field.Type)
+		if err != nil {
+			return condition{}, fmt.Errorf("%v for field %q", err, field.Name)
+		}
+		cond.value = value
+
+	case models.FilterIN, models.FilterNIN:
+		if !isScalar(field.Type) {
+			return condition{}, fmt.Errorf("operator %s is not supported for %s field %q", filter.Operator, field.Type, field.Name)
+		}
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			return condition{}, fmt.Errorf("operator %s requires an array value", filter.Operator)
+		}
+		cond.values = make([]interface{}, 0, len(values))
+		for _, value := range values {
+			converted, err := ConvertValue(value, field.Type)
+			if err != nil {
+				return condition{}, fmt.Errorf("%v for field %q", err, field.Name)
+			}
+			if converted != nil {
+				cond.values = append(cond.values, converted)
+			}
+		}
+
+	case models.FilterLIKE:
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			return condition{}, fmt.Errorf("operator like requires a string pattern")
+		}
+		compiled, err := likePattern(pattern)
+		if err != nil {
+			return condition{}, err
+		}
+		cond.pattern = compiled
+
+	case models.FilterREGEX:
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			return condition{}, fmt.Errorf("operator regex requires a string pattern")
+		}
+		goPattern, _, err := translateRegex(pattern)
+		if err != nil {
+			return condition{}, err
+		}
+		cond.pattern = regexp.MustCompile(goPattern)
+
+	case models.FilterEXISTS:
+		cond.exists = true
+		if filter.Value != nil {
+			b, ok := filter.Value.(bool)
+			if !ok {
+				return condition{}, fmt.Errorf("operator exists requires a boolean value")
+			}
+			cond.exists = b
+		}
+
+	default:
+		return condition{}, fmt.Errorf("unknown operator %q", filter.Operator)
+	}
+
+	return cond, nil
+}
+
+// Match reports whether row r of a frame matches the filter. The frame must
+// have the fields the filter was compiled against.
+func (f *Filter) Match(frame *Frame, r int) bool {
+	if len(f.conditions) == 0 {
+		return true
+	}
+	for _, cond := range f.conditions {
+		matched := cond.match(frame.Columns[cond.index][r])
+		if f.or && matched {
+			return true
+		}
+		if !f.or && !matched {
+			return false
+		}
+	}
+	return !f.or
+}
+
+// Apply returns a frame holding the matching rows
+func (f *Filter) Apply(frame *Frame) *Frame {
+	indices := make([]int, 0, frame.Len())
+	for r := 0; r < frame.Len(); r++ {
+		if f.Match(frame, r) {
+			indices = append(indices, r)
+		}
+	}
+	return frame.take(indices)
+}
+
+// match evaluates the condition for a stored value. Missing and null values are
+// indistinguishable in a frame, so exists tests for a non-null value.
+func (c condition) match(stored interface{}) bool {
+	switch c.operator {
+	case models.FilterEXISTS:
+		return (stored != nil) == c.exists
+	case models.FilterNE:
+		return !c.equals(stored)
+	case models.FilterEQ:
+		return c.equals(stored)
+	case models.FilterNIN:
+		return !c.in(stored)
+	case models.FilterIN:
+		return c.in(stored)
+	}
+
+	if stored == nil {
+		return false
+	}
+	switch c.operator {
+	case models.FilterLIKE, models.FilterREGEX:
+		return c.pattern.MatchString(FormatValue(stored))
+	}
+
+	value, err := ConvertValue(stored, c.field.Type)
+	if err != nil {
+		return false
+	}
+	cmp := compareNative(value, c.value)
+	switch c.operator {
+	case models.FilterGT:
+		return cmp > 0
+	case models.FilterGTE:
+		return cmp >= 0
+	case models.FilterLT:
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// equals compares a stored value with the condition value
+func (c condition) equals(stored interface{}) bool {
+	if c.value == nil || stored == nil {
+		return c.value == nil && stored == nil
+	}
+	value, err := ConvertValue(stored, c.field.Type)
+	if err != nil {
+		return false
+	}
+	return compareNative(value, c.value) == 0
+}
+
+// in reports whether a stored value is one of the condition values
+func (c condition) in(stored interface{}) bool {
+	if stored == nil {
+		return false
+	}
+	value, err := ConvertValue(stored, c.field.Type)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range c.values {
+		if compareNative(value, candidate) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// likePattern translates a SQL LIKE pattern, where % matches any sequence, _
+// any single character and a backslash escapes the character after it, into
+// an anchored regular expression. A pattern ending with a lone backslash is
+// rejected, as PostgreSQL does.
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("like pattern must not end with an escape character")
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), nil
+}
+
+// fieldIndex returns the position of a named field, or -1. A field may also be
+// referred to by one of its aliases, as in compiled SQL, unless another field
+// has that name.
+func fieldIndex(fields []models.DataField, name string) int {
+	for i, field := range fields {
+		if field.Name == name {
+			return i
+		}
+	}
+	for i, field := range fields {
+		for _, alias := range field.Aliases {
+			if alias == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	fields := []models.DataField{
+		{Name: "code", Type: models.DataTypeString, Aliases: []string{"sku"}},
+		{Name: "qty", Type: models.DataTypeInteger},
+	}
+	frame := NewFrame(fields, []map[string]interface{}{
+		{"code": "50%_off", "qty": int64(1)},
+		{"code": "50 off", "qty": int64(2)},
+		{"code": `a\b`, "qty": int64(3)},
+	})
+
+	// Test Case 1: Escaped wildcards in like patterns match literally
+	t.Run("Like Escape", func(t *testing.T) {
+		cases := map[string][]interface{}{
+			`50%`:     {"50%_off", "50 off"},
+			`50\%%`:   {"50%_off"},
+			`50\%\_%`: {"50%_off"},
+			`a\\b`:    {`a\b`},
+		}
+		for pattern, expected := range cases {
+			filter, err := CompileFilter([]models.FilterCondition{{Field: "code", Operator: models.FilterLIKE, Value: pattern}}, fields, FilterModeAnd)
+			require.NoError(t, err, pattern)
+			assert.Equal(t, expected, filter.Apply(frame).Column("code"), pattern)
+		}
+	})
+
+	// Test Case 2: Aliases resolve to their field, as in compiled SQL
+	t.Run("Aliases", func(t *testing.T) {
+		filter, err := CompileFilter([]models.FilterCondition{{Field: "sku", Operator: models.FilterEQ, Value: "50 off"}}, fields, FilterModeAnd)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(2)}, filter.Apply(frame).Column("qty"))
+	})
+
+	// Test Case 3: Invalid patterns are rejected by both engines
+	t.Run("Trailing Escape", func(t *testing.T) {
+		conditions := []models.FilterCondition{{Field: "code", Operator: models.FilterLIKE, Value: `50\`}}
+		var validationErr *models.ValidationError
+
+		_, err := CompileFilter(conditions, fields, FilterModeAnd)
+		assert.ErrorAs(t, err, &validationErr)
+		_, err = Compile(&models.QueryRequest{Filters: conditions}, &models.DataSchema{Fields: fields}, testLimits)
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
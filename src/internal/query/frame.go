@@ -0,0 +1,113 @@
+package query
+
+import (
+	"encoding/json"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Frame is an in-memory columnar table. Columns[i] holds the values of Fields[i]
+// for every row; missing values are stored as nil.
+type Frame struct {
+	Fields  []models.DataField
+	Columns [][]interface{}
+	rows    int
+}
+
+// NewFrame builds a frame with the fields of a schema from row maps
+func NewFrame(fields []models.DataField, rows []map[string]interface{}) *Frame {
+	f := &Frame{
+		Fields:  append([]models.DataField(nil), fields...),
+		Columns: make([][]interface{}, len(fields)),
+		rows:    len(rows),
+	}
+	for i, field := range fields {
+		column := make([]interface{}, len(rows))
+		for r, row := range rows {
+			column[r] = row[field.Name]
+		}
+		f.Columns[i] = column
+	}
+	return f
+}
+
+// Len returns the number of rows
+func (f *Frame) Len() int {
+	return f.rows
+}
+
+// Index returns the position of a field, or -1 if the frame has no such field
+func (f *Frame) Index(name string) int {
+	for i, field := range f.Fields {
+		if field.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Column returns the values of a field, or nil if the frame has no such field
+func (f *Frame) Column(name string) []interface{} {
+	if i := f.Index(name); i >= 0 {
+		return f.Columns[i]
+	}
+	return nil
+}
+
+// Rows converts the frame back to row maps
+func (f *Frame) Rows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, f.rows)
+	for r := range rows {
+		row := make(map[string]interface{}, len(f.Fields))
+		for i, field := range f.Fields {
+			row[field.Name] = f.Columns[i][r]
+		}
+		rows[r] = row
+	}
+	return rows
+}
+
+// Schema returns a schema describing the frame fields
+func (f *Frame) Schema() models.DataSchema {
+	return models.DataSchema{Fields: append([]models.DataField{}, f.Fields...)}
+}
+
+// RowsSize estimates the JSON encoded size of rows in bytes
+func RowsSize(rows []map[string]interface{}) int64 {
+	var size int64
+	for _, row := range rows {
+		encoded, err := json.Marshal(row)
+		if err == nil {
+			size += int64(len(encoded))
+		}
+	}
+	return size
+}
+
+// take returns a frame holding the given rows, in order
+func (f *Frame) take(indices []int) *Frame {
+	out := &Frame{
+		Fields:  f.Fields,
+		Columns: make([][]interface{}, len(f.Columns)),
+		rows:    len(indices),
+	}
+	for i, column := range f.Columns {
+		taken := make([]interface{}, len(indices))
+		for j, r := range indices {
+			taken[j] = column[r]
+		}
+		out.Columns[i] = taken
+	}
+	return out
+}
+
+// Dataset wraps the frame as an unsaved dataset carrying its rows in Data
+func (f *Frame) Dataset() *models.Dataset {
+	rows := f.Rows()
+	return &models.Dataset{
+		Schema:   f.Schema(),
+		Data:     rows,
+		Size:     RowsSize(rows),
+		RowCount: int64(f.rows),
+	}
+}
@@ -0,0 +1,416 @@
+package query
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// hashJoinMaxBuildRows is the largest input for which a hash table is built;
+// joins whose inputs both exceed it use a sort-merge join instead
+const hashJoinMaxBuildRows = 100000
+
+// Join strategies
+const (
+	joinStrategyHash      = "hash"
+	joinStrategySortMerge = "sort-merge"
+	joinStrategyNested    = "nested-loop"
+)
+
+// Join is a validated join of two frames. Output fields are the left fields
+// followed by the right fields. A condition joining two fields of the same name
+// produces a single column holding the value of whichever side matched, typed
+// as a float when one side is an integer and the other a float; other names
+// present on both sides are suffixed with _left and _right.
+type Join struct {
+	joinType  models.JoinType
+	leftKeys  []int
+	rightKeys []int
+	keyTypes  []models.DataType
+	columns   []joinColumn
+	fields    []models.DataField
+	maxRows   int
+	hashLimit int
+	strategy  string
+}
+
+// joinColumn describes where an output column takes its values from; left and
+// right are -1 when the column does not come from that side. Values of a
+// widened column are converted to float64.
+type joinColumn struct {
+	left    int
+	right   int
+	widened bool
+}
+
+// CompileJoin validates a join request against the fields of both inputs
+func CompileJoin(req *models.JoinRequest, left, right []models.DataField, limits Limits) (*Join, error) {
+	j := &Join{joinType: req.JoinType, maxRows: limits.MaxJoinRows, hashLimit: hashJoinMaxBuildRows}
+
+	switch req.JoinType {
+	case models.JoinCross:
+		if len(req.Conditions) > 0 {
+			return nil, models.NewValidationError("cross join does not take conditions")
+		}
+	case models.JoinInner, models.JoinLeft, models.JoinRight, models.JoinFull:
+		if len(req.Conditions) == 0 {
+			return nil, models.NewValidationError("%s join requires at least one condition", req.JoinType)
+		}
+	default:
+		return nil, models.NewValidationError("unknown join type %q", req.JoinType)
+	}
+
+	// Conditions
+	merged := make(map[string]bool)
+	for i, cond := range req.Conditions {
+		l := fieldIndex(left, cond.LeftField)
+		if l < 0 {
+			return nil, models.NewValidationError("conditions[%d]: unknown left field %q", i, cond.LeftField)
+		}
+		r := fieldIndex(right, cond.RightField)
+		if r < 0 {
+			return nil, models.NewValidationError("conditions[%d]: unknown right field %q", i, cond.RightField)
+		}
+		lt, rt := left[l].Type, right[r].Type
+		if !isScalar(lt) || !isScalar(rt) {
+			return nil, models.NewValidationError("conditions[%d]: cannot join on %s and %s fields", i, lt, rt)
+		}
+		if lt != rt && !(isNumeric(lt) && isNumeric(rt)) {
+			return nil, models.NewValidationError("conditions[%d]: cannot join %s field %q with %s field %q", i, lt, cond.LeftField, rt, cond.RightField)
+		}
+		j.leftKeys = append(j.leftKeys, l)
+		j.rightKeys = append(j.rightKeys, r)
+		j.keyTypes = append(j.keyTypes, lt)
+		if left[l].Name == right[r].Name {
+			merged[left[l].Name] = true
+		}
+	}
+
+	// Output columns
+	rightNames := make(map[string]bool, len(right))
+	for _, field := range right {
+		rightNames[field.Name] = true
+	}
+	leftNames := make(map[string]bool, len(left))
+	for _, field := range left {
+		leftNames[field.Name] = true
+	}
+	leftOuter := req.JoinType == models.JoinRight || req.JoinType == models.JoinFull
+	rightOuter := req.JoinType == models.JoinLeft || req.JoinType == models.JoinFull
+
+	used := make(map[string]bool, len(left)+len(right))
+	var fields []models.DataField
+	add := func(field models.DataField, column joinColumn, suffix string, outer bool) {
+		field.Name = uniqueName(field.Name, suffix, used)
+		field.Unique = false
+		if outer {
+			field.Required = false
+			field.Nullable = true
+		}
+		fields = append(fields, field)
+		j.columns = append(j.columns, column)
+	}
+	for i, field := range left {
+		switch {
+		case merged[field.Name]:
+			column := joinColumn{left: i, right: fieldIndex(right, field.Name)}
+			if right[column.right].Type != field.Type {
+				field.Type = models.DataTypeFloat
+				column.widened = true
+			}
+			add(field, column, "", false)
+		case rightNames[field.Name]:
+			add(field, joinColumn{left: i, right: -1}, "_left", leftOuter)
+		default:
+			add(field, joinColumn{left: i, right: -1}, "", leftOuter)
+		}
+	}
+	for i, field := range right {
+		switch {
+		case merged[field.Name]:
+		case leftNames[field.Name]:
+			add(field, joinColumn{left: -1, right: i}, "_right", rightOuter)
+		default:
+			add(field, joinColumn{left: -1, right: i}, "", rightOuter)
+		}
+	}
+
+	// Projection
+	j.fields = fields
+	if len(req.Fields) > 0 {
+		indices, err := lookupFields(fields, req.Fields)
+		if err != nil {
+			return nil, models.NewValidationError("fields: %v", err)
+		}
+		j.fields = make([]models.DataField, len(indices))
+		columns := make([]joinColumn, len(indices))
+		for k, index := range indices {
+			j.fields[k] = fields[index]
+			columns[k] = j.columns[index]
+		}
+		j.columns = columns
+	}
+
+	return j, nil
+}
+
+// uniqueName appends suffix to name, then a counter if the result is already used
+func uniqueName(name, suffix string, used map[string]bool) string {
+	candidate := name + suffix
+	for n := 2; used[candidate]; n++ {
+		candidate = name + suffix + "_" + strconv.Itoa(n)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// Fields returns the fields of the join output
+func (j *Join) Fields() []models.DataField {
+	return append([]models.DataField(nil), j.fields...)
+}
+
+// Apply joins two frames with the fields the join was compiled for. Rows are
+// ordered by left row then right row, followed by the unmatched right rows of
+// right and full joins. Null keys never match. A validation error is returned
+// when the output would exceed the configured row cap.
+func (j *Join) Apply(left, right *Frame) (*Frame, error) {
+	var pairs [][2]int
+	var err error
+	if j.joinType == models.JoinCross {
+		j.strategy = joinStrategyNested
+		pairs, err = j.crossPairs(left.Len(), right.Len())
+	} else {
+		leftKeys := joinKeys(left, j.leftKeys, j.keyTypes)
+		rightKeys := joinKeys(right, j.rightKeys, j.keyTypes)
+		if left.Len() <= j.hashLimit || right.Len() <= j.hashLimit {
+			j.strategy = joinStrategyHash
+			pairs, err = j.hashPairs(leftKeys, rightKeys)
+		} else {
+			j.strategy = joinStrategySortMerge
+			pairs, err = j.mergePairs(leftKeys, rightKeys)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err = j.addUnmatched(pairs, left.Len(), right.Len())
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Frame{Fields: j.Fields(), Columns: make([][]interface{}, len(j.columns)), rows: len(pairs)}
+	for c, column := range j.columns {
+		values := make([]interface{}, len(pairs))
+		for p, pair := range pairs {
+			if column.left >= 0 && pair[0] >= 0 {
+				values[p] = left.Columns[column.left][pair[0]]
+			}
+			if values[p] == nil && column.right >= 0 && pair[1] >= 0 {
+				values[p] = right.Columns[column.right][pair[1]]
+			}
+			if column.widened && values[p] != nil {
+				if f, ok := ToFloat(values[p]); ok {
+					values[p] = f
+				}
+			}
+		}
+		out.Columns[c] = values
+	}
+	return out, nil
+}
+
+// joinKeys encodes the key values of every row; rows with a null key get an
+// empty key, which never matches
+func joinKeys(frame *Frame, indices []int, types []models.DataType) []string {
+	keys := make([]string, frame.Len())
+	parts := make([]string, len(indices))
+	for r := range keys {
+		null := false
+		for k, index := range indices {
+			value := frame.Columns[index][r]
+			if value == nil {
+				null = true
+				break
+			}
+			parts[k] = GroupKey(value, types[k])
+		}
+		if !null {
+			keys[r] = strings.Join(parts, "\x1f")
+		}
+	}
+	return keys
+}
+
+// checkCap rejects outputs larger than the row cap
+func (j *Join) checkCap(rows int) error {
+	if j.maxRows > 0 && rows > j.maxRows {
+		return models.NewValidationError("join produces more than the maximum of %d rows", j.maxRows)
+	}
+	return nil
+}
+
+// crossPairs pairs every left row with every right row
+func (j *Join) crossPairs(leftRows, rightRows int) ([][2]int, error) {
+	if err := j.checkCap(leftRows * rightRows); err != nil {
+		return nil, err
+	}
+	pairs := make([][2]int, 0, leftRows*rightRows)
+	for l := 0; l < leftRows; l++ {
+		for r := 0; r < rightRows; r++ {
+			pairs = append(pairs, [2]int{l, r})
+		}
+	}
+	return pairs, nil
+}
+
+// hashPairs builds a hash table over the smaller input and probes it with the other
+func (j *Join) hashPairs(leftKeys, rightKeys []string) ([][2]int, error) {
+	build, probe := rightKeys, leftKeys
+	swapped := len(leftKeys) < len(rightKeys)
+	if swapped {
+		build, probe = leftKeys, rightKeys
+	}
+
+	table := make(map[string][]int)
+	for i, key := range build {
+		if key != "" {
+			table[key] = append(table[key], i)
+		}
+	}
+
+	var pairs [][2]int
+	for p, key := range probe {
+		if key == "" {
+			continue
+		}
+		for _, b := range table[key] {
+			pair := [2]int{p, b}
+			if swapped {
+				pair = [2]int{b, p}
+			}
+			pairs = append(pairs, pair)
+		}
+		if err := j.checkCap(len(pairs)); err != nil {
+			return nil, err
+		}
+	}
+
+	if swapped {
+		sortPairs(pairs)
+	}
+	return pairs, nil
+}
+
+// mergePairs sorts both inputs by key and merges runs of equal keys
+func (j *Join) mergePairs(leftKeys, rightKeys []string) ([][2]int, error) {
+	leftOrder := sortedByKey(leftKeys)
+	rightOrder := sortedByKey(rightKeys)
+
+	var pairs [][2]int
+	for a, b := 0, 0; a < len(leftOrder) && b < len(rightOrder); {
+		lk, rk := leftKeys[leftOrder[a]], rightKeys[rightOrder[b]]
+		switch {
+		case lk < rk:
+			a++
+		case lk > rk:
+			b++
+		default:
+			aEnd, bEnd := a, b
+			for aEnd < len(leftOrder) && leftKeys[leftOrder[aEnd]] == lk {
+				aEnd++
+			}
+			for bEnd < len(rightOrder) && rightKeys[rightOrder[bEnd]] == rk {
+				bEnd++
+			}
+			for _, l := range leftOrder[a:aEnd] {
+				for _, r := range rightOrder[b:bEnd] {
+					pairs = append(pairs, [2]int{l, r})
+				}
+			}
+			if err := j.checkCap(len(pairs)); err != nil {
+				return nil, err
+			}
+			a, b = aEnd, bEnd
+		}
+	}
+
+	sortPairs(pairs)
+	return pairs, nil
+}
+
+// sortedByKey returns the positions of the non-null keys ordered by key
+func sortedByKey(keys []string) []int {
+	order := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if key != "" {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+	return order
+}
+
+// sortPairs orders pairs by left row, then right row
+func sortPairs(pairs [][2]int) {
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a][0] != pairs[b][0] {
+			return pairs[a][0] < pairs[b][0]
+		}
+		return pairs[a][1] < pairs[b][1]
+	})
+}
+
+// addUnmatched adds the rows of the outer sides that have no match; -1 marks
+// the missing side of a pair
+func (j *Join) addUnmatched(pairs [][2]int, leftRows, rightRows int) ([][2]int, error) {
+	keepLeft := j.joinType == models.JoinLeft || j.joinType == models.JoinFull
+	keepRight := j.joinType == models.JoinRight || j.joinType == models.JoinFull
+	if !keepLeft && !keepRight {
+		return pairs, nil
+	}
+
+	leftMatched := make([]bool, leftRows)
+	rightMatched := make([]bool, rightRows)
+	for _, pair := range pairs {
+		leftMatched[pair[0]] = true
+		rightMatched[pair[1]] = true
+	}
+
+	out := pairs
+	if keepLeft {
+		// Interleave unmatched left rows in left order
+		out = make([][2]int, 0, len(pairs))
+		p := 0
+		for l := 0; l < leftRows; l++ {
+			if !leftMatched[l] {
+				out = append(out, [2]int{l, -1})
+				continue
+			}
+			for p < len(pairs) && pairs[p][0] == l {
+				out = append(out, pairs[p])
+				p++
+			}
+		}
+	}
+	if keepRight {
+		for r := 0; r < rightRows; r++ {
+			if !rightMatched[r] {
+				out = append(out, [2]int{-1, r})
+			}
+		}
+	}
+
+	if err := j.checkCap(len(out)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Strategy returns the strategy used by the last Apply: hash, sort-merge, or
+// nested-loop for cross joins
+func (j *Join) Strategy() string {
+	return j.strategy
+}
@@ -0,0 +1,152 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	customerFields = []models.DataField{
+		{Name: "id", Type: models.DataTypeInteger, Unique: true},
+		{Name: "name", Type: models.DataTypeString, Required: true},
+	}
+	orderFields = []models.DataField{
+		{Name: "order_id", Type: models.DataTypeInteger},
+		{Name: "id", Type: models.DataTypeFloat},
+		{Name: "name", Type: models.DataTypeString},
+	}
+)
+
+func joinFrames() (*Frame, *Frame) {
+	customers := NewFrame(customerFields, []map[string]interface{}{
+		{"id": int64(1), "name": "ann"},
+		{"id": int64(2), "name": "bob"},
+		{"id": nil, "name": "eve"},
+	})
+	orders := NewFrame(orderFields, []map[string]interface{}{
+		{"order_id": int64(10), "id": 2.0, "name": "book"},
+		{"order_id": int64(11), "id": 1.0, "name": "pen"},
+		{"order_id": int64(12), "id": 2.0, "name": "ink"},
+		{"order_id": int64(13), "id": 9.0, "name": "cup"},
+	})
+	return customers, orders
+}
+
+func joinRequest(joinType models.JoinType) *models.JoinRequest {
+	req := &models.JoinRequest{JoinType: joinType}
+	if joinType != models.JoinCross {
+		req.Conditions = []models.JoinCondition{{LeftField: "id", RightField: "id"}}
+	}
+	return req
+}
+
+func TestJoin(t *testing.T) {
+	limits := Limits{MaxJoinRows: 100}
+
+	// Test Case 1: Clashing names are disambiguated and shared keys merged,
+	// widening integer and float keys to float
+	t.Run("Schema", func(t *testing.T) {
+		join, err := CompileJoin(joinRequest(models.JoinLeft), customerFields, orderFields, limits)
+		require.NoError(t, err)
+
+		assert.Equal(t, []models.DataField{
+			{Name: "id", Type: models.DataTypeFloat},
+			{Name: "name_left", Type: models.DataTypeString, Required: true},
+			{Name: "order_id", Type: models.DataTypeInteger, Nullable: true},
+			{Name: "name_right", Type: models.DataTypeString, Nullable: true},
+		}, join.Fields())
+	})
+
+	// Test Case 2: Every join type with both strategies; matches follow left row order
+	expected := map[models.JoinType][]interface{}{
+		models.JoinInner: {int64(11), int64(10), int64(12)},
+		models.JoinLeft:  {int64(11), int64(10), int64(12), nil},
+		models.JoinRight: {int64(11), int64(10), int64(12), int64(13)},
+		models.JoinFull:  {int64(11), int64(10), int64(12), nil, int64(13)},
+	}
+	strategies := map[string]int{joinStrategyHash: hashJoinMaxBuildRows, joinStrategySortMerge: 0}
+	for joinType, orderIDs := range expected {
+		for strategy, hashLimit := range strategies {
+			joinType, orderIDs, strategy, hashLimit := joinType, orderIDs, strategy, hashLimit
+			t.Run(string(joinType)+" "+strategy, func(t *testing.T) {
+				join, err := CompileJoin(joinRequest(joinType), customerFields, orderFields, limits)
+				require.NoError(t, err)
+				join.hashLimit = hashLimit
+
+				left, right := joinFrames()
+				frame, err := join.Apply(left, right)
+				require.NoError(t, err)
+
+				assert.Equal(t, strategy, join.Strategy())
+				assert.Equal(t, orderIDs, frame.Column("order_id"))
+			})
+		}
+	}
+
+	// Test Case 3: Full joins keep unmatched rows of both sides
+	t.Run("Full Unmatched", func(t *testing.T) {
+		join, err := CompileJoin(joinRequest(models.JoinFull), customerFields, orderFields, limits)
+		require.NoError(t, err)
+		left, right := joinFrames()
+		frame, err := join.Apply(left, right)
+		require.NoError(t, err)
+
+		rows := frame.Rows()
+		require.Len(t, rows, 5)
+		assert.Equal(t, []interface{}{1.0, 2.0, 2.0, nil, 9.0}, frame.Column("id"))
+		assert.Equal(t, map[string]interface{}{"id": nil, "name_left": "eve", "order_id": nil, "name_right": nil}, rows[3])
+		assert.Equal(t, map[string]interface{}{"id": 9.0, "name_left": nil, "order_id": int64(13), "name_right": "cup"}, rows[4])
+	})
+
+	// Test Case 4: Cross joins respect the row cap
+	t.Run("Cross Row Cap", func(t *testing.T) {
+		join, err := CompileJoin(joinRequest(models.JoinCross), customerFields, orderFields, limits)
+		require.NoError(t, err)
+		left, right := joinFrames()
+		frame, err := join.Apply(left, right)
+		require.NoError(t, err)
+		assert.Equal(t, 12, frame.Len())
+		assert.Equal(t, "id_left", frame.Fields[0].Name)
+
+		join, err = CompileJoin(joinRequest(models.JoinCross), customerFields, orderFields, Limits{MaxJoinRows: 5})
+		require.NoError(t, err)
+		_, err = join.Apply(left, right)
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 5: Projection uses output names
+	t.Run("Fields", func(t *testing.T) {
+		req := joinRequest(models.JoinInner)
+		req.Fields = []string{"name_right", "id"}
+		join, err := CompileJoin(req, customerFields, orderFields, limits)
+		require.NoError(t, err)
+		left, right := joinFrames()
+		frame, err := join.Apply(left, right)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"name_right": "pen", "id": 1.0}, frame.Rows()[0])
+	})
+
+	// Test Case 6: Invalid requests are rejected with validation errors
+	invalid := map[string]*models.JoinRequest{
+		"Unknown Type":       {JoinType: "semi", Conditions: []models.JoinCondition{{LeftField: "id", RightField: "id"}}},
+		"Missing Conditions": {JoinType: models.JoinInner},
+		"Cross Conditions":   {JoinType: models.JoinCross, Conditions: []models.JoinCondition{{LeftField: "id", RightField: "id"}}},
+		"Unknown Left":       {JoinType: models.JoinInner, Conditions: []models.JoinCondition{{LeftField: "x", RightField: "id"}}},
+		"Unknown Right":      {JoinType: models.JoinInner, Conditions: []models.JoinCondition{{LeftField: "id", RightField: "x"}}},
+		"Type Mismatch":      {JoinType: models.JoinInner, Conditions: []models.JoinCondition{{LeftField: "id", RightField: "name"}}},
+		"Unknown Projection": {JoinType: models.JoinInner, Conditions: []models.JoinCondition{{LeftField: "id", RightField: "id"}}, Fields: []string{"name"}},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileJoin(req, customerFields, orderFields, limits)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRegexRepeat is the largest repetition count PostgreSQL accepts in a bound
+const maxRegexRepeat = 255
+
+// regexClasses are the shorthand escapes of the regex syntax, matching ASCII only
+var regexClasses = map[byte]string{
+	'd': `0-9`,
+	'w': `0-9A-Za-z_`,
+	's': `\t\n\f\r `,
+}
+
+// regexNamedClasses are the POSIX classes of the regex syntax, matching ASCII only
+var regexNamedClasses = map[string]string{
+	"alpha":  `A-Za-z`,
+	"digit":  `0-9`,
+	"alnum":  `0-9A-Za-z`,
+	"upper":  `A-Z`,
+	"lower":  `a-z`,
+	"xdigit": `0-9A-Fa-f`,
+	"space":  `\t\n\v\f\r `,
+	"blank":  `\t `,
+}
+
+// regexEscapes are the character escapes of the regex syntax
+var regexEscapes = map[byte]rune{
+	'n': '\n',
+	't': '\t',
+	'r': '\r',
+	'f': '\f',
+	'v': '\v',
+}
+
+// translateRegex checks that a regex filter pattern keeps to the syntax Go
+// (RE2) and PostgreSQL (ARE) read the same way, and returns its Go and
+// PostgreSQL forms, so a filter matches the same values whether it runs in
+// memory or in SQL. The syntax is:
+//
+//   - literal characters, with \ escaping any punctuation character
+//   - . matching any character, newlines included
+//   - ^ and $ anchoring at the start and end of the value
+//   - groups (...) and (?:...), and alternation |
+//   - the quantifiers *, +, ?, {n}, {n,} and {n,m} with counts up to 255,
+//     optionally followed by ? for a lazy match
+//   - bracket expressions [...] and [^...] holding characters, ranges such as
+//     a-z, the escapes below and the classes [:alpha:], [:digit:], [:alnum:],
+//     [:upper:], [:lower:], [:xdigit:], [:space:] and [:blank:]
+//   - the escapes \d, \w and \s and their negations \D, \W and \S (these
+//     outside brackets only), and \n, \t, \r, \f and \v
+//
+// Classes and shorthands match ASCII characters only. Everything else, such as
+// lookaround, backreferences, inline flags like (?i), word boundaries and
+// Unicode classes, is rejected, as are unescaped { } and ] that are not part of
+// a bound or bracket expression.
+func translateRegex(pattern string) (string, string, error) {
+	if !utf8.ValidString(pattern) {
+		return "", "", fmt.Errorf("invalid regular expression: pattern is not valid UTF-8")
+	}
+
+	t := &regexTranslator{pattern: pattern}
+	if err := t.alternation(); err != nil {
+		return "", "", fmt.Errorf("invalid regular expression: %v", err)
+	}
+	if t.pos < len(pattern) {
+		return "", "", fmt.Errorf("invalid regular expression: unexpected ) at position %d", t.pos)
+	}
+
+	// The Go form must compile; the translation keeps to RE2 syntax, so this
+	// only guards against mistakes in it
+	if _, err := regexp.Compile(t.goForm.String()); err != nil {
+		return "", "", fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return t.goForm.String(), t.pgForm.String(), nil
+}
+
+// regexTranslator parses a pattern, writing its Go and PostgreSQL forms
+type regexTranslator struct {
+	pattern string
+	pos     int
+	depth   int
+	goForm  strings.Builder
+	pgForm  strings.Builder
+}
+
+// write appends text used as is in both forms
+func (t *regexTranslator) write(s string) {
+	t.goForm.WriteString(s)
+	t.pgForm.WriteString(s)
+}
+
+// alternation parses branches separated by |, up to the end of the pattern or
+// of the enclosing group
+func (t *regexTranslator) alternation() error {
+	for {
+		if err := t.branch(); err != nil {
+			return err
+		}
+		if t.pos >= len(t.pattern) || t.pattern[t.pos] != '|' {
+			return nil
+		}
+		t.write("|")
+		t.pos++
+	}
+}
+
+// branch parses a sequence of atoms, each optionally quantified
+func (t *regexTranslator) branch() error {
+	for t.pos < len(t.pattern) {
+		c := t.pattern[t.pos]
+		if c == '|' || c == ')' {
+			if c == ')' && t.depth == 0 {
+				return fmt.Errorf("unexpected ) at position %d", t.pos)
+			}
+			return nil
+		}
+
+		quantifiable, err := t.atom()
+		if err != nil {
+			return err
+		}
+		if err := t.quantifier(quantifiable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// atom parses a single element and reports whether it may be quantified
+func (t *regexTranslator) atom() (bool, error) {
+	c := t.pattern[t.pos]
+	switch c {
+	case '^', '$':
+		t.write(string(c))
+		t.pos++
+		return false, nil
+
+	case '.':
+		// Go only matches newlines with the s flag, PostgreSQL always does
+		t.goForm.WriteString(`(?s:.)`)
+		t.pgForm.WriteString(`.`)
+		t.pos++
+		return true, nil
+
+	case '(':
+		start := t.pos
+		t.pos++
+		if strings.HasPrefix(t.pattern[t.pos:], "?:") {
+			t.write("(?:")
+			t.pos += 2
+		} else if t.pos < len(t.pattern) && t.pattern[t.pos] == '?' {
+			return false, fmt.Errorf("unsupported group syntax at position %d", start)
+		} else {
+			t.write("(")
+		}
+		t.depth++
+		if err := t.alternation(); err != nil {
+			return false, err
+		}
+		t.depth--
+		if t.pos >= len(t.pattern) {
+			return false, fmt.Errorf("missing ) for the group at position %d", start)
+		}
+		t.write(")")
+		t.pos++
+		return true, nil
+
+	case '[':
+		return true, t.bracket()
+
+	case '\\':
+		return true, t.escape()
+
+	case '*', '+', '?', '{':
+		return false, fmt.Errorf("missing argument to repetition operator at position %d", t.pos)
+
+	case '}', ']':
+		return false, fmt.Errorf("unescaped %c at position %d", c, t.pos)
+
+	default:
+		_, size := utf8.DecodeRuneInString(t.pattern[t.pos:])
+		t.write(t.pattern[t.pos : t.pos+size])
+		t.pos += size
+		return true, nil
+	}
+}
+
+// escape parses a backslash escape outside a bracket expression
+func (t *regexTranslator) escape() error {
+	start := t.pos
+	t.pos++
+	if t.pos >= len(t.pattern) {
+		return fmt.Errorf("trailing \\ at position %d", start)
+	}
+
+	c := t.pattern[t.pos]
+	t.pos++
+	if class, ok := regexClasses[c]; ok {
+		t.write("[" + class + "]")
+		return nil
+	}
+	if c >= 'A' && c <= 'Z' {
+		if class, ok := regexClasses[c+'a'-'A']; ok {
+			t.write("[^" + class + "]")
+			return nil
+		}
+	}
+	if r, ok := regexEscapes[c]; ok {
+		t.write(regexLiteral(r))
+		return nil
+	}
+	if isRegexPunct(c) {
+		t.write(regexLiteral(rune(c)))
+		return nil
+	}
+	return fmt.Errorf("unsupported escape \\%c at position %d", c, start)
+}
+
+// bracket parses a bracket expression
+func (t *regexTranslator) bracket() error {
+	start := t.pos
+	t.pos++
+	t.write("[")
+	if t.pos < len(t.pattern) && t.pattern[t.pos] == '^' {
+		t.write("^")
+		t.pos++
+	}
+
+	empty := true
+	for {
+		if t.pos >= len(t.pattern) {
+			return fmt.Errorf("missing ] for the bracket expression at position %d", start)
+		}
+		if t.pattern[t.pos] == ']' {
+			if empty {
+				return fmt.Errorf("empty bracket expression at position %d", start)
+			}
+			t.write("]")
+			t.pos++
+			return nil
+		}
+		empty = false
+
+		// Named classes expand to their ASCII ranges
+		if strings.HasPrefix(t.pattern[t.pos:], "[:") {
+			end := strings.Index(t.pattern[t.pos+2:], ":]")
+			if end < 0 {
+				return fmt.Errorf("unterminated character class at position %d", t.pos)
+			}
+			name := t.pattern[t.pos+2 : t.pos+2+end]
+			class, ok := regexNamedClasses[name]
+			if !ok {
+				return fmt.Errorf("unsupported character class [:%s:]", name)
+			}
+			t.write(class)
+			t.pos += end + 4
+			continue
+		}
+		if t.pattern[t.pos] == '[' && t.pos+1 < len(t.pattern) && (t.pattern[t.pos+1] == '.' || t.pattern[t.pos+1] == '=') {
+			return fmt.Errorf("unsupported bracket syntax at position %d", t.pos)
+		}
+
+		low, class, err := t.bracketChar()
+		if err != nil {
+			return err
+		}
+		if class != "" {
+			t.write(class)
+			continue
+		}
+
+		// A - between two characters makes a range, elsewhere it is literal
+		if t.pos+1 < len(t.pattern) && t.pattern[t.pos] == '-' && t.pattern[t.pos+1] != ']' {
+			t.pos++
+			high, class, err := t.bracketChar()
+			if err != nil {
+				return err
+			}
+			if class != "" || high < low {
+				return fmt.Errorf("invalid range in bracket expression at position %d", start)
+			}
+			t.write(regexLiteral(low) + "-" + regexLiteral(high))
+			continue
+		}
+		t.write(regexLiteral(low))
+	}
+}
+
+// bracketChar parses a character of a bracket expression, returning either the
+// character or the ranges of a shorthand class
+func (t *regexTranslator) bracketChar() (rune, string, error) {
+	start := t.pos
+	if t.pattern[t.pos] != '\\' {
+		r, size := utf8.DecodeRuneInString(t.pattern[t.pos:])
+		if r == '[' {
+			return 0, "", fmt.Errorf("unescaped [ in bracket expression at position %d", start)
+		}
+		t.pos += size
+		return r, "", nil
+	}
+
+	t.pos++
+	if t.pos >= len(t.pattern) {
+		return 0, "", fmt.Errorf("trailing \\ at position %d", start)
+	}
+	c := t.pattern[t.pos]
+	t.pos++
+	if class, ok := regexClasses[c]; ok {
+		return 0, class, nil
+	}
+	if r, ok := regexEscapes[c]; ok {
+		return r, "", nil
+	}
+	if isRegexPunct(c) {
+		return rune(c), "", nil
+	}
+	return 0, "", fmt.Errorf("unsupported escape \\%c in bracket expression at position %d", c, start)
+}
+
+// quantifier parses an optional quantifier following an atom
+func (t *regexTranslator) quantifier(quantifiable bool) error {
+	if t.pos >= len(t.pattern) {
+		return nil
+	}
+
+	start := t.pos
+	switch t.pattern[t.pos] {
+	case '*', '+', '?':
+		t.write(t.pattern[t.pos : t.pos+1])
+		t.pos++
+	case '{':
+		end := strings.IndexByte(t.pattern[t.pos:], '}')
+		if end < 0 {
+			return fmt.Errorf("unescaped { at position %d", start)
+		}
+		bound := t.pattern[t.pos+1 : t.pos+end]
+		if err := checkRegexBound(bound); err != nil {
+			return fmt.Errorf("invalid repetition {%s} at position %d: %v", bound, start, err)
+		}
+		t.write("{" + bound + "}")
+		t.pos += end + 1
+	default:
+		return nil
+	}
+	if !quantifiable {
+		return fmt.Errorf("missing argument to repetition operator at position %d", start)
+	}
+
+	// A lazy quantifier matches the same values, any other repetition is nested
+	if t.pos < len(t.pattern) && t.pattern[t.pos] == '?' {
+		t.write("?")
+		t.pos++
+	}
+	if t.pos < len(t.pattern) && strings.IndexByte("*+?{", t.pattern[t.pos]) >= 0 {
+		return fmt.Errorf("invalid nested repetition operator at position %d", t.pos)
+	}
+	return nil
+}
+
+// checkRegexBound checks the n, n, or n,m of a bounded repetition
+func checkRegexBound(bound string) error {
+	parts := strings.SplitN(bound, ",", 2)
+	counts := make([]int, 0, 2)
+	for i, part := range parts {
+		if part == "" && i == 1 {
+			continue
+		}
+		if part == "" || strings.TrimLeft(part, "0123456789") != "" {
+			return fmt.Errorf("counts must be numbers")
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n > maxRegexRepeat {
+			return fmt.Errorf("counts must be at most %d", maxRegexRepeat)
+		}
+		counts = append(counts, n)
+	}
+	if len(counts) == 2 && counts[1] < counts[0] {
+		return fmt.Errorf("the maximum is below the minimum")
+	}
+	return nil
+}
+
+// isRegexPunct reports whether an escaped byte stands for itself
+func isRegexPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte(`!"#$%&'()*+,-./:;<=>?@[\]^_{|}~`+"`", c) >= 0
+}
+
+// regexLiteral writes a character matching itself, escaping the characters
+// either syntax reads as an operator in or out of a bracket expression
+func regexLiteral(r rune) string {
+	switch r {
+	case '\n':
+		return `\n`
+	case '\t':
+		return `\t`
+	case '\r':
+		return `\r`
+	case '\f':
+		return `\f`
+	case '\v':
+		return `\v`
+	}
+	if r < utf8.RuneSelf && strings.IndexByte(`\.+*?()|[]{}^$-`, byte(r)) >= 0 {
+		return `\` + string(r)
+	}
+	return string(r)
+}
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateRegex(t *testing.T) {
+	// Test Case 1: Patterns of the common syntax keep their form in both
+	t.Run("Unchanged", func(t *testing.T) {
+		patterns := []string{
+			`^abc$`,
+			`a|b|`,
+			`(ab)+(?:cd)*?x{2}y{1,}z{0,3}`,
+			`[a-z_]`,
+			`[^0-9]`,
+			`\(\)\[\]\{\}\.\*\+\?\|\^\$\\`,
+			`café`,
+		}
+		for _, pattern := range patterns {
+			goPattern, pgPattern, err := translateRegex(pattern)
+			require.NoError(t, err, pattern)
+			assert.Equal(t, pattern, goPattern, pattern)
+			assert.Equal(t, pattern, pgPattern, pattern)
+		}
+	})
+
+	// Test Case 2: Constructs the engines read differently are rewritten
+	t.Run("Rewritten", func(t *testing.T) {
+		cases := []struct {
+			pattern   string
+			goPattern string
+			pgPattern string
+		}{
+			{`a.b`, `a(?s:.)b`, `a.b`},
+			{`\d+`, `[0-9]+`, `[0-9]+`},
+			{`\W`, `[^0-9A-Za-z_]`, `[^0-9A-Za-z_]`},
+			{`\s\n`, `[\t\n\f\r ]\n`, `[\t\n\f\r ]\n`},
+			{`[\d.-]`, `[0-9\.\-]`, `[0-9\.\-]`},
+			{`[[:alpha:]\]]`, `[A-Za-z\]]`, `[A-Za-z\]]`},
+			{`[+--]`, `[\+-\-]`, `[\+-\-]`},
+		}
+		for _, c := range cases {
+			goPattern, pgPattern, err := translateRegex(c.pattern)
+			require.NoError(t, err, c.pattern)
+			assert.Equal(t, c.goPattern, goPattern, c.pattern)
+			assert.Equal(t, c.pgPattern, pgPattern, c.pattern)
+		}
+	})
+
+	// Test Case 3: The Go form matches as PostgreSQL would
+	t.Run("Matching", func(t *testing.T) {
+		goPattern, _, err := translateRegex(`^a.c$`)
+		require.NoError(t, err)
+		assert.True(t, regexp.MustCompile(goPattern).MatchString("a\nc"))
+
+		goPattern, _, err = translateRegex(`^\w+$`)
+		require.NoError(t, err)
+		assert.True(t, regexp.MustCompile(goPattern).MatchString("abc_1"))
+		assert.False(t, regexp.MustCompile(goPattern).MatchString("né"))
+	})
+
+	// Test Case 4: Syntax outside the common subset is rejected
+	invalid := map[string]string{
+		"Unbalanced Group":       `(`,
+		"Unbalanced Close":       `a)`,
+		"Lookahead":              `a(?=b)`,
+		"Negative Lookbehind":    `(?<!a)b`,
+		"Inline Flags":           `(?i)abc`,
+		"Named Group":            `(?P<x>a)`,
+		"Backreference":          `(a)\1`,
+		"Word Boundary":          `\bword\b`,
+		"Unicode Class":          `\pL`,
+		"Hex Escape":             `\x41`,
+		"Quoted Literal":         `\Qa.b\E`,
+		"Negated Class In Range": `[\D]`,
+		"Collating Element":      `[[.a.]]`,
+		"Unknown Class":          `[[:word:]]`,
+		"Empty Bracket":          `[]a]`,
+		"Unterminated Bracket":   `[abc`,
+		"Reversed Range":         `[z-a]`,
+		"Leading Quantifier":     `*a`,
+		"Quantified Anchor":      `^*`,
+		"Nested Quantifier":      `a**`,
+		"Bound Too Large":        `a{256}`,
+		"Reversed Bound":         `a{3,2}`,
+		"Open Bound":             `a{,2}`,
+		"Bare Brace":             `a{b`,
+		"Bare Close Brace":       `a}`,
+		"Bare Close Bracket":     `a]`,
+		"Director":               `***=a`,
+		"Trailing Backslash":     `a\`,
+	}
+	for name, pattern := range invalid {
+		pattern := pattern
+		t.Run(name, func(t *testing.T) {
+			_, _, err := translateRegex(pattern)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,446 @@
+// Package query compiles and executes dataset queries. Requests are either
+// compiled into parameterised SQL over the row storage table or evaluated in
+// memory by the transform, aggregation and join engines.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Limits bounds the number of rows a single query may return. MaxJoinRows caps
+// the output of in-memory joins and MaxInputRows the rows a transformation,
+// aggregation, join or analysis loads from each dataset; zero disables either
+// cap.
+type Limits struct {
+	DefaultLimit int
+	MaxLimit     int
+	MaxJoinRows  int
+	MaxInputRows int
+}
+
+// SQL is a parameterised statement
+type SQL struct {
+	Query string
+	Args  []interface{}
+}
+
+// Statement holds the statements compiled from a QueryRequest: one selecting the
+// requested page of rows and one counting every matching row. Select returns the
+// row, its id and then the text of each sort key, from which the cursor of the
+// next page is built.
+type Statement struct {
+	Select SQL
+	Count  SQL
+	Limit  int
+}
+
+// Compile translates a query request into SQL over the dataset_rows table, or
+// over the rows of the version named by req.AsOfVersion. Field names are
+// validated against the schema and embedded as quoted literals, while every
+// filter value is passed as a parameter.
+func Compile(req *models.QueryRequest, schema *models.DataSchema, limits Limits) (*Statement, error) {
+	return CompileAfter(req, schema, limits, nil)
+}
+
+// CompileAfter compiles a query request like Compile, selecting only the rows
+// that sort after a cursor when it is set. Rows matching the filters are still
+// counted from the first.
+func CompileAfter(req *models.QueryRequest, schema *models.DataSchema, limits Limits, after *Cursor) (*Statement, error) {
+	c := newCompiler(schema)
+
+	// Source
+	source := "dataset_rows"
+	if req.AsOfVersion < 0 {
+		return nil, models.NewValidationError("as_of_version must not be negative")
+	}
+	if req.AsOfVersion > 0 {
+		source = VersionRows(c.bind(req.DatasetID), c.bind(req.AsOfVersion)) + " AS dataset_rows"
+	}
+
+	// Projection
+	projection := "data"
+	if len(req.Fields) > 0 {
+		pairs := make([]string, 0, len(req.Fields))
+		for _, name := range req.Fields {
+			field, err := c.field(name)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, fmt.Sprintf("%s, data->%s", pq.QuoteLiteral(name), pq.QuoteLiteral(field.Name)))
+		}
+		projection = "jsonb_build_object(" + strings.Join(pairs, ", ") + ")"
+	}
+
+	// Filters
+	where, err := c.where(req.DatasetID, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	countArgs := append([]interface{}(nil), c.args...)
+
+	// Sort
+	orderBy, err := c.orderBy(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{projection, "id"}
+	for _, s := range req.Sort {
+		field, _ := c.field(s.Field)
+		columns = append(columns, typedExpr(field)+"::text")
+	}
+
+	// Cursor
+	selectWhere := where
+	if after != nil {
+		if req.Offset != 0 {
+			return nil, models.NewValidationError("offset cannot be combined with a cursor")
+		}
+		if len(after.Keys) != len(req.Sort) {
+			return nil, models.NewValidationError("cursor does not match the sort fields")
+		}
+		selectWhere += " AND " + c.after(req.Sort, after)
+	}
+
+	// Pagination
+	limit := req.Limit
+	if limit <= 0 {
+		limit = limits.DefaultLimit
+	}
+	if limits.MaxLimit > 0 && limit > limits.MaxLimit {
+		return nil, models.NewValidationError("limit %d exceeds the maximum of %d", limit, limits.MaxLimit)
+	}
+	if req.Offset < 0 {
+		return nil, models.NewValidationError("offset must not be negative")
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %s OFFSET %s",
+		strings.Join(columns, ", "), source, selectWhere, orderBy, c.bind(limit), c.bind(req.Offset),
+	)
+
+	return &Statement{
+		Select: SQL{Query: query, Args: c.args},
+		Count:  SQL{Query: "SELECT COUNT(*) FROM " + source + " WHERE " + where, Args: countArgs},
+		Limit:  limit,
+	}, nil
+}
+
+// CompileRows translates filters into SQL selecting every row of a dataset that
+// matches all of them, in insertion order, for rows loaded to be processed in
+// memory
+func CompileRows(datasetID uuid.UUID, schema *models.DataSchema, filters []models.FilterCondition) (*SQL, error) {
+	c := newCompiler(schema)
+	where, err := c.where(datasetID, filters)
+	if err != nil {
+		return nil, err
+	}
+	return &SQL{Query: "SELECT data FROM dataset_rows WHERE " + where + " ORDER BY id", Args: c.args}, nil
+}
+
+// VersionRows returns a subquery over the rows of a dataset as they were at a
+// version, with the id, dataset_id, row_key and data columns of dataset_rows.
+// Each row is its latest write in the row history up to the version, unless
+// that write deleted it. The dataset and version are given as placeholders.
+func VersionRows(dataset, version string) string {
+	return "(SELECT id, dataset_id, row_key, data FROM (" +
+		"SELECT DISTINCT ON (row_key) row_id AS id, dataset_id, row_key, data FROM dataset_row_history" +
+		" WHERE dataset_id = " + dataset + " AND version <= " + version +
+		" ORDER BY row_key, version DESC, seq DESC) latest WHERE data IS NOT NULL)"
+}
+
+// compiler accumulates the parameters of a statement being built
+type compiler struct {
+	fields map[string]models.DataField
+	args   []interface{}
+}
+
+// newCompiler creates a compiler for rows of the given schema, whose fields
+// may also be referred to by their aliases
+func newCompiler(schema *models.DataSchema) *compiler {
+	fields := make(map[string]models.DataField, len(schema.Fields))
+	for _, field := range schema.Fields {
+		fields[field.Name] = field
+		for _, alias := range field.Aliases {
+			fields[alias] = field
+		}
+	}
+	return &compiler{fields: fields}
+}
+
+// bind adds a parameter and returns its placeholder
+func (c *compiler) bind(value interface{}) string {
+	c.args = append(c.args, value)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+// field looks up a schema field by name
+func (c *compiler) field(name string) (models.DataField, error) {
+	field, ok := c.fields[name]
+	if !ok {
+		return models.DataField{}, models.NewValidationError("unknown field %q", name)
+	}
+	return field, nil
+}
+
+// where builds the row predicate for a dataset and its filters
+func (c *compiler) where(datasetID uuid.UUID, filters []models.FilterCondition) (string, error) {
+	conditions := []string{"dataset_id = " + c.bind(datasetID)}
+	for i, filter := range filters {
+		condition, err := c.filter(filter)
+		if err != nil {
+			return "", models.NewValidationError("filter %d: %v", i, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// orderBy builds the ORDER BY clause, always ending with the insertion order so pages are stable
+func (c *compiler) orderBy(sort []models.SortField) (string, error) {
+	terms := make([]string, 0, len(sort)+1)
+	for _, s := range sort {
+		field, err := c.field(s.Field)
+		if err != nil {
+			return "", err
+		}
+		switch s.Direction {
+		case models.SortAsc:
+			terms = append(terms, typedExpr(field)+" ASC NULLS LAST")
+		case models.SortDesc:
+			terms = append(terms, typedExpr(field)+" DESC NULLS LAST")
+		default:
+			return "", models.NewValidationError("invalid sort direction %q for field %q", s.Direction, s.Field)
+		}
+	}
+	terms = append(terms, "id")
+	return strings.Join(terms, ", "), nil
+}
+
+// after builds the predicate selecting the rows that sort after the row a
+// cursor was taken from, in the order built by orderBy. Nulls sort last, so
+// only rows tied on a null key can follow it.
+func (c *compiler) after(sort []models.SortField, cursor *Cursor) string {
+	var terms, ties []string
+	for i, s := range sort {
+		field, _ := c.field(s.Field)
+		expr := typedExpr(field)
+		key := cursor.Keys[i]
+		if key == nil {
+			ties = append(ties, expr+" IS NULL")
+			continue
+		}
+
+		keyType := "jsonb"
+		if isScalar(field.Type) {
+			keyType = sqlTypes[field.Type]
+		}
+		param := c.bind(*key) + "::" + keyType
+		operator := ">"
+		if s.Direction == models.SortDesc {
+			operator = "<"
+		}
+		later := "(" + expr + " " + operator + " " + param + " OR " + expr + " IS NULL)"
+		terms = append(terms, strings.Join(append(ties[:len(ties):len(ties)], later), " AND "))
+		ties = append(ties, expr+" = "+param)
+	}
+	terms = append(terms, strings.Join(append(ties, "id > "+c.bind(cursor.ID)), " AND "))
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// filter compiles a single filter condition
+func (c *compiler) filter(filter models.FilterCondition) (string, error) {
+	field, err := c.field(filter.Field)
+	if err != nil {
+		return "", err
+	}
+	expr := typedExpr(field)
+	text := "(data->>" + pq.QuoteLiteral(field.Name) + ")"
+
+	switch filter.Operator {
+	case models.FilterEQ, models.FilterNE:
+		if filter.Value == nil {
+			if filter.Operator == models.FilterEQ {
+				return expr + " IS NULL", nil
+			}
+			return expr + " IS NOT NULL", nil
+		}
+		param, err := c.typedParam(field, filter.Value)
+		if err != nil {
+			return "", err
+		}
+		if filter.Operator == models.FilterEQ {
+			return expr + " = " + param, nil
+		}
+		return expr + " IS DISTINCT FROM " + param, nil
+
+	case models.FilterGT, models.FilterGTE, models.FilterLT, models.FilterLTE:
+		if !isOrdered(field.Type) {
+			return "", fmt.Errorf("operator %s is not supported for %s field %q", filter.Operator, field.Type, field.Name)
+		}
+		param, err := c.typedParam(field, filter.Value)
+		if err != nil {
+			return "", err
+		}
+		return expr + " " + comparisonOperators[filter.Operator] + " " + param, nil
+
+	case models.FilterIN, models.FilterNIN:
+		if !isScalar(field.Type) {
+			return "", fmt.Errorf("operator %s is not supported for %s field %q", filter.Operator, field.Type, field.Name)
+		}
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("operator %s requires an array value", filter.Operator)
+		}
+		texts := make([]string, len(values))
+		for i, value := range values {
+			if texts[i], err = scalarText(field, value); err != nil {
+				return "", err
+			}
+		}
+		param := c.bind(pq.Array(texts)) + "::" + sqlTypes[field.Type] + "[]"
+		if filter.Operator == models.FilterIN {
+			return expr + " = ANY(" + param + ")", nil
+		}
+		return "NOT COALESCE(" + expr + " = ANY(" + param + "), false)", nil
+
+	case models.FilterLIKE:
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("operator like requires a string pattern")
+		}
+		if _, err := likePattern(pattern); err != nil {
+			return "", err
+		}
+		return text + " LIKE " + c.bind(pattern), nil
+
+	case models.FilterREGEX:
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("operator regex requires a string pattern")
+		}
+		_, pgPattern, err := translateRegex(pattern)
+		if err != nil {
+			return "", err
+		}
+		return text + " ~ " + c.bind(pgPattern), nil
+
+	case models.FilterEXISTS:
+		exists := true
+		if filter.Value != nil {
+			b, ok := filter.Value.(bool)
+			if !ok {
+				return "", fmt.Errorf("operator exists requires a boolean value")
+			}
+			exists = b
+		}
+		if exists {
+			return "data ? " + pq.QuoteLiteral(field.Name), nil
+		}
+		return "NOT (data ? " + pq.QuoteLiteral(field.Name) + ")", nil
+
+	default:
+		return "", fmt.Errorf("unknown operator %q", filter.Operator)
+	}
+}
+
+// typedParam binds a comparison value cast to the SQL type of the field
+func (c *compiler) typedParam(field models.DataField, value interface{}) (string, error) {
+	if !isScalar(field.Type) {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for field %q: %v", field.Name, err)
+		}
+		return c.bind(string(encoded)) + "::jsonb", nil
+	}
+
+	text, err := scalarText(field, value)
+	if err != nil {
+		return "", err
+	}
+	return c.bind(text) + "::" + sqlTypes[field.Type], nil
+}
+
+// comparisonOperators maps ordering operators to SQL
+var comparisonOperators = map[models.FilterOperator]string{
+	models.FilterGT:  ">",
+	models.FilterGTE: ">=",
+	models.FilterLT:  "<",
+	models.FilterLTE: "<=",
+}
+
+// sqlTypes maps scalar data types to the SQL type their values are compared as
+var sqlTypes = map[models.DataType]string{
+	models.DataTypeString:   "text",
+	models.DataTypeInteger:  "numeric",
+	models.DataTypeFloat:    "numeric",
+	models.DataTypeBoolean:  "boolean",
+	models.DataTypeDateTime: "timestamptz",
+}
+
+// typedExpr returns the SQL expression extracting a field with its natural type
+func typedExpr(field models.DataField) string {
+	name := pq.QuoteLiteral(field.Name)
+	switch field.Type {
+	case models.DataTypeString:
+		return "(data->>" + name + ")"
+	case models.DataTypeArray, models.DataTypeObject:
+		return "(data->" + name + ")"
+	default:
+		return "(data->>" + name + ")::" + sqlTypes[field.Type]
+	}
+}
+
+// isScalar reports whether values of a type are compared as SQL scalars
+func isScalar(t models.DataType) bool {
+	_, ok := sqlTypes[t]
+	return ok
+}
+
+// isOrdered reports whether values of a type support range comparisons
+func isOrdered(t models.DataType) bool {
+	return t == models.DataTypeString || t == models.DataTypeInteger || t == models.DataTypeFloat || t == models.DataTypeDateTime
+}
+
+// scalarText converts a filter value to the text form of the field's SQL type,
+// rejecting values that cannot be compared with it
+func scalarText(field models.DataField, value interface{}) (string, error) {
+	switch field.Type {
+	case models.DataTypeInteger, models.DataTypeFloat:
+		f, ok := ToFloat(value)
+		if !ok {
+			return "", fmt.Errorf("value %v is not a number for field %q", value, field.Name)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+
+	case models.DataTypeBoolean:
+		b, ok := ToBool(value)
+		if !ok {
+			return "", fmt.Errorf("value %v is not a boolean for field %q", value, field.Name)
+		}
+		return strconv.FormatBool(b), nil
+
+	case models.DataTypeDateTime:
+		t, ok := ToTime(value)
+		if !ok {
+			return "", fmt.Errorf("value %v is not a datetime for field %q", value, field.Name)
+		}
+		return t.Format("2006-01-02T15:04:05.999999999Z07:00"), nil
+
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case nil, []interface{}, map[string]interface{}:
+			return "", fmt.Errorf("value %v is not a string for field %q", value, field.Name)
+		default:
+			return fmt.Sprint(v), nil
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = models.DataSchema{
+	Fields: []models.DataField{
+		{Name: "name", Type: models.DataTypeString},
+		{Name: "age", Type: models.DataTypeInteger},
+		{Name: "score", Type: models.DataTypeFloat},
+		{Name: "active", Type: models.DataTypeBoolean},
+		{Name: "created", Type: models.DataTypeDateTime},
+		{Name: "tags", Type: models.DataTypeArray},
+	},
+}
+
+var testLimits = Limits{DefaultLimit: 100, MaxLimit: 1000}
+
+func TestCompile(t *testing.T) {
+	datasetID := uuid.New()
+
+	// Test Case 1: Defaults select whole rows in insertion order
+	t.Run("Defaults", func(t *testing.T) {
+		stmt, err := Compile(&models.QueryRequest{DatasetID: datasetID}, &testSchema, testLimits)
+		require.NoError(t, err)
+
+		assert.Equal(t, "SELECT data, id FROM dataset_rows WHERE dataset_id = $1 ORDER BY id LIMIT $2 OFFSET $3", stmt.Select.Query)
+		assert.Equal(t, []interface{}{datasetID, 100, 0}, stmt.Select.Args)
+		assert.Equal(t, "SELECT COUNT(*) FROM dataset_rows WHERE dataset_id = $1", stmt.Count.Query)
+		assert.Equal(t, []interface{}{datasetID}, stmt.Count.Args)
+		assert.Equal(t, 100, stmt.Limit)
+	})
+
+	// Test Case 2: Projection, sort and pagination
+	t.Run("Fields Sort Pagination", func(t *testing.T) {
+		stmt, err := Compile(&models.QueryRequest{
+			DatasetID: datasetID,
+			Fields:    []string{"name", "age"},
+			Sort:      []models.SortField{{Field: "age", Direction: models.SortDesc}},
+			Limit:     10,
+			Offset:    20,
+		}, &testSchema, testLimits)
+		require.NoError(t, err)
+
+		assert.Equal(t,
+			"SELECT jsonb_build_object('name', data->'name', 'age', data->'age'), id, (data->>'age')::numeric::text FROM dataset_rows WHERE dataset_id = $1 "+
+				"ORDER BY (data->>'age')::numeric DESC NULLS LAST, id LIMIT $2 OFFSET $3",
+			stmt.Select.Query)
+		assert.Equal(t, []interface{}{datasetID, 10, 20}, stmt.Select.Args)
+	})
+
+	// Test Case 3: Every filter operator is parameterised
+	t.Run("Filter Operators", func(t *testing.T) {
+		stmt, err := Compile(&models.QueryRequest{
+			DatasetID: datasetID,
+			Filters: []models.FilterCondition{
+				{Field: "name", Operator: models.FilterEQ, Value: "alice"},
+				{Field: "age", Operator: models.FilterNE, Value: float64(30)},
+				{Field: "score", Operator: models.FilterGT, Value: 1.5},
+				{Field: "age", Operator: models.FilterGTE, Value: "18"},
+				{Field: "created", Operator: models.FilterLT, Value: "2024-01-02"},
+				{Field: "score", Operator: models.FilterLTE, Value: 10},
+				{Field: "name", Operator: models.FilterIN, Value: []interface{}{"a", "b"}},
+				{Field: "age", Operator: models.FilterNIN, Value: []interface{}{1.0, 2.0}},
+				{Field: "name", Operator: models.FilterLIKE, Value: "al%"},
+				{Field: "name", Operator: models.FilterREGEX, Value: "^a.*e$"},
+				{Field: "tags", Operator: models.FilterEXISTS, Value: false},
+				{Field: "active", Operator: models.FilterEQ, Value: nil},
+			},
+		}, &testSchema, testLimits)
+		require.NoError(t, err)
+
+		expected := "SELECT COUNT(*) FROM dataset_rows WHERE dataset_id = $1" +
+			" AND (data->>'name') = $2::text" +
+			" AND (data->>'age')::numeric IS DISTINCT FROM $3::numeric" +
+			" AND (data->>'score')::numeric > $4::numeric" +
+			" AND (data->>'age')::numeric >= $5::numeric" +
+			" AND (data->>'created')::timestamptz < $6::timestamptz" +
+			" AND (data->>'score')::numeric <= $7::numeric" +
+			" AND (data->>'name') = ANY($8::text[])" +
+			" AND NOT COALESCE((data->>'age')::numeric = ANY($9::numeric[]), false)" +
+			" AND (data->>'name') LIKE $10" +
+			" AND (data->>'name') ~ $11" +
+			" AND NOT (data ? 'tags')" +
+			" AND (data->>'active')::boolean IS NULL"
+		assert.Equal(t, expected, stmt.Count.Query)
+		assert.Equal(t, []interface{}{
+			datasetID, "alice", "30", "1.5", "18", "2024-01-02T00:00:00Z", "10",
+			pq.Array([]string{"a", "b"}), pq.Array([]string{"1", "2"}), "al%", "^a.*e$",
+		}, stmt.Count.Args)
+	})
+
+	// Test Case 4: Field names that need quoting are escaped
+	t.Run("Quoted Field Name", func(t *testing.T) {
+		schema := models.DataSchema{Fields: []models.DataField{{Name: "it's", Type: models.DataTypeString}}}
+		stmt, err := Compile(&models.QueryRequest{
+			DatasetID: datasetID,
+			Filters:   []models.FilterCondition{{Field: "it's", Operator: models.FilterEQ, Value: "x"}},
+		}, &schema, testLimits)
+		require.NoError(t, err)
+
+		assert.Contains(t, stmt.Count.Query, "(data->>'it''s') = $2::text")
+	})
+
+	// Test Case 5: A cursor selects the rows sorting after it
+	t.Run("Cursor", func(t *testing.T) {
+		age := "30"
+		req := &models.QueryRequest{
+			DatasetID: datasetID,
+			Sort: []models.SortField{
+				{Field: "age", Direction: models.SortDesc},
+				{Field: "name", Direction: models.SortAsc},
+			},
+			Limit: 10,
+		}
+		stmt, err := CompileAfter(req, &testSchema, testLimits, &Cursor{Keys: []*string{&age, nil}, ID: 42})
+		require.NoError(t, err)
+
+		assert.Equal(t,
+			"SELECT data, id, (data->>'age')::numeric::text, (data->>'name')::text FROM dataset_rows WHERE dataset_id = $1 AND "+
+				"(((data->>'age')::numeric < $2::numeric OR (data->>'age')::numeric IS NULL) OR "+
+				"(data->>'age')::numeric = $2::numeric AND (data->>'name') IS NULL AND id > $3) "+
+				"ORDER BY (data->>'age')::numeric DESC NULLS LAST, (data->>'name') ASC NULLS LAST, id LIMIT $4 OFFSET $5",
+			stmt.Select.Query)
+		assert.Equal(t, []interface{}{datasetID, "30", int64(42), 10, 0}, stmt.Select.Args)
+		assert.Equal(t, "SELECT COUNT(*) FROM dataset_rows WHERE dataset_id = $1", stmt.Count.Query)
+
+		// Cursors cannot be combined with offsets or other sort orders
+		var validationErr *models.ValidationError
+		req.Offset = 10
+		_, err = CompileAfter(req, &testSchema, testLimits, &Cursor{Keys: []*string{&age, nil}, ID: 42})
+		assert.ErrorAs(t, err, &validationErr)
+		req.Offset = 0
+		_, err = CompileAfter(req, &testSchema, testLimits, &Cursor{Keys: []*string{&age}, ID: 42})
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	// Test Case 6: Queries as of a version read the rows from the row history
+	t.Run("As Of Version", func(t *testing.T) {
+		stmt, err := Compile(&models.QueryRequest{
+			DatasetID:   datasetID,
+			Filters:     []models.FilterCondition{{Field: "name", Operator: models.FilterEQ, Value: "alice"}},
+			AsOfVersion: 3,
+		}, &testSchema, testLimits)
+		require.NoError(t, err)
+
+		source := "(SELECT id, dataset_id, row_key, data FROM (" +
+			"SELECT DISTINCT ON (row_key) row_id AS id, dataset_id, row_key, data FROM dataset_row_history " +
+			"WHERE dataset_id = $1 AND version <= $2 ORDER BY row_key, version DESC, seq DESC) latest " +
+			"WHERE data IS NOT NULL) AS dataset_rows"
+		assert.Equal(t,
+			"SELECT data, id FROM "+source+" WHERE dataset_id = $3 AND (data->>'name') = $4::text ORDER BY id LIMIT $5 OFFSET $6",
+			stmt.Select.Query)
+		assert.Equal(t, []interface{}{datasetID, int64(3), datasetID, "alice", 100, 0}, stmt.Select.Args)
+		assert.Equal(t, "SELECT COUNT(*) FROM "+source+" WHERE dataset_id = $3 AND (data->>'name') = $4::text", stmt.Count.Query)
+		assert.Equal(t, []interface{}{datasetID, int64(3), datasetID, "alice"}, stmt.Count.Args)
+	})
+
+	// Test Case 7: Aliases of renamed fields read the field under the requested name
+	t.Run("Aliases", func(t *testing.T) {
+		schema := models.DataSchema{Fields: []models.DataField{
+			{Name: "full_name", Type: models.DataTypeString, Aliases: []string{"name"}},
+		}}
+		stmt, err := Compile(&models.QueryRequest{
+			DatasetID: datasetID,
+			Fields:    []string{"name"},
+			Filters:   []models.FilterCondition{{Field: "name", Operator: models.FilterEQ, Value: "alice"}},
+		}, &schema, testLimits)
+		require.NoError(t, err)
+
+		assert.Equal(t,
+			"SELECT jsonb_build_object('name', data->'full_name'), id FROM dataset_rows WHERE dataset_id = $1 AND (data->>'full_name') = $2::text ORDER BY id LIMIT $3 OFFSET $4",
+			stmt.Select.Query)
+	})
+
+	// Test Case 8: Invalid requests are rejected with validation errors
+	invalid := map[string]models.QueryRequest{
+		"Unknown Field":       {Fields: []string{"missing"}},
+		"Unknown Sort Field":  {Sort: []models.SortField{{Field: "missing", Direction: models.SortAsc}}},
+		"Bad Sort Direction":  {Sort: []models.SortField{{Field: "age", Direction: "up"}}},
+		"Unknown Filter":      {Filters: []models.FilterCondition{{Field: "missing", Operator: models.FilterEQ, Value: 1}}},
+		"Unknown Operator":    {Filters: []models.FilterCondition{{Field: "age", Operator: "between", Value: 1}}},
+		"Non Numeric Value":   {Filters: []models.FilterCondition{{Field: "age", Operator: models.FilterGT, Value: "old"}}},
+		"Range On Boolean":    {Filters: []models.FilterCondition{{Field: "active", Operator: models.FilterGT, Value: true}}},
+		"In Without Array":    {Filters: []models.FilterCondition{{Field: "name", Operator: models.FilterIN, Value: "a"}}},
+		"Invalid Regex":       {Filters: []models.FilterCondition{{Field: "name", Operator: models.FilterREGEX, Value: "("}}},
+		"Regex Lookahead":     {Filters: []models.FilterCondition{{Field: "name", Operator: models.FilterREGEX, Value: "a(?=b)"}}},
+		"Invalid Datetime":    {Filters: []models.FilterCondition{{Field: "created", Operator: models.FilterLT, Value: "yesterday"}}},
+		"Limit Above Maximum": {Limit: 5000},
+		"Negative Offset":     {Offset: -1},
+		"Negative Version":    {AsOfVersion: -1},
+	}
+	for name, req := range invalid {
+		req := req
+		t.Run(name, func(t *testing.T) {
+			req.DatasetID = datasetID
+			_, err := Compile(&req, &testSchema, testLimits)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestCompileRows(t *testing.T) {
+	datasetID := uuid.New()
+
+	// Test Case 1: Filters select the matching rows in insertion order
+	t.Run("Filters", func(t *testing.T) {
+		stmt, err := CompileRows(datasetID, &testSchema, []models.FilterCondition{
+			{Field: "age", Operator: models.FilterGTE, Value: 18},
+			{Field: "name", Operator: models.FilterLIKE, Value: "al%"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "SELECT data FROM dataset_rows WHERE dataset_id = $1 AND (data->>'age')::numeric >= $2::numeric AND (data->>'name') LIKE $3 ORDER BY id", stmt.Query)
+		assert.Equal(t, []interface{}{datasetID, "18", "al%"}, stmt.Args)
+	})
+
+	// Test Case 2: Invalid filters are rejected with validation errors
+	t.Run("Unknown Field", func(t *testing.T) {
+		_, err := CompileRows(datasetID, &testSchema, []models.FilterCondition{{Field: "missing", Operator: models.FilterEQ, Value: 1}})
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
@@ -0,0 +1,935 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// Transform is a validated transformation pipeline. Each step is checked against
+// the schema produced by the previous steps when the pipeline is compiled, so
+// Fields describes the output before any row is processed.
+type Transform struct {
+	steps  []transformStep
+	fields []models.DataField
+}
+
+// transformStep is a single compiled step of a pipeline
+type transformStep interface {
+	// plan validates the step against its input fields and returns its output fields
+	plan(fields []models.DataField) ([]models.DataField, error)
+	// apply runs the step over a frame with the fields passed to plan
+	apply(frame *Frame) (*Frame, error)
+}
+
+// CompileTransform decodes and validates the steps of a pipeline over rows with
+// the given fields. Every step's params are decoded, and all decoding errors are
+// reported together along with the index of the offending step; schema checks
+// stop at the first failing step since later steps depend on its output.
+func CompileTransform(steps []models.TransformStep, fields []models.DataField) (*Transform, error) {
+	if len(steps) == 0 {
+		return nil, models.NewValidationError("transform requires at least one step")
+	}
+
+	// Decode params
+	compiled := make([]transformStep, len(steps))
+	var problems []string
+	for i, step := range steps {
+		s, err := newTransformStep(step)
+		if err != nil {
+			problems = append(problems, stepError(i, step.Type, err))
+			continue
+		}
+		compiled[i] = s
+	}
+	if len(problems) > 0 {
+		return nil, models.NewValidationError("invalid transform: %s", strings.Join(problems, "; "))
+	}
+
+	// Check each step against the schema it receives
+	current := append([]models.DataField(nil), fields...)
+	for i, s := range compiled {
+		next, err := s.plan(current)
+		if err != nil {
+			return nil, models.NewValidationError("invalid transform: %s", stepError(i, steps[i].Type, err))
+		}
+		current = next
+	}
+
+	return &Transform{steps: compiled, fields: current}, nil
+}
+
+// Fields returns the fields of the pipeline output
+func (t *Transform) Fields() []models.DataField {
+	return append([]models.DataField(nil), t.fields...)
+}
+
+// Apply runs the pipeline over a frame with the fields the pipeline was compiled for
+func (t *Transform) Apply(frame *Frame) (*Frame, error) {
+	for i, s := range t.steps {
+		out, err := s.apply(frame)
+		if err != nil {
+			var validationErr *models.ValidationError
+			if errors.As(err, &validationErr) {
+				return nil, models.NewValidationError("transform failed at steps[%d]: %s", i, validationErr.Message)
+			}
+			return nil, fmt.Errorf("transform failed at steps[%d]: %w", i, err)
+		}
+		frame = out
+	}
+	return frame, nil
+}
+
+// stepError formats an error of a step, identified by its position in the request
+func stepError(i int, t models.TransformType, err error) string {
+	return fmt.Sprintf("steps[%d] (%s): %v", i, t, err)
+}
+
+// newTransformStep decodes the params of a step
+func newTransformStep(step models.TransformStep) (transformStep, error) {
+	switch step.Type {
+	case models.TransformSelect:
+		s := &selectStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformRename:
+		s := &renameStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformFilter:
+		s := &filterStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformSort:
+		s := &sortStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformAddColumn:
+		s := &addColumnStep{}
+		if err := DecodeParams(step.Params, &s.params); err != nil {
+			return nil, err
+		}
+		if s.params.Expression != "" {
+			expr, err := ParseExpr(s.params.Expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expression: %v", err)
+			}
+			s.expr = expr
+		}
+		return s, nil
+	case models.TransformCast:
+		s := &castStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformDrop:
+		s := &dropStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformFill:
+		s := &fillStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	case models.TransformReplace:
+		s := &replaceStep{}
+		if err := DecodeParams(step.Params, &s.params); err != nil {
+			return nil, err
+		}
+		if s.params.Pattern != "" {
+			re, err := regexp.Compile(s.params.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression: %v", err)
+			}
+			s.pattern = re
+		}
+		return s, nil
+	case models.TransformNormalize:
+		s := &normalizeStep{}
+		return s, DecodeParams(step.Params, &s.params)
+	default:
+		return nil, fmt.Errorf("unknown transform type %q", step.Type)
+	}
+}
+
+// DecodeParams strictly decodes request params into their typed form, rejecting
+// unknown keys and values of the wrong type
+func DecodeParams(params map[string]interface{}, out interface{}) error {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("invalid params: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("param %q must be of type %s", typeErr.Field, typeErr.Type)
+		}
+		return fmt.Errorf("invalid params: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// validType reports whether a data type is known
+func validType(t models.DataType) bool {
+	return isScalar(t) || t == models.DataTypeArray || t == models.DataTypeObject
+}
+
+// lookupFields resolves field names to their positions, rejecting unknown and
+// repeated names
+func lookupFields(fields []models.DataField, names []string) ([]int, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	indices := make([]int, len(names))
+	seen := make(map[int]bool, len(names))
+	for i, name := range names {
+		if indices[i] = fieldIndex(fields, name); indices[i] < 0 {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if seen[indices[i]] {
+			return nil, fmt.Errorf("field %q is listed more than once", name)
+		}
+		seen[indices[i]] = true
+	}
+	return indices, nil
+}
+
+// withColumns returns a frame with new fields and columns and the same rows
+func withColumns(frame *Frame, fields []models.DataField, columns [][]interface{}) *Frame {
+	return &Frame{Fields: fields, Columns: columns, rows: frame.Len()}
+}
+
+// replaceColumns copies a frame, substituting the columns at the given positions
+func replaceColumns(frame *Frame, fields []models.DataField, replaced map[int][]interface{}) *Frame {
+	columns := append([][]interface{}(nil), frame.Columns...)
+	for i, column := range replaced {
+		columns[i] = column
+	}
+	return withColumns(frame, fields, columns)
+}
+
+// select
+
+type selectStep struct {
+	params  models.SelectParams
+	indices []int
+}
+
+func (s *selectStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	indices, err := lookupFields(fields, s.params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	s.indices = indices
+	out := make([]models.DataField, len(indices))
+	for i, index := range indices {
+		out[i] = fields[index]
+	}
+	return out, nil
+}
+
+func (s *selectStep) apply(frame *Frame) (*Frame, error) {
+	fields := make([]models.DataField, len(s.indices))
+	columns := make([][]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		fields[i] = frame.Fields[index]
+		columns[i] = frame.Columns[index]
+	}
+	return withColumns(frame, fields, columns), nil
+}
+
+// rename
+
+type renameStep struct {
+	params models.RenameParams
+	fields []models.DataField
+}
+
+func (s *renameStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	if len(s.params.Mapping) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	names := make([]string, 0, len(s.params.Mapping))
+	for from := range s.params.Mapping {
+		names = append(names, from)
+	}
+	sort.Strings(names)
+
+	out := append([]models.DataField(nil), fields...)
+	for _, from := range names {
+		to := s.params.Mapping[from]
+		index := fieldIndex(fields, from)
+		if index < 0 {
+			return nil, fmt.Errorf("unknown field %q", from)
+		}
+		if to == "" {
+			return nil, fmt.Errorf("new name for field %q is empty", from)
+		}
+		out[index].Name = to
+	}
+
+	seen := make(map[string]bool, len(out))
+	for _, field := range out {
+		if seen[field.Name] {
+			return nil, fmt.Errorf("field %q would be declared more than once", field.Name)
+		}
+		seen[field.Name] = true
+	}
+	s.fields = out
+	return out, nil
+}
+
+func (s *renameStep) apply(frame *Frame) (*Frame, error) {
+	return withColumns(frame, s.fields, frame.Columns), nil
+}
+
+// filter
+
+type filterStep struct {
+	params models.FilterParams
+	filter *Filter
+}
+
+func (s *filterStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	if len(s.params.Conditions) == 0 {
+		return nil, fmt.Errorf("no conditions given")
+	}
+	filter, err := CompileFilter(s.params.Conditions, fields, s.params.Mode)
+	if err != nil {
+		return nil, err
+	}
+	s.filter = filter
+	return fields, nil
+}
+
+func (s *filterStep) apply(frame *Frame) (*Frame, error) {
+	return s.filter.Apply(frame), nil
+}
+
+// sort
+
+type sortStep struct {
+	params  models.SortParams
+	indices []int
+}
+
+func (s *sortStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	indices, err := lookupSortFields(fields, s.params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	s.indices = indices
+	return fields, nil
+}
+
+func (s *sortStep) apply(frame *Frame) (*Frame, error) {
+	return sortFrame(frame, s.indices, s.params.Fields), nil
+}
+
+// lookupSortFields validates sort fields and resolves their positions
+func lookupSortFields(fields []models.DataField, sortFields []models.SortField) ([]int, error) {
+	names := make([]string, len(sortFields))
+	for i, field := range sortFields {
+		names[i] = field.Field
+		if field.Direction != models.SortAsc && field.Direction != models.SortDesc {
+			return nil, fmt.Errorf("invalid sort direction %q for field %q", field.Direction, field.Field)
+		}
+	}
+	return lookupFields(fields, names)
+}
+
+// sortFrame stably orders the rows of a frame by the fields at the given positions
+func sortFrame(frame *Frame, indices []int, sortFields []models.SortField) *Frame {
+	keys := make([][]interface{}, len(indices))
+	for k, index := range indices {
+		keys[k] = convertColumn(frame.Columns[index], frame.Fields[index].Type)
+	}
+
+	order := make([]int, frame.Len())
+	for r := range order {
+		order[r] = r
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for k, key := range keys {
+			cmp := compareNullsLast(key[order[a]], key[order[b]], sortFields[k].Direction == models.SortDesc)
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return frame.take(order)
+}
+
+// convertColumn converts the values of a column for comparison; values that
+// cannot be converted to the field type are treated as null
+func convertColumn(column []interface{}, t models.DataType) []interface{} {
+	converted := make([]interface{}, len(column))
+	for r, value := range column {
+		if v, err := ConvertValue(value, t); err == nil {
+			converted[r] = v
+		}
+	}
+	return converted
+}
+
+// compareNullsLast orders converted values with nulls after every other value
+// regardless of direction
+func compareNullsLast(a, b interface{}, desc bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case desc:
+		return -compareNative(a, b)
+	default:
+		return compareNative(a, b)
+	}
+}
+
+// add_column
+
+type addColumnStep struct {
+	params models.AddColumnParams
+	expr   *Expr
+	field  models.DataField
+	index  int
+	value  interface{}
+}
+
+func (s *addColumnStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	if s.params.Name == "" {
+		return nil, fmt.Errorf("param \"name\" is required")
+	}
+	if (s.expr == nil) == (s.params.Value == nil) {
+		return nil, fmt.Errorf("exactly one of \"expression\" and \"value\" is required")
+	}
+	if s.params.Type != "" && !validType(s.params.Type) {
+		return nil, fmt.Errorf("unknown type %q", s.params.Type)
+	}
+
+	t := s.params.Type
+	if s.expr != nil {
+		inferred, err := s.expr.Bind(fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression: %v", err)
+		}
+		if t == "" {
+			t = inferred
+		}
+	} else {
+		if t == "" {
+			t = InferType([]interface{}{s.params.Value})
+		}
+		value, err := CastValue(s.params.Value, t)
+		if err != nil {
+			return nil, err
+		}
+		s.value = value
+	}
+
+	// A column with an existing name replaces that field in place
+	s.field = models.DataField{Name: s.params.Name, Type: t}
+	out := append([]models.DataField(nil), fields...)
+	s.index = fieldIndex(fields, s.params.Name)
+	if s.index >= 0 {
+		out[s.index] = s.field
+	} else {
+		s.index = len(out)
+		out = append(out, s.field)
+	}
+	return out, nil
+}
+
+func (s *addColumnStep) apply(frame *Frame) (*Frame, error) {
+	column := make([]interface{}, frame.Len())
+	if s.expr == nil {
+		for r := range column {
+			column[r] = s.value
+		}
+	} else {
+		row := make([]interface{}, len(frame.Columns))
+		for r := range column {
+			for i, values := range frame.Columns {
+				row[i] = values[r]
+			}
+			value, err := CastValue(s.expr.Eval(row), s.field.Type)
+			if err != nil {
+				return nil, models.NewValidationError("row %d: %v", r, err)
+			}
+			column[r] = value
+		}
+	}
+
+	fields := append([]models.DataField(nil), frame.Fields...)
+	columns := append([][]interface{}(nil), frame.Columns...)
+	if s.index < len(fields) {
+		fields[s.index] = s.field
+		columns[s.index] = column
+	} else {
+		fields = append(fields, s.field)
+		columns = append(columns, column)
+	}
+	return withColumns(frame, fields, columns), nil
+}
+
+// cast
+
+// Cast error handling modes
+const (
+	castOnErrorFail = "fail"
+	castOnErrorNull = "null"
+)
+
+type castStep struct {
+	params  models.CastParams
+	names   []string
+	indices []int
+	fields  []models.DataField
+}
+
+func (s *castStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	switch s.params.OnError {
+	case "", castOnErrorFail, castOnErrorNull:
+	default:
+		return nil, fmt.Errorf("invalid on_error %q", s.params.OnError)
+	}
+
+	// Sort names so errors do not depend on map iteration order
+	s.names = make([]string, 0, len(s.params.Fields))
+	for name := range s.params.Fields {
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+	indices, err := lookupFields(fields, s.names)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]models.DataField(nil), fields...)
+	for i, name := range s.names {
+		t := s.params.Fields[name]
+		if !validType(t) {
+			return nil, fmt.Errorf("unknown type %q for field %q", t, name)
+		}
+		out[indices[i]].Type = t
+	}
+	s.indices = indices
+	s.fields = out
+	return out, nil
+}
+
+func (s *castStep) apply(frame *Frame) (*Frame, error) {
+	replaced := make(map[int][]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		t := s.params.Fields[s.names[i]]
+		column := make([]interface{}, frame.Len())
+		for r, value := range frame.Columns[index] {
+			cast, err := CastValue(value, t)
+			if err != nil {
+				if s.params.OnError != castOnErrorNull {
+					return nil, models.NewValidationError("row %d: field %q: %v", r, s.names[i], err)
+				}
+				cast = nil
+			}
+			column[r] = cast
+		}
+		replaced[index] = column
+	}
+	return replaceColumns(frame, s.fields, replaced), nil
+}
+
+// drop
+
+type dropStep struct {
+	params models.DropParams
+	keep   []int
+}
+
+func (s *dropStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	indices, err := lookupFields(fields, s.params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	dropped := make(map[int]bool, len(indices))
+	for _, index := range indices {
+		dropped[index] = true
+	}
+
+	s.keep = s.keep[:0]
+	out := make([]models.DataField, 0, len(fields)-len(indices))
+	for i, field := range fields {
+		if !dropped[i] {
+			s.keep = append(s.keep, i)
+			out = append(out, field)
+		}
+	}
+	return out, nil
+}
+
+func (s *dropStep) apply(frame *Frame) (*Frame, error) {
+	fields := make([]models.DataField, len(s.keep))
+	columns := make([][]interface{}, len(s.keep))
+	for i, index := range s.keep {
+		fields[i] = frame.Fields[index]
+		columns[i] = frame.Columns[index]
+	}
+	return withColumns(frame, fields, columns), nil
+}
+
+// fill
+
+// Fill strategies
+const (
+	fillValue    = "value"
+	fillForward  = "forward"
+	fillBackward = "backward"
+	fillMean     = "mean"
+	fillMedian   = "median"
+	fillMode     = "mode"
+)
+
+type fillStep struct {
+	params  models.FillParams
+	indices []int
+	values  []interface{}
+}
+
+func (s *fillStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	strategy := s.params.Strategy
+	switch strategy {
+	case "":
+		strategy = fillValue
+	case fillValue, fillForward, fillBackward, fillMean, fillMedian, fillMode:
+	default:
+		return nil, fmt.Errorf("invalid strategy %q", strategy)
+	}
+	s.params.Strategy = strategy
+
+	if len(s.params.Fields) == 0 {
+		s.indices = make([]int, len(fields))
+		for i := range fields {
+			s.indices[i] = i
+		}
+	} else {
+		indices, err := lookupFields(fields, s.params.Fields)
+		if err != nil {
+			return nil, err
+		}
+		s.indices = indices
+	}
+
+	if strategy != fillValue && s.params.Value != nil {
+		return nil, fmt.Errorf("param \"value\" is only allowed with strategy %q", fillValue)
+	}
+	s.values = make([]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		field := fields[index]
+		switch strategy {
+		case fillValue:
+			if s.params.Value == nil {
+				return nil, fmt.Errorf("param \"value\" is required")
+			}
+			value, err := CastValue(s.params.Value, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", field.Name, err)
+			}
+			s.values[i] = value
+		case fillMean, fillMedian:
+			if !isNumeric(field.Type) {
+				return nil, fmt.Errorf("strategy %s requires a numeric field, %q is %s", strategy, field.Name, field.Type)
+			}
+		}
+	}
+	return fields, nil
+}
+
+func (s *fillStep) apply(frame *Frame) (*Frame, error) {
+	replaced := make(map[int][]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		field := frame.Fields[index]
+		column := append([]interface{}(nil), frame.Columns[index]...)
+
+		switch s.params.Strategy {
+		case fillForward:
+			var last interface{}
+			for r, value := range column {
+				if value == nil {
+					column[r] = last
+				} else {
+					last = value
+				}
+			}
+		case fillBackward:
+			var next interface{}
+			for r := len(column) - 1; r >= 0; r-- {
+				if column[r] == nil {
+					column[r] = next
+				} else {
+					next = column[r]
+				}
+			}
+		default:
+			fill := s.values[i]
+			switch s.params.Strategy {
+			case fillMean, fillMedian:
+				fill = numericFill(column, field.Type, s.params.Strategy == fillMedian)
+			case fillMode:
+				fill = modeOf(column, field.Type)
+			}
+			for r, value := range column {
+				if value == nil {
+					column[r] = fill
+				}
+			}
+		}
+		replaced[index] = column
+	}
+	return replaceColumns(frame, frame.Fields, replaced), nil
+}
+
+// numericFill computes the mean or median of the non-null numbers of a column,
+// rounded for integer fields; it is nil when the column has no numbers
+func numericFill(column []interface{}, t models.DataType, median bool) interface{} {
+	numbers := make([]float64, 0, len(column))
+	for _, value := range column {
+		if f, ok := numeric(value); ok {
+			numbers = append(numbers, f)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+
+	result := mean(numbers)
+	if median {
+		sort.Float64s(numbers)
+		result = percentile(numbers, 50)
+	}
+
+	if t == models.DataTypeInteger {
+		return int64(math.Round(result))
+	}
+	return result
+}
+
+// modeOf returns the most frequent non-null value of a column, preferring the
+// value seen first on ties
+func modeOf(column []interface{}, t models.DataType) interface{} {
+	counts := make(map[string]int)
+	var best interface{}
+	bestCount := 0
+	for _, value := range column {
+		if value == nil {
+			continue
+		}
+		key := FormatValue(value)
+		if converted, err := ConvertValue(value, t); err == nil {
+			key = FormatValue(converted)
+		}
+		counts[key]++
+		if counts[key] > bestCount {
+			best, bestCount = value, counts[key]
+		}
+	}
+	return best
+}
+
+// replace
+
+type replaceStep struct {
+	params  models.ReplaceParams
+	pattern *regexp.Regexp
+	indices []int
+	from    [][]interface{}
+	to      [][]interface{}
+}
+
+func (s *replaceStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	if (s.pattern == nil) == (len(s.params.Replacements) == 0) {
+		return nil, fmt.Errorf("exactly one of \"replacements\" and \"pattern\" is required")
+	}
+	if s.pattern == nil && s.params.Replacement != "" {
+		return nil, fmt.Errorf("param \"replacement\" requires \"pattern\"")
+	}
+	indices, err := lookupFields(fields, s.params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	s.indices = indices
+
+	s.from = make([][]interface{}, len(indices))
+	s.to = make([][]interface{}, len(indices))
+	for i, index := range indices {
+		field := fields[index]
+		if s.pattern != nil {
+			if field.Type != models.DataTypeString {
+				return nil, fmt.Errorf("pattern replacement requires a string field, %q is %s", field.Name, field.Type)
+			}
+			continue
+		}
+		for _, replacement := range s.params.Replacements {
+			from, err := ConvertValue(replacement.From, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%v for field %q", err, field.Name)
+			}
+			to, err := CastValue(replacement.To, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", field.Name, err)
+			}
+			s.from[i] = append(s.from[i], from)
+			s.to[i] = append(s.to[i], to)
+		}
+	}
+	return fields, nil
+}
+
+func (s *replaceStep) apply(frame *Frame) (*Frame, error) {
+	replaced := make(map[int][]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		t := frame.Fields[index].Type
+		column := make([]interface{}, frame.Len())
+		for r, value := range frame.Columns[index] {
+			column[r] = value
+			if s.pattern != nil {
+				if str, ok := value.(string); ok {
+					column[r] = s.pattern.ReplaceAllString(str, s.params.Replacement)
+				}
+				continue
+			}
+
+			converted, err := ConvertValue(value, t)
+			if err != nil {
+				continue
+			}
+			for j, from := range s.from[i] {
+				if from == nil && converted == nil || from != nil && converted != nil && compareNative(converted, from) == 0 {
+					column[r] = s.to[i][j]
+					break
+				}
+			}
+		}
+		replaced[index] = column
+	}
+	return replaceColumns(frame, frame.Fields, replaced), nil
+}
+
+// normalize
+
+// Normalization methods
+const (
+	normalizeMinMax = "minmax"
+	normalizeZScore = "zscore"
+)
+
+type normalizeStep struct {
+	params  models.NormalizeParams
+	indices []int
+	fields  []models.DataField
+}
+
+func (s *normalizeStep) plan(fields []models.DataField) ([]models.DataField, error) {
+	switch s.params.Method {
+	case "":
+		s.params.Method = normalizeMinMax
+	case normalizeMinMax, normalizeZScore:
+	default:
+		return nil, fmt.Errorf("invalid method %q", s.params.Method)
+	}
+	if s.params.Method == normalizeZScore && (s.params.Min != nil || s.params.Max != nil) {
+		return nil, fmt.Errorf("params \"min\" and \"max\" are only allowed with method %q", normalizeMinMax)
+	}
+	s.bounds()
+	if *s.params.Min >= *s.params.Max {
+		return nil, fmt.Errorf("param \"min\" must be less than \"max\"")
+	}
+
+	indices, err := lookupFields(fields, s.params.Fields)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]models.DataField(nil), fields...)
+	for _, index := range indices {
+		if !isNumeric(fields[index].Type) {
+			return nil, fmt.Errorf("field %q is %s, not numeric", fields[index].Name, fields[index].Type)
+		}
+		out[index].Type = models.DataTypeFloat
+	}
+	s.indices = indices
+	s.fields = out
+	return out, nil
+}
+
+// bounds fills in the default target range of min-max scaling
+func (s *normalizeStep) bounds() {
+	if s.params.Min == nil {
+		lo := 0.0
+		s.params.Min = &lo
+	}
+	if s.params.Max == nil {
+		hi := 1.0
+		s.params.Max = &hi
+	}
+}
+
+func (s *normalizeStep) apply(frame *Frame) (*Frame, error) {
+	replaced := make(map[int][]interface{}, len(s.indices))
+	for _, index := range s.indices {
+		numbers := make([]float64, frame.Len())
+		present := make([]bool, frame.Len())
+		var count int
+		var sum, lo, hi float64
+		for r, value := range frame.Columns[index] {
+			f, ok := numeric(value)
+			if !ok {
+				continue
+			}
+			numbers[r], present[r] = f, true
+			if count == 0 || f < lo {
+				lo = f
+			}
+			if count == 0 || f > hi {
+				hi = f
+			}
+			sum += f
+			count++
+		}
+
+		var scale func(float64) float64
+		if s.params.Method == normalizeZScore {
+			mean := sum / math.Max(float64(count), 1)
+			var squares float64
+			for r, f := range numbers {
+				if present[r] {
+					squares += (f - mean) * (f - mean)
+				}
+			}
+			std := math.Sqrt(squares / math.Max(float64(count), 1))
+			scale = func(f float64) float64 {
+				if std == 0 {
+					return 0
+				}
+				return (f - mean) / std
+			}
+		} else {
+			min, max := *s.params.Min, *s.params.Max
+			scale = func(f float64) float64 {
+				if hi == lo {
+					return min
+				}
+				return min + (f-lo)/(hi-lo)*(max-min)
+			}
+		}
+
+		column := make([]interface{}, frame.Len())
+		for r, f := range numbers {
+			if present[r] {
+				column[r] = scale(f)
+			}
+		}
+		replaced[index] = column
+	}
+	return replaceColumns(frame, s.fields, replaced), nil
+}
@@ -0,0 +1,200 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transformFields = []models.DataField{
+	{Name: "name", Type: models.DataTypeString},
+	{Name: "qty", Type: models.DataTypeInteger},
+	{Name: "price", Type: models.DataTypeFloat},
+	{Name: "created", Type: models.DataTypeDateTime},
+}
+
+func transformRows() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"name": "apple", "qty": int64(3), "price": 1.5, "created": "2024-01-01T00:00:00Z"},
+		{"name": "pear", "qty": nil, "price": 2.0, "created": "2024-01-03T00:00:00Z"},
+		{"name": "Plum", "qty": int64(5), "price": nil, "created": "2024-01-02T00:00:00Z"},
+	}
+}
+
+// runTransform compiles and applies steps to the test rows
+func runTransform(t *testing.T, steps ...models.TransformStep) *Frame {
+	t.Helper()
+	pipeline, err := CompileTransform(steps, transformFields)
+	require.NoError(t, err)
+	frame, err := pipeline.Apply(NewFrame(transformFields, transformRows()))
+	require.NoError(t, err)
+	assert.Equal(t, pipeline.Fields(), frame.Fields)
+	return frame
+}
+
+func step(t models.TransformType, params map[string]interface{}) models.TransformStep {
+	return models.TransformStep{Type: t, Params: params}
+}
+
+func TestTransform(t *testing.T) {
+	// Test Case 1: Select, rename and drop reshape the schema
+	t.Run("Select Rename Drop", func(t *testing.T) {
+		frame := runTransform(t,
+			step(models.TransformSelect, map[string]interface{}{"fields": []interface{}{"price", "name", "qty"}}),
+			step(models.TransformRename, map[string]interface{}{"mapping": map[string]interface{}{"price": "unit_price"}}),
+			step(models.TransformDrop, map[string]interface{}{"fields": []interface{}{"qty"}}),
+		)
+
+		assert.Equal(t, []models.DataField{
+			{Name: "unit_price", Type: models.DataTypeFloat},
+			{Name: "name", Type: models.DataTypeString},
+		}, frame.Fields)
+		assert.Equal(t, map[string]interface{}{"unit_price": 1.5, "name": "apple"}, frame.Rows()[0])
+	})
+
+	// Test Case 2: Filter and sort keep SQL semantics for nulls
+	t.Run("Filter Sort", func(t *testing.T) {
+		frame := runTransform(t,
+			step(models.TransformFilter, map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"field": "name", "operator": "like", "value": "p%"},
+					map[string]interface{}{"field": "qty", "operator": "gt", "value": 4},
+				},
+				"mode": "or",
+			}),
+			step(models.TransformSort, map[string]interface{}{
+				"fields": []interface{}{map[string]interface{}{"field": "created", "direction": "desc"}},
+			}),
+		)
+
+		assert.Equal(t, []interface{}{"pear", "Plum"}, frame.Column("name"))
+	})
+
+	// Test Case 3: Computed columns infer their type from the expression
+	t.Run("Add Column", func(t *testing.T) {
+		frame := runTransform(t,
+			step(models.TransformAddColumn, map[string]interface{}{"name": "total", "expression": "qty * price"}),
+			step(models.TransformAddColumn, map[string]interface{}{"name": "label", "expression": "upper(name) + '-' + coalesce(qty, 0)"}),
+			step(models.TransformAddColumn, map[string]interface{}{"name": "source", "value": "import"}),
+		)
+
+		assert.Equal(t, models.DataField{Name: "total", Type: models.DataTypeFloat}, frame.Fields[4])
+		assert.Equal(t, []interface{}{4.5, nil, nil}, frame.Column("total"))
+		assert.Equal(t, []interface{}{"APPLE-3", "PEAR-0", "PLUM-5"}, frame.Column("label"))
+		assert.Equal(t, []interface{}{"import", "import", "import"}, frame.Column("source"))
+	})
+
+	// Test Case 4: Cast, fill, replace and normalize rewrite values
+	t.Run("Cast Fill Replace Normalize", func(t *testing.T) {
+		frame := runTransform(t,
+			step(models.TransformFill, map[string]interface{}{"fields": []interface{}{"qty"}, "strategy": "mean"}),
+			step(models.TransformFill, map[string]interface{}{"fields": []interface{}{"price"}, "strategy": "forward"}),
+			step(models.TransformCast, map[string]interface{}{"fields": map[string]interface{}{"qty": "string"}}),
+			step(models.TransformReplace, map[string]interface{}{"fields": []interface{}{"name"}, "pattern": "^[Pp]", "replacement": "b"}),
+			step(models.TransformNormalize, map[string]interface{}{"fields": []interface{}{"price"}}),
+		)
+
+		assert.Equal(t, models.DataTypeString, frame.Fields[1].Type)
+		assert.Equal(t, []interface{}{"3", "4", "5"}, frame.Column("qty"))
+		assert.Equal(t, []interface{}{"apple", "bear", "blum"}, frame.Column("name"))
+		assert.Equal(t, []interface{}{0.0, 1.0, 1.0}, frame.Column("price"))
+	})
+
+	// Test Case 5: Cast failures either abort with the row or store null
+	t.Run("Cast Errors", func(t *testing.T) {
+		steps := []models.TransformStep{step(models.TransformCast, map[string]interface{}{"fields": map[string]interface{}{"name": "integer"}})}
+		pipeline, err := CompileTransform(steps, transformFields)
+		require.NoError(t, err)
+		_, err = pipeline.Apply(NewFrame(transformFields, transformRows()))
+		var validationErr *models.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Contains(t, err.Error(), "steps[0]")
+
+		steps[0].Params["on_error"] = "null"
+		frame := runTransform(t, steps...)
+		assert.Equal(t, []interface{}{nil, nil, nil}, frame.Column("name"))
+	})
+
+	// Test Case 6: Invalid steps are rejected with their index
+	t.Run("Invalid Steps", func(t *testing.T) {
+		_, err := CompileTransform([]models.TransformStep{
+			step(models.TransformSelect, map[string]interface{}{"fields": "name"}),
+			step(models.TransformDrop, map[string]interface{}{"fields": []interface{}{"qty"}}),
+			step(models.TransformSort, map[string]interface{}{"fields": []interface{}{}, "extra": true}),
+			step("pivot", map[string]interface{}{}),
+		}, transformFields)
+
+		var validationErr *models.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Contains(t, err.Error(), "steps[0] (select)")
+		assert.Contains(t, err.Error(), "steps[2] (sort)")
+		assert.Contains(t, err.Error(), "steps[3] (pivot)")
+		assert.NotContains(t, err.Error(), "steps[1]")
+	})
+
+	// Test Case 7: Steps are checked against the schema produced by earlier steps
+	invalid := map[string][]models.TransformStep{
+		"Dropped Field": {
+			step(models.TransformDrop, map[string]interface{}{"fields": []interface{}{"qty"}}),
+			step(models.TransformSort, map[string]interface{}{"fields": []interface{}{map[string]interface{}{"field": "qty", "direction": "asc"}}}),
+		},
+		"Rename Collision":    {step(models.TransformRename, map[string]interface{}{"mapping": map[string]interface{}{"qty": "price"}})},
+		"Unknown Expression":  {step(models.TransformAddColumn, map[string]interface{}{"name": "x", "expression": "missing + 1"})},
+		"Bad Expression":      {step(models.TransformAddColumn, map[string]interface{}{"name": "x", "expression": "qty +"})},
+		"Normalize String":    {step(models.TransformNormalize, map[string]interface{}{"fields": []interface{}{"name"}})},
+		"Fill Without Value":  {step(models.TransformFill, map[string]interface{}{"fields": []interface{}{"qty"}})},
+		"Unknown Cast Type":   {step(models.TransformCast, map[string]interface{}{"fields": map[string]interface{}{"qty": "decimal"}})},
+		"Replace Bad Target":  {step(models.TransformReplace, map[string]interface{}{"fields": []interface{}{"qty"}, "replacements": []interface{}{map[string]interface{}{"from": 1, "to": "one"}}})},
+		"Filter Bad Operator": {step(models.TransformFilter, map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"field": "qty", "operator": "between"}}})},
+	}
+	for name, steps := range invalid {
+		steps := steps
+		t.Run(name, func(t *testing.T) {
+			_, err := CompileTransform(steps, transformFields)
+
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	fields := []models.DataField{
+		{Name: "a", Type: models.DataTypeInteger},
+		{Name: "b c", Type: models.DataTypeFloat},
+		{Name: "s", Type: models.DataTypeString},
+	}
+	row := []interface{}{int64(7), 2.0, " Hi "}
+
+	cases := map[string]struct {
+		expected interface{}
+		t        models.DataType
+	}{
+		"a + 1":                     {int64(8), models.DataTypeInteger},
+		"a / 2":                     {3.5, models.DataTypeFloat},
+		"-a % 4":                    {int64(-3), models.DataTypeInteger},
+		`a * "b c"`:                 {14.0, models.DataTypeFloat},
+		"a / 0":                     {nil, models.DataTypeFloat},
+		"round(a / 3, 2)":           {2.33, models.DataTypeFloat},
+		"lower(trim(s))":            {"hi", models.DataTypeString},
+		"length(s)":                 {int64(4), models.DataTypeInteger},
+		"concat(s, null, a)":        {" Hi 7", models.DataTypeString},
+		"a >= 7 and not (s = 'x')":  {true, models.DataTypeBoolean},
+		"a < 1 or 'it''s' != 'its'": {true, models.DataTypeBoolean},
+		"null = 1":                  {nil, models.DataTypeBoolean},
+	}
+	for src, tc := range cases {
+		src, tc := src, tc
+		t.Run(src, func(t *testing.T) {
+			expr, err := ParseExpr(src)
+			require.NoError(t, err)
+			typ, err := expr.Bind(fields)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.t, typ)
+			assert.Equal(t, tc.expected, expr.Eval(row))
+		})
+	}
+}
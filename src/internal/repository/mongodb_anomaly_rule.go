@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// anomalyRulesCollection is the MongoDB collection holding anomaly rule documents
+const anomalyRulesCollection = "anomaly_rules"
+
+// mongoAnomalyRule is the document of an anomaly rule. The detection is kept as
+// JSON, as BSON would decode the values of its filters and params into driver
+// types.
+type mongoAnomalyRule struct {
+	ID          uuid.UUID `bson:"_id"`
+	Name        string    `bson:"name"`
+	Description string    `bson:"description,omitempty"`
+	DatasetID   uuid.UUID `bson:"dataset_id"`
+	Detection   string    `bson:"detection"`
+	CreatedBy   uuid.UUID `bson:"created_by"`
+	CreatedAt   time.Time `bson:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// MongoAnomalyRuleRepository is a MongoDB implementation of the anomaly rule repository
+type MongoAnomalyRuleRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAnomalyRuleRepository creates a new MongoDB anomaly rule repository
+func NewMongoAnomalyRuleRepository(db *database.MongoDB) *MongoAnomalyRuleRepository {
+	return &MongoAnomalyRuleRepository{
+		collection: db.Collection(anomalyRulesCollection),
+	}
+}
+
+// EnsureIndexes creates the indexes used by dataset lookups and listing
+func (r *MongoAnomalyRuleRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "dataset_id", Value: 1}},
+			Options: options.Index().SetName("idx_anomaly_rules_dataset"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("idx_anomaly_rules_created_at"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create anomaly rule indexes: %w", err)
+	}
+	return nil
+}
+
+// FindByID finds an anomaly rule by ID, returning nil if it does not exist
+func (r *MongoAnomalyRuleRepository) FindByID(id uuid.UUID) (*models.AnomalyRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	var document mongoAnomalyRule
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find anomaly rule: %w", err)
+	}
+
+	return document.rule()
+}
+
+// FindAll returns a page of anomaly rules matching the given filters along with the total count.
+// The only supported filter is "dataset_id".
+func (r *MongoAnomalyRuleRepository) FindAll(page, pageSize int, filters map[string]interface{}) ([]models.AnomalyRule, int64, error) {
+	filter := bson.M{}
+	if value, ok := filters["dataset_id"]; ok {
+		datasetID, ok := value.(uuid.UUID)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid dataset_id filter: %v", value)
+		}
+		filter["dataset_id"] = datasetID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	// Count matching rules
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count anomaly rules: %w", err)
+	}
+
+	// Load the requested page
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query anomaly rules: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var documents []mongoAnomalyRule
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode anomaly rules: %w", err)
+	}
+
+	rules := make([]models.AnomalyRule, 0, len(documents))
+	for _, document := range documents {
+		rule, err := document.rule()
+		if err != nil {
+			return nil, 0, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, total, nil
+}
+
+// Create inserts a new anomaly rule
+func (r *MongoAnomalyRuleRepository) Create(rule *models.AnomalyRule) error {
+	detection, err := json.Marshal(rule.Detection)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly detection: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	document := mongoAnomalyRule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		DatasetID:   rule.DatasetID,
+		Detection:   string(detection),
+		CreatedBy:   rule.CreatedBy,
+		CreatedAt:   rule.CreatedAt,
+		UpdatedAt:   rule.UpdatedAt,
+	}
+	if _, err := r.collection.InsertOne(ctx, document); err != nil {
+		return fmt.Errorf("failed to create anomaly rule: %w", err)
+	}
+	return nil
+}
+
+// Update updates the name, description and detection of an existing anomaly rule
+func (r *MongoAnomalyRuleRepository) Update(rule *models.AnomalyRule) error {
+	detection, err := json.Marshal(rule.Detection)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly detection: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"name":        rule.Name,
+		"description": rule.Description,
+		"detection":   string(detection),
+		"updated_at":  rule.UpdatedAt,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": rule.ID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update anomaly rule: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("anomaly rule %s not found", rule.ID)
+	}
+
+	return nil
+}
+
+// Delete deletes an anomaly rule by ID
+func (r *MongoAnomalyRuleRepository) Delete(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to delete anomaly rule: %w", err)
+	}
+	return nil
+}
+
+// rule converts the document back to an anomaly rule
+func (d *mongoAnomalyRule) rule() (*models.AnomalyRule, error) {
+	rule := &models.AnomalyRule{
+		ID:          d.ID,
+		Name:        d.Name,
+		Description: d.Description,
+		DatasetID:   d.DatasetID,
+		CreatedBy:   d.CreatedBy,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+	if err := json.Unmarshal([]byte(d.Detection), &rule.Detection); err != nil {
+		return nil, fmt.Errorf("invalid detection for anomaly rule %s: %w", d.ID, err)
+	}
+	return rule, nil
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// datasetsCollection is the MongoDB collection holding dataset documents
+const datasetsCollection = "datasets"
+
+// MongoDatasetRepository is a MongoDB implementation of the dataset repository
+type MongoDatasetRepository struct {
+	collection *mongo.Collection
+	dependents []*mongo.Collection
+}
+
+// NewMongoDatasetRepository creates a new MongoDB dataset repository
+func NewMongoDatasetRepository(db *database.MongoDB) *MongoDatasetRepository {
+	return &MongoDatasetRepository{
+		collection: db.Collection(datasetsCollection),
+		dependents: []*mongo.Collection{db.Collection(anomalyRulesCollection), db.Collection(trainedModelsCollection)},
+	}
+}
+
+// EnsureIndexes creates the indexes used by name and tag lookups
+func (r *MongoDatasetRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetName("idx_datasets_name"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("idx_datasets_tags"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_by", Value: 1}},
+			Options: options.Index().SetName("idx_datasets_created_by"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("idx_datasets_created_at"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create dataset indexes: %w", err)
+	}
+	return nil
+}
+
+// FindByID finds a dataset by ID, returning nil if it does not exist
+func (r *MongoDatasetRepository) FindByID(id uuid.UUID) (*models.Dataset, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	var dataset models.Dataset
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&dataset)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dataset: %w", err)
+	}
+
+	return &dataset, nil
+}
+
+// FindAll returns a page of datasets matching the given filters along with the total count.
+// Supported filters are "name" (case-insensitive substring match) and "tag" (exact tag match).
+func (r *MongoDatasetRepository) FindAll(page, pageSize int, filters map[string]interface{}) ([]models.Dataset, int64, error) {
+	filter, err := mongoDatasetFilter(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	// Count matching datasets
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count datasets: %w", err)
+	}
+
+	// Load the requested page
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query datasets: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	datasets := make([]models.Dataset, 0, pageSize)
+	if err := cursor.All(ctx, &datasets); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode datasets: %w", err)
+	}
+
+	return datasets, total, nil
+}
+
+// Create inserts a new dataset
+func (r *MongoDatasetRepository) Create(dataset *models.Dataset) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	if _, err := r.collection.InsertOne(ctx, dataset); err != nil {
+		return fmt.Errorf("failed to create dataset: %w", err)
+	}
+	return nil
+}
+
+// Update updates an existing dataset. The row count and size are left as they
+// are, since the row store records them with SetCounts.
+func (r *MongoDatasetRepository) Update(dataset *models.Dataset) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"name":        dataset.Name,
+		"description": dataset.Description,
+		"schema":      dataset.Schema,
+		"source":      dataset.Source,
+		"format":      dataset.Format,
+		"tags":        dataset.Tags,
+		"metadata":    dataset.Metadata,
+		"updated_at":  dataset.UpdatedAt,
+	}}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": dataset.ID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update dataset: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return models.NewNotFoundError("dataset %s not found", dataset.ID)
+	}
+
+	return nil
+}
+
+// SetCounts records the row count and size of a dataset as of a version of its
+// rows. Counts recorded for a later version are kept, so writers finishing out
+// of order leave the counts of the latest version.
+func (r *MongoDatasetRepository) SetCounts(id uuid.UUID, version, rowCount, size int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "$or": bson.A{
+		bson.M{"counts_version": bson.M{"$exists": false}},
+		bson.M{"counts_version": bson.M{"$lte": version}},
+	}}
+	update := bson.M{"$set": bson.M{
+		"row_count":      rowCount,
+		"size":           size,
+		"counts_version": version,
+	}}
+
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to update dataset statistics: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a dataset by ID along with its anomaly rules and trained
+// models, as the foreign keys of the PostgreSQL tables do
+func (r *MongoDatasetRepository) Delete(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	for _, collection := range r.dependents {
+		if _, err := collection.DeleteMany(ctx, bson.M{"dataset_id": id}); err != nil {
+			return fmt.Errorf("failed to delete the %s of the dataset: %w", collection.Name(), err)
+		}
+	}
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to delete dataset: %w", err)
+	}
+	return nil
+}
+
+// mongoDatasetFilter builds the query document for the filters accepted by FindAll
+func mongoDatasetFilter(filters map[string]interface{}) (bson.M, error) {
+	filter := bson.M{}
+
+	if value, ok := filters["name"]; ok {
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid name filter: %v", value)
+		}
+		filter["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+	}
+
+	if value, ok := filters["tag"]; ok {
+		tag, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag filter: %v", value)
+		}
+		filter["tags"] = tag
+	}
+
+	return filter, nil
+}
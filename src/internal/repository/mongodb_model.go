@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trainedModelsCollection is the MongoDB collection holding trained model documents
+const trainedModelsCollection = "trained_models"
+
+// mongoTrainedModel is the document of a trained model. Like anomaly rules,
+// the spec and the fitted model are kept as JSON.
+type mongoTrainedModel struct {
+	ID          uuid.UUID `bson:"_id"`
+	Name        string    `bson:"name"`
+	Description string    `bson:"description,omitempty"`
+	DatasetID   uuid.UUID `bson:"dataset_id"`
+	Spec        string    `bson:"spec"`
+	Model       string    `bson:"model"`
+	CreatedBy   uuid.UUID `bson:"created_by"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+// MongoModelRepository is a MongoDB implementation of the trained model repository
+type MongoModelRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoModelRepository creates a new MongoDB trained model repository
+func NewMongoModelRepository(db *database.MongoDB) *MongoModelRepository {
+	return &MongoModelRepository{
+		collection: db.Collection(trainedModelsCollection),
+	}
+}
+
+// EnsureIndexes creates the index used by dataset lookups
+func (r *MongoModelRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "dataset_id", Value: 1}},
+		Options: options.Index().SetName("idx_trained_models_dataset"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create trained model indexes: %w", err)
+	}
+	return nil
+}
+
+// FindByID finds a trained model by ID, returning nil if it does not exist
+func (r *MongoModelRepository) FindByID(id uuid.UUID) (*models.TrainedModel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	var document mongoTrainedModel
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trained model: %w", err)
+	}
+
+	model := &models.TrainedModel{
+		ID:          document.ID,
+		Name:        document.Name,
+		Description: document.Description,
+		DatasetID:   document.DatasetID,
+		CreatedBy:   document.CreatedBy,
+		CreatedAt:   document.CreatedAt,
+	}
+	if err := json.Unmarshal([]byte(document.Spec), &model.Spec); err != nil {
+		return nil, fmt.Errorf("invalid spec for trained model %s: %w", document.ID, err)
+	}
+	if err := json.Unmarshal([]byte(document.Model), &model.Model); err != nil {
+		return nil, fmt.Errorf("invalid model for trained model %s: %w", document.ID, err)
+	}
+	return model, nil
+}
+
+// Create inserts a new trained model
+func (r *MongoModelRepository) Create(model *models.TrainedModel) error {
+	spec, err := json.Marshal(model.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode model spec: %w", err)
+	}
+	fit, err := json.Marshal(model.Model)
+	if err != nil {
+		return fmt.Errorf("failed to encode model: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	document := mongoTrainedModel{
+		ID:          model.ID,
+		Name:        model.Name,
+		Description: model.Description,
+		DatasetID:   model.DatasetID,
+		Spec:        string(spec),
+		Model:       string(fit),
+		CreatedBy:   model.CreatedBy,
+		CreatedAt:   model.CreatedAt,
+	}
+	if _, err := r.collection.InsertOne(ctx, document); err != nil {
+		return fmt.Errorf("failed to create trained model: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a trained model by ID
+func (r *MongoModelRepository) Delete(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to delete trained model: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// usersCollection is the MongoDB collection holding user documents
+const usersCollection = "users"
+
+// mongoOperationTimeout bounds every single MongoDB repository call
+const mongoOperationTimeout = 10 * time.Second
+
+// MongoUserRepository is a MongoDB implementation of the user repository
+type MongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoUserRepository creates a new MongoDB user repository
+func NewMongoUserRepository(db *database.MongoDB) *MongoUserRepository {
+	return &MongoUserRepository{
+		collection: db.Collection(usersCollection),
+	}
+}
+
+// EnsureIndexes creates the unique email index
+func (r *MongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("idx_users_email").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user indexes: %w", err)
+	}
+	return nil
+}
+
+// FindByEmail finds a user by email, returning nil if it does not exist
+func (r *MongoUserRepository) FindByEmail(email string) (*models.User, error) {
+	return r.findOne(bson.M{"email": email})
+}
+
+// FindByID finds a user by ID, returning nil if it does not exist
+func (r *MongoUserRepository) FindByID(userID uuid.UUID) (*models.User, error) {
+	return r.findOne(bson.M{"_id": userID})
+}
+
+// Create inserts a new user
+func (r *MongoUserRepository) Create(user *models.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	if _, err := r.collection.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("user with email %s already exists", user.Email)
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// Update updates an existing user
+func (r *MongoUserRepository) Update(user *models.User) error {
+	update := bson.M{"$set": bson.M{
+		"email":         user.Email,
+		"password":      user.Password,
+		"first_name":    user.FirstName,
+		"last_name":     user.LastName,
+		"role":          user.Role,
+		"active":        user.Active,
+		"verified":      user.Verified,
+		"metadata":      user.Metadata,
+		"updated_at":    user.UpdatedAt,
+		"last_login_at": user.LastLoginAt,
+	}}
+
+	return r.updateOne(user.ID, update)
+}
+
+// Delete deletes a user by ID
+func (r *MongoUserRepository) Delete(userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID}); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// UpdateRefreshToken stores the current refresh token of a user
+func (r *MongoUserRepository) UpdateRefreshToken(userID uuid.UUID, token string) error {
+	return r.updateOne(userID, bson.M{"$set": bson.M{"refresh_token": token}})
+}
+
+// ClearRefreshToken removes the stored refresh token of a user
+func (r *MongoUserRepository) ClearRefreshToken(userID uuid.UUID) error {
+	return r.updateOne(userID, bson.M{"$unset": bson.M{"refresh_token": ""}})
+}
+
+// findOne loads the single user matching filter
+func (r *MongoUserRepository) findOne(filter bson.M) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	var user models.User
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// updateOne applies update to the user with the given ID
+func (r *MongoUserRepository) updateOne(userID uuid.UUID, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOperationTimeout)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("email already in use")
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user %s not found", userID)
+	}
+
+	return nil
+}
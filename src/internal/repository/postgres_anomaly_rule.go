@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// anomalyRuleColumns lists the columns selected when loading an anomaly rule
+const anomalyRuleColumns = `id, name, description, dataset_id, detection, created_by, created_at, updated_at`
+
+// PostgresAnomalyRuleRepository is a PostgreSQL implementation of the anomaly rule repository
+type PostgresAnomalyRuleRepository struct {
+	db *database.PostgresDB
+}
+
+// NewPostgresAnomalyRuleRepository creates a new PostgreSQL anomaly rule repository
+func NewPostgresAnomalyRuleRepository(db *database.PostgresDB) *PostgresAnomalyRuleRepository {
+	return &PostgresAnomalyRuleRepository{
+		db: db,
+	}
+}
+
+// FindByID finds an anomaly rule by ID, returning nil if it does not exist
+func (r *PostgresAnomalyRuleRepository) FindByID(id uuid.UUID) (*models.AnomalyRule, error) {
+	row := r.db.QueryRow(`SELECT `+anomalyRuleColumns+` FROM anomaly_rules WHERE id = $1`, id)
+
+	rule, err := scanAnomalyRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find anomaly rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// FindAll returns a page of anomaly rules matching the given filters along with the total count.
+// The only supported filter is "dataset_id".
+func (r *PostgresAnomalyRuleRepository) FindAll(page, pageSize int, filters map[string]interface{}) ([]models.AnomalyRule, int64, error) {
+	where := ""
+	var args []interface{}
+	if value, ok := filters["dataset_id"]; ok {
+		datasetID, ok := value.(uuid.UUID)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid dataset_id filter: %v", value)
+		}
+		where = ` WHERE dataset_id = $1`
+		args = append(args, datasetID)
+	}
+
+	// Count matching rules
+	var total int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM anomaly_rules`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count anomaly rules: %w", err)
+	}
+
+	// Load the requested page
+	query := fmt.Sprintf(
+		`SELECT %s FROM anomaly_rules%s ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`,
+		anomalyRuleColumns, where, len(args)+1, len(args)+2,
+	)
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query anomaly rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]models.AnomalyRule, 0, pageSize)
+	for rows.Next() {
+		rule, err := scanAnomalyRule(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan anomaly rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate anomaly rules: %w", err)
+	}
+
+	return rules, total, nil
+}
+
+// Create inserts a new anomaly rule
+func (r *PostgresAnomalyRuleRepository) Create(rule *models.AnomalyRule) error {
+	detection, err := json.Marshal(rule.Detection)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly detection: %w", err)
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO anomaly_rules (id, name, description, dataset_id, detection, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		rule.ID, rule.Name, rule.Description, rule.DatasetID, detection, rule.CreatedBy, rule.CreatedAt, rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create anomaly rule: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates the name, description and detection of an existing anomaly rule
+func (r *PostgresAnomalyRuleRepository) Update(rule *models.AnomalyRule) error {
+	detection, err := json.Marshal(rule.Detection)
+	if err != nil {
+		return fmt.Errorf("failed to encode anomaly detection: %w", err)
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE anomaly_rules SET name = $2, description = $3, detection = $4, updated_at = $5 WHERE id = $1`,
+		rule.ID, rule.Name, rule.Description, detection, rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update anomaly rule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update anomaly rule: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("anomaly rule %s not found", rule.ID)
+	}
+
+	return nil
+}
+
+// Delete deletes an anomaly rule by ID
+func (r *PostgresAnomalyRuleRepository) Delete(id uuid.UUID) error {
+	if _, err := r.db.Exec(`DELETE FROM anomaly_rules WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete anomaly rule: %w", err)
+	}
+	return nil
+}
+
+// scanAnomalyRule scans an anomaly rule row selected with anomalyRuleColumns
+func scanAnomalyRule(row rowScanner) (*models.AnomalyRule, error) {
+	var rule models.AnomalyRule
+	var detection []byte
+
+	err := row.Scan(
+		&rule.ID, &rule.Name, &rule.Description, &rule.DatasetID, &detection,
+		&rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(detection, &rule.Detection); err != nil {
+		return nil, fmt.Errorf("invalid detection for anomaly rule %s: %w", rule.ID, err)
+	}
+
+	return &rule, nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// datasetColumns lists the columns selected when loading a dataset
+const datasetColumns = `id, name, description, schema, source, format, size, row_count, tags, metadata, created_by, created_at, updated_at`
+
+// PostgresDatasetRepository is a PostgreSQL implementation of the dataset repository
+type PostgresDatasetRepository struct {
+	db *database.PostgresDB
+}
+
+// NewPostgresDatasetRepository creates a new PostgreSQL dataset repository
+func NewPostgresDatasetRepository(db *database.PostgresDB) *PostgresDatasetRepository {
+	return &PostgresDatasetRepository{
+		db: db,
+	}
+}
+
+// FindByID finds a dataset by ID, returning nil if it does not exist
+func (r *PostgresDatasetRepository) FindByID(id uuid.UUID) (*models.Dataset, error) {
+	row := r.db.QueryRow(`SELECT `+datasetColumns+` FROM datasets WHERE id = $1`, id)
+
+	dataset, err := scanDataset(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dataset: %w", err)
+	}
+
+	return dataset, nil
+}
+
+// FindAll returns a page of datasets matching the given filters along with the total count.
+// Supported filters are "name" (case-insensitive substring match) and "tag" (exact tag match).
+func (r *PostgresDatasetRepository) FindAll(page, pageSize int, filters map[string]interface{}) ([]models.Dataset, int64, error) {
+	where, args, err := datasetFilters(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count matching datasets
+	var total int64
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM datasets`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count datasets: %w", err)
+	}
+
+	// Load the requested page
+	query := fmt.Sprintf(
+		`SELECT %s FROM datasets%s ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`,
+		datasetColumns, where, len(args)+1, len(args)+2,
+	)
+	args = append(args, pageSize, (page-1)*pageSize)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query datasets: %w", err)
+	}
+	defer rows.Close()
+
+	datasets := make([]models.Dataset, 0, pageSize)
+	for rows.Next() {
+		dataset, err := scanDataset(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		datasets = append(datasets, *dataset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate datasets: %w", err)
+	}
+
+	return datasets, total, nil
+}
+
+// Create inserts a new dataset
+func (r *PostgresDatasetRepository) Create(dataset *models.Dataset) error {
+	schema, tags, metadata, err := marshalDatasetJSON(dataset)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO datasets (id, name, description, schema, source, format, size, row_count, tags, metadata, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		dataset.ID, dataset.Name, dataset.Description, schema, dataset.Source, dataset.Format,
+		dataset.Size, dataset.RowCount, tags, metadata, dataset.CreatedBy, dataset.CreatedAt, dataset.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create dataset: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates an existing dataset. The row count and size are left as they
+// are, since the row store keeps them as it writes rows.
+func (r *PostgresDatasetRepository) Update(dataset *models.Dataset) error {
+	schema, tags, metadata, err := marshalDatasetJSON(dataset)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(
+		`UPDATE datasets SET name = $2, description = $3, schema = $4, source = $5, format = $6,
+		tags = $7, metadata = $8, updated_at = $9
+		WHERE id = $1`,
+		dataset.ID, dataset.Name, dataset.Description, schema, dataset.Source, dataset.Format,
+		tags, metadata, dataset.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update dataset: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update dataset: %w", err)
+	}
+	if affected == 0 {
+		return models.NewNotFoundError("dataset %s not found", dataset.ID)
+	}
+
+	return nil
+}
+
+// Delete deletes a dataset by ID. Its anomaly rules and trained models are
+// deleted with it by their foreign keys.
+func (r *PostgresDatasetRepository) Delete(id uuid.UUID) error {
+	if _, err := r.db.Exec(`DELETE FROM datasets WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete dataset: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDataset scans a dataset row selected with datasetColumns
+func scanDataset(row rowScanner) (*models.Dataset, error) {
+	var dataset models.Dataset
+	var schema, tags, metadata []byte
+
+	err := row.Scan(
+		&dataset.ID, &dataset.Name, &dataset.Description, &schema, &dataset.Source, &dataset.Format,
+		&dataset.Size, &dataset.RowCount, &tags, &metadata, &dataset.CreatedBy, &dataset.CreatedAt, &dataset.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(schema, &dataset.Schema); err != nil {
+		return nil, fmt.Errorf("invalid schema for dataset %s: %w", dataset.ID, err)
+	}
+	if err := json.Unmarshal(tags, &dataset.Tags); err != nil {
+		return nil, fmt.Errorf("invalid tags for dataset %s: %w", dataset.ID, err)
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &dataset.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for dataset %s: %w", dataset.ID, err)
+		}
+	}
+
+	return &dataset, nil
+}
+
+// marshalDatasetJSON encodes the JSONB columns of a dataset
+func marshalDatasetJSON(dataset *models.Dataset) (schema, tags, metadata []byte, err error) {
+	if dataset.Schema.Fields == nil {
+		dataset.Schema.Fields = []models.DataField{}
+	}
+	schema, err = json.Marshal(dataset.Schema)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode dataset schema: %w", err)
+	}
+
+	datasetTags := dataset.Tags
+	if datasetTags == nil {
+		datasetTags = []string{}
+	}
+	tags, err = json.Marshal(datasetTags)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode dataset tags: %w", err)
+	}
+
+	if dataset.Metadata != nil {
+		metadata, err = json.Marshal(dataset.Metadata)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to encode dataset metadata: %w", err)
+		}
+	}
+
+	return schema, tags, metadata, nil
+}
+
+// datasetFilters builds the WHERE clause for the filters accepted by FindAll
+func datasetFilters(filters map[string]interface{}) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if value, ok := filters["name"]; ok {
+		name, ok := value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid name filter: %v", value)
+		}
+		args = append(args, "%"+escapeLike(name)+"%")
+		conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", len(args)))
+	}
+
+	if value, ok := filters["tag"]; ok {
+		tag, ok := value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid tag filter: %v", value)
+		}
+		encoded, err := json.Marshal([]string{tag})
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid tag filter: %w", err)
+		}
+		args = append(args, string(encoded))
+		conditions = append(conditions, fmt.Sprintf("tags @> $%d::jsonb", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// escapeLike escapes the LIKE wildcard characters in a user supplied pattern
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
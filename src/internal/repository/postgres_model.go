@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// PostgresModelRepository is a PostgreSQL implementation of the trained model repository
+type PostgresModelRepository struct {
+	db *database.PostgresDB
+}
+
+// NewPostgresModelRepository creates a new PostgreSQL trained model repository
+func NewPostgresModelRepository(db *database.PostgresDB) *PostgresModelRepository {
+	return &PostgresModelRepository{
+		db: db,
+	}
+}
+
+// FindByID finds a trained model by ID, returning nil if it does not exist
+func (r *PostgresModelRepository) FindByID(id uuid.UUID) (*models.TrainedModel, error) {
+	var model models.TrainedModel
+	var spec, fit []byte
+
+	err := r.db.QueryRow(
+		`SELECT id, name, description, dataset_id, spec, model, created_by, created_at FROM trained_models WHERE id = $1`,
+		id,
+	).Scan(&model.ID, &model.Name, &model.Description, &model.DatasetID, &spec, &fit, &model.CreatedBy, &model.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find trained model: %w", err)
+	}
+
+	if err := json.Unmarshal(spec, &model.Spec); err != nil {
+		return nil, fmt.Errorf("invalid spec for trained model %s: %w", model.ID, err)
+	}
+	if err := json.Unmarshal(fit, &model.Model); err != nil {
+		return nil, fmt.Errorf("invalid model for trained model %s: %w", model.ID, err)
+	}
+
+	return &model, nil
+}
+
+// Create inserts a new trained model
+func (r *PostgresModelRepository) Create(model *models.TrainedModel) error {
+	spec, err := json.Marshal(model.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode model spec: %w", err)
+	}
+	fit, err := json.Marshal(model.Model)
+	if err != nil {
+		return fmt.Errorf("failed to encode model: %w", err)
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO trained_models (id, name, description, dataset_id, spec, model, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		model.ID, model.Name, model.Description, model.DatasetID, spec, fit, model.CreatedBy, model.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create trained model: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a trained model by ID
+func (r *PostgresModelRepository) Delete(id uuid.UUID) error {
+	if _, err := r.db.Exec(`DELETE FROM trained_models WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete trained model: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// userColumns lists the columns selected when loading a user
+const userColumns = `id, email, password, first_name, last_name, role, active, verified, refresh_token, metadata, created_at, updated_at, last_login_at`
+
+// uniqueViolation is the PostgreSQL error code raised by unique constraints
+const uniqueViolation = "23505"
+
+// PostgresUserRepository is a PostgreSQL implementation of the user repository
+type PostgresUserRepository struct {
+	db *database.PostgresDB
+}
+
+// NewPostgresUserRepository creates a new PostgreSQL user repository
+func NewPostgresUserRepository(db *database.PostgresDB) *PostgresUserRepository {
+	return &PostgresUserRepository{
+		db: db,
+	}
+}
+
+// FindByEmail finds a user by email, returning nil if it does not exist
+func (r *PostgresUserRepository) FindByEmail(email string) (*models.User, error) {
+	return r.findOne(`SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+// FindByID finds a user by ID, returning nil if it does not exist
+func (r *PostgresUserRepository) FindByID(userID uuid.UUID) (*models.User, error) {
+	return r.findOne(`SELECT `+userColumns+` FROM users WHERE id = $1`, userID)
+}
+
+// Create inserts a new user
+func (r *PostgresUserRepository) Create(user *models.User) error {
+	metadata, err := marshalUserMetadata(user)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO users (id, email, password, first_name, last_name, role, active, verified, refresh_token, metadata, created_at, updated_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13)`,
+		user.ID, user.Email, user.Password, user.FirstName, user.LastName, user.Role, user.Active, user.Verified,
+		user.RefreshToken, metadata, user.CreatedAt, user.UpdatedAt, user.LastLoginAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user with email %s already exists", user.Email)
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates an existing user
+func (r *PostgresUserRepository) Update(user *models.User) error {
+	metadata, err := marshalUserMetadata(user)
+	if err != nil {
+		return err
+	}
+
+	return r.execUpdate(
+		`UPDATE users SET email = $2, password = $3, first_name = $4, last_name = $5, role = $6,
+		active = $7, verified = $8, metadata = $9, updated_at = $10, last_login_at = $11
+		WHERE id = $1`,
+		user.ID, user.Email, user.Password, user.FirstName, user.LastName, user.Role,
+		user.Active, user.Verified, metadata, user.UpdatedAt, user.LastLoginAt,
+	)
+}
+
+// Delete deletes a user by ID
+func (r *PostgresUserRepository) Delete(userID uuid.UUID) error {
+	if _, err := r.db.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// UpdateRefreshToken stores the current refresh token of a user
+func (r *PostgresUserRepository) UpdateRefreshToken(userID uuid.UUID, token string) error {
+	return r.execUpdate(`UPDATE users SET refresh_token = $2 WHERE id = $1`, userID, token)
+}
+
+// ClearRefreshToken removes the stored refresh token of a user
+func (r *PostgresUserRepository) ClearRefreshToken(userID uuid.UUID) error {
+	return r.execUpdate(`UPDATE users SET refresh_token = NULL WHERE id = $1`, userID)
+}
+
+// findOne loads the single user selected by query
+func (r *PostgresUserRepository) findOne(query string, args ...interface{}) (*models.User, error) {
+	var user models.User
+	var refreshToken sql.NullString
+	var metadata []byte
+
+	err := r.db.QueryRow(query, args...).Scan(
+		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName, &user.Role, &user.Active, &user.Verified,
+		&refreshToken, &metadata, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	user.RefreshToken = refreshToken.String
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &user.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for user %s: %w", user.ID, err)
+		}
+	}
+
+	return &user, nil
+}
+
+// execUpdate runs an UPDATE statement whose first argument is the user ID
+func (r *PostgresUserRepository) execUpdate(query string, args ...interface{}) error {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("email already in use")
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user %s not found", args[0])
+	}
+
+	return nil
+}
+
+// marshalUserMetadata encodes the metadata column of a user
+func marshalUserMetadata(user *models.User) ([]byte, error) {
+	if user.Metadata == nil {
+		return nil, nil
+	}
+	metadata, err := json.Marshal(user.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// isUniqueViolation reports whether err was raised by a unique constraint
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,167 @@
+// Package repository provides the persistence implementations of the handler
+// repository interfaces, backed either by PostgreSQL or by MongoDB.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+	"github.com/galafis/go-data-api-microservices/migrations"
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverMongoDB  = "mongodb"
+)
+
+// Repositories groups the repositories backed by the configured database driver
+type Repositories struct {
+	Datasets     handlers.DatasetRepository
+	Users        handlers.UserRepository
+	AnomalyRules handlers.AnomalyRuleRepository
+	Models       handlers.ModelRepository
+
+	// Postgres is always connected since it holds the dataset rows,
+	// Mongo is only set when it is the selected metadata driver
+	Postgres *database.PostgresDB
+	Mongo    *database.MongoDB
+
+	// ExternalDatasets is set when dataset metadata is not stored in
+	// Postgres, so the row store updates it after writing rows
+	ExternalDatasets storage.DatasetStore
+}
+
+// New connects to the database selected by cfg.Driver and builds the repositories.
+// PostgreSQL is required and migrated to the latest schema version in every mode
+// because it stores the dataset rows; with the "mongodb" driver datasets, users,
+// anomaly rules and trained models live in MongoDB instead, whose collections get
+// their indexes created here.
+func New(cfg *config.DatabaseConfig) (*Repositories, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = DriverPostgres
+	}
+	if driver != DriverPostgres && driver != DriverMongoDB {
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+
+	if missing := missingPostgresSettings(cfg); len(missing) > 0 {
+		return nil, fmt.Errorf("PostgreSQL stores the dataset rows and is required with the %s driver: missing %s",
+			driver, strings.Join(missing, ", "))
+	}
+
+	db, err := database.NewPostgresDB(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("PostgreSQL stores the dataset rows and is required with the %s driver, check the database.* settings: %w",
+			driver, err)
+	}
+
+	if err := db.Migrate(migrations.FS); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if cfg.Driver == DriverMongoDB {
+		repositories, err := newMongoRepositories(cfg)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		repositories.Postgres = db
+		return repositories, nil
+	}
+
+	return &Repositories{
+		Datasets:     NewPostgresDatasetRepository(db),
+		Users:        NewPostgresUserRepository(db),
+		AnomalyRules: NewPostgresAnomalyRuleRepository(db),
+		Models:       NewPostgresModelRepository(db),
+		Postgres:     db,
+	}, nil
+}
+
+// Close closes the underlying database connections
+func (r *Repositories) Close(ctx context.Context) error {
+	if r.Mongo != nil {
+		if err := r.Mongo.Close(ctx); err != nil {
+			return err
+		}
+	}
+	return r.Postgres.Close()
+}
+
+// missingPostgresSettings lists the PostgreSQL connection settings that are
+// not set, with the environment variables that set them
+func missingPostgresSettings(cfg *config.DatabaseConfig) []string {
+	settings := []struct {
+		name string
+		set  bool
+	}{
+		{"database.host (DB_HOST)", cfg.Host != ""},
+		{"database.port (DB_PORT)", cfg.Port != 0},
+		{"database.username (DB_USERNAME)", cfg.Username != ""},
+		{"database.database (DB_NAME)", cfg.Database != ""},
+	}
+
+	var missing []string
+	for _, setting := range settings {
+		if !setting.set {
+			missing = append(missing, setting.name)
+		}
+	}
+	return missing
+}
+
+// newMongoRepositories builds the MongoDB backed repositories
+func newMongoRepositories(cfg *config.DatabaseConfig) (*Repositories, error) {
+	db, err := database.NewMongoDB(&database.MongoDBConfig{
+		URI:      cfg.Mongo.URI,
+		Database: cfg.Mongo.Database,
+		Username: cfg.Mongo.Username,
+		Password: cfg.Mongo.Password,
+		Timeout:  cfg.Mongo.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.Timeout)
+	defer cancel()
+
+	datasets := NewMongoDatasetRepository(db)
+	users := NewMongoUserRepository(db)
+	anomalyRules := NewMongoAnomalyRuleRepository(db)
+	trainedModels := NewMongoModelRepository(db)
+
+	if err := datasets.EnsureIndexes(ctx); err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+	if err := users.EnsureIndexes(ctx); err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+	if err := anomalyRules.EnsureIndexes(ctx); err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+	if err := trainedModels.EnsureIndexes(ctx); err != nil {
+		db.Close(ctx)
+		return nil, err
+	}
+
+	return &Repositories{
+		Datasets:         datasets,
+		Users:            users,
+		AnomalyRules:     anomalyRules,
+		Models:           trainedModels,
+		Mongo:            db,
+		ExternalDatasets: datasets,
+	}, nil
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/galafis/go-data-api-microservices/internal/analytics"
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+	"github.com/galafis/go-data-api-microservices/internal/visualize"
+	"github.com/google/uuid"
+)
+
+// AnalyticsService computes statistics, correlations, time series, forecasts, anomalies and regressions over datasets, and renders them as charts
+type AnalyticsService struct {
+	db                *database.PostgresDB
+	datasetRepository handlers.DatasetRepository
+	rowStore          *storage.RowStore
+	maxInputRows      int
+}
+
+// NewAnalyticsService creates a new analytics service, which loads at most the
+// configured maximum of rows for in-memory processing from a dataset
+func NewAnalyticsService(db *database.PostgresDB, datasetRepository handlers.DatasetRepository, rowStore *storage.RowStore, cfg *config.QueryConfig) *AnalyticsService {
+	return &AnalyticsService{
+		db:                db,
+		datasetRepository: datasetRepository,
+		rowStore:          rowStore,
+		maxInputRows:      cfg.MaxInputRows,
+	}
+}
+
+// GetDataSummary returns a summary of a dataset, or nil when the dataset does not exist
+func (s *AnalyticsService) GetDataSummary(datasetID string) (*models.DataSummary, error) {
+	id, err := uuid.Parse(datasetID)
+	if err != nil {
+		return nil, models.NewValidationError("invalid dataset ID %q", datasetID)
+	}
+	dataset, err := s.datasetRepository.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dataset: %w", err)
+	}
+	if dataset == nil {
+		return nil, nil
+	}
+
+	frame, err := s.loadFrame(dataset, nil)
+	if err != nil {
+		return nil, err
+	}
+	return analytics.Summarize(dataset, frame), nil
+}
+
+// ComputeStatistics computes statistics over dataset fields. The request is
+// validated against the dataset schema before any row is loaded.
+func (s *AnalyticsService) ComputeStatistics(req *models.StatisticsRequest) (*models.StatisticsResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := analytics.CompileStatistics(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return stats.Compute(frame), nil
+}
+
+// ComputeCorrelation computes a correlation matrix between dataset fields, with
+// the p-value of every coefficient
+func (s *AnalyticsService) ComputeCorrelation(req *models.CorrelationRequest) (*models.CorrelationResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	correlation, err := analytics.CompileCorrelation(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return correlation.Compute(frame), nil
+}
+
+// ComputeCovariance computes the sample covariance matrix of dataset fields
+func (s *AnalyticsService) ComputeCovariance(req *models.CovarianceRequest) (*models.CovarianceResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	covariance, err := analytics.CompileCovariance(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return covariance.Compute(frame), nil
+}
+
+// ComputePartialCorrelation computes the correlation matrix of dataset fields
+// controlling for other fields, with the p-value of every coefficient
+func (s *AnalyticsService) ComputePartialCorrelation(req *models.PartialCorrelationRequest) (*models.CorrelationResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	correlation, err := analytics.CompilePartialCorrelation(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return correlation.Compute(frame), nil
+}
+
+// AnalyzeTimeSeries aggregates a dataset field into calendar buckets, one
+// series per group
+func (s *AnalyticsService) AnalyzeTimeSeries(req *models.TimeSeriesRequest) (*models.TimeSeriesResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := analytics.CompileTimeSeries(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return series.Compute(frame)
+}
+
+// DecomposeTimeSeries splits the series of a dataset field into trend, seasonal
+// and residual components
+func (s *AnalyticsService) DecomposeTimeSeries(req *models.DecompositionRequest) (*models.DecompositionResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	decomposition, err := analytics.CompileDecomposition(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return decomposition.Compute(frame)
+}
+
+// AnalyzeTrend tests the series of a dataset field for monotonic trends and
+// detects shifts in their level
+func (s *AnalyticsService) AnalyzeTrend(req *models.TrendRequest) (*models.TrendResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	trend, err := analytics.CompileTrend(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return trend.Compute(frame)
+}
+
+// GenerateForecast forecasts future values of a dataset field from its history
+// aggregated into interval buckets, with prediction intervals and backtest metrics
+func (s *AnalyticsService) GenerateForecast(req *models.ForecastRequest) (*models.ForecastResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast, err := analytics.CompileForecast(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return forecast.Compute(frame)
+}
+
+// RunHypothesisTest runs a statistical test on the filtered rows of a dataset
+func (s *AnalyticsService) RunHypothesisTest(req *models.HypothesisTestRequest) (*models.HypothesisTestResult, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	test, err := analytics.CompileHypothesisTest(req, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return test.Compute(frame)
+}
+
+// RenderChart renders a time series as a line chart, or histogram or box plot
+// statistics, as an SVG or PNG image
+func (s *AnalyticsService) RenderChart(req *models.ChartRequest) (*models.Image, error) {
+	if err := visualize.CheckOptions(&req.ImageOptions); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.TimeSeries != nil && req.Statistics != nil:
+		return nil, models.NewValidationError("only one of time_series and statistics can be charted")
+	case req.TimeSeries != nil:
+		result, err := s.AnalyzeTimeSeries(req.TimeSeries)
+		if err != nil {
+			return nil, err
+		}
+		return visualize.TimeSeriesChart(result, &req.ImageOptions)
+	case req.Statistics != nil:
+		if err := visualize.CheckStatisticsType(req.Statistics.Type); err != nil {
+			return nil, err
+		}
+		result, err := s.ComputeStatistics(req.Statistics)
+		if err != nil {
+			return nil, err
+		}
+		return visualize.StatisticsChart(result, &req.ImageOptions)
+	default:
+		return nil, models.NewValidationError("either time_series or statistics is required")
+	}
+}
+
+// RenderHeatmap renders a correlation or partial correlation matrix as an SVG
+// or PNG heatmap
+func (s *AnalyticsService) RenderHeatmap(req *models.HeatmapRequest) (*models.Image, error) {
+	if err := visualize.CheckOptions(&req.ImageOptions); err != nil {
+		return nil, err
+	}
+
+	var result *models.CorrelationResult
+	var err error
+	switch {
+	case req.Correlation != nil && req.PartialCorrelation != nil:
+		return nil, models.NewValidationError("only one of correlation and partial_correlation can be rendered")
+	case req.Correlation != nil:
+		result, err = s.ComputeCorrelation(req.Correlation)
+	case req.PartialCorrelation != nil:
+		result, err = s.ComputePartialCorrelation(req.PartialCorrelation)
+	default:
+		return nil, models.NewValidationError("either correlation or partial_correlation is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return visualize.Heatmap(result, &req.ImageOptions)
+}
+
+// DetectAnomalies scores the values of a dataset field or time series with a
+// detection and lists the anomalies among them
+func (s *AnalyticsService) DetectAnomalies(datasetID uuid.UUID, detection *models.AnomalyDetection) (*models.AnomalyResult, error) {
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	detector, err := analytics.CompileAnomalyDetection(detection, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, anomalyFilters(detection))
+	if err != nil {
+		return nil, err
+	}
+	result, err := detector.Compute(frame)
+	if err != nil {
+		return nil, err
+	}
+	result.DatasetID = datasetID
+	return result, nil
+}
+
+// ValidateAnomalyDetection checks that a detection, and its filters, can run on
+// a dataset without loading any row
+func (s *AnalyticsService) ValidateAnomalyDetection(datasetID uuid.UUID, detection *models.AnomalyDetection) error {
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := analytics.CompileAnomalyDetection(detection, dataset.Schema.Fields); err != nil {
+		return err
+	}
+	if filters := anomalyFilters(detection); len(filters) > 0 {
+		if _, err := query.CompileRows(dataset.ID, &dataset.Schema, filters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// anomalyFilters combines the filters of a detection with those of its time series
+func anomalyFilters(detection *models.AnomalyDetection) []models.FilterCondition {
+	if detection.TimeSeries == nil {
+		return detection.Filters
+	}
+	return append(append([]models.FilterCondition(nil), detection.Filters...), detection.TimeSeries.Filters...)
+}
+
+// FitRegression fits a regression on the filtered rows of a dataset
+func (s *AnalyticsService) FitRegression(datasetID uuid.UUID, spec *models.RegressionSpec) (*models.RegressionResult, error) {
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	regression, err := analytics.CompileRegression(spec, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := s.loadFrame(dataset, spec.Filters)
+	if err != nil {
+		return nil, err
+	}
+	return regression.Compute(frame)
+}
+
+// Predict predicts with a trained model either the filtered rows of a dataset
+// or the rows of the request
+func (s *AnalyticsService) Predict(model *models.TrainedModel, req *models.PredictRequest) (*models.PredictionResult, error) {
+	if (req.DatasetID == nil) == (len(req.Rows) == 0) {
+		return nil, models.NewValidationError("either dataset_id or rows is required")
+	}
+
+	var predictor *analytics.Predictor
+	var frame *query.Frame
+	if req.DatasetID != nil {
+		dataset, err := findDataset(s.datasetRepository, *req.DatasetID)
+		if err != nil {
+			return nil, err
+		}
+		if predictor, err = analytics.CompilePredictor(&model.Model, dataset.Schema.Fields, req.Limit); err != nil {
+			return nil, err
+		}
+		if frame, err = s.loadFrame(dataset, req.Filters); err != nil {
+			return nil, err
+		}
+	} else {
+		frame = inlineFrame(&model.Model, req.Rows)
+		if len(req.Filters) > 0 {
+			filter, err := query.CompileFilter(req.Filters, frame.Fields, query.FilterModeAnd)
+			if err != nil {
+				return nil, err
+			}
+			frame = filter.Apply(frame)
+		}
+		var err error
+		if predictor, err = analytics.CompilePredictor(&model.Model, frame.Fields, req.Limit); err != nil {
+			return nil, err
+		}
+	}
+
+	result := predictor.Compute(frame)
+	result.ModelID = model.ID
+	result.DatasetID = req.DatasetID
+	return result, nil
+}
+
+// inlineFrame builds a frame of request rows with at least the model's
+// features. The features are typed as numbers and the target as in training,
+// while the types of other fields are inferred from their values.
+func inlineFrame(model *models.RegressionResult, rows []map[string]interface{}) *query.Frame {
+	types := map[string]models.DataType{model.Target: model.TargetType}
+	for _, feature := range model.Features {
+		types[feature] = models.DataTypeFloat
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for name := range row {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, feature := range model.Features {
+		if !seen[feature] {
+			names = append(names, feature)
+		}
+	}
+	sort.Strings(names)
+
+	fields := make([]models.DataField, len(names))
+	for i, name := range names {
+		t, ok := types[name]
+		if !ok {
+			values := make([]interface{}, len(rows))
+			for r, row := range rows {
+				values[r] = row[name]
+			}
+			t = query.InferType(values)
+		}
+		fields[i] = models.DataField{Name: name, Type: t}
+	}
+	return query.NewFrame(fields, rows)
+}
+
+// loadFrame loads the rows of a dataset that match all filters, selecting them
+// with the filters compiled to SQL as queries are. Filters are validated before
+// any row is loaded, and more matching rows than the configured maximum for
+// in-memory processing are rejected.
+func (s *AnalyticsService) loadFrame(dataset *models.Dataset, filters []models.FilterCondition) (*query.Frame, error) {
+	if len(filters) == 0 {
+		rows, err := s.rowStore.RowsUpTo(dataset, s.maxInputRows)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewFrame(dataset.Schema.Fields, rows), nil
+	}
+
+	stmt, err := query.CompileRows(dataset.ID, &dataset.Schema, filters)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(stmt.Query, stmt.Args...)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	cursor := queryRows{storage.NewRowCursor(rows, &dataset.Schema, dataset.Schema.Fields)}
+	defer cursor.Close()
+
+	var data []map[string]interface{}
+	for {
+		row, err := cursor.Next()
+		if err == io.EOF {
+			return query.NewFrame(dataset.Schema.Fields, data), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.maxInputRows > 0 && len(data) == s.maxInputRows {
+			return nil, models.NewValidationError("dataset %s has more than the maximum of %d matching rows processed in memory", dataset.ID, s.maxInputRows)
+		}
+		data = append(data, row)
+	}
+}
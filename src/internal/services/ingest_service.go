@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/formats"
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+	"github.com/galafis/go-data-api-microservices/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Rows sampled by a schema inference when no sample size is given, and at most
+const (
+	defaultInferSample = 1000
+	maxInferSample     = 100000
+)
+
+// IngestService implements handlers.IngestService
+type IngestService struct {
+	datasetRepository handlers.DatasetRepository
+	rowStore          *storage.RowStore
+	cfg               *config.IngestConfig
+}
+
+// NewIngestService creates a new ingest service
+func NewIngestService(datasetRepository handlers.DatasetRepository, rowStore *storage.RowStore, cfg *config.IngestConfig) *IngestService {
+	return &IngestService{
+		datasetRepository: datasetRepository,
+		rowStore:          rowStore,
+		cfg:               cfg,
+	}
+}
+
+// IngestRows reads the rows of an uploaded file and writes the valid ones to a
+// dataset in batches. Invalid rows and rows clashing with stored ones are
+// reported in the result rather than failing the upload. The batches written
+// before a failure stay stored, so once a batch is written a failure returns
+// the result so far, with the rows accepted and the version they were stored
+// as, along with the error.
+func (s *IngestService) IngestRows(datasetID uuid.UUID, body io.Reader, opts *models.IngestOptions) (*models.IngestResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = models.IngestInsert
+	}
+	if opts.Mode != models.IngestInsert && opts.Mode != models.IngestUpsert {
+		return nil, models.NewValidationError("mode must be insert or upsert")
+	}
+
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	upsert := opts.Mode == models.IngestUpsert
+	if upsert && dataset.Schema.PrimaryKey == "" {
+		return nil, models.NewValidationError("dataset %s has no primary key to upsert on", dataset.ID)
+	}
+
+	reader, release, err := s.openReader(&limitedReader{r: body, limit: s.cfg.MaxUploadSize}, opts.Format, opts.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := checkColumns(dataset, reader.Columns()); err != nil {
+		return nil, err
+	}
+
+	result := &models.IngestResult{
+		DatasetID: dataset.ID,
+		Format:    opts.Format,
+		Mode:      opts.Mode,
+		DryRun:    opts.DryRun,
+		Errors:    []models.RowError{},
+	}
+	batch := &ingestBatch{
+		dataset:   dataset,
+		writer:    s.rowStore.Batch(dataset),
+		validator: storage.NewRowValidator(dataset, upsert),
+		upsert:    upsert,
+		dryRun:    opts.DryRun,
+		result:    result,
+	}
+	fail := func(err error) (*models.IngestResult, error) {
+		if batch.writer.Version() == 0 {
+			return nil, err
+		}
+		result.RowCount = dataset.RowCount
+		result.Version = batch.writer.Version()
+		return result, err
+	}
+
+	for {
+		position, row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *models.RowError
+		if errors.As(err, &rowErr) {
+			result.RowsReceived++
+			s.reject(result, *rowErr)
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		result.RowsReceived++
+		converted, rowErr := batch.validator.Validate(position, row)
+		if rowErr != nil {
+			s.reject(result, *rowErr)
+			continue
+		}
+		batch.rows = append(batch.rows, converted)
+		batch.positions = append(batch.positions, position)
+
+		if len(batch.rows) >= s.cfg.BatchSize {
+			if err := s.flush(batch); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if err := s.flush(batch); err != nil {
+		return fail(err)
+	}
+
+	result.RowCount = dataset.RowCount
+	result.Version = batch.writer.Version()
+	return result, nil
+}
+
+// InferSchema proposes a dataset schema from the first rows of an uploaded
+// file. Rows that cannot be decoded are skipped rather than sampled.
+func (s *IngestService) InferSchema(body io.Reader, opts *models.InferOptions) (*models.SchemaInference, error) {
+	sample := opts.SampleSize
+	if sample <= 0 {
+		sample = defaultInferSample
+	}
+	if sample > maxInferSample {
+		return nil, models.NewValidationError("sample_size %d exceeds the maximum of %d", sample, maxInferSample)
+	}
+
+	reader, release, err := s.openReader(&limitedReader{r: body, limit: s.cfg.MaxUploadSize}, opts.Format, opts.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result := &models.SchemaInference{Format: opts.Format}
+	inferrer := storage.NewSchemaInferrer(reader.Columns())
+	for {
+		_, row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *models.RowError
+		if errors.As(err, &rowErr) {
+			result.RowsSkipped++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if result.RowsSampled == int64(sample) {
+			result.Truncated = true
+			break
+		}
+		inferrer.Add(row)
+		result.RowsSampled++
+	}
+	if result.RowsSampled == 0 {
+		return nil, models.NewValidationError("the file has no rows to infer a schema from")
+	}
+
+	result.Schema, result.Columns, result.CandidateKeys = inferrer.Infer()
+	return result, nil
+}
+
+// openReader creates the row reader for the upload format and a function
+// releasing it. Parquet keeps its metadata at the end of the file, so the
+// upload is spooled to a temporary file first rather than held in memory.
+func (s *IngestService) openReader(body io.Reader, format models.FileFormat, delimiter string) (formats.RowReader, func(), error) {
+	release := func() {}
+	switch format {
+	case models.FormatCSV:
+		comma := ','
+		if delimiter != "" {
+			r, size := utf8.DecodeRuneInString(delimiter)
+			if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+				return nil, nil, models.NewValidationError("delimiter must be a single character other than a quote or line break")
+			}
+			comma = r
+		}
+		reader, err := formats.NewCSVReader(body, comma)
+		return reader, release, err
+	case models.FormatNDJSON:
+		return formats.NewNDJSONReader(body), release, nil
+	case models.FormatParquet:
+		file, err := spool(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		release = func() { removeSpool(file) }
+
+		info, err := file.Stat()
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to spool upload: %w", err)
+		}
+		reader, err := formats.NewParquetReader(file, info.Size())
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		return reader, release, nil
+	default:
+		return nil, nil, models.NewValidationError("format must be csv, ndjson or parquet")
+	}
+}
+
+// spool copies an upload to a temporary file
+func spool(body io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		removeSpool(file)
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	return file, nil
+}
+
+// removeSpool closes and deletes a spooled upload
+func removeSpool(file *os.File) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		logger.Errorf("Error removing spooled upload: %v", err)
+	}
+}
+
+// checkColumns rejects files whose columns are not all dataset fields or field
+// aliases, which would otherwise fail every row
+func checkColumns(dataset *models.Dataset, columns []string) error {
+	declared := make(map[string]bool, len(dataset.Schema.Fields))
+	for _, field := range dataset.Schema.Fields {
+		declared[field.Name] = true
+		for _, alias := range field.Aliases {
+			declared[alias] = true
+		}
+	}
+	for _, column := range columns {
+		if !declared[column] {
+			return models.NewValidationError("column %q is not a field of dataset %s", column, dataset.ID)
+		}
+	}
+	return nil
+}
+
+// ingestBatch collects validated rows until they are written together
+type ingestBatch struct {
+	dataset   *models.Dataset
+	writer    *storage.VersionBatch
+	validator *storage.RowValidator
+	upsert    bool
+	dryRun    bool
+	result    *models.IngestResult
+	rows      []map[string]interface{}
+	positions []int
+}
+
+// flush rejects the rows of a batch that clash with stored rows and writes the
+// others. A write that still fails on a constraint, for example because of a
+// concurrent upload, rejects the whole batch.
+func (s *IngestService) flush(batch *ingestBatch) error {
+	if len(batch.rows) == 0 {
+		return nil
+	}
+	defer func() {
+		batch.rows = batch.rows[:0]
+		batch.positions = batch.positions[:0]
+	}()
+
+	conflicts, err := s.rowStore.FindConflicts(batch.dataset, batch.rows, batch.upsert)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(batch.rows))
+	positions := make([]int, 0, len(batch.rows))
+	for i, row := range batch.rows {
+		field, ok := conflicts[i]
+		if !ok {
+			rows = append(rows, row)
+			positions = append(positions, batch.positions[i])
+			continue
+		}
+		batch.validator.Release(batch.positions[i], row)
+		message := "value already exists in the dataset"
+		if field == batch.dataset.Schema.PrimaryKey {
+			message = "primary key already exists in the dataset"
+		}
+		s.reject(batch.result, models.RowError{Row: batch.positions[i], Field: field, Message: message})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if !batch.dryRun {
+		write := batch.writer.Insert
+		if batch.upsert {
+			write = batch.writer.Upsert
+		}
+		if err := write(rows); err != nil {
+			var validationErr *models.ValidationError
+			if !errors.As(err, &validationErr) {
+				return err
+			}
+			for i, row := range rows {
+				batch.validator.Release(positions[i], row)
+				s.reject(batch.result, models.RowError{Row: positions[i], Message: err.Error()})
+			}
+			return nil
+		}
+	}
+
+	batch.result.RowsAccepted += int64(len(rows))
+	return nil
+}
+
+// reject counts a rejected row, listing it until the error limit is reached
+func (s *IngestService) reject(result *models.IngestResult, rowErr models.RowError) {
+	result.RowsRejected++
+	if len(result.Errors) < s.cfg.MaxErrors {
+		result.Errors = append(result.Errors, rowErr)
+	} else {
+		result.ErrorsTruncated = true
+	}
+}
+
+// limitedReader fails with a validation error once more than limit bytes have
+// been read
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		return 0, models.NewValidationError("upload exceeds the limit of %d bytes", l.limit)
+	}
+	if int64(len(p)) > l.limit-l.read+1 {
+		p = p[:l.limit-l.read+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, models.NewValidationError("upload exceeds the limit of %d bytes", l.limit)
+	}
+	return n, err
+}
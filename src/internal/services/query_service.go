@@ -0,0 +1,347 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/config"
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// QueryService executes queries, transformations, aggregations and joins over datasets
+type QueryService struct {
+	db                *database.PostgresDB
+	datasetRepository handlers.DatasetRepository
+	rowStore          *storage.RowStore
+	limits            query.Limits
+	exportLimits      query.Limits
+	cursors           *query.CursorSigner
+}
+
+// NewQueryService creates a new query service
+func NewQueryService(db *database.PostgresDB, datasetRepository handlers.DatasetRepository, rowStore *storage.RowStore, cfg *config.QueryConfig) *QueryService {
+	return &QueryService{
+		db:                db,
+		datasetRepository: datasetRepository,
+		rowStore:          rowStore,
+		limits: query.Limits{
+			DefaultLimit: cfg.DefaultLimit,
+			MaxLimit:     cfg.MaxLimit,
+			MaxJoinRows:  cfg.MaxJoinRows,
+			MaxInputRows: cfg.MaxInputRows,
+		},
+		exportLimits: query.Limits{
+			DefaultLimit: cfg.MaxExportRows,
+			MaxLimit:     cfg.MaxExportRows,
+		},
+		cursors: query.NewCursorSigner(cfg.CursorSecret),
+	}
+}
+
+// ExecuteQuery executes a query against a dataset. It returns the requested page of
+// rows, the number of matching rows, the generated SQL and the execution time in seconds.
+// The effective limit is written back to req.Limit, the version queried to
+// req.AsOfVersion, and req.Cursor is replaced by the cursor of the next page, or
+// cleared after the last page.
+func (s *QueryService) ExecuteQuery(req *models.QueryRequest) ([]map[string]interface{}, int64, string, float64, error) {
+	start := time.Now()
+
+	dataset, err := s.datasetAsOf(req)
+	if err != nil {
+		return nil, 0, "", 0, err
+	}
+
+	// Compile query
+	after, err := s.cursors.Decode(req)
+	if err != nil {
+		return nil, 0, "", 0, err
+	}
+	stmt, err := query.CompileAfter(req, &dataset.Schema, s.limits, after)
+	if err != nil {
+		return nil, 0, "", 0, err
+	}
+	req.Limit = stmt.Limit
+
+	// Count matching rows
+	var total int64
+	if err := s.db.QueryRow(stmt.Count.Query, stmt.Count.Args...).Scan(&total); err != nil {
+		return nil, 0, "", 0, queryError(err)
+	}
+
+	// Load the requested page
+	rows, err := s.db.Query(stmt.Select.Query, stmt.Select.Args...)
+	if err != nil {
+		return nil, 0, "", 0, queryError(err)
+	}
+	defer rows.Close()
+
+	data := make([]map[string]interface{}, 0, stmt.Limit)
+	var last query.Cursor
+	keys := make([]sql.NullString, len(req.Sort))
+	dest := []interface{}{nil, &last.ID}
+	for i := range keys {
+		dest = append(dest, &keys[i])
+	}
+	for rows.Next() {
+		var raw []byte
+		dest[0] = &raw
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, "", 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row, err := storage.DecodeRow(raw, &dataset.Schema)
+		if err != nil {
+			return nil, 0, "", 0, err
+		}
+		data = append(data, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, "", 0, queryError(err)
+	}
+
+	// A full page is followed by a cursor unless the count shows no rows remain
+	req.Cursor = ""
+	if len(data) > 0 && len(data) == stmt.Limit && (after != nil || int64(req.Offset+len(data)) < total) {
+		last.Keys = make([]*string, len(keys))
+		for i, key := range keys {
+			if key.Valid {
+				text := key.String
+				last.Keys[i] = &text
+			}
+		}
+		req.Cursor = s.cursors.Encode(req, &last)
+	}
+
+	return data, total, stmt.Select.Query, time.Since(start).Seconds(), nil
+}
+
+// StreamQuery executes a query against a dataset and returns a cursor over every
+// matching row rather than a page. Without a limit at most the configured number
+// of export rows are returned.
+func (s *QueryService) StreamQuery(req *models.QueryRequest) (handlers.RowIterator, error) {
+	dataset, err := s.datasetAsOf(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compile query
+	after, err := s.cursors.Decode(req)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := query.CompileAfter(req, &dataset.Schema, s.exportLimits, after)
+	if err != nil {
+		return nil, err
+	}
+	req.Limit = stmt.Limit
+
+	// Select the projected fields in request order, named as requested
+	fields := dataset.Schema.Fields
+	if len(req.Fields) > 0 {
+		byName := make(map[string]models.DataField, len(fields))
+		for _, field := range fields {
+			byName[field.Name] = field
+			for _, alias := range field.Aliases {
+				byName[alias] = field
+			}
+		}
+		fields = make([]models.DataField, 0, len(req.Fields))
+		seen := make(map[string]bool, len(req.Fields))
+		for _, name := range req.Fields {
+			if !seen[name] {
+				seen[name] = true
+				field := byName[name]
+				field.Name = name
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	rows, err := s.db.Query(stmt.Select.Query, stmt.Select.Args...)
+	if err != nil {
+		return nil, queryError(err)
+	}
+	return queryRows{storage.NewRowCursor(rows, &dataset.Schema, fields)}, nil
+}
+
+// datasetAsOf finds the dataset a query reads. A query as of a version or time
+// reads the schema of that version, and a time is resolved to the version
+// current at that time, written back to req.AsOfVersion.
+func (s *QueryService) datasetAsOf(req *models.QueryRequest) (*models.Dataset, error) {
+	dataset, err := findDataset(s.datasetRepository, req.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	if req.AsOf == nil && req.AsOfVersion == 0 {
+		return dataset, nil
+	}
+	if req.AsOf != nil && req.AsOfVersion != 0 {
+		return nil, models.NewValidationError("as_of and as_of_version cannot be combined")
+	}
+	if req.AsOfVersion < 0 {
+		return nil, models.NewValidationError("as_of_version must not be negative")
+	}
+
+	var version *models.DatasetVersion
+	if req.AsOf != nil {
+		version, err = s.rowStore.VersionAt(dataset.ID, *req.AsOf)
+	} else {
+		version, err = s.rowStore.Version(dataset.ID, req.AsOfVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		if req.AsOf != nil {
+			return nil, models.NewValidationError("dataset %s has no version at %s", dataset.ID, req.AsOf.Format(time.RFC3339))
+		}
+		return nil, models.NewValidationError("dataset %s has no version %d", dataset.ID, req.AsOfVersion)
+	}
+
+	req.AsOfVersion = version.Version
+	snapshot := *dataset
+	snapshot.Schema = version.Schema
+	snapshot.RowCount = version.RowCount
+	return &snapshot, nil
+}
+
+// ExportDataset returns a cursor over every row of a dataset in insertion order
+func (s *QueryService) ExportDataset(datasetID uuid.UUID) (handlers.RowIterator, error) {
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.rowStore.Open(dataset)
+	if err != nil {
+		return nil, err
+	}
+	return queryRows{cursor}, nil
+}
+
+// ExecuteTransform executes a transformation pipeline against a dataset. The
+// pipeline is validated against the dataset schema before any row is loaded, and
+// the returned dataset carries the resulting schema and rows. Datasets with more
+// rows than the configured maximum for in-memory processing are rejected.
+func (s *QueryService) ExecuteTransform(transform *models.TransformRequest) (*models.Dataset, error) {
+	dataset, err := findDataset(s.datasetRepository, transform.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate steps
+	pipeline, err := query.CompileTransform(transform.Steps, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load rows and run the pipeline
+	rows, err := s.rowStore.RowsUpTo(dataset, s.limits.MaxInputRows)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := pipeline.Apply(query.NewFrame(dataset.Schema.Fields, rows))
+	if err != nil {
+		return nil, err
+	}
+
+	return frame.Dataset(), nil
+}
+
+// ExecuteAggregate executes an aggregation against a dataset. The returned
+// dataset carries the aggregated rows and a schema with the inferred type of
+// every output field. Datasets with more rows than the configured maximum for
+// in-memory processing are rejected.
+func (s *QueryService) ExecuteAggregate(aggregate *models.AggregateRequest) (*models.Dataset, error) {
+	dataset, err := findDataset(s.datasetRepository, aggregate.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate aggregations
+	plan, err := query.CompileAggregate(aggregate, dataset.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load rows and aggregate them
+	rows, err := s.rowStore.RowsUpTo(dataset, s.limits.MaxInputRows)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := plan.Apply(query.NewFrame(dataset.Schema.Fields, rows))
+	if err != nil {
+		return nil, err
+	}
+
+	return frame.Dataset(), nil
+}
+
+// ExecuteJoin joins two datasets in memory, each of which may have at most the
+// configured maximum of rows for in-memory processing. The strategy used is
+// recorded in the metadata of the returned dataset under "join_strategy".
+func (s *QueryService) ExecuteJoin(join *models.JoinRequest) (*models.Dataset, error) {
+	left, err := findDataset(s.datasetRepository, join.LeftDatasetID)
+	if err != nil {
+		return nil, err
+	}
+	right, err := findDataset(s.datasetRepository, join.RightDatasetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate conditions and build the output schema
+	plan, err := query.CompileJoin(join, left.Schema.Fields, right.Schema.Fields, s.limits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load rows of both sides and join them
+	leftRows, err := s.rowStore.RowsUpTo(left, s.limits.MaxInputRows)
+	if err != nil {
+		return nil, err
+	}
+	rightRows, err := s.rowStore.RowsUpTo(right, s.limits.MaxInputRows)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := plan.Apply(query.NewFrame(left.Schema.Fields, leftRows), query.NewFrame(right.Schema.Fields, rightRows))
+	if err != nil {
+		return nil, err
+	}
+
+	result := frame.Dataset()
+	result.Metadata = map[string]interface{}{"join_strategy": plan.Strategy()}
+	return result, nil
+}
+
+// queryError reports stored values that cannot be converted to their declared
+// field type as validation errors rather than server failures
+func queryError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && strings.HasPrefix(string(pqErr.Code), "22") {
+		return models.NewValidationError("stored values do not match the dataset schema: %s", pqErr.Message)
+	}
+	return fmt.Errorf("failed to execute query: %w", err)
+}
+
+// queryRows reports the errors of a row cursor as queryError does
+type queryRows struct {
+	*storage.RowCursor
+}
+
+// Next returns the next row, or io.EOF after the last row
+func (r queryRows) Next() (map[string]interface{}, error) {
+	row, err := r.RowCursor.Next()
+	if err != nil && err != io.EOF {
+		return nil, queryError(err)
+	}
+	return row, err
+}
@@ -0,0 +1,23 @@
+// Package services contains the concrete implementations of the service
+// interfaces consumed by the HTTP handlers.
+package services
+
+import (
+	"fmt"
+
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// findDataset loads a dataset, reporting a missing one as a not-found error
+func findDataset(repository handlers.DatasetRepository, id uuid.UUID) (*models.Dataset, error) {
+	dataset, err := repository.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find dataset: %w", err)
+	}
+	if dataset == nil {
+		return nil, models.NewNotFoundError("dataset %s not found", id)
+	}
+	return dataset, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/galafis/go-data-api-microservices/internal/handlers"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Row changes listed by a version diff when no limit is given, and at most
+const (
+	defaultDiffRows = 100
+	maxDiffRows     = 1000
+)
+
+// VersionService lists, compares and restores dataset versions
+type VersionService struct {
+	datasetRepository handlers.DatasetRepository
+	rowStore          *storage.RowStore
+}
+
+// NewVersionService creates a new version service
+func NewVersionService(datasetRepository handlers.DatasetRepository, rowStore *storage.RowStore) *VersionService {
+	return &VersionService{
+		datasetRepository: datasetRepository,
+		rowStore:          rowStore,
+	}
+}
+
+// ListVersions lists the versions of a dataset, latest first
+func (s *VersionService) ListVersions(datasetID uuid.UUID) ([]models.DatasetVersion, error) {
+	if _, err := findDataset(s.datasetRepository, datasetID); err != nil {
+		return nil, err
+	}
+	return s.rowStore.Versions(datasetID)
+}
+
+// GetVersion finds a version of a dataset, returning nil if it does not exist
+func (s *VersionService) GetVersion(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error) {
+	if _, err := findDataset(s.datasetRepository, datasetID); err != nil {
+		return nil, err
+	}
+	return s.rowStore.Version(datasetID, version)
+}
+
+// DiffVersions compares two versions of a dataset. Without a To version the
+// From version is compared with the latest one.
+func (s *VersionService) DiffVersions(datasetID uuid.UUID, opts *models.VersionDiffOptions) (*models.VersionDiff, error) {
+	if _, err := findDataset(s.datasetRepository, datasetID); err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultDiffRows
+	}
+	if limit > maxDiffRows {
+		return nil, models.NewValidationError("limit %d exceeds the maximum of %d", limit, maxDiffRows)
+	}
+
+	to := opts.To
+	if to == 0 {
+		latest, err := s.rowStore.LatestVersion(datasetID)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			to = latest.Version
+		}
+	}
+
+	return s.rowStore.DiffVersions(datasetID, opts.From, to, limit)
+}
+
+// Rollback restores a dataset to an earlier version, returning the version
+// recording the rollback
+func (s *VersionService) Rollback(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error) {
+	dataset, err := findDataset(s.datasetRepository, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	return s.rowStore.Rollback(dataset, version)
+}
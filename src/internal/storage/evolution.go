@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// widenings lists the type changes every stored value converts to losslessly
+var widenings = map[[2]models.DataType]bool{
+	{models.DataTypeInteger, models.DataTypeFloat}:   true,
+	{models.DataTypeInteger, models.DataTypeString}:  true,
+	{models.DataTypeFloat, models.DataTypeString}:    true,
+	{models.DataTypeBoolean, models.DataTypeString}:  true,
+	{models.DataTypeDateTime, models.DataTypeString}: true,
+}
+
+// fieldMatch pairs a field of a new schema with the field of the old schema it
+// continues, matched by name or else by alias; old is nil for added fields
+type fieldMatch struct {
+	old   *models.DataField
+	field models.DataField
+}
+
+// matchFields pairs the fields of two schemas, returning the pairs in the order
+// of the new schema and the old fields left unmatched
+func matchFields(from, to *models.DataSchema) ([]fieldMatch, []models.DataField) {
+	old := make(map[string]*models.DataField, len(from.Fields))
+	for i := range from.Fields {
+		old[from.Fields[i].Name] = &from.Fields[i]
+	}
+	names := make(map[string]bool, len(to.Fields))
+	for _, field := range to.Fields {
+		names[field.Name] = true
+	}
+
+	matched := make(map[string]bool, len(from.Fields))
+	matches := make([]fieldMatch, 0, len(to.Fields))
+	for _, field := range to.Fields {
+		match := fieldMatch{field: field}
+		if previous, ok := old[field.Name]; ok {
+			match.old = previous
+		} else {
+			for _, alias := range field.Aliases {
+				if previous, ok := old[alias]; ok && !names[alias] && !matched[alias] {
+					match.old = previous
+					break
+				}
+			}
+		}
+		if match.old != nil {
+			matched[match.old.Name] = true
+		}
+		matches = append(matches, match)
+	}
+
+	var dropped []models.DataField
+	for _, field := range from.Fields {
+		if !matched[field.Name] {
+			dropped = append(dropped, field)
+		}
+	}
+	return matches, dropped
+}
+
+// CheckSchemaEvolution lists the changes from one schema to another and whether
+// they are compatible with stored rows. Fields are renamed by listing their old
+// name among their aliases. Adding optional fields, renaming fields, widening
+// types and relaxing constraints are compatible; dropping fields, narrowing
+// types, making fields required, not nullable or unique and moving the
+// primary key are breaking unless the dataset has no rows. A migration plan
+// can allow the first three, unique fields are verified against the stored
+// rows as the change is applied, and the primary key cannot move.
+func CheckSchemaEvolution(from, to *models.DataSchema, plan *models.SchemaMigration, hasRows bool) *models.SchemaCompatibility {
+	if plan == nil {
+		plan = &models.SchemaMigration{}
+	}
+	report := &models.SchemaCompatibility{Compatible: true, Applicable: true, Changes: []models.SchemaChange{}}
+	add := func(change models.SchemaChange) {
+		if !hasRows {
+			change.Breaking = false
+			change.Migration = ""
+		}
+		if change.Breaking {
+			report.Compatible = false
+			if change.Migration == "" {
+				report.Applicable = false
+			}
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	matches, dropped := matchFields(from, to)
+	for _, match := range matches {
+		field := match.field
+		if match.old == nil {
+			change := models.SchemaChange{
+				Kind:    models.SchemaFieldAdded,
+				Field:   field.Name,
+				To:      string(field.Type),
+				Message: fmt.Sprintf("field %q is added", field.Name),
+			}
+			if field.Required {
+				change.Breaking = true
+				change.Migration, change.Message = fillMigration(field, plan,
+					fmt.Sprintf("required field %q is added", field.Name))
+			}
+			add(change)
+			continue
+		}
+
+		previous := *match.old
+		if previous.Name != field.Name {
+			add(models.SchemaChange{
+				Kind:    models.SchemaFieldRenamed,
+				Field:   field.Name,
+				From:    previous.Name,
+				To:      field.Name,
+				Message: fmt.Sprintf("field %q is renamed to %q", previous.Name, field.Name),
+			})
+		}
+
+		if previous.Type != field.Type {
+			change := models.SchemaChange{
+				Kind:    models.SchemaTypeWidened,
+				Field:   field.Name,
+				From:    string(previous.Type),
+				To:      string(field.Type),
+				Message: fmt.Sprintf("field %q widens from %s to %s", field.Name, previous.Type, field.Type),
+			}
+			if !widenings[[2]models.DataType{previous.Type, field.Type}] {
+				change.Kind = models.SchemaTypeNarrowed
+				change.Breaking = true
+				change.Migration, change.Message = castMigration(field, plan,
+					fmt.Sprintf("field %q changes type from %s to %s", field.Name, previous.Type, field.Type))
+			}
+			add(change)
+		}
+
+		if (field.Required && !previous.Required) || (previous.Nullable && !field.Nullable) {
+			change := models.SchemaChange{Kind: models.SchemaFieldTightened, Field: field.Name, Breaking: true}
+			change.Migration, change.Message = fillMigration(field, plan,
+				fmt.Sprintf("field %q becomes required or not nullable", field.Name))
+			add(change)
+		}
+
+		if field.Unique && !previous.Unique {
+			add(models.SchemaChange{
+				Kind:      models.SchemaFieldTightened,
+				Field:     field.Name,
+				Breaking:  true,
+				Migration: "verify",
+				Message:   fmt.Sprintf("field %q becomes unique; the change is rejected if stored rows hold duplicate values", field.Name),
+			})
+		}
+	}
+
+	for _, field := range dropped {
+		change := models.SchemaChange{
+			Kind:     models.SchemaFieldDropped,
+			Field:    field.Name,
+			From:     string(field.Type),
+			Breaking: true,
+			Message:  fmt.Sprintf("field %q is dropped; list it in the migration drops to discard its values", field.Name),
+		}
+		if containsName(plan.Drop, field.Name) {
+			change.Migration = "drop"
+			change.Message = fmt.Sprintf("field %q is dropped and its values discarded", field.Name)
+		}
+		add(change)
+	}
+
+	// The primary key may follow its field through a rename, but not move
+	if from.PrimaryKey != "" || to.PrimaryKey != "" {
+		keyField := ""
+		for _, match := range matches {
+			if match.old != nil && match.old.Name == from.PrimaryKey {
+				keyField = match.field.Name
+			}
+		}
+		if keyField != to.PrimaryKey {
+			add(models.SchemaChange{
+				Kind:     models.SchemaPrimaryKeyChanged,
+				Field:    to.PrimaryKey,
+				From:     from.PrimaryKey,
+				To:       to.PrimaryKey,
+				Breaking: true,
+				Message:  "the primary key of a dataset with rows cannot change",
+			})
+		}
+	}
+
+	return report
+}
+
+// fillMigration returns how the plan fills the missing or null values of a
+// field, and the message describing the change
+func fillMigration(field models.DataField, plan *models.SchemaMigration, message string) (string, string) {
+	if !containsName(plan.Defaults, field.Name) {
+		if field.Default == nil {
+			return "", message + "; stored rows need a default, which the field does not declare"
+		}
+		return "", message + "; list it in the migration defaults to fill stored rows from its default"
+	}
+	if field.Default == nil {
+		return "", message + "; the field has no default to fill stored rows from"
+	}
+	if _, err := ConvertFieldValue(field.Default, field.Type); err != nil {
+		return "", fmt.Sprintf("%s; its default is invalid: %v", message, err)
+	}
+	return "default", message + "; stored rows are filled from its default"
+}
+
+// castMigration returns how the plan casts the stored values of a field, and
+// the message describing the change
+func castMigration(field models.DataField, plan *models.SchemaMigration, message string) (string, string) {
+	rule, ok := plan.Casts[field.Name]
+	if !ok {
+		return "", message + "; stored values need a cast rule in the migration"
+	}
+	switch rule.OnError {
+	case "", models.CastReject:
+	case models.CastNull:
+		if !field.Nullable {
+			return "", message + "; the cast cannot store null in a field that is not nullable"
+		}
+	case models.CastDefault:
+		if field.Default == nil {
+			return "", message + "; the cast cannot fall back to a default the field does not declare"
+		}
+		if _, err := ConvertFieldValue(field.Default, field.Type); err != nil {
+			return "", fmt.Sprintf("%s; its default is invalid: %v", message, err)
+		}
+	default:
+		return "", fmt.Sprintf("%s; unknown cast failure action %q", message, rule.OnError)
+	}
+	return "cast", message + "; stored values are cast"
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rowMigration rewrites stored rows from one schema to another
+type rowMigration struct {
+	fields []fieldMigration
+	drop   []string
+}
+
+// fieldMigration moves, converts and fills the value of one field
+type fieldMigration struct {
+	from, to  string
+	fieldType models.DataType
+	convert   bool
+	cast      *models.CastRule
+	fill      bool
+	def       interface{}
+}
+
+// newRowMigration builds the rewrite of stored rows for a schema change
+// checked by CheckSchemaEvolution, returning nil when rows stay as they are
+func newRowMigration(from, to *models.DataSchema, plan *models.SchemaMigration) *rowMigration {
+	if plan == nil {
+		plan = &models.SchemaMigration{}
+	}
+
+	matches, dropped := matchFields(from, to)
+	migration := &rowMigration{}
+	for _, match := range matches {
+		field := match.field
+		step := fieldMigration{from: field.Name, to: field.Name, fieldType: field.Type}
+		if match.old != nil {
+			step.from = match.old.Name
+			step.convert = match.old.Type != field.Type
+			if rule, ok := plan.Casts[field.Name]; ok && step.convert && !widenings[[2]models.DataType{match.old.Type, field.Type}] {
+				step.cast = &rule
+			}
+		}
+		if field.Default != nil {
+			step.def, _ = ConvertFieldValue(field.Default, field.Type)
+			step.fill = containsName(plan.Defaults, field.Name)
+		}
+		if step.from != step.to || step.convert || step.fill {
+			migration.fields = append(migration.fields, step)
+		}
+	}
+	for _, field := range dropped {
+		if containsName(plan.Drop, field.Name) {
+			migration.drop = append(migration.drop, field.Name)
+		}
+	}
+
+	if len(migration.fields) == 0 && len(migration.drop) == 0 {
+		return nil
+	}
+	return migration
+}
+
+// apply rewrites a decoded row in place
+func (m *rowMigration) apply(row map[string]interface{}) error {
+	for _, name := range m.drop {
+		delete(row, name)
+	}
+
+	for _, step := range m.fields {
+		value, present := row[step.from]
+		delete(row, step.from)
+
+		if present && value != nil && step.convert {
+			var converted interface{}
+			var err error
+			if step.cast != nil {
+				converted, err = query.CastValue(value, step.fieldType)
+			} else {
+				converted, err = ConvertFieldValue(value, step.fieldType)
+			}
+			if err != nil {
+				if step.cast == nil || step.cast.OnError == "" || step.cast.OnError == models.CastReject {
+					return fmt.Errorf("field %q: %v", step.to, err)
+				}
+				converted = nil
+				if step.cast.OnError == models.CastDefault {
+					converted = step.def
+				}
+			}
+			value = converted
+		}
+		if step.fill && (!present || value == nil) {
+			value, present = step.def, true
+		}
+		if present {
+			row[step.to] = value
+		}
+	}
+
+	return nil
+}
+
+// CheckSchema checks a schema change for a dataset against its stored rows
+func (s *RowStore) CheckSchema(dataset *models.Dataset, schema *models.DataSchema, plan *models.SchemaMigration) (*models.SchemaCompatibility, error) {
+	if err := ValidateSchema(schema); err != nil {
+		return nil, err
+	}
+	return CheckSchemaEvolution(&dataset.Schema, schema, plan, dataset.RowCount > 0), nil
+}
+
+// EvolveSchema moves a dataset to a new schema as a new version, rewriting its
+// stored rows by the migration plan. Breaking changes the plan does not cover
+// are rejected with a validation error listing them. The change is checked
+// again under the version lock against the stored schema and rows, so a
+// concurrent change is never migrated from a stale schema. The dataset, with
+// any other changes made by the caller, is persisted and updated in place.
+func (s *RowStore) EvolveSchema(dataset *models.Dataset, schema models.DataSchema, plan *models.SchemaMigration) (*models.SchemaCompatibility, error) {
+	report, err := s.CheckSchema(dataset, &schema, plan)
+	if err != nil {
+		return nil, err
+	}
+	if err := breakingChanges(report); err != nil {
+		return nil, err
+	}
+
+	evolved := *dataset
+	evolved.Schema = schema
+
+	// New indexes guard the rows as they are rewritten; the indexes of the old
+	// schema are dropped once they are
+	if err := s.createIndexes(&evolved); err != nil {
+		return nil, s.restoreIndexes(dataset, err)
+	}
+
+	stored := dataset
+	version := models.DatasetVersion{Change: models.VersionUpdate}
+	err = s.mutate(&evolved, &version, true, func(tx *sql.Tx, number int64) (rowChange, error) {
+		current, err := storedDataset(tx, dataset)
+		if err != nil {
+			return rowChange{}, err
+		}
+		stored = current
+
+		report = CheckSchemaEvolution(&current.Schema, &schema, plan, current.RowCount > 0)
+		if err := breakingChanges(report); err != nil {
+			return rowChange{}, err
+		}
+		if current.RowCount == 0 {
+			return rowChange{}, nil
+		}
+		return s.migrateRows(tx, current, &evolved, newRowMigration(&current.Schema, &schema, plan), number)
+	})
+	if err != nil {
+		return nil, s.restoreIndexes(stored, err)
+	}
+
+	if err := s.dropStaleIndexes(&evolved); err != nil {
+		return nil, err
+	}
+
+	*dataset = evolved
+	return report, nil
+}
+
+// breakingChanges returns a validation error listing the breaking changes a
+// migration plan does not cover, or nil when it covers them all
+func breakingChanges(report *models.SchemaCompatibility) error {
+	if report.Applicable {
+		return nil
+	}
+	var problems []string
+	for _, change := range report.Changes {
+		if change.Breaking && change.Migration == "" {
+			problems = append(problems, change.Message)
+		}
+	}
+	return models.NewValidationError("breaking schema changes: %s", strings.Join(problems, "; "))
+}
+
+// restoreIndexes puts back the row indexes of a dataset after a failed schema
+// change, returning the error of the change
+func (s *RowStore) restoreIndexes(dataset *models.Dataset, err error) error {
+	if syncErr := s.SyncIndexes(dataset); syncErr != nil {
+		return fmt.Errorf("%w (and failed to restore indexes: %v)", err, syncErr)
+	}
+	return err
+}
+
+// migrateRows rewrites the stored rows of a dataset in batches of ascending id,
+// recording every changed row in the history of the version
+func (s *RowStore) migrateRows(tx *sql.Tx, from, to *models.Dataset, migration *rowMigration, number int64) (rowChange, error) {
+	var total rowChange
+	var last int64
+	for {
+		rows, err := tx.Query(
+			`SELECT id, row_key, data FROM dataset_rows WHERE dataset_id = $1 AND id > $2 ORDER BY id LIMIT $3`,
+			from.ID, last, insertBatchSize,
+		)
+		if err != nil {
+			return rowChange{}, fmt.Errorf("failed to load rows: %w", err)
+		}
+
+		args := []interface{}{from.ID, number}
+		var values []string
+		count := 0
+		for rows.Next() {
+			var id int64
+			var rowKey string
+			var data []byte
+			if err := rows.Scan(&id, &rowKey, &data); err != nil {
+				rows.Close()
+				return rowChange{}, fmt.Errorf("failed to scan row: %w", err)
+			}
+			last = id
+			count++
+
+			row, err := DecodeRow(data, &from.Schema)
+			if err != nil {
+				rows.Close()
+				return rowChange{}, err
+			}
+			original, _ := json.Marshal(row)
+			if err := migration.apply(row); err != nil {
+				rows.Close()
+				return rowChange{}, models.NewValidationError("row %s: %v", rowKey, err)
+			}
+			migrated, err := json.Marshal(row)
+			if err != nil {
+				rows.Close()
+				return rowChange{}, models.NewValidationError("row %s: %v", rowKey, err)
+			}
+			if bytes.Equal(original, migrated) {
+				continue
+			}
+
+			if to.Schema.PrimaryKey != "" {
+				key, err := KeyString(row[to.Schema.PrimaryKey])
+				if err != nil || key != rowKey {
+					rows.Close()
+					return rowChange{}, models.NewValidationError("row %s: the migration changes its primary key", rowKey)
+				}
+			}
+
+			args = append(args, id, migrated)
+			values = append(values, fmt.Sprintf("($%d::bigint, $%d::jsonb)", len(args)-1, len(args)))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rowChange{}, fmt.Errorf("failed to load rows: %w", err)
+		}
+
+		if len(values) > 0 {
+			change, err := queryChange(tx,
+				`WITH input (id, data) AS (VALUES `+strings.Join(values, ", ")+`),
+				old AS (
+					SELECT id, `+rowSize+` AS size FROM dataset_rows
+					WHERE dataset_id = $1 AND id IN (SELECT id FROM input)
+				),
+				written AS (
+					UPDATE dataset_rows r SET data = i.data, updated_at = NOW()
+					FROM input i
+					WHERE r.dataset_id = $1 AND r.id = i.id
+					RETURNING r.id, r.row_key, r.data
+				),
+				history AS (`+historyInsert+`id, row_key, data FROM written)
+				SELECT COUNT(*), 0, COALESCE(SUM(octet_length(w.data::text)), 0) - COALESCE(SUM(old.size), 0)
+				FROM written w LEFT JOIN old ON old.id = w.id`,
+				args...,
+			)
+			if err != nil {
+				return rowChange{}, rowWriteError(to, err)
+			}
+			total.add(change)
+		}
+
+		if count < insertBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changeKinds lists the kinds of the changes in a report, in order
+func changeKinds(report *models.SchemaCompatibility) []models.SchemaChangeKind {
+	kinds := make([]models.SchemaChangeKind, len(report.Changes))
+	for i, change := range report.Changes {
+		kinds[i] = change.Kind
+	}
+	return kinds
+}
+
+func TestCheckSchemaEvolution(t *testing.T) {
+	from := customersDataset().Schema
+
+	// Test Case 1: Optional fields, renames and widened types are compatible
+	t.Run("Compatible", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[0].Type = models.DataTypeFloat
+		to.Fields[2].Name = "rating"
+		to.Fields[2].Aliases = []string{"score"}
+		to.Fields[4].Type = models.DataTypeString
+		to.Fields = append(to.Fields, models.DataField{Name: "region", Type: models.DataTypeString, Nullable: true})
+
+		report := CheckSchemaEvolution(&from, &to, nil, true)
+		assert.True(t, report.Compatible)
+		assert.True(t, report.Applicable)
+		assert.Equal(t, []models.SchemaChangeKind{
+			models.SchemaTypeWidened, models.SchemaFieldRenamed, models.SchemaTypeWidened, models.SchemaFieldAdded,
+		}, changeKinds(report))
+		assert.Equal(t, "score", report.Changes[1].From)
+		assert.Equal(t, "rating", report.Changes[1].To)
+	})
+
+	// Test Case 2: Breaking changes are rejected without a migration plan
+	t.Run("Breaking", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[2].Type = models.DataTypeInteger
+		to.Fields[3].Required = true
+		to.Fields = to.Fields[:5]
+
+		report := CheckSchemaEvolution(&from, &to, nil, true)
+		assert.False(t, report.Compatible)
+		assert.False(t, report.Applicable)
+		assert.Equal(t, []models.SchemaChangeKind{
+			models.SchemaTypeNarrowed, models.SchemaFieldTightened, models.SchemaFieldDropped,
+		}, changeKinds(report))
+		for _, change := range report.Changes {
+			assert.True(t, change.Breaking, change.Field)
+			assert.Equal(t, "", change.Migration, change.Field)
+		}
+
+		// The same changes are compatible while the dataset has no rows
+		report = CheckSchemaEvolution(&from, &to, nil, false)
+		assert.True(t, report.Compatible)
+		assert.Len(t, report.Changes, 3)
+	})
+
+	// Test Case 3: A migration plan covers casts, defaults and drops
+	t.Run("Migration", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[2].Type = models.DataTypeInteger
+		to.Fields[3].Required = true
+		to.Fields = to.Fields[:5]
+		plan := &models.SchemaMigration{
+			Casts:    map[string]models.CastRule{"score": {OnError: models.CastNull}},
+			Defaults: []string{"active"},
+			Drop:     []string{"tags"},
+		}
+
+		report := CheckSchemaEvolution(&from, &to, plan, true)
+		assert.False(t, report.Compatible)
+		assert.True(t, report.Applicable)
+		var migrations []string
+		for _, change := range report.Changes {
+			migrations = append(migrations, change.Migration)
+		}
+		assert.Equal(t, []string{"cast", "default", "drop"}, migrations)
+	})
+
+	// Test Case 4: Plans that cannot be applied leave the change unresolved
+	t.Run("Unresolved", func(t *testing.T) {
+		invalid := map[string]struct {
+			change func(schema *models.DataSchema)
+			plan   *models.SchemaMigration
+		}{
+			"null into not nullable": {
+				func(schema *models.DataSchema) { schema.Fields[1].Type = models.DataTypeInteger },
+				&models.SchemaMigration{Casts: map[string]models.CastRule{"email": {OnError: models.CastNull}}},
+			},
+			"cast without default": {
+				func(schema *models.DataSchema) { schema.Fields[2].Type = models.DataTypeInteger },
+				&models.SchemaMigration{Casts: map[string]models.CastRule{"score": {OnError: models.CastDefault}}},
+			},
+			"required without default": {
+				func(schema *models.DataSchema) {
+					schema.Fields = append(schema.Fields, models.DataField{Name: "region", Type: models.DataTypeString, Required: true})
+				},
+				&models.SchemaMigration{Defaults: []string{"region"}},
+			},
+			"moved primary key": {
+				func(schema *models.DataSchema) { schema.PrimaryKey = "email" },
+				&models.SchemaMigration{},
+			},
+		}
+		for name, c := range invalid {
+			to := customersDataset().Schema
+			c.change(&to)
+			report := CheckSchemaEvolution(&from, &to, c.plan, true)
+			assert.False(t, report.Applicable, name)
+		}
+	})
+
+	// Test Case 5: Fields that become unique are breaking and verified against stored rows
+	t.Run("Unique", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[2].Unique = true
+
+		report := CheckSchemaEvolution(&from, &to, nil, true)
+		assert.False(t, report.Compatible)
+		assert.True(t, report.Applicable)
+		require.Len(t, report.Changes, 1)
+		assert.Equal(t, models.SchemaFieldTightened, report.Changes[0].Kind)
+		assert.Equal(t, "score", report.Changes[0].Field)
+		assert.True(t, report.Changes[0].Breaking)
+		assert.Equal(t, "verify", report.Changes[0].Migration)
+
+		// Without rows there is nothing to verify
+		report = CheckSchemaEvolution(&from, &to, nil, false)
+		assert.True(t, report.Compatible)
+		assert.Equal(t, "", report.Changes[0].Migration)
+	})
+}
+
+func TestRowMigration(t *testing.T) {
+	from := customersDataset().Schema
+
+	// Test Case 1: Rows are renamed, converted, filled and trimmed
+	t.Run("Apply", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[0].Type = models.DataTypeString
+		to.Fields[2].Name = "rating"
+		to.Fields[2].Aliases = []string{"score"}
+		to.Fields[2].Type = models.DataTypeInteger
+		to.Fields[3].Required = true
+		to.Fields = to.Fields[:5]
+		plan := &models.SchemaMigration{
+			Casts:    map[string]models.CastRule{"rating": {}},
+			Defaults: []string{"active"},
+			Drop:     []string{"tags"},
+		}
+
+		migration := newRowMigration(&from, &to, plan)
+		require.NotNil(t, migration)
+
+		row := map[string]interface{}{"id": int64(7), "email": "a", "score": 9.0, "tags": []interface{}{"x"}}
+		require.NoError(t, migration.apply(row))
+		assert.Equal(t, map[string]interface{}{"id": "7", "email": "a", "rating": int64(9), "active": true}, row)
+	})
+
+	// Test Case 2: Values that do not cast follow the rule of the field
+	t.Run("Cast Failures", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields[1].Type = models.DataTypeInteger
+		to.Fields[1].Default = 0
+
+		migration := newRowMigration(&from, &to, &models.SchemaMigration{
+			Casts: map[string]models.CastRule{"email": {OnError: models.CastDefault}},
+		})
+		row := map[string]interface{}{"id": int64(1), "email": "a@example.com"}
+		require.NoError(t, migration.apply(row))
+		assert.Equal(t, int64(0), row["email"])
+
+		migration = newRowMigration(&from, &to, &models.SchemaMigration{
+			Casts: map[string]models.CastRule{"email": {}},
+		})
+		row = map[string]interface{}{"id": int64(1), "email": "a@example.com"}
+		assert.Error(t, migration.apply(row))
+	})
+
+	// Test Case 3: Compatible changes that keep every key need no rewrite
+	t.Run("Unchanged", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields = append(to.Fields, models.DataField{Name: "region", Type: models.DataTypeString, Nullable: true})
+		assert.Nil(t, newRowMigration(&from, &to, nil))
+	})
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// SchemaInferrer proposes a dataset schema from sample rows of a file. Column
+// types are the narrowest the row validator accepts for every sampled value:
+// integers widen to floats when a column mixes them, and columns mixing other
+// types, or holding numbers written with leading zeros such as postal codes,
+// are strings.
+type SchemaInferrer struct {
+	columns []*columnStats
+	byName  map[string]*columnStats
+	rows    int64
+}
+
+// columnStats accumulates what the sampled values of a column looked like
+type columnStats struct {
+	name     string
+	values   int64
+	nulls    int64
+	types    map[models.DataType]bool
+	layouts  []string
+	distinct map[string]bool
+}
+
+// NewSchemaInferrer creates an inferrer for rows with the given columns. Files
+// without a header, whose rows name their own fields, pass nil and get their
+// columns in order of first appearance, sorted by name within a row.
+func NewSchemaInferrer(columns []string) *SchemaInferrer {
+	i := &SchemaInferrer{byName: make(map[string]*columnStats, len(columns))}
+	for _, name := range columns {
+		i.column(name)
+	}
+	return i
+}
+
+// column returns the statistics of a column, adding it when first seen
+func (i *SchemaInferrer) column(name string) *columnStats {
+	stats, ok := i.byName[name]
+	if !ok {
+		stats = &columnStats{
+			name:     name,
+			types:    make(map[models.DataType]bool),
+			distinct: make(map[string]bool),
+		}
+		i.byName[name] = stats
+		i.columns = append(i.columns, stats)
+	}
+	return stats
+}
+
+// Add samples a row as read from a file
+func (i *SchemaInferrer) Add(row map[string]interface{}) {
+	i.rows++
+
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := i.column(name)
+		value := row[name]
+		if value == nil {
+			stats.nulls++
+			continue
+		}
+
+		t, layout, text := inferValue(value)
+		stats.values++
+		stats.types[t] = true
+		stats.distinct[text] = true
+		if layout != "" && !containsName(stats.layouts, layout) {
+			stats.layouts = append(stats.layouts, layout)
+		}
+	}
+}
+
+// Infer returns the proposed schema, the profile of every column and the
+// candidate primary keys, best first
+func (i *SchemaInferrer) Infer() (models.DataSchema, []models.ColumnProfile, []string) {
+	schema := models.DataSchema{Fields: make([]models.DataField, 0, len(i.columns))}
+	profiles := make([]models.ColumnProfile, 0, len(i.columns))
+	keys := []string{}
+
+	for _, stats := range i.columns {
+		profile := models.ColumnProfile{
+			Name:     stats.name,
+			Type:     stats.resolve(),
+			Values:   stats.values,
+			Nulls:    stats.nulls,
+			Missing:  i.rows - stats.values - stats.nulls,
+			Distinct: int64(len(stats.distinct)),
+		}
+		if profile.Type == models.DataTypeDateTime {
+			profile.DateTimeFormats = stats.layouts
+		}
+		profile.Unique = profile.Values > 0 && profile.Distinct == profile.Values
+		profiles = append(profiles, profile)
+
+		complete := profile.Nulls == 0 && profile.Missing == 0
+		schema.Fields = append(schema.Fields, models.DataField{
+			Name:     stats.name,
+			Type:     profile.Type,
+			Required: complete && profile.Values > 0,
+			Nullable: profile.Nulls > 0 || profile.Values == 0,
+		})
+
+		if complete && profile.Unique && (profile.Type == models.DataTypeInteger || profile.Type == models.DataTypeString) {
+			keys = append(keys, stats.name)
+		}
+	}
+
+	// Prefer key columns named as identifiers, then integers, then file order
+	rank := make(map[string]int, len(keys))
+	for _, field := range schema.Fields {
+		if !containsName(keys, field.Name) {
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		switch {
+		case name == "id":
+			rank[field.Name] = 0
+		case strings.HasSuffix(name, "id") || strings.HasSuffix(name, "key") || strings.HasSuffix(name, "code"):
+			rank[field.Name] = 2
+		default:
+			rank[field.Name] = 4
+		}
+		if field.Type != models.DataTypeInteger {
+			rank[field.Name]++
+		}
+	}
+	sort.SliceStable(keys, func(a, b int) bool { return rank[keys[a]] < rank[keys[b]] })
+	if len(keys) > 0 {
+		schema.PrimaryKey = keys[0]
+	}
+
+	return schema, profiles, keys
+}
+
+// resolve returns the narrowest type accepting every sampled value of a column
+func (c *columnStats) resolve() models.DataType {
+	switch len(c.types) {
+	case 0:
+		return models.DataTypeString
+	case 1:
+		for t := range c.types {
+			return t
+		}
+	case 2:
+		if c.types[models.DataTypeInteger] && c.types[models.DataTypeFloat] {
+			return models.DataTypeFloat
+		}
+	}
+	return models.DataTypeString
+}
+
+// inferValue returns the type of a non-null value read from a file, the
+// layout of a datetime string and the text used to count distinct values
+func inferValue(value interface{}) (models.DataType, string, string) {
+	switch v := value.(type) {
+	case bool:
+		return models.DataTypeBoolean, "", strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return models.DataTypeInteger, "", query.FormatValue(v)
+	case float32, float64:
+		return models.DataTypeFloat, "", query.FormatValue(v)
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return models.DataTypeInteger, "", v.String()
+		}
+		return models.DataTypeFloat, "", v.String()
+	case time.Time:
+		return models.DataTypeDateTime, "", v.UTC().Format(time.RFC3339Nano)
+	case string:
+		t, layout := inferString(v)
+		return t, layout, v
+	case []interface{}:
+		encoded, _ := json.Marshal(v)
+		return models.DataTypeArray, "", string(encoded)
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return models.DataTypeObject, "", string(encoded)
+	default:
+		return models.DataTypeString, "", query.FormatValue(v)
+	}
+}
+
+// inferString returns the type a string value holds and, for datetimes, the
+// layout it was written in
+func inferString(s string) (models.DataType, string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return models.DataTypeString, ""
+	}
+
+	// Numbers with leading zeros are codes whose zeros must be kept
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	code := len(digits) > 1 && digits[0] == '0' && digits[1] != '.'
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if code {
+			return models.DataTypeString, ""
+		}
+		return models.DataTypeInteger, ""
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.ContainsAny(s, "xX_") {
+		if code {
+			return models.DataTypeString, ""
+		}
+		return models.DataTypeFloat, ""
+	}
+
+	switch s {
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return models.DataTypeBoolean, ""
+	}
+
+	for _, layout := range query.DateTimeLayouts {
+		if _, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return models.DataTypeDateTime, layout
+		}
+	}
+
+	return models.DataTypeString, ""
+}
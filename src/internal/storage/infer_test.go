@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaInferrer(t *testing.T) {
+	// Test Case 1: CSV values are typed by their text
+	t.Run("CSV", func(t *testing.T) {
+		inferrer := NewSchemaInferrer([]string{"id", "price", "active", "day", "at", "zip", "note"})
+		inferrer.Add(map[string]interface{}{
+			"id": "1", "price": "10", "active": "true", "day": "2024-03-01",
+			"at": "2024-03-01 12:00:00", "zip": "02134", "note": nil,
+		})
+		inferrer.Add(map[string]interface{}{
+			"id": "2", "price": "10.5", "active": "FALSE", "day": "2024-03-02",
+			"at": "2024-03-01T12:00:00Z", "zip": "90210", "note": "late",
+		})
+
+		schema, profiles, keys := inferrer.Infer()
+		assert.Equal(t, models.DataSchema{
+			PrimaryKey: "id",
+			Fields: []models.DataField{
+				{Name: "id", Type: models.DataTypeInteger, Required: true},
+				{Name: "price", Type: models.DataTypeFloat, Required: true},
+				{Name: "active", Type: models.DataTypeBoolean, Required: true},
+				{Name: "day", Type: models.DataTypeDateTime, Required: true},
+				{Name: "at", Type: models.DataTypeDateTime, Required: true},
+				{Name: "zip", Type: models.DataTypeString, Required: true},
+				{Name: "note", Type: models.DataTypeString, Nullable: true},
+			},
+		}, schema)
+		assert.Equal(t, []string{"id", "zip"}, keys)
+		assert.Equal(t, []string{"2006-01-02"}, profiles[3].DateTimeFormats)
+		assert.Equal(t, []string{"2006-01-02 15:04:05", time.RFC3339Nano}, profiles[4].DateTimeFormats)
+		assert.Equal(t, int64(1), profiles[6].Nulls)
+	})
+
+	// Test Case 2: Typed values keep their type and absent fields are optional
+	t.Run("Typed", func(t *testing.T) {
+		inferrer := NewSchemaInferrer(nil)
+		inferrer.Add(map[string]interface{}{
+			"sku": "a-1", "qty": json.Number("3"), "tags": []interface{}{"x"}, "seen": time.Now(),
+		})
+		inferrer.Add(map[string]interface{}{
+			"sku": "a-2", "qty": json.Number("4"), "extra": map[string]interface{}{"k": 1.0}, "seen": time.Now(),
+		})
+
+		schema, profiles, keys := inferrer.Infer()
+		require.Len(t, schema.Fields, 5)
+		types := make(map[string]models.DataType)
+		for _, field := range schema.Fields {
+			types[field.Name] = field.Type
+		}
+		assert.Equal(t, map[string]models.DataType{
+			"qty": models.DataTypeInteger, "seen": models.DataTypeDateTime, "sku": models.DataTypeString,
+			"tags": models.DataTypeArray, "extra": models.DataTypeObject,
+		}, types)
+		assert.Equal(t, "qty", schema.PrimaryKey)
+		assert.Equal(t, []string{"qty", "sku"}, keys)
+		for _, profile := range profiles {
+			if profile.Name == "tags" {
+				assert.Equal(t, int64(1), profile.Missing)
+			}
+		}
+	})
+
+	// Test Case 3: Mixed, repeated and empty columns
+	t.Run("Mixed", func(t *testing.T) {
+		inferrer := NewSchemaInferrer([]string{"code", "value", "empty"})
+		inferrer.Add(map[string]interface{}{"code": "a", "value": "1", "empty": nil})
+		inferrer.Add(map[string]interface{}{"code": "a", "value": "yes", "empty": nil})
+		inferrer.Add(map[string]interface{}{"code": "b", "value": "yes", "empty": nil})
+
+		schema, profiles, keys := inferrer.Infer()
+		assert.Equal(t, models.DataTypeString, schema.Fields[1].Type)
+		assert.Equal(t, models.DataField{Name: "empty", Type: models.DataTypeString, Nullable: true}, schema.Fields[2])
+		assert.False(t, profiles[0].Unique)
+		assert.Empty(t, keys)
+		assert.Equal(t, "", schema.PrimaryKey)
+	})
+}
+
+func TestInferredSchemaValidatesSample(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": "7", "score": "9", "joined": "2024-03-01 12:00", "zip": "02134"},
+		{"id": "8", "score": "9.5", "joined": "2024-03-02", "zip": "10001"},
+	}
+	inferrer := NewSchemaInferrer([]string{"id", "score", "joined", "zip"})
+	for _, row := range rows {
+		inferrer.Add(row)
+	}
+	schema, _, _ := inferrer.Infer()
+	require.NoError(t, ValidateSchema(&schema))
+
+	validator := NewRowValidator(&models.Dataset{Schema: schema}, false)
+	for i, row := range rows {
+		converted, rowErr := validator.Validate(i+1, row)
+		require.Nil(t, rowErr)
+		if i == 0 {
+			assert.Equal(t, "02134", converted["zip"])
+		}
+	}
+}
@@ -0,0 +1,878 @@
+// Package storage persists dataset rows. Rows are stored as JSONB documents in a
+// single PostgreSQL table keyed by dataset, with per-dataset expression indexes
+// generated from the dataset schema. Every change is recorded as an immutable
+// dataset version, with the rows it wrote or deleted kept in a history table
+// from which the rows of any version can be read back.
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/database"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// insertBatchSize bounds the number of rows written by a single INSERT statement
+const insertBatchSize = 500
+
+// rowKeyConstraint is the unique constraint guarding primary key values
+const rowKeyConstraint = "uq_dataset_rows_key"
+
+// uniqueViolation is the PostgreSQL error code raised by unique constraints
+const uniqueViolation = "23505"
+
+// DatasetStore persists the metadata of datasets stored outside PostgreSQL,
+// which cannot change in the transaction that writes their rows. SetCounts
+// records the row count and size of a dataset as of a version, and must leave
+// counts recorded by a later version as they are.
+type DatasetStore interface {
+	Update(dataset *models.Dataset) error
+	SetCounts(datasetID uuid.UUID, version, rowCount, size int64) error
+}
+
+// RowStore stores and retrieves dataset rows
+type RowStore struct {
+	db       *database.PostgresDB
+	datasets DatasetStore
+}
+
+// NewRowStore creates a new row store. datasets is nil when dataset metadata
+// is kept in the datasets table of db, whose row count, size and, for schema
+// changes and rollbacks, metadata are then updated in the transaction that
+// writes the rows.
+func NewRowStore(db *database.PostgresDB, datasets DatasetStore) *RowStore {
+	return &RowStore{
+		db:       db,
+		datasets: datasets,
+	}
+}
+
+// SyncIndexes validates the dataset schema and creates or drops the row indexes
+// so they match its unique fields and Indexes list
+func (s *RowStore) SyncIndexes(dataset *models.Dataset) error {
+	if err := ValidateSchema(&dataset.Schema); err != nil {
+		return err
+	}
+	if err := s.createIndexes(dataset); err != nil {
+		return err
+	}
+	return s.dropStaleIndexes(dataset)
+}
+
+// createIndexes creates the row indexes required by the dataset schema that do
+// not exist yet. Indexes are built concurrently, outside any transaction, so
+// writes to other datasets are not blocked while they are. When a build fails
+// the indexes created by the call are dropped again, along with the invalid
+// index the failed build leaves behind.
+func (s *RowStore) createIndexes(dataset *models.Dataset) error {
+	existing, err := s.existingIndexes(dataset.ID)
+	if err != nil {
+		return err
+	}
+
+	var created []string
+	for _, spec := range indexSpecs(dataset) {
+		valid, ok := existing[spec.name]
+		if valid {
+			continue
+		}
+		if ok {
+			// An earlier build failed; IF NOT EXISTS would keep its invalid index
+			if err := s.dropIndex(spec.name); err != nil {
+				return err
+			}
+		}
+
+		unique := ""
+		if spec.unique {
+			unique = "UNIQUE "
+		}
+		query := fmt.Sprintf(
+			`CREATE %sINDEX CONCURRENTLY IF NOT EXISTS %s ON dataset_rows ((data->>%s)) WHERE dataset_id = %s`,
+			unique, pq.QuoteIdentifier(spec.name), pq.QuoteLiteral(spec.field), pq.QuoteLiteral(dataset.ID.String()),
+		)
+		if _, err := s.db.Exec(query); err != nil {
+			if isUniqueViolation(err) {
+				err = models.NewValidationError("existing rows contain duplicate values for unique field %q", spec.field)
+			} else {
+				err = fmt.Errorf("failed to create index on field %s: %w", spec.field, err)
+			}
+			for _, name := range append(created, spec.name) {
+				if dropErr := s.dropIndex(name); dropErr != nil {
+					return fmt.Errorf("%w (and %v)", err, dropErr)
+				}
+			}
+			return err
+		}
+		created = append(created, spec.name)
+	}
+
+	return nil
+}
+
+// dropStaleIndexes drops the row indexes the dataset schema no longer requires
+func (s *RowStore) dropStaleIndexes(dataset *models.Dataset) error {
+	existing, err := s.existingIndexes(dataset.ID)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	for _, spec := range indexSpecs(dataset) {
+		wanted[spec.name] = true
+	}
+	for name := range existing {
+		if !wanted[name] {
+			if err := s.dropIndex(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// dropIndex drops a row index concurrently, outside any transaction
+func (s *RowStore) dropIndex(name string) error {
+	if _, err := s.db.Exec(`DROP INDEX CONCURRENTLY IF EXISTS ` + pq.QuoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to drop index %s: %w", name, err)
+	}
+	return nil
+}
+
+// Insert appends rows to a dataset as a new version. Rows whose primary key
+// already exists are rejected.
+func (s *RowStore) Insert(dataset *models.Dataset, rows []map[string]interface{}) error {
+	return s.Batch(dataset).Insert(rows)
+}
+
+// Upsert inserts rows or replaces the stored rows with the same primary key as
+// a new version. The dataset schema must declare a primary key.
+func (s *RowStore) Upsert(dataset *models.Dataset, rows []map[string]interface{}) error {
+	return s.Batch(dataset).Upsert(rows)
+}
+
+// VersionBatch writes rows to a dataset in several transactions recorded as a
+// single version, such as the batches of an upload. The version is created by
+// the first write.
+type VersionBatch struct {
+	store   *RowStore
+	dataset *models.Dataset
+	version models.DatasetVersion
+}
+
+// Batch starts writing rows to a dataset as a single version
+func (s *RowStore) Batch(dataset *models.Dataset) *VersionBatch {
+	return &VersionBatch{store: s, dataset: dataset}
+}
+
+// Version returns the number of the version written by the batch, or 0 before
+// its first write
+func (b *VersionBatch) Version() int64 {
+	return b.version.Version
+}
+
+// Insert appends rows. Rows whose primary key already exists are rejected.
+func (b *VersionBatch) Insert(rows []map[string]interface{}) error {
+	return b.store.write(b.dataset, &b.version, rows, false)
+}
+
+// Upsert inserts rows or replaces the stored rows with the same primary key.
+// The dataset schema must declare a primary key.
+func (b *VersionBatch) Upsert(rows []map[string]interface{}) error {
+	if b.dataset.Schema.PrimaryKey == "" {
+		return models.NewValidationError("dataset %s has no primary key to upsert on", b.dataset.ID)
+	}
+	return b.store.write(b.dataset, &b.version, dedupeByKey(b.dataset, rows), true)
+}
+
+// FindConflicts reports which rows would violate a unique constraint of the stored
+// rows: in insert mode a stored row with the same primary key, and in either mode
+// a stored row with another key holding the same value of a unique field. The
+// result maps row indexes to the conflicting field. Rows must have been checked
+// by a RowValidator.
+func (s *RowStore) FindConflicts(dataset *models.Dataset, rows []map[string]interface{}, upsert bool) (map[int]string, error) {
+	conflicts := make(map[int]string)
+	primaryKey := dataset.Schema.PrimaryKey
+
+	keys := make([]string, len(rows))
+	if primaryKey != "" {
+		for i, row := range rows {
+			key, err := KeyString(row[primaryKey])
+			if err != nil {
+				return nil, models.NewValidationError("row %d: %v", i, err)
+			}
+			keys[i] = key
+		}
+	}
+
+	if primaryKey != "" && !upsert {
+		stored, err := s.storedValues(dataset.ID, "row_key", keys)
+		if err != nil {
+			return nil, err
+		}
+		for i, key := range keys {
+			if _, ok := stored[key]; ok {
+				conflicts[i] = primaryKey
+			}
+		}
+	}
+
+	for _, spec := range indexSpecs(dataset) {
+		if !spec.unique {
+			continue
+		}
+		values := make([]string, 0, len(rows))
+		for _, row := range rows {
+			if value, ok := row[spec.field]; ok && value != nil {
+				values = append(values, uniqueText(value))
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		stored, err := s.storedValues(dataset.ID, "data->>"+pq.QuoteLiteral(spec.field), values)
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range rows {
+			value, ok := row[spec.field]
+			if !ok || value == nil {
+				continue
+			}
+			storedKey, exists := stored[uniqueText(value)]
+			if _, marked := conflicts[i]; exists && !marked && (primaryKey == "" || storedKey != keys[i]) {
+				conflicts[i] = spec.field
+			}
+		}
+	}
+
+	return conflicts, nil
+}
+
+// storedValues returns the values of a row expression that match any of the given
+// values, mapped to the key of the stored row holding them
+func (s *RowStore) storedValues(datasetID uuid.UUID, expr string, values []string) (map[string]string, error) {
+	rows, err := s.db.Query(
+		fmt.Sprintf(`SELECT %s, row_key FROM dataset_rows WHERE dataset_id = $1 AND %s = ANY($2)`, expr, expr),
+		datasetID, pq.Array(values),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stored values: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]string)
+	for rows.Next() {
+		var value, key string
+		if err := rows.Scan(&value, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan stored value: %w", err)
+		}
+		stored[value] = key
+	}
+
+	return stored, rows.Err()
+}
+
+// Delete removes the rows with the given primary key values and returns how many were deleted
+func (s *RowStore) Delete(dataset *models.Dataset, keys []interface{}) (int64, error) {
+	if dataset.Schema.PrimaryKey == "" {
+		return 0, models.NewValidationError("dataset %s has no primary key to delete by", dataset.ID)
+	}
+
+	rowKeys := make([]string, len(keys))
+	for i, key := range keys {
+		rowKey, err := KeyString(key)
+		if err != nil {
+			return 0, models.NewValidationError("key %d: %v", i, err)
+		}
+		rowKeys[i] = rowKey
+	}
+
+	var deleted int64
+	version := models.DatasetVersion{Change: models.VersionDelete}
+	err := s.mutate(dataset, &version, false, func(tx *sql.Tx, number int64) (rowChange, error) {
+		change, err := queryChange(tx,
+			`WITH removed AS (DELETE FROM dataset_rows WHERE dataset_id = $1 AND row_key = ANY($3) RETURNING id, row_key, data),
+			history AS (`+historyInsert+`id, row_key, NULL FROM removed) `+removedChange,
+			dataset.ID, number, pq.Array(rowKeys),
+		)
+		if err != nil {
+			return rowChange{}, fmt.Errorf("failed to delete rows: %w", err)
+		}
+		deleted = change.changed
+		return change, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// Truncate removes every row of a dataset as a new version
+func (s *RowStore) Truncate(dataset *models.Dataset) error {
+	version := models.DatasetVersion{Change: models.VersionTruncate}
+	return s.mutate(dataset, &version, false, func(tx *sql.Tx, number int64) (rowChange, error) {
+		change, err := queryChange(tx,
+			`WITH removed AS (DELETE FROM dataset_rows WHERE dataset_id = $1 RETURNING id, row_key, data),
+			history AS (`+historyInsert+`id, row_key, NULL FROM removed) `+removedChange,
+			dataset.ID, number,
+		)
+		if err != nil {
+			return rowChange{}, fmt.Errorf("failed to truncate rows: %w", err)
+		}
+		return change, nil
+	})
+}
+
+// Drop removes the rows, history and indexes of a dataset that is being deleted.
+// The rows, history and versions are deleted in one transaction under the
+// version lock, so a concurrent write either completes first or finds the
+// dataset gone; the indexes are dropped concurrently once it commits.
+func (s *RowStore) Drop(datasetID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := lockVersion(tx, datasetID, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, table := range []string{"dataset_rows", "dataset_row_history", "dataset_versions"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE dataset_id = $1`, datasetID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete rows: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deletion: %w", err)
+	}
+
+	existing, err := s.existingIndexes(datasetID)
+	if err != nil {
+		return err
+	}
+	for name := range existing {
+		if err := s.dropIndex(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Scan calls fn for every row of a dataset in insertion order, stopping at the first error
+func (s *RowStore) Scan(dataset *models.Dataset, fn func(row map[string]interface{}) error) error {
+	cursor, err := s.Open(dataset)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	for {
+		row, err := cursor.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Open returns a cursor over every row of a dataset in insertion order
+func (s *RowStore) Open(dataset *models.Dataset) (*RowCursor, error) {
+	rows, err := s.db.Query(`SELECT data FROM dataset_rows WHERE dataset_id = $1 ORDER BY id`, dataset.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan rows: %w", err)
+	}
+	return NewRowCursor(rows, &dataset.Schema, dataset.Schema.Fields), nil
+}
+
+// RowCursor decodes the rows returned by a query selecting a JSON row as its
+// first column, one row at a time, so rows can be streamed without being loaded
+// together. Further columns are ignored.
+type RowCursor struct {
+	rows   *sql.Rows
+	schema *models.DataSchema
+	fields []models.DataField
+	data   []byte
+	dest   []interface{}
+}
+
+// NewRowCursor creates a cursor over rows of the given schema, whose selected
+// fields are listed by fields
+func NewRowCursor(rows *sql.Rows, schema *models.DataSchema, fields []models.DataField) *RowCursor {
+	return &RowCursor{
+		rows:   rows,
+		schema: schema,
+		fields: fields,
+	}
+}
+
+// Fields returns the fields of the rows
+func (c *RowCursor) Fields() []models.DataField {
+	return c.fields
+}
+
+// Next returns the next row, or io.EOF after the last row
+func (c *RowCursor) Next() (map[string]interface{}, error) {
+	if !c.rows.Next() {
+		if err := c.rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	if c.dest == nil {
+		columns, err := c.rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		c.dest = make([]interface{}, len(columns))
+		c.dest[0] = &c.data
+		for i := 1; i < len(columns); i++ {
+			c.dest[i] = new(interface{})
+		}
+	}
+	if err := c.rows.Scan(c.dest...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+	return DecodeRow(c.data, c.schema)
+}
+
+// Close releases the rows
+func (c *RowCursor) Close() error {
+	return c.rows.Close()
+}
+
+// Rows loads every row of a dataset in insertion order
+func (s *RowStore) Rows(dataset *models.Dataset) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, dataset.RowCount)
+	err := s.Scan(dataset, func(row map[string]interface{}) error {
+		result = append(result, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RowsUpTo loads every row of a dataset in insertion order like Rows, failing
+// with a validation error once more than max rows are read; zero disables the cap
+func (s *RowStore) RowsUpTo(dataset *models.Dataset, max int) ([]map[string]interface{}, error) {
+	if max <= 0 {
+		return s.Rows(dataset)
+	}
+
+	limitErr := models.NewValidationError("dataset %s has more than the maximum of %d rows processed in memory", dataset.ID, max)
+	if dataset.RowCount > int64(max) {
+		return nil, limitErr
+	}
+
+	result := make([]map[string]interface{}, 0, dataset.RowCount)
+	err := s.Scan(dataset, func(row map[string]interface{}) error {
+		if len(result) == max {
+			return limitErr
+		}
+		result = append(result, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// write inserts rows in batches, replacing rows with the same key when upsert
+// is set, as part of a version
+func (s *RowStore) write(dataset *models.Dataset, version *models.DatasetVersion, rows []map[string]interface{}, upsert bool) error {
+	if version.Change == "" {
+		version.Change = models.VersionInsert
+		if upsert {
+			version.Change = models.VersionUpsert
+		}
+	}
+
+	return s.mutate(dataset, version, false, func(tx *sql.Tx, number int64) (rowChange, error) {
+		var total rowChange
+		for start := 0; start < len(rows); start += insertBatchSize {
+			end := start + insertBatchSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+
+			args := []interface{}{dataset.ID, number}
+			values := make([]string, 0, end-start)
+			for i := start; i < end; i++ {
+				rowKey, err := rowKey(dataset, rows[i])
+				if err != nil {
+					return rowChange{}, models.NewValidationError("row %d: %v", i, err)
+				}
+				data, err := json.Marshal(rows[i])
+				if err != nil {
+					return rowChange{}, models.NewValidationError("row %d: %v", i, err)
+				}
+				args = append(args, rowKey, data)
+				values = append(values, fmt.Sprintf("($%d::text, $%d::jsonb)", len(args)-1, len(args)))
+			}
+
+			// Replaced rows are read before the statement writes them, so the
+			// change of the dataset size counts their old data out
+			query := `WITH input (row_key, data) AS (VALUES ` + strings.Join(values, ", ") + `),
+			old AS (
+				SELECT row_key, ` + rowSize + ` AS size FROM dataset_rows
+				WHERE dataset_id = $1 AND row_key IN (SELECT row_key FROM input)
+			),
+			written AS (
+				INSERT INTO dataset_rows (dataset_id, row_key, data) SELECT $1::uuid, row_key, data FROM input`
+			if upsert {
+				query += ` ON CONFLICT (dataset_id, row_key) DO UPDATE SET data = EXCLUDED.data, updated_at = NOW()`
+			}
+			query += ` RETURNING id, row_key, data
+			),
+			history AS (` + historyInsert + `id, row_key, data FROM written)
+			SELECT COUNT(*), COUNT(*) - COUNT(old.row_key), COALESCE(SUM(octet_length(w.data::text)), 0) - COALESCE(SUM(old.size), 0)
+			FROM written w LEFT JOIN old ON old.row_key = w.row_key`
+
+			change, err := queryChange(tx, query, args...)
+			if err != nil {
+				return rowChange{}, rowWriteError(dataset, err)
+			}
+			total.add(change)
+		}
+		return total, nil
+	})
+}
+
+// rowSize is the size a stored row adds to its dataset: the length of its JSON
+// text, which unlike the stored size does not depend on whether PostgreSQL
+// compressed the row, so rows count the same when written and when deleted
+const rowSize = `octet_length(data::text)`
+
+// removedChange completes a statement deleting rows in a removed CTE that
+// returns their data, selecting the rowChange of the deletion
+const removedChange = `SELECT COUNT(*), -COUNT(*), -COALESCE(SUM(` + rowSize + `), 0) FROM removed`
+
+// rowChange is what a mutation did to the stored rows of a dataset: how many
+// rows it wrote or deleted, and by how much it changed their count and total size
+type rowChange struct {
+	changed int64
+	rows    int64
+	size    int64
+}
+
+// add accumulates the change of another statement of the same mutation
+func (c *rowChange) add(other rowChange) {
+	c.changed += other.changed
+	c.rows += other.rows
+	c.size += other.size
+}
+
+// queryChange runs a statement selecting a single rowChange
+func queryChange(tx *sql.Tx, query string, args ...interface{}) (rowChange, error) {
+	var change rowChange
+	err := tx.QueryRow(query, args...).Scan(&change.changed, &change.rows, &change.size)
+	return change, err
+}
+
+// mutate runs fn in a transaction as part of a dataset version and applies the
+// change fn made to the stored rows to the dataset row count and size. The
+// version continues the one recorded in *version while it is still the
+// latest, and is otherwise a new one; *version is set to the saved record on
+// success. When metadata is set the change also persists the dataset schema
+// and metadata, as schema changes and rollbacks do. The dataset is updated in
+// place.
+//
+// The counts follow from those of the latest version, which the version lock
+// serialises, so concurrent writers never overwrite each other's counts.
+func (s *RowStore) mutate(dataset *models.Dataset, version *models.DatasetVersion, metadata bool, fn func(tx *sql.Tx, number int64) (rowChange, error)) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	record := *version
+	latest, err := lockVersion(tx, dataset.ID, version.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	record.Version = latest.number
+
+	change, err := fn(tx, record.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	dataset.RowCount = latest.rowCount + change.rows
+	dataset.Size = latest.size + change.size
+	dataset.UpdatedAt = time.Now()
+	if s.datasets == nil {
+		if err := updateDataset(tx, dataset, change, metadata); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := saveVersion(tx, dataset, &record, change.changed); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rows: %w", err)
+	}
+	*version = record
+
+	if s.datasets != nil {
+		if metadata {
+			if err := s.datasets.Update(dataset); err != nil {
+				return fmt.Errorf("failed to update dataset: %w", err)
+			}
+		}
+		if err := s.datasets.SetCounts(dataset.ID, record.Version, dataset.RowCount, dataset.Size); err != nil {
+			return fmt.Errorf("failed to update dataset statistics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// updateDataset adds a change of the stored rows to the row count and size of
+// a dataset in the datasets table, persisting its schema and metadata too
+// when metadata is set
+func updateDataset(tx *sql.Tx, dataset *models.Dataset, change rowChange, metadata bool) error {
+	var result sql.Result
+	var err error
+	if metadata {
+		var schema, tags, meta []byte
+		schema, tags, meta, err = encodeDataset(dataset)
+		if err != nil {
+			return err
+		}
+		result, err = tx.Exec(
+			`UPDATE datasets SET row_count = row_count + $2, size = size + $3, updated_at = $4,
+			name = $5, description = $6, schema = $7, source = $8, format = $9, tags = $10, metadata = $11
+			WHERE id = $1`,
+			dataset.ID, change.rows, change.size, dataset.UpdatedAt,
+			dataset.Name, dataset.Description, schema, dataset.Source, dataset.Format, tags, meta,
+		)
+	} else {
+		result, err = tx.Exec(
+			`UPDATE datasets SET row_count = row_count + $2, size = size + $3, updated_at = $4 WHERE id = $1`,
+			dataset.ID, change.rows, change.size, dataset.UpdatedAt,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update dataset statistics: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update dataset statistics: %w", err)
+	}
+	if affected == 0 {
+		return models.NewNotFoundError("dataset %s not found", dataset.ID)
+	}
+	return nil
+}
+
+// existingIndexes returns the names of the row indexes currently defined for a
+// dataset, mapped to whether they are valid; a failed concurrent build leaves
+// an invalid index behind
+func (s *RowStore) existingIndexes(datasetID uuid.UUID) (map[string]bool, error) {
+	rows, err := s.db.Query(
+		`SELECT c.relname, i.indisvalid FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = 'dataset_rows'::regclass AND strpos(c.relname, $1) = 1`,
+		datasetIndexPrefix(datasetID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var valid bool
+		if err := rows.Scan(&name, &valid); err != nil {
+			return nil, fmt.Errorf("failed to scan index name: %w", err)
+		}
+		existing[name] = valid
+	}
+
+	return existing, rows.Err()
+}
+
+// rowKey returns the stored key of a row: its primary key value, or a random
+// identifier when the schema has no primary key
+func rowKey(dataset *models.Dataset, row map[string]interface{}) (string, error) {
+	if dataset.Schema.PrimaryKey == "" {
+		return uuid.New().String(), nil
+	}
+	value, ok := row[dataset.Schema.PrimaryKey]
+	if !ok || value == nil {
+		return "", fmt.Errorf("missing primary key %q", dataset.Schema.PrimaryKey)
+	}
+	return KeyString(value)
+}
+
+// dedupeByKey keeps the last occurrence of every primary key, since a single
+// upsert statement cannot touch the same row twice
+func dedupeByKey(dataset *models.Dataset, rows []map[string]interface{}) []map[string]interface{} {
+	positions := make(map[string]int, len(rows))
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		key, err := rowKey(dataset, row)
+		if err != nil {
+			// Keep the row so the write reports the error with its position
+			result = append(result, row)
+			continue
+		}
+		if pos, ok := positions[key]; ok {
+			result[pos] = row
+			continue
+		}
+		positions[key] = len(result)
+		result = append(result, row)
+	}
+	return result
+}
+
+// KeyString converts a primary key value to its canonical stored form, so that
+// for example 1, 1.0 and json.Number("1") address the same row
+func KeyString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float32:
+		return floatKey(float64(v)), nil
+	case float64:
+		return floatKey(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return "", fmt.Errorf("invalid key %q", v)
+		}
+		return floatKey(f), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case nil:
+		return "", fmt.Errorf("key is null")
+	default:
+		return "", fmt.Errorf("unsupported key type %T", value)
+	}
+}
+
+// floatKey formats integral floats without a fractional part
+func floatKey(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// DecodeRow decodes a stored JSON row, converting numbers of integer fields to
+// int64 and every other number to float64. Keys may also be field aliases, as
+// in projections that select a field by one.
+func DecodeRow(data []byte, schema *models.DataSchema) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var row map[string]interface{}
+	if err := decoder.Decode(&row); err != nil {
+		return nil, fmt.Errorf("failed to decode row: %w", err)
+	}
+
+	types := make(map[string]models.DataType, len(schema.Fields))
+	for _, field := range schema.Fields {
+		types[field.Name] = field.Type
+		for _, alias := range field.Aliases {
+			types[alias] = field.Type
+		}
+	}
+
+	for name, value := range row {
+		row[name] = normalizeNumbers(value, types[name] == models.DataTypeInteger)
+	}
+
+	return row, nil
+}
+
+// normalizeNumbers replaces json.Number values, recursing into arrays and objects
+func normalizeNumbers(value interface{}, integer bool) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if integer {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeNumbers(v[i], false)
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeNumbers(v[k], false)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// rowWriteError converts unique violations into validation errors naming the field
+func rowWriteError(dataset *models.Dataset, err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok || pqErr.Code != uniqueViolation {
+		return fmt.Errorf("failed to write rows: %w", err)
+	}
+
+	if pqErr.Constraint == rowKeyConstraint {
+		return models.NewValidationError("duplicate value for primary key %q", dataset.Schema.PrimaryKey)
+	}
+	for _, spec := range indexSpecs(dataset) {
+		if spec.name == pqErr.Constraint {
+			return models.NewValidationError("duplicate value for unique field %q", spec.field)
+		}
+	}
+	return models.NewValidationError("duplicate value violates a unique constraint")
+}
+
+// isUniqueViolation reports whether err was raised by a unique constraint
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+)
+
+// indexPrefix is the name prefix of every per-dataset expression index
+const indexPrefix = "dsr_"
+
+// validDataTypes lists the data types a schema field may declare
+var validDataTypes = map[models.DataType]bool{
+	models.DataTypeString:   true,
+	models.DataTypeInteger:  true,
+	models.DataTypeFloat:    true,
+	models.DataTypeBoolean:  true,
+	models.DataTypeDateTime: true,
+	models.DataTypeArray:    true,
+	models.DataTypeObject:   true,
+}
+
+// ValidateSchema checks that field names are unique, types are known and that the
+// primary key and indexes refer to declared scalar fields
+func ValidateSchema(schema *models.DataSchema) error {
+	fields := make(map[string]models.DataField, len(schema.Fields))
+	for i, field := range schema.Fields {
+		if field.Name == "" {
+			return models.NewValidationError("schema field %d has no name", i)
+		}
+		if _, exists := fields[field.Name]; exists {
+			return models.NewValidationError("schema field %q is declared more than once", field.Name)
+		}
+		if !validDataTypes[field.Type] {
+			return models.NewValidationError("schema field %q has unknown type %q", field.Name, field.Type)
+		}
+		fields[field.Name] = field
+	}
+
+	// Aliases name a field as its old names did, so no name may mean two fields
+	aliases := make(map[string]bool)
+	for _, field := range schema.Fields {
+		for _, alias := range field.Aliases {
+			if alias == "" {
+				return models.NewValidationError("schema field %q has an empty alias", field.Name)
+			}
+			if _, exists := fields[alias]; exists || aliases[alias] {
+				return models.NewValidationError("alias %q of schema field %q names another field", alias, field.Name)
+			}
+			aliases[alias] = true
+		}
+	}
+
+	if schema.PrimaryKey != "" {
+		field, ok := fields[schema.PrimaryKey]
+		if !ok {
+			return models.NewValidationError("primary key %q is not a schema field", schema.PrimaryKey)
+		}
+		if field.Type == models.DataTypeArray || field.Type == models.DataTypeObject {
+			return models.NewValidationError("primary key %q must be a scalar field", schema.PrimaryKey)
+		}
+	}
+
+	for _, name := range schema.Indexes {
+		if _, ok := fields[name]; !ok {
+			return models.NewValidationError("index field %q is not a schema field", name)
+		}
+	}
+
+	return nil
+}
+
+// indexSpec describes an expression index over one field of a dataset's rows
+type indexSpec struct {
+	name   string
+	field  string
+	unique bool
+}
+
+// datasetIndexPrefix returns the index name prefix reserved for a dataset
+func datasetIndexPrefix(datasetID uuid.UUID) string {
+	return indexPrefix + hex.EncodeToString(datasetID[:]) + "_"
+}
+
+// indexSpecs returns the indexes required by a dataset schema: a unique index for
+// every field marked Unique and a plain index for every entry of Indexes. The
+// primary key needs none since it is stored in the indexed row_key column.
+func indexSpecs(dataset *models.Dataset) []indexSpec {
+	prefix := datasetIndexPrefix(dataset.ID)
+	seen := map[string]bool{dataset.Schema.PrimaryKey: true}
+
+	var specs []indexSpec
+	for _, field := range dataset.Schema.Fields {
+		if field.Unique && !seen[field.Name] {
+			seen[field.Name] = true
+			specs = append(specs, indexSpec{name: prefix + "u_" + fieldHash(field.Name), field: field.Name, unique: true})
+		}
+	}
+	for _, name := range dataset.Schema.Indexes {
+		if !seen[name] {
+			seen[name] = true
+			specs = append(specs, indexSpec{name: prefix + "i_" + fieldHash(name), field: name})
+		}
+	}
+
+	return specs
+}
+
+// fieldHash shortens a field name to a fixed width so index names stay within
+// the PostgreSQL identifier length limit
+func fieldHash(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+)
+
+// RowValidator checks uploaded rows against a dataset schema and converts their
+// values to the stored representation of the field types. It remembers the
+// primary key and unique values of the rows it accepts, so duplicates within an
+// upload are reported before they reach the database.
+type RowValidator struct {
+	dataset *models.Dataset
+	upsert  bool
+	unique  []string
+	aliases map[string]string
+	seen    map[string]map[string]seenValue
+}
+
+// seenValue records which row first used a unique value
+type seenValue struct {
+	row    int
+	rowKey string
+}
+
+// NewRowValidator creates a validator for rows written to a dataset. In upsert
+// mode a repeated primary key replaces the earlier row instead of being a
+// duplicate.
+func NewRowValidator(dataset *models.Dataset, upsert bool) *RowValidator {
+	var unique []string
+	if dataset.Schema.PrimaryKey != "" {
+		unique = append(unique, dataset.Schema.PrimaryKey)
+	}
+	for _, field := range dataset.Schema.Fields {
+		if field.Unique && field.Name != dataset.Schema.PrimaryKey {
+			unique = append(unique, field.Name)
+		}
+	}
+
+	seen := make(map[string]map[string]seenValue, len(unique))
+	for _, name := range unique {
+		seen[name] = make(map[string]seenValue)
+	}
+	aliases := make(map[string]string)
+	for _, field := range dataset.Schema.Fields {
+		for _, alias := range field.Aliases {
+			aliases[alias] = field.Name
+		}
+	}
+	return &RowValidator{dataset: dataset, upsert: upsert, unique: unique, aliases: aliases, seen: seen}
+}
+
+// Validate checks the row at a position of the upload and returns it with its
+// values converted. Absent fields take their default and must otherwise not be
+// required; null values take the default of a field that is not nullable.
+func (v *RowValidator) Validate(position int, row map[string]interface{}) (map[string]interface{}, *models.RowError) {
+	fail := func(field, format string, args ...interface{}) (map[string]interface{}, *models.RowError) {
+		return nil, &models.RowError{Row: position, Field: field, Message: fmt.Sprintf(format, args...)}
+	}
+
+	declared := make(map[string]bool, len(v.dataset.Schema.Fields))
+	for _, field := range v.dataset.Schema.Fields {
+		declared[field.Name] = true
+	}
+	var unknown []string
+	for name := range row {
+		if !declared[name] && v.aliases[name] == "" {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fail(unknown[0], "field is not part of the dataset schema")
+	}
+
+	// Values given under an alias belong to the field it names
+	if len(v.aliases) > 0 {
+		renamed := make(map[string]interface{}, len(row))
+		for name, value := range row {
+			if field := v.aliases[name]; field != "" {
+				name = field
+			}
+			if _, exists := renamed[name]; exists {
+				return fail(name, "field is given more than once through its aliases")
+			}
+			renamed[name] = value
+		}
+		row = renamed
+	}
+
+	result := make(map[string]interface{}, len(v.dataset.Schema.Fields))
+	for _, field := range v.dataset.Schema.Fields {
+		value, present := row[field.Name]
+		if (!present || (value == nil && !field.Nullable)) && field.Default != nil {
+			value, present = field.Default, true
+		}
+		if !present {
+			if field.Required || field.Name == v.dataset.Schema.PrimaryKey {
+				return fail(field.Name, "required field is missing")
+			}
+			continue
+		}
+		if value == nil {
+			if !field.Nullable || field.Name == v.dataset.Schema.PrimaryKey {
+				return fail(field.Name, "field is not nullable")
+			}
+			result[field.Name] = nil
+			continue
+		}
+
+		converted, err := ConvertFieldValue(value, field.Type)
+		if err != nil {
+			return fail(field.Name, "%v", err)
+		}
+		result[field.Name] = converted
+	}
+
+	key, err := v.rowKey(position, result)
+	if err != nil {
+		return fail(v.dataset.Schema.PrimaryKey, "%v", err)
+	}
+	for _, name := range v.unique {
+		value, ok := result[name]
+		if !ok || value == nil {
+			continue
+		}
+		first, exists := v.seen[name][uniqueText(value)]
+		if !exists {
+			continue
+		}
+		if name == v.dataset.Schema.PrimaryKey && !v.upsert {
+			return fail(name, "duplicate primary key value, first used by row %d", first.row)
+		}
+		if name != v.dataset.Schema.PrimaryKey && first.rowKey != key {
+			return fail(name, "duplicate value for unique field, first used by row %d", first.row)
+		}
+	}
+
+	for _, name := range v.unique {
+		if value, ok := result[name]; ok && value != nil {
+			v.seen[name][uniqueText(value)] = seenValue{row: position, rowKey: key}
+		}
+	}
+	return result, nil
+}
+
+// Release forgets the unique values of a validated row that was not written
+// after all, so later rows may use them
+func (v *RowValidator) Release(position int, row map[string]interface{}) {
+	for _, name := range v.unique {
+		value, ok := row[name]
+		if !ok || value == nil {
+			continue
+		}
+		text := uniqueText(value)
+		if v.seen[name][text].row == position {
+			delete(v.seen[name], text)
+		}
+	}
+}
+
+// rowKey returns the primary key of a converted row, or a key unique to its
+// position when the schema has none
+func (v *RowValidator) rowKey(position int, row map[string]interface{}) (string, error) {
+	if v.dataset.Schema.PrimaryKey == "" {
+		return "#" + strconv.Itoa(position), nil
+	}
+	return KeyString(row[v.dataset.Schema.PrimaryKey])
+}
+
+// uniqueText returns the text a unique index compares for a converted value,
+// which is what the data->>'field' expression yields
+func uniqueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}, map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return query.FormatValue(v)
+	}
+}
+
+// ConvertFieldValue converts a non-null uploaded value to the stored
+// representation of a field type: int64, finite float64, bool, RFC 3339
+// strings for datetimes, string, []interface{} or map[string]interface{}.
+// Unlike a cast it refuses lossy conversions such as 1.5 to an integer or a
+// number to a boolean.
+func ConvertFieldValue(value interface{}, t models.DataType) (interface{}, error) {
+	switch t {
+	case models.DataTypeString:
+		switch value.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, fmt.Errorf("expected a string")
+		}
+		return query.FormatValue(value), nil
+
+	case models.DataTypeInteger:
+		if _, ok := value.(bool); ok {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		i, ok := query.ToInt(value)
+		if !ok {
+			return nil, fmt.Errorf("expected an integer, got %v", value)
+		}
+		return i, nil
+
+	case models.DataTypeFloat:
+		f, ok := query.ToFloat(value)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("expected a finite number, got %v", value)
+		}
+		return f, nil
+
+	case models.DataTypeBoolean:
+		b, ok := query.ToBool(value)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %v", value)
+		}
+		return b, nil
+
+	case models.DataTypeDateTime:
+		tm, ok := query.ToTime(value)
+		if !ok {
+			return nil, fmt.Errorf("expected a datetime, got %v", value)
+		}
+		return tm.UTC().Format(time.RFC3339Nano), nil
+
+	case models.DataTypeArray, models.DataTypeObject:
+		converted, err := query.CastValue(value, t)
+		if err != nil {
+			return nil, fmt.Errorf("expected an %s", t)
+		}
+		return normalizeNumbers(converted, false), nil
+
+	default:
+		return nil, fmt.Errorf("unknown type %q", t)
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func customersDataset() *models.Dataset {
+	return &models.Dataset{
+		ID: uuid.New(),
+		Schema: models.DataSchema{
+			PrimaryKey: "id",
+			Fields: []models.DataField{
+				{Name: "id", Type: models.DataTypeInteger, Required: true},
+				{Name: "email", Type: models.DataTypeString, Required: true, Unique: true},
+				{Name: "score", Type: models.DataTypeFloat, Nullable: true},
+				{Name: "active", Type: models.DataTypeBoolean, Default: true},
+				{Name: "joined", Type: models.DataTypeDateTime},
+				{Name: "tags", Type: models.DataTypeArray, Nullable: true},
+			},
+		},
+	}
+}
+
+func TestRowValidator(t *testing.T) {
+	// Test Case 1: Values are converted to their field types and defaults fill gaps
+	t.Run("Convert", func(t *testing.T) {
+		validator := NewRowValidator(customersDataset(), false)
+
+		row, rowErr := validator.Validate(1, map[string]interface{}{
+			"id":     json.Number("7"),
+			"email":  "a@example.com",
+			"score":  "9.5",
+			"joined": time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("", 3600)),
+			"tags":   `["x", 2]`,
+		})
+		require.Nil(t, rowErr)
+		assert.Equal(t, map[string]interface{}{
+			"id":     int64(7),
+			"email":  "a@example.com",
+			"score":  9.5,
+			"active": true,
+			"joined": "2024-03-01T11:00:00Z",
+			"tags":   []interface{}{"x", 2.0},
+		}, row)
+
+		// A null takes the default of a field that is not nullable
+		row, rowErr = validator.Validate(2, map[string]interface{}{
+			"id": "8", "email": "b@example.com", "active": nil, "score": nil,
+		})
+		require.Nil(t, rowErr)
+		assert.Equal(t, true, row["active"])
+		assert.Nil(t, row["score"])
+	})
+
+	// Test Case 2: Rows breaking the schema are reported with their field
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := map[string]struct {
+			row   map[string]interface{}
+			field string
+		}{
+			"unknown field":   {map[string]interface{}{"id": 1, "email": "x", "nickname": "y"}, "nickname"},
+			"missing":         {map[string]interface{}{"id": 1}, "email"},
+			"missing key":     {map[string]interface{}{"email": "x"}, "id"},
+			"not nullable":    {map[string]interface{}{"id": 1, "email": nil}, "email"},
+			"fractional":      {map[string]interface{}{"id": 1.5, "email": "x"}, "id"},
+			"not a number":    {map[string]interface{}{"id": 1, "email": "x", "score": "high"}, "score"},
+			"infinite":        {map[string]interface{}{"id": 1, "email": "x", "score": "Inf"}, "score"},
+			"numeric boolean": {map[string]interface{}{"id": 1, "email": "x", "active": 1}, "active"},
+			"bad datetime":    {map[string]interface{}{"id": 1, "email": "x", "joined": "yesterday"}, "joined"},
+			"array string":    {map[string]interface{}{"id": 1, "email": []interface{}{"x"}}, "email"},
+		}
+		for name, c := range invalid {
+			validator := NewRowValidator(customersDataset(), false)
+			_, rowErr := validator.Validate(3, c.row)
+			require.NotNil(t, rowErr, name)
+			assert.Equal(t, 3, rowErr.Row, name)
+			assert.Equal(t, c.field, rowErr.Field, name)
+		}
+	})
+
+	// Test Case 3: Duplicates within an upload are caught
+	t.Run("Duplicates", func(t *testing.T) {
+		validator := NewRowValidator(customersDataset(), false)
+		_, rowErr := validator.Validate(1, map[string]interface{}{"id": 1, "email": "a"})
+		require.Nil(t, rowErr)
+
+		_, rowErr = validator.Validate(2, map[string]interface{}{"id": "1", "email": "b"})
+		require.NotNil(t, rowErr)
+		assert.Equal(t, "id", rowErr.Field)
+		assert.Contains(t, rowErr.Message, "row 1")
+
+		_, rowErr = validator.Validate(3, map[string]interface{}{"id": 2, "email": "a"})
+		require.NotNil(t, rowErr)
+		assert.Equal(t, "email", rowErr.Field)
+
+		// Released values may be used again
+		row, rowErr := validator.Validate(4, map[string]interface{}{"id": 3, "email": "c"})
+		require.Nil(t, rowErr)
+		validator.Release(4, row)
+		_, rowErr = validator.Validate(5, map[string]interface{}{"id": 3, "email": "c"})
+		assert.Nil(t, rowErr)
+	})
+
+	// Test Case 4: Upserts may repeat a key but not take another key's unique value
+	t.Run("Upsert", func(t *testing.T) {
+		validator := NewRowValidator(customersDataset(), true)
+		_, rowErr := validator.Validate(1, map[string]interface{}{"id": 1, "email": "a"})
+		require.Nil(t, rowErr)
+
+		_, rowErr = validator.Validate(2, map[string]interface{}{"id": 1, "email": "a"})
+		assert.Nil(t, rowErr)
+
+		_, rowErr = validator.Validate(3, map[string]interface{}{"id": 2, "email": "a"})
+		require.NotNil(t, rowErr)
+		assert.Equal(t, "email", rowErr.Field)
+	})
+
+	// Test Case 5: Values given under an alias are stored under the field name
+	t.Run("Aliases", func(t *testing.T) {
+		dataset := customersDataset()
+		dataset.Schema.Fields[1].Aliases = []string{"mail"}
+		validator := NewRowValidator(dataset, false)
+
+		row, rowErr := validator.Validate(1, map[string]interface{}{"id": 1, "mail": "a"})
+		require.Nil(t, rowErr)
+		assert.Equal(t, "a", row["email"])
+		assert.NotContains(t, row, "mail")
+
+		_, rowErr = validator.Validate(2, map[string]interface{}{"id": 2, "email": "b", "mail": "c"})
+		require.NotNil(t, rowErr)
+		assert.Equal(t, "email", rowErr.Field)
+	})
+}
@@ -0,0 +1,554 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/galafis/go-data-api-microservices/internal/query"
+	"github.com/google/uuid"
+)
+
+// historyInsert begins the statement recording the rows written or deleted by
+// a version, with $1 and $2 binding the dataset and version. The statement is
+// completed with the row id, row key and data of each row, NULL for deleted
+// rows, and the table they are selected from.
+const historyInsert = `INSERT INTO dataset_row_history (dataset_id, version, row_id, row_key, data) SELECT $1::uuid, $2::bigint, `
+
+// versionColumns lists the columns selected when loading a dataset version
+const versionColumns = `dataset_id, version, change, restored_version, name, description, schema, source, format, tags, metadata, row_count, size, rows_changed, created_at`
+
+// RecordVersion records the current schema and metadata of a dataset as a new
+// version, for changes that leave its rows as they are
+func (s *RowStore) RecordVersion(dataset *models.Dataset, change models.VersionChange) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	record := models.DatasetVersion{Change: change}
+	latest, err := lockVersion(tx, dataset.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	record.Version = latest.number
+	dataset.RowCount = latest.rowCount
+	dataset.Size = latest.size
+	if err := saveVersion(tx, dataset, &record, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit version: %w", err)
+	}
+	return nil
+}
+
+// UpdateMetadata saves the name, description, source, format, tags and
+// metadata of a dataset and records them as a new version in one transaction
+// under the version lock. The schema is left as stored: the dataset is given
+// the schema of the latest version, which schema changes record under the
+// same lock.
+func (s *RowStore) UpdateMetadata(dataset *models.Dataset) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	record := models.DatasetVersion{Change: models.VersionUpdate}
+	latest, err := lockVersion(tx, dataset.ID, 0)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	record.Version = latest.number
+	dataset.RowCount = latest.rowCount
+	dataset.Size = latest.size
+
+	stored, err := storedDataset(tx, dataset)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	dataset.Schema = stored.Schema
+
+	if s.datasets == nil {
+		if err := updateMetadata(tx, dataset); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := saveVersion(tx, dataset, &record, 0); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit version: %w", err)
+	}
+
+	if s.datasets != nil {
+		if err := s.datasets.Update(dataset); err != nil {
+			return fmt.Errorf("failed to update dataset: %w", err)
+		}
+	}
+	return nil
+}
+
+// storedDataset returns a copy of a dataset with the schema and row count of
+// its latest version, as read by a transaction holding the version lock. The
+// copy is unchanged when the dataset has no version yet.
+func storedDataset(tx *sql.Tx, dataset *models.Dataset) (*models.Dataset, error) {
+	stored := *dataset
+	var schema []byte
+	err := tx.QueryRow(
+		`SELECT schema, row_count FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC LIMIT 1`,
+		dataset.ID,
+	).Scan(&schema, &stored.RowCount)
+	if err == sql.ErrNoRows {
+		return &stored, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the latest version: %w", err)
+	}
+
+	stored.Schema = models.DataSchema{}
+	if err := json.Unmarshal(schema, &stored.Schema); err != nil {
+		return nil, fmt.Errorf("invalid schema for dataset %s: %w", dataset.ID, err)
+	}
+	return &stored, nil
+}
+
+// updateMetadata writes every column of a dataset in the datasets table but
+// its schema and row statistics
+func updateMetadata(tx *sql.Tx, dataset *models.Dataset) error {
+	_, tags, metadata, err := encodeDataset(dataset)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`UPDATE datasets SET name = $2, description = $3, source = $4, format = $5, tags = $6, metadata = $7, updated_at = $8
+		WHERE id = $1`,
+		dataset.ID, dataset.Name, dataset.Description, dataset.Source, dataset.Format, tags, metadata, dataset.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update dataset: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update dataset: %w", err)
+	}
+	if affected == 0 {
+		return models.NewNotFoundError("dataset %s not found", dataset.ID)
+	}
+	return nil
+}
+
+// Versions lists the versions of a dataset, latest first
+func (s *RowStore) Versions(datasetID uuid.UUID) ([]models.DatasetVersion, error) {
+	rows, err := s.db.Query(
+		`SELECT `+versionColumns+` FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC`,
+		datasetID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]models.DatasetVersion, 0)
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		versions = append(versions, *version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// Version finds a version of a dataset, returning nil if it does not exist
+func (s *RowStore) Version(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error) {
+	return s.findVersion(
+		`SELECT `+versionColumns+` FROM dataset_versions WHERE dataset_id = $1 AND version = $2`,
+		datasetID, version,
+	)
+}
+
+// LatestVersion finds the latest version of a dataset, returning nil if it
+// has none
+func (s *RowStore) LatestVersion(datasetID uuid.UUID) (*models.DatasetVersion, error) {
+	return s.findVersion(
+		`SELECT `+versionColumns+` FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC LIMIT 1`,
+		datasetID,
+	)
+}
+
+// VersionAt finds the latest version of a dataset created at or before a point
+// in time, returning nil if there is none
+func (s *RowStore) VersionAt(datasetID uuid.UUID, at time.Time) (*models.DatasetVersion, error) {
+	return s.findVersion(
+		`SELECT `+versionColumns+` FROM dataset_versions WHERE dataset_id = $1 AND created_at <= $2 ORDER BY version DESC LIMIT 1`,
+		datasetID, at,
+	)
+}
+
+func (s *RowStore) findVersion(query string, args ...interface{}) (*models.DatasetVersion, error) {
+	version, err := scanVersion(s.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find version: %w", err)
+	}
+	return version, nil
+}
+
+// DiffVersions compares two versions of a dataset: their schemas, the number of
+// rows added, removed and modified, and the first limit of those rows
+func (s *RowStore) DiffVersions(datasetID uuid.UUID, from, to int64, limit int) (*models.VersionDiff, error) {
+	before, err := s.requireVersion(datasetID, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.requireVersion(datasetID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.VersionDiff{
+		DatasetID: datasetID,
+		From:      from,
+		To:        to,
+		Schema:    DiffSchemas(&before.Schema, &after.Schema),
+		Rows:      make([]models.RowChange, 0),
+	}
+
+	// Pair the rows of both versions by key, keeping those that differ
+	pairs := ` FROM ` + query.VersionRows("$1", "$2") + ` a FULL JOIN ` + query.VersionRows("$1", "$3") + ` b` +
+		` ON a.row_key = b.row_key WHERE a.data IS DISTINCT FROM b.data`
+
+	err = s.db.QueryRow(
+		`SELECT COUNT(*) FILTER (WHERE a.row_key IS NULL), COUNT(*) FILTER (WHERE b.row_key IS NULL),
+		COUNT(*) FILTER (WHERE a.row_key IS NOT NULL AND b.row_key IS NOT NULL)`+pairs,
+		datasetID, from, to,
+	).Scan(&diff.RowsAdded, &diff.RowsRemoved, &diff.RowsModified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count changed rows: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT COALESCE(a.row_key, b.row_key), a.data, b.data`+pairs+
+			` ORDER BY COALESCE(b.id, a.id), COALESCE(a.row_key, b.row_key) LIMIT $4`,
+		datasetID, from, to, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.RowChange
+		var beforeData, afterData []byte
+		if err := rows.Scan(&change.Key, &beforeData, &afterData); err != nil {
+			return nil, fmt.Errorf("failed to scan changed row: %w", err)
+		}
+
+		switch {
+		case beforeData == nil:
+			change.Change = models.RowAdded
+		case afterData == nil:
+			change.Change = models.RowRemoved
+		default:
+			change.Change = models.RowModified
+		}
+		if beforeData != nil {
+			if change.Before, err = DecodeRow(beforeData, &before.Schema); err != nil {
+				return nil, err
+			}
+		}
+		if afterData != nil {
+			if change.After, err = DecodeRow(afterData, &after.Schema); err != nil {
+				return nil, err
+			}
+		}
+		diff.Rows = append(diff.Rows, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate changed rows: %w", err)
+	}
+
+	return diff, nil
+}
+
+// Rollback restores the schema, metadata and rows a dataset had at an earlier
+// version, recording the restore as a new version. Rows keep the ids they had
+// at that version, so they sort in their original order. The dataset is
+// updated in place.
+func (s *RowStore) Rollback(dataset *models.Dataset, target int64) (*models.DatasetVersion, error) {
+	version, err := s.requireVersion(dataset.ID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := *dataset
+	restored.Name = version.Name
+	restored.Description = version.Description
+	restored.Schema = version.Schema
+	restored.Source = version.Source
+	restored.Format = version.Format
+	restored.Tags = version.Tags
+	restored.Metadata = version.Metadata
+
+	// Indexes the restored schema does not declare may not hold for the
+	// restored rows, so they are dropped first
+	if err := s.dropStaleIndexes(&restored); err != nil {
+		return nil, err
+	}
+
+	record := models.DatasetVersion{Change: models.VersionRollback, RestoredVersion: target}
+	err = s.mutate(&restored, &record, true, func(tx *sql.Tx, number int64) (rowChange, error) {
+		// Delete the rows that are missing from the target or differ from it
+		change, err := queryChange(tx,
+			`WITH target AS `+query.VersionRows("$1", "$3")+`,
+			removed AS (
+				DELETE FROM dataset_rows r WHERE r.dataset_id = $1
+				AND NOT EXISTS (SELECT 1 FROM target t WHERE t.row_key = r.row_key AND t.data = r.data)
+				RETURNING r.id, r.row_key, r.data
+			),
+			history AS (`+historyInsert+`id, row_key, NULL FROM removed) `+removedChange,
+			dataset.ID, number, target,
+		)
+		if err != nil {
+			return rowChange{}, fmt.Errorf("failed to delete rows: %w", err)
+		}
+
+		// Insert the target rows that are no longer stored
+		inserted, err := queryChange(tx,
+			`WITH target AS `+query.VersionRows("$1", "$3")+`,
+			written AS (
+				INSERT INTO dataset_rows (id, dataset_id, row_key, data)
+				SELECT t.id, $1, t.row_key, t.data FROM target t
+				WHERE NOT EXISTS (SELECT 1 FROM dataset_rows r WHERE r.dataset_id = $1 AND r.row_key = t.row_key)
+				ORDER BY t.id
+				RETURNING id, row_key, data
+			),
+			history AS (`+historyInsert+`id, row_key, data FROM written)
+			SELECT COUNT(*), COUNT(*), COALESCE(SUM(`+rowSize+`), 0) FROM written`,
+			dataset.ID, number, target,
+		)
+		if err != nil {
+			return rowChange{}, fmt.Errorf("failed to restore rows: %w", err)
+		}
+
+		change.add(inserted)
+		return change, nil
+	})
+	if err != nil {
+		if syncErr := s.SyncIndexes(dataset); syncErr != nil {
+			return nil, fmt.Errorf("%w (and failed to restore indexes: %v)", err, syncErr)
+		}
+		return nil, err
+	}
+
+	if err := s.createIndexes(&restored); err != nil {
+		return nil, err
+	}
+
+	*dataset = restored
+	return &record, nil
+}
+
+// requireVersion finds a version of a dataset, returning a validation error if
+// it does not exist
+func (s *RowStore) requireVersion(datasetID uuid.UUID, version int64) (*models.DatasetVersion, error) {
+	found, err := s.Version(datasetID, version)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, models.NewValidationError("dataset %s has no version %d", datasetID, version)
+	}
+	return found, nil
+}
+
+// DiffSchemas lists the fields added, removed and changed from one schema to
+// another, in the order of the schema that has them
+func DiffSchemas(from, to *models.DataSchema) models.SchemaDiff {
+	before := make(map[string]models.DataField, len(from.Fields))
+	for _, field := range from.Fields {
+		before[field.Name] = field
+	}
+	after := make(map[string]bool, len(to.Fields))
+
+	var diff models.SchemaDiff
+	for _, field := range to.Fields {
+		after[field.Name] = true
+		previous, ok := before[field.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, field)
+		case !reflect.DeepEqual(previous, field):
+			diff.Changed = append(diff.Changed, models.FieldChange{Name: field.Name, Before: previous, After: field})
+		}
+	}
+	for _, field := range from.Fields {
+		if !after[field.Name] {
+			diff.Removed = append(diff.Removed, field)
+		}
+	}
+
+	if from.PrimaryKey != to.PrimaryKey {
+		diff.PrimaryKeyBefore = from.PrimaryKey
+		diff.PrimaryKeyAfter = to.PrimaryKey
+	}
+
+	return diff
+}
+
+// versionLock is the state of the versions of a dataset once a transaction
+// holds their lock
+type versionLock struct {
+	number   int64 // version the transaction writes
+	rowCount int64 // row count recorded by the latest version
+	size     int64 // size recorded by the latest version
+}
+
+// lockVersion serialises the versions of a dataset until the transaction ends
+// and returns the number of the version the transaction writes, current while
+// it is still the latest version and else the next one, with the row count
+// and size of the latest version
+func lockVersion(tx *sql.Tx, datasetID uuid.UUID, current int64) (versionLock, error) {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, datasetID.String()); err != nil {
+		return versionLock{}, fmt.Errorf("failed to lock dataset versions: %w", err)
+	}
+
+	var lock versionLock
+	var latest int64
+	err := tx.QueryRow(
+		`SELECT version, row_count, size FROM dataset_versions WHERE dataset_id = $1 ORDER BY version DESC LIMIT 1`,
+		datasetID,
+	).Scan(&latest, &lock.rowCount, &lock.size)
+	if err != nil && err != sql.ErrNoRows {
+		return versionLock{}, fmt.Errorf("failed to find the latest version: %w", err)
+	}
+
+	lock.number = latest + 1
+	if current != 0 && current == latest {
+		lock.number = current
+	}
+	return lock, nil
+}
+
+// saveVersion records the dataset as version.Version, or updates the row
+// statistics of that version when an earlier transaction created it, adding
+// changed to the rows it changed. version is filled from the saved record.
+func saveVersion(tx *sql.Tx, dataset *models.Dataset, version *models.DatasetVersion, changed int64) error {
+	version.DatasetID = dataset.ID
+	version.Name = dataset.Name
+	version.Description = dataset.Description
+	version.Schema = dataset.Schema
+	version.Source = dataset.Source
+	version.Format = dataset.Format
+	version.Tags = dataset.Tags
+	version.Metadata = dataset.Metadata
+	version.RowCount = dataset.RowCount
+	version.Size = dataset.Size
+
+	schemaJSON, tagsJSON, metadataJSON, err := encodeDataset(dataset)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO dataset_versions (dataset_id, version, change, restored_version, name, description, schema, source, format,
+		tags, metadata, row_count, size, rows_changed, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, clock_timestamp())
+		ON CONFLICT (dataset_id, version) DO UPDATE SET row_count = EXCLUDED.row_count, size = EXCLUDED.size,
+		rows_changed = dataset_versions.rows_changed + EXCLUDED.rows_changed
+		RETURNING rows_changed, created_at`,
+		dataset.ID, version.Version, string(version.Change), version.RestoredVersion, dataset.Name, dataset.Description,
+		schemaJSON, dataset.Source, dataset.Format, tagsJSON, metadataJSON, dataset.RowCount, dataset.Size, changed,
+	).Scan(&version.RowsChanged, &version.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save version: %w", err)
+	}
+
+	return nil
+}
+
+// encodeDataset encodes the schema, tags and metadata of a dataset as stored
+// in JSONB columns
+func encodeDataset(dataset *models.Dataset) ([]byte, []byte, []byte, error) {
+	fields := dataset.Schema.Fields
+	if fields == nil {
+		fields = []models.DataField{}
+	}
+	schema := dataset.Schema
+	schema.Fields = fields
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode dataset schema: %w", err)
+	}
+	tags := dataset.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode dataset tags: %w", err)
+	}
+	var metadataJSON []byte
+	if dataset.Metadata != nil {
+		if metadataJSON, err = json.Marshal(dataset.Metadata); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to encode dataset metadata: %w", err)
+		}
+	}
+	return schemaJSON, tagsJSON, metadataJSON, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanVersion scans a version row selected with versionColumns
+func scanVersion(row rowScanner) (*models.DatasetVersion, error) {
+	var version models.DatasetVersion
+	var change string
+	var schema, tags, metadata []byte
+
+	err := row.Scan(
+		&version.DatasetID, &version.Version, &change, &version.RestoredVersion, &version.Name, &version.Description,
+		&schema, &version.Source, &version.Format, &tags, &metadata, &version.RowCount, &version.Size,
+		&version.RowsChanged, &version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	version.Change = models.VersionChange(change)
+
+	if err := json.Unmarshal(schema, &version.Schema); err != nil {
+		return nil, fmt.Errorf("invalid schema for version %d: %w", version.Version, err)
+	}
+	if err := json.Unmarshal(tags, &version.Tags); err != nil {
+		return nil, fmt.Errorf("invalid tags for version %d: %w", version.Version, err)
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &version.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for version %d: %w", version.Version, err)
+		}
+	}
+
+	return &version, nil
+}
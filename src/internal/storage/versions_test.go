@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	from := customersDataset().Schema
+
+	// Test Case 1: Identical schemas have no differences
+	t.Run("Identical", func(t *testing.T) {
+		diff := DiffSchemas(&from, &from)
+		assert.Equal(t, models.SchemaDiff{}, diff)
+	})
+
+	// Test Case 2: Added, removed and changed fields are listed in schema order
+	t.Run("Fields", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.Fields = append(to.Fields[:4:4], models.DataField{Name: "region", Type: models.DataTypeString, Nullable: true})
+		to.Fields[2].Type = models.DataTypeInteger
+
+		diff := DiffSchemas(&from, &to)
+		assert.Equal(t, []models.DataField{{Name: "region", Type: models.DataTypeString, Nullable: true}}, diff.Added)
+		assert.Equal(t, []models.DataField{from.Fields[4], from.Fields[5]}, diff.Removed)
+		if assert.Len(t, diff.Changed, 1) {
+			assert.Equal(t, "score", diff.Changed[0].Name)
+			assert.Equal(t, models.DataTypeFloat, diff.Changed[0].Before.Type)
+			assert.Equal(t, models.DataTypeInteger, diff.Changed[0].After.Type)
+		}
+		assert.Equal(t, "", diff.PrimaryKeyBefore)
+	})
+
+	// Test Case 3: A new primary key is reported with the previous one
+	t.Run("Primary Key", func(t *testing.T) {
+		to := customersDataset().Schema
+		to.PrimaryKey = "email"
+
+		diff := DiffSchemas(&from, &to)
+		assert.Equal(t, "id", diff.PrimaryKeyBefore)
+		assert.Equal(t, "email", diff.PrimaryKeyAfter)
+		assert.Empty(t, diff.Changed)
+	})
+}
@@ -0,0 +1,148 @@
+package visualize
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	titleSize = 16
+	labelSize = 12
+	tickSize  = 11
+)
+
+// area is the rectangle a plot is drawn in
+type area struct {
+	x, y, w, h float64
+}
+
+// plotArea leaves margins for the title, the tick labels of both axes and a
+// legend or color bar on the right
+func plotArea(w, h, left, right float64) area {
+	top, bottom := 48.0, 44.0
+	return area{x: left, y: top, w: w - left - right, h: h - top - bottom}
+}
+
+// linear maps the domain [lo, hi] onto the pixels [from, to]
+type linear struct {
+	lo, hi, from, to float64
+}
+
+func (s linear) at(v float64) float64 {
+	if s.hi == s.lo {
+		return (s.from + s.to) / 2
+	}
+	return s.from + (v-s.lo)/(s.hi-s.lo)*(s.to-s.from)
+}
+
+// ticks returns round values covering lo to hi, about n of them, with the step
+// between them. The first and last tick bound the axis.
+func ticks(lo, hi float64, n int) ([]float64, float64) {
+	if lo == hi {
+		pad := math.Abs(lo) / 10
+		if pad == 0 {
+			pad = 1
+		}
+		lo, hi = lo-pad, hi+pad
+	}
+	step := niceStep((hi - lo) / float64(n))
+	start := math.Floor(lo/step) * step
+	count := int(math.Ceil(hi/step)-math.Floor(lo/step)) + 1
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = start + float64(i)*step
+	}
+	return values, step
+}
+
+// niceStep rounds a step up to 1, 2 or 5 times a power of ten
+func niceStep(raw float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / magnitude; {
+	case f <= 1:
+		return magnitude
+	case f <= 2:
+		return 2 * magnitude
+	case f <= 5:
+		return 5 * magnitude
+	default:
+		return 10 * magnitude
+	}
+}
+
+// formatTick formats a tick with as many decimals as its step needs
+func formatTick(v, step float64) string {
+	decimals := int(-math.Floor(math.Log10(step)))
+	if decimals < 0 {
+		decimals = 0
+	}
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Trim(s, "-0.") == "" {
+		return "0"
+	}
+	return s
+}
+
+// valueAxis draws the gridlines and labels of a vertical axis over a plot and
+// returns its scale
+func valueAxis(c canvas, a area, lo, hi float64) linear {
+	values, step := ticks(lo, hi, int(math.Max(2, a.h/60)))
+	scale := linear{lo: values[0], hi: values[len(values)-1], from: a.y + a.h, to: a.y}
+	for _, v := range values {
+		y := scale.at(v)
+		c.line(a.x, y, a.x+a.w, y, lightGray, 1)
+		c.text(a.x-6, y+4, formatTick(v, step), tickSize, anchorEnd, gray)
+	}
+	return scale
+}
+
+// frame draws the left and bottom axis lines of a plot
+func frame(c canvas, a area) {
+	c.line(a.x, a.y, a.x, a.y+a.h, gray, 1)
+	c.line(a.x, a.y+a.h, a.x+a.w, a.y+a.h, gray, 1)
+}
+
+// outline draws the border of a rectangle
+func outline(c canvas, x, y, w, h float64, stroke color.RGBA) {
+	c.line(x, y, x+w, y, stroke, 1)
+	c.line(x+w, y, x+w, y+h, stroke, 1)
+	c.line(x+w, y+h, x, y+h, stroke, 1)
+	c.line(x, y+h, x, y, stroke, 1)
+}
+
+// noData marks a plot without anything to draw
+func noData(c canvas, a area) {
+	c.text(a.x+a.w/2, a.y+a.h/2, "No data", labelSize, anchorMiddle, gray)
+}
+
+// legend lists series names with their colors in the top right corner of a
+// plot
+func legend(c canvas, a area, names []string) {
+	y := a.y + 4
+	for i, name := range names {
+		col := palette[i%len(palette)]
+		c.rect(a.x+a.w-12, y+6, 10, 10, col)
+		c.text(a.x+a.w-18, y+15, name, tickSize, anchorEnd, black)
+		y += 16
+	}
+}
+
+// textWidth estimates the width of text
+func textWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.6
+}
+
+// truncate shortens text to fit a width, ending it with two dots when cut
+func truncate(s string, size, width float64) string {
+	runes := []rune(s)
+	fit := int(width / (size * 0.6))
+	if len(runes) <= fit {
+		return s
+	}
+	if fit <= 2 {
+		return ""
+	}
+	return string(runes[:fit-2]) + ".."
+}
@@ -0,0 +1,118 @@
+// Package visualize renders analytics results as SVG or PNG images. Charts
+// draw on a canvas, which either writes SVG elements or rasterizes the shapes
+// itself, so images can be produced without a browser or a charting library.
+package visualize
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+const (
+	defaultWidth  = 800
+	defaultHeight = 500
+	minSize       = 200
+	maxSize       = 4000
+	maxTitle      = 200
+)
+
+// anchor is the horizontal alignment of text relative to its position
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// point is a position on a canvas, in pixels from the top left corner
+type point struct {
+	x, y float64
+}
+
+// canvas is a drawing surface. Text is positioned by its baseline.
+type canvas interface {
+	rect(x, y, w, h float64, fill color.RGBA)
+	line(x1, y1, x2, y2 float64, stroke color.RGBA, width float64)
+	polyline(points []point, stroke color.RGBA, width float64)
+	circle(x, y, r float64, fill color.RGBA)
+	text(x, y float64, s string, size float64, align anchor, fill color.RGBA)
+	encode() ([]byte, error)
+}
+
+var (
+	white      = color.RGBA{255, 255, 255, 255}
+	black      = color.RGBA{33, 33, 33, 255}
+	gray       = color.RGBA{117, 117, 117, 255}
+	lightGray  = color.RGBA{224, 224, 224, 255}
+	emptyColor = color.RGBA{238, 238, 238, 255}
+)
+
+// palette colors the series of a chart in turn
+var palette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{127, 127, 127, 255},
+	{188, 189, 34, 255},
+	{23, 190, 207, 255},
+}
+
+// CheckOptions checks image options, filling in the default format and size
+func CheckOptions(opts *models.ImageOptions) error {
+	switch opts.Format {
+	case "":
+		opts.Format = models.ImageSVG
+	case models.ImageSVG, models.ImagePNG:
+	default:
+		return models.NewValidationError("unsupported image format %q", opts.Format)
+	}
+	if opts.Width == 0 {
+		opts.Width = defaultWidth
+	}
+	if opts.Height == 0 {
+		opts.Height = defaultHeight
+	}
+	if opts.Width < minSize || opts.Width > maxSize || opts.Height < minSize || opts.Height > maxSize {
+		return models.NewValidationError("width and height must be between %d and %d", minSize, maxSize)
+	}
+	if len(opts.Title) > maxTitle {
+		return models.NewValidationError("title must be at most %d characters", maxTitle)
+	}
+	return nil
+}
+
+// render draws on a white canvas of the requested format and encodes it
+func render(opts *models.ImageOptions, draw func(c canvas, w, h float64)) (*models.Image, error) {
+	if err := CheckOptions(opts); err != nil {
+		return nil, err
+	}
+
+	var c canvas
+	contentType := "image/svg+xml"
+	if opts.Format == models.ImagePNG {
+		c = newPNGCanvas(opts.Width, opts.Height)
+		contentType = "image/png"
+	} else {
+		c = newSVGCanvas(opts.Width, opts.Height)
+	}
+
+	w, h := float64(opts.Width), float64(opts.Height)
+	c.rect(0, 0, w, h, white)
+	if opts.Title != "" {
+		c.text(w/2, 24, opts.Title, titleSize, anchorMiddle, black)
+	}
+	draw(c, w, h)
+
+	data, err := c.encode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return &models.Image{ContentType: contentType, Data: data}, nil
+}
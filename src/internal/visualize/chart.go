@@ -0,0 +1,263 @@
+package visualize
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/galafis/go-data-api-microservices/internal/analytics"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+// maxMarkers is the number of points a series can have before its points are
+// no longer marked
+const maxMarkers = 60
+
+// CheckStatisticsType checks that statistics of a type can be charted
+func CheckStatisticsType(kind models.StatisticsType) error {
+	if kind != models.StatsHistogram && kind != models.StatsBoxPlot {
+		return models.NewValidationError("only %s and %s statistics can be charted", models.StatsHistogram, models.StatsBoxPlot)
+	}
+	return nil
+}
+
+// TimeSeriesChart renders a time series as a line chart with a line per group.
+// Lines break at buckets without a value.
+func TimeSeriesChart(result *models.TimeSeriesResult, opts *models.ImageOptions) (*models.Image, error) {
+	return render(opts, func(c canvas, w, h float64) {
+		a := plotArea(w, h, 64, 24)
+		label := string(result.Aggregation)
+		if result.ValueField != "" {
+			label = fmt.Sprintf("%s(%s)", result.Aggregation, result.ValueField)
+		}
+		c.text(a.x, a.y-10, label, labelSize, anchorStart, gray)
+
+		// Collect the buckets and the values of each group
+		var stamps []string
+		seen := make(map[string]bool)
+		var groups []string
+		series := make(map[string]map[string]*float64)
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, p := range result.Points {
+			if !seen[p.Timestamp] {
+				seen[p.Timestamp] = true
+				stamps = append(stamps, p.Timestamp)
+			}
+			if series[p.Group] == nil {
+				series[p.Group] = make(map[string]*float64)
+				groups = append(groups, p.Group)
+			}
+			series[p.Group][p.Timestamp] = p.Value
+			if p.Value != nil {
+				lo, hi = math.Min(lo, *p.Value), math.Max(hi, *p.Value)
+			}
+		}
+		if math.IsInf(lo, 1) {
+			frame(c, a)
+			noData(c, a)
+			return
+		}
+		// Order the buckets by time
+		positions, layout := timePositions(stamps)
+		sort.SliceStable(stamps, func(i, j int) bool { return positions[stamps[i]] < positions[stamps[j]] })
+
+		// Keep the labels of the first and last buckets inside the image
+		labelWidth := textWidth(formatStamp(stamps[0], layout), tickSize)
+		y := valueAxis(c, a, lo, hi)
+		first, last := positions[stamps[0]], positions[stamps[len(stamps)-1]]
+		x := linear{lo: first, hi: last, from: a.x + 8, to: a.x + a.w - math.Max(8, labelWidth/2-16)}
+		frame(c, a)
+
+		// Label evenly spaced buckets
+		labels := int(a.w / (labelWidth + 24))
+		if labels < 1 {
+			labels = 1
+		}
+		every := (len(stamps) + labels - 1) / labels
+		for i := 0; i < len(stamps); i += every {
+			px := x.at(positions[stamps[i]])
+			c.line(px, a.y+a.h, px, a.y+a.h+4, gray, 1)
+			c.text(px, a.y+a.h+18, formatStamp(stamps[i], layout), tickSize, anchorMiddle, gray)
+		}
+
+		for i, group := range groups {
+			col := palette[i%len(palette)]
+			var line []point
+			count := len(series[group])
+			for _, stamp := range stamps {
+				value, ok := series[group][stamp]
+				if !ok || value == nil {
+					if len(line) > 0 {
+						c.polyline(line, col, 2)
+					}
+					line = nil
+					continue
+				}
+				p := point{x.at(positions[stamp]), y.at(*value)}
+				line = append(line, p)
+				if count <= maxMarkers {
+					c.circle(p.x, p.y, 3, col)
+				}
+			}
+			if len(line) > 0 {
+				c.polyline(line, col, 2)
+			}
+		}
+		if len(groups) > 1 || groups[0] != "" {
+			legend(c, a, groups)
+		}
+	})
+}
+
+// timePositions places timestamps on the x axis by time, or by their order
+// when one of them is not RFC 3339, and picks the layout of their labels
+func timePositions(stamps []string) (map[string]float64, string) {
+	positions := make(map[string]float64, len(stamps))
+	midnight := true
+	for _, stamp := range stamps {
+		t, err := time.Parse(time.RFC3339, stamp)
+		if err != nil {
+			for i, stamp := range stamps {
+				positions[stamp] = float64(i)
+			}
+			return positions, ""
+		}
+		positions[stamp] = float64(t.Unix())
+		if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+			midnight = false
+		}
+	}
+	if midnight {
+		return positions, "2006-01-02"
+	}
+	return positions, "2006-01-02 15:04"
+}
+
+// formatStamp formats a bucket timestamp with a label layout, or as is
+// without one
+func formatStamp(stamp, layout string) string {
+	if layout == "" {
+		return stamp
+	}
+	t, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return stamp
+	}
+	return t.Format(layout)
+}
+
+// StatisticsChart renders histogram statistics as a histogram per field,
+// stacked, and box plot statistics as box plots side by side on one axis
+func StatisticsChart(result *models.StatisticsResult, opts *models.ImageOptions) (*models.Image, error) {
+	if err := CheckStatisticsType(result.Type); err != nil {
+		return nil, err
+	}
+	if result.Type == models.StatsBoxPlot {
+		return render(opts, func(c canvas, w, h float64) {
+			boxPlots(c, plotArea(w, h, 64, 24), result)
+		})
+	}
+	return render(opts, func(c canvas, w, h float64) {
+		a := plotArea(w, h, 64, 24)
+		n := float64(len(result.Fields))
+		gap := 40.0
+		panel := (a.h - gap*(n-1)) / n
+		for i, field := range result.Fields {
+			p := area{x: a.x, y: a.y + float64(i)*(panel+gap), w: a.w, h: panel}
+			bins, _ := result.Results[field].([]analytics.Bin)
+			histogram(c, p, field, bins)
+		}
+	})
+}
+
+// histogram draws the bins of a field
+func histogram(c canvas, a area, field string, bins []analytics.Bin) {
+	c.text(a.x, a.y-10, field, labelSize, anchorStart, gray)
+	if len(bins) == 0 {
+		frame(c, a)
+		noData(c, a)
+		return
+	}
+
+	var most int64
+	for _, bin := range bins {
+		if bin.Count > most {
+			most = bin.Count
+		}
+	}
+	y := valueAxis(c, a, 0, float64(most))
+	lo, hi := bins[0].Lower, bins[len(bins)-1].Upper
+	x := linear{lo: lo, hi: hi, from: a.x, to: a.x + a.w}
+	col := palette[0]
+	if lo == hi {
+		// All numbers are equal, so the one bin spans the plot
+		c.rect(a.x+a.w/4, y.at(float64(bins[0].Count)), a.w/2, y.at(0)-y.at(float64(bins[0].Count)), col)
+	} else {
+		for _, bin := range bins {
+			left, right := x.at(bin.Lower), x.at(bin.Upper)
+			top := y.at(float64(bin.Count))
+			c.rect(left, top, right-left, y.at(0)-top, col)
+			c.line(right, top, right, y.at(0), white, 1)
+		}
+	}
+	frame(c, a)
+
+	if lo == hi {
+		c.text(a.x+a.w/2, a.y+a.h+18, strconv.FormatFloat(lo, 'g', 6, 64), tickSize, anchorMiddle, gray)
+		return
+	}
+	values, step := ticks(lo, hi, int(math.Max(2, a.w/80)))
+	for _, v := range values {
+		if v < lo || v > hi {
+			continue
+		}
+		px := x.at(v)
+		c.line(px, a.y+a.h, px, a.y+a.h+4, gray, 1)
+		c.text(px, a.y+a.h+18, formatTick(v, step), tickSize, anchorMiddle, gray)
+	}
+}
+
+// boxPlots draws the box plots of the fields side by side
+func boxPlots(c canvas, a area, result *models.StatisticsResult) {
+	boxes := make([]*analytics.BoxPlot, len(result.Fields))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, field := range result.Fields {
+		box, ok := result.Results[field].(analytics.BoxPlot)
+		if !ok {
+			continue
+		}
+		boxes[i] = &box
+		lo, hi = math.Min(lo, box.Min), math.Max(hi, box.Max)
+	}
+	if math.IsInf(lo, 1) {
+		frame(c, a)
+		noData(c, a)
+		return
+	}
+
+	y := valueAxis(c, a, lo, hi)
+	frame(c, a)
+	slot := a.w / float64(len(boxes))
+	width := math.Min(slot/2, 80)
+	for i, box := range boxes {
+		center := a.x + slot*(float64(i)+0.5)
+		c.text(center, a.y+a.h+18, truncate(result.Fields[i], tickSize, slot-8), tickSize, anchorMiddle, gray)
+		if box == nil {
+			continue
+		}
+		col := palette[i%len(palette)]
+		left := center - width/2
+		c.line(center, y.at(box.LowerWhisker), center, y.at(box.Q1), black, 1)
+		c.line(center, y.at(box.Q3), center, y.at(box.UpperWhisker), black, 1)
+		c.line(center-width/4, y.at(box.LowerWhisker), center+width/4, y.at(box.LowerWhisker), black, 1)
+		c.line(center-width/4, y.at(box.UpperWhisker), center+width/4, y.at(box.UpperWhisker), black, 1)
+		c.rect(left, y.at(box.Q3), width, y.at(box.Q1)-y.at(box.Q3), col)
+		outline(c, left, y.at(box.Q3), width, y.at(box.Q1)-y.at(box.Q3), black)
+		c.line(left, y.at(box.Median), left+width, y.at(box.Median), black, 2)
+		for _, outlier := range box.Outliers {
+			c.circle(center, y.at(outlier), 3, black)
+		}
+	}
+}
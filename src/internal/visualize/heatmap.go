@@ -0,0 +1,105 @@
+package visualize
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/galafis/go-data-api-microservices/internal/models"
+)
+
+const (
+	// minValueCell is the smallest cell, in pixels, whose value is written in it
+	minValueCell  = 32
+	colorBarWidth = 16
+)
+
+var (
+	negativeColor = color.RGBA{59, 76, 192, 255}
+	positiveColor = color.RGBA{180, 4, 38, 255}
+)
+
+// Heatmap renders a correlation matrix as a grid of cells colored from blue at
+// -1 through white to red at 1, with a color bar for the scale. Undefined
+// correlations are left gray.
+func Heatmap(result *models.CorrelationResult, opts *models.ImageOptions) (*models.Image, error) {
+	return render(opts, func(c canvas, w, h float64) {
+		n := len(result.Fields)
+		labelWidth := 0.0
+		for _, field := range result.Fields {
+			labelWidth = math.Max(labelWidth, textWidth(field, tickSize))
+		}
+		labelWidth = math.Min(labelWidth, w/4) + 12
+
+		a := plotArea(w, h, labelWidth, colorBarWidth+56)
+		if n == 0 {
+			noData(c, a)
+			return
+		}
+		cell := math.Min(a.w, a.h) / float64(n)
+		for i, row := range result.Correlation {
+			y := a.y + float64(i)*cell
+			c.text(a.x-8, y+cell/2+4, truncate(result.Fields[i], tickSize, labelWidth-12), tickSize, anchorEnd, black)
+			for j, value := range row {
+				x := a.x + float64(j)*cell
+				if value == nil {
+					c.rect(x, y, cell, cell, emptyColor)
+					continue
+				}
+				c.rect(x, y, cell, cell, diverging(*value))
+				if cell >= minValueCell {
+					ink := black
+					if math.Abs(*value) > 0.6 {
+						ink = white
+					}
+					c.text(x+cell/2, y+cell/2+4, strconv.FormatFloat(*value, 'f', 2, 64), tickSize, anchorMiddle, ink)
+				}
+			}
+		}
+		for i := 0; i <= n; i++ {
+			offset := float64(i) * cell
+			c.line(a.x+offset, a.y, a.x+offset, a.y+float64(n)*cell, white, 1)
+			c.line(a.x, a.y+offset, a.x+float64(n)*cell, a.y+offset, white, 1)
+		}
+		for j, field := range result.Fields {
+			c.text(a.x+(float64(j)+0.5)*cell, a.y+float64(n)*cell+18, truncate(field, tickSize, cell-4), tickSize, anchorMiddle, black)
+		}
+
+		colorBar(c, a.x+float64(n)*cell+24, a.y, float64(n)*cell)
+	})
+}
+
+// colorBar draws the scale of the heatmap colors from 1 at the top to -1 at
+// the bottom
+func colorBar(c canvas, x, y, h float64) {
+	const steps = 40
+	step := h / steps
+	for i := 0; i < steps; i++ {
+		value := 1 - 2*(float64(i)+0.5)/steps
+		c.rect(x, y+float64(i)*step, colorBarWidth, step+0.5, diverging(value))
+	}
+	outline(c, x, y, colorBarWidth, h, gray)
+	for _, tick := range []float64{1, 0.5, 0, -0.5, -1} {
+		ty := y + (1-tick)/2*h
+		c.line(x+colorBarWidth, ty, x+colorBarWidth+4, ty, gray, 1)
+		c.text(x+colorBarWidth+6, ty+4, formatTick(tick, 0.5), tickSize, anchorStart, gray)
+	}
+}
+
+// diverging colors a correlation, blending white into blue below zero and into
+// red above it
+func diverging(value float64) color.RGBA {
+	value = math.Max(-1, math.Min(1, value))
+	if value < 0 {
+		return blend(white, negativeColor, -value)
+	}
+	return blend(white, positiveColor, value)
+}
+
+// blend mixes two colors, t of the way from a to b
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + t*(float64(y)-float64(x))))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
@@ -0,0 +1,156 @@
+package visualize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"unicode"
+)
+
+// pngCanvas rasterizes shapes onto an RGBA image. Shapes are not
+// anti-aliased and text is drawn with the bitmap font, scaled by whole pixels.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	return &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+// fill paints the pixels of the half-open rectangle [x0, x1) x [y0, y1)
+func (c *pngCanvas) fill(x0, y0, x1, y1 int, col color.RGBA) {
+	bounds := c.img.Bounds()
+	if x0 < bounds.Min.X {
+		x0 = bounds.Min.X
+	}
+	if y0 < bounds.Min.Y {
+		y0 = bounds.Min.Y
+	}
+	if x1 > bounds.Max.X {
+		x1 = bounds.Max.X
+	}
+	if y1 > bounds.Max.Y {
+		y1 = bounds.Max.Y
+	}
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			c.img.SetRGBA(x, y, col)
+		}
+	}
+}
+
+func (c *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	c.fill(round(x), round(y), round(x+w), round(y+h), fill)
+}
+
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, stroke color.RGBA, width float64) {
+	size := round(width)
+	if size < 1 {
+		size = 1
+	}
+	offset := float64(size) / 2
+	x, y := round(x1-offset), round(y1-offset)
+	endX, endY := round(x2-offset), round(y2-offset)
+
+	// Bresenham's algorithm, stamping a square of the line width at each step
+	dx, dy := abs(endX-x), -abs(endY-y)
+	stepX, stepY := 1, 1
+	if x > endX {
+		stepX = -1
+	}
+	if y > endY {
+		stepY = -1
+	}
+	err := dx + dy
+	for {
+		c.fill(x, y, x+size, y+size, stroke)
+		if x == endX && y == endY {
+			return
+		}
+		if 2*err >= dy {
+			err += dy
+			x += stepX
+		}
+		if 2*err <= dx {
+			err += dx
+			y += stepY
+		}
+	}
+}
+
+func (c *pngCanvas) polyline(points []point, stroke color.RGBA, width float64) {
+	if len(points) == 1 {
+		c.circle(points[0].x, points[0].y, width/2, stroke)
+	}
+	for i := 1; i < len(points); i++ {
+		c.line(points[i-1].x, points[i-1].y, points[i].x, points[i].y, stroke, width)
+	}
+}
+
+func (c *pngCanvas) circle(x, y, r float64, fill color.RGBA) {
+	for py := int(math.Floor(y - r)); py <= int(math.Ceil(y+r)); py++ {
+		for px := int(math.Floor(x - r)); px <= int(math.Ceil(x+r)); px++ {
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if dx*dx+dy*dy <= r*r {
+				c.fill(px, py, px+1, py+1, fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s string, size float64, align anchor, fill color.RGBA) {
+	scale := glyphScale(size)
+	runes := []rune(s)
+	width := float64(len(runes) * glyphAdvance * scale)
+	switch align {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+
+	left, top := round(x), round(y)-glyphHeight*scale
+	for i, r := range runes {
+		rows := glyph(unicode.ToUpper(r))
+		for row, bits := range rows {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px := left + (i*glyphAdvance+col)*scale
+				py := top + row*scale
+				c.fill(px, py, px+scale, py+scale, fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// glyphScale is the whole-pixel scale of the bitmap font closest to a font size
+func glyphScale(size float64) int {
+	scale := round(size / 9)
+	if scale < 1 {
+		return 1
+	}
+	return scale
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func round(x float64) int {
+	return int(math.Floor(x + 0.5))
+}
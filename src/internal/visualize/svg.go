@@ -0,0 +1,82 @@
+package visualize
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// svgCanvas writes shapes as SVG elements
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif">`,
+		width, height, width, height)
+	c.buf.WriteByte('\n')
+	return c
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+		num(x), num(y), num(w), num(h), hex(fill))
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, stroke color.RGBA, width float64) {
+	fmt.Fprintf(&c.buf, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"/>`+"\n",
+		num(x1), num(y1), num(x2), num(y2), hex(stroke), num(width))
+}
+
+func (c *svgCanvas) polyline(points []point, stroke color.RGBA, width float64) {
+	c.buf.WriteString(`<polyline points="`)
+	for i, p := range points {
+		if i > 0 {
+			c.buf.WriteByte(' ')
+		}
+		c.buf.WriteString(num(p.x) + "," + num(p.y))
+	}
+	fmt.Fprintf(&c.buf, `" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round"/>`+"\n", hex(stroke), num(width))
+}
+
+func (c *svgCanvas) circle(x, y, r float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`+"\n", num(x), num(y), num(r), hex(fill))
+}
+
+func (c *svgCanvas) text(x, y float64, s string, size float64, align anchor, fill color.RGBA) {
+	textAnchor := "start"
+	switch align {
+	case anchorMiddle:
+		textAnchor = "middle"
+	case anchorEnd:
+		textAnchor = "end"
+	}
+	fmt.Fprintf(&c.buf, `<text x="%s" y="%s" font-size="%s" text-anchor="%s" fill="%s">`,
+		num(x), num(y), num(size), textAnchor, hex(fill))
+	xml.EscapeText(&c.buf, []byte(s))
+	c.buf.WriteString("</text>\n")
+}
+
+func (c *svgCanvas) encode() ([]byte, error) {
+	c.buf.WriteString("</svg>\n")
+	return c.buf.Bytes(), nil
+}
+
+// num formats a coordinate with at most two decimals
+func num(x float64) string {
+	s := strconv.FormatFloat(x, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// hex formats a color as #rrggbb
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,221 @@
+package visualize
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/galafis/go-data-api-microservices/internal/analytics"
+	"github.com/galafis/go-data-api-microservices/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func float(x float64) *float64 {
+	return &x
+}
+
+func salesSeries() *models.TimeSeriesResult {
+	return &models.TimeSeriesResult{
+		TimeField:   "day",
+		ValueField:  "amount",
+		Aggregation: models.TimeSeriesSum,
+		Interval:    models.TimeSeriesDay,
+		Points: []models.TimeSeriesPoint{
+			{Timestamp: "2024-01-01T00:00:00Z", Value: float(10), Group: "north"},
+			{Timestamp: "2024-01-02T00:00:00Z", Value: float(12), Group: "north"},
+			{Timestamp: "2024-01-03T00:00:00Z", Value: nil, Group: "north"},
+			{Timestamp: "2024-01-04T00:00:00Z", Value: float(9), Group: "north"},
+			{Timestamp: "2024-01-01T00:00:00Z", Value: float(4), Group: "south"},
+			{Timestamp: "2024-01-02T00:00:00Z", Value: float(6), Group: "south"},
+			{Timestamp: "2024-01-03T00:00:00Z", Value: float(5), Group: "south"},
+			{Timestamp: "2024-01-04T00:00:00Z", Value: float(7), Group: "south"},
+		},
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	// Test Case 1: Defaults are filled in
+	t.Run("Defaults", func(t *testing.T) {
+		opts := models.ImageOptions{}
+		require.NoError(t, CheckOptions(&opts))
+
+		assert.Equal(t, models.ImageSVG, opts.Format)
+		assert.Equal(t, defaultWidth, opts.Width)
+		assert.Equal(t, defaultHeight, opts.Height)
+	})
+
+	// Test Case 2: Invalid options are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := map[string]models.ImageOptions{
+			"format":     {Format: "gif"},
+			"too narrow": {Width: 50},
+			"too tall":   {Height: maxSize + 1},
+			"title":      {Title: strings.Repeat("x", maxTitle+1)},
+		}
+		for name, opts := range invalid {
+			err := CheckOptions(&opts)
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+}
+
+func TestTicks(t *testing.T) {
+	// Test Case 1: Ticks are round and cover the range
+	t.Run("Round", func(t *testing.T) {
+		values, step := ticks(3, 97, 5)
+
+		assert.Equal(t, 20.0, step)
+		assert.Equal(t, []float64{0, 20, 40, 60, 80, 100}, values)
+		assert.Equal(t, "40", formatTick(values[2], step))
+	})
+
+	// Test Case 2: Small steps keep their decimals
+	t.Run("Decimals", func(t *testing.T) {
+		values, step := ticks(0.12, 0.38, 6)
+
+		assert.InDelta(t, 0.05, step, 1e-12)
+		assert.Equal(t, "0.10", formatTick(values[0], step))
+		assert.Equal(t, "0", formatTick(-1e-17, step))
+	})
+
+	// Test Case 3: A single value gets a range around it
+	t.Run("Constant", func(t *testing.T) {
+		values, _ := ticks(5, 5, 4)
+
+		assert.True(t, values[0] < 5)
+		assert.True(t, values[len(values)-1] > 5)
+	})
+}
+
+func TestTimeSeriesChart(t *testing.T) {
+	// Test Case 1: Each group is drawn as a line, broken at missing values
+	t.Run("SVG", func(t *testing.T) {
+		image, err := TimeSeriesChart(salesSeries(), &models.ImageOptions{Title: "Sales & returns"})
+		require.NoError(t, err)
+
+		svg := string(image.Data)
+		assert.Equal(t, "image/svg+xml", image.ContentType)
+		assert.True(t, strings.HasPrefix(svg, "<svg "))
+		assert.True(t, strings.HasSuffix(svg, "</svg>\n"))
+		assert.Equal(t, 3, strings.Count(svg, "<polyline"))
+		assert.Contains(t, svg, "Sales &amp; returns")
+		assert.Contains(t, svg, "sum(amount)")
+		assert.Contains(t, svg, ">2024-01-02</text>")
+		assert.Contains(t, svg, ">south</text>")
+	})
+
+	// Test Case 2: A PNG decodes with the requested size
+	t.Run("PNG", func(t *testing.T) {
+		image, err := TimeSeriesChart(salesSeries(), &models.ImageOptions{Format: models.ImagePNG, Width: 640, Height: 320})
+		require.NoError(t, err)
+
+		assert.Equal(t, "image/png", image.ContentType)
+		decoded, err := png.Decode(bytes.NewReader(image.Data))
+		require.NoError(t, err)
+		assert.Equal(t, 640, decoded.Bounds().Dx())
+		assert.Equal(t, 320, decoded.Bounds().Dy())
+
+		// The first series is drawn in the first palette color
+		found := false
+		for y := 0; y < 320 && !found; y++ {
+			for x := 0; x < 640 && !found; x++ {
+				r, g, b, _ := decoded.At(x, y).RGBA()
+				found = r>>8 == uint32(palette[0].R) && g>>8 == uint32(palette[0].G) && b>>8 == uint32(palette[0].B)
+			}
+		}
+		assert.True(t, found)
+	})
+
+	// Test Case 3: A series without values still renders
+	t.Run("Empty", func(t *testing.T) {
+		image, err := TimeSeriesChart(&models.TimeSeriesResult{Aggregation: models.TimeSeriesCount}, &models.ImageOptions{})
+		require.NoError(t, err)
+
+		assert.Contains(t, string(image.Data), "No data")
+	})
+}
+
+func TestStatisticsChart(t *testing.T) {
+	// Test Case 1: A histogram draws a bar per bin
+	t.Run("Histogram", func(t *testing.T) {
+		result := &models.StatisticsResult{
+			Type:   models.StatsHistogram,
+			Fields: []string{"price"},
+			Results: map[string]any{
+				"price": analytics.Histogram([]float64{1, 2, 2, 3, 3, 3, 4}, 3),
+			},
+		}
+		image, err := StatisticsChart(result, &models.ImageOptions{})
+		require.NoError(t, err)
+
+		// The background and three bars
+		assert.Equal(t, 4, strings.Count(string(image.Data), "<rect"))
+	})
+
+	// Test Case 2: Box plots are drawn per field, skipping fields without values
+	t.Run("BoxPlot", func(t *testing.T) {
+		sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 40}
+		result := &models.StatisticsResult{
+			Type:    models.StatsBoxPlot,
+			Fields:  []string{"price", "empty"},
+			Results: map[string]any{"price": analytics.NewBoxPlot(sorted), "empty": nil},
+		}
+		image, err := StatisticsChart(result, &models.ImageOptions{Format: models.ImagePNG})
+		require.NoError(t, err)
+
+		_, err = png.Decode(bytes.NewReader(image.Data))
+		assert.NoError(t, err)
+	})
+
+	// Test Case 3: Other statistics cannot be charted
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := StatisticsChart(&models.StatisticsResult{Type: models.StatsMean}, &models.ImageOptions{})
+
+		var validationErr *models.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestHeatmap(t *testing.T) {
+	result := &models.CorrelationResult{
+		Method: models.CorrelationPearson,
+		Fields: []string{"price", "quantity", "constant"},
+		Correlation: [][]*float64{
+			{float(1), float(-0.8), nil},
+			{float(-0.8), float(1), nil},
+			{nil, nil, nil},
+		},
+	}
+
+	// Test Case 1: Cells are colored by correlation and labeled with it
+	t.Run("SVG", func(t *testing.T) {
+		image, err := Heatmap(result, &models.ImageOptions{})
+		require.NoError(t, err)
+
+		svg := string(image.Data)
+		assert.Contains(t, svg, hex(positiveColor))
+		assert.Contains(t, svg, ">-0.80</text>")
+		assert.Contains(t, svg, hex(emptyColor))
+		assert.Contains(t, svg, ">quantity</text>")
+	})
+
+	// Test Case 2: The scale runs from blue through white to red
+	t.Run("Colors", func(t *testing.T) {
+		assert.Equal(t, negativeColor, diverging(-1))
+		assert.Equal(t, white, diverging(0))
+		assert.Equal(t, positiveColor, diverging(2))
+	})
+
+	// Test Case 3: A PNG decodes with the requested size
+	t.Run("PNG", func(t *testing.T) {
+		image, err := Heatmap(result, &models.ImageOptions{Format: models.ImagePNG, Width: 400, Height: 400})
+		require.NoError(t, err)
+
+		decoded, err := png.Decode(bytes.NewReader(image.Data))
+		require.NoError(t, err)
+		assert.Equal(t, 400, decoded.Bounds().Dx())
+	})
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS datasets (
+    id          UUID PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    schema      JSONB NOT NULL DEFAULT '{"fields": []}'::jsonb,
+    source      VARCHAR(255) NOT NULL DEFAULT '',
+    format      VARCHAR(50) NOT NULL DEFAULT '',
+    size        BIGINT NOT NULL DEFAULT 0,
+    row_count   BIGINT NOT NULL DEFAULT 0,
+    tags        JSONB NOT NULL DEFAULT '[]'::jsonb,
+    metadata    JSONB,
+    created_by  UUID NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The name filter is a substring match (name ILIKE '%x%'), which only a
+-- trigram index can serve
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_datasets_name_trgm ON datasets USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_datasets_tags ON datasets USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_datasets_created_by ON datasets (created_by);
+CREATE INDEX IF NOT EXISTS idx_datasets_created_at ON datasets (created_at DESC);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users (
+    id            UUID PRIMARY KEY,
+    email         VARCHAR(255) NOT NULL,
+    password      VARCHAR(255) NOT NULL,
+    first_name    VARCHAR(100) NOT NULL DEFAULT '',
+    last_name     VARCHAR(100) NOT NULL DEFAULT '',
+    role          VARCHAR(20) NOT NULL DEFAULT 'user',
+    active        BOOLEAN NOT NULL DEFAULT TRUE,
+    verified      BOOLEAN NOT NULL DEFAULT FALSE,
+    refresh_token TEXT,
+    metadata      JSONB,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS dataset_rows (
+    id         BIGSERIAL PRIMARY KEY,
+    dataset_id UUID NOT NULL,
+    row_key    TEXT NOT NULL,
+    data       JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_dataset_rows_key UNIQUE (dataset_id, row_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_rows_dataset ON dataset_rows (dataset_id, id);
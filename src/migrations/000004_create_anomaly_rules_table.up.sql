@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS anomaly_rules (
+    id          UUID PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    dataset_id  UUID NOT NULL REFERENCES datasets (id) ON DELETE CASCADE,
+    detection   JSONB NOT NULL,
+    created_by  UUID NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_anomaly_rules_dataset ON anomaly_rules (dataset_id);
+CREATE INDEX IF NOT EXISTS idx_anomaly_rules_created_at ON anomaly_rules (created_at DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS trained_models;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS trained_models (
+    id          UUID PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    dataset_id  UUID NOT NULL REFERENCES datasets (id) ON DELETE CASCADE,
+    spec        JSONB NOT NULL,
+    model       JSONB NOT NULL,
+    created_by  UUID NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trained_models_dataset ON trained_models (dataset_id);
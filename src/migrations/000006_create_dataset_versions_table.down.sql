@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS dataset_row_history;
+DROP TABLE IF EXISTS dataset_versions;
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS dataset_versions (
+    dataset_id       UUID NOT NULL,
+    version          BIGINT NOT NULL,
+    change           VARCHAR(50) NOT NULL,
+    restored_version BIGINT NOT NULL DEFAULT 0,
+    name             VARCHAR(255) NOT NULL DEFAULT '',
+    description      TEXT NOT NULL DEFAULT '',
+    schema           JSONB NOT NULL DEFAULT '{"fields": []}'::jsonb,
+    source           VARCHAR(255) NOT NULL DEFAULT '',
+    format           VARCHAR(50) NOT NULL DEFAULT '',
+    tags             JSONB NOT NULL DEFAULT '[]'::jsonb,
+    metadata         JSONB,
+    row_count        BIGINT NOT NULL DEFAULT 0,
+    size             BIGINT NOT NULL DEFAULT 0,
+    rows_changed     BIGINT NOT NULL DEFAULT 0,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (dataset_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_versions_created_at ON dataset_versions (dataset_id, created_at);
+
+CREATE TABLE IF NOT EXISTS dataset_row_history (
+    seq        BIGSERIAL PRIMARY KEY,
+    dataset_id UUID NOT NULL,
+    version    BIGINT NOT NULL,
+    row_id     BIGINT NOT NULL,
+    row_key    TEXT NOT NULL,
+    data       JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_row_history_key ON dataset_row_history (dataset_id, row_key, version DESC, seq DESC);
+
+-- Existing datasets start from a baseline version holding their current rows
+INSERT INTO dataset_versions (dataset_id, version, change, name, description, schema, source, format, tags, metadata, row_count, size, rows_changed, created_at)
+SELECT COALESCE(d.id, r.dataset_id), 1, 'baseline',
+       COALESCE(d.name, ''), COALESCE(d.description, ''), COALESCE(d.schema, '{"fields": []}'::jsonb),
+       COALESCE(d.source, ''), COALESCE(d.format, ''), COALESCE(d.tags, '[]'::jsonb), d.metadata,
+       COALESCE(r.row_count, 0), COALESCE(r.size, 0), COALESCE(r.row_count, 0), COALESCE(d.updated_at, NOW())
+FROM datasets d
+FULL JOIN (
+    SELECT dataset_id, COUNT(*) AS row_count, SUM(octet_length(data::text)) AS size
+    FROM dataset_rows
+    GROUP BY dataset_id
+) r ON r.dataset_id = d.id
+ON CONFLICT DO NOTHING;
+
+-- Row counts and sizes are kept from there as each change adds to them, with
+-- rows measured by the length of their JSON text
+UPDATE datasets d SET row_count = v.row_count, size = v.size
+FROM dataset_versions v
+WHERE v.dataset_id = d.id AND v.version = 1;
+
+INSERT INTO dataset_row_history (dataset_id, version, row_id, row_key, data)
+SELECT dataset_id, 1, id, row_key, data
+FROM dataset_rows
+ORDER BY id;
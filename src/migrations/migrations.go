@@ -0,0 +1,13 @@
+// Package migrations embeds the versioned SQL migrations for the PostgreSQL schema.
+//
+// Files follow the golang-migrate naming convention ({version}_{title}.up.sql and
+// {version}_{title}.down.sql), so they can be applied either at startup through
+// database.PostgresDB.Migrate or with the migrate CLI (see the Makefile db-migrate targets).
+package migrations
+
+import "embed"
+
+// FS contains all migration files
+//
+//go:embed *.sql
+var FS embed.FS